@@ -1,11 +1,94 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/mail"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// Metrics display modes for the analysis email
+const (
+	MetricsDisplayGauges = "gauges" // Visual gauge only (default)
+	MetricsDisplayTable  = "table"  // Accessible table instead of the gauge
+	MetricsDisplayBoth   = "both"   // Gauge followed by the accessible table
+)
+
+// Severity display styles in the analysis metrics section
+const (
+	SeverityDisplayBar   = "bar"   // Legal risk gauge bar only (default)
+	SeverityDisplayStars = "stars" // Adds a 0-5 star severity rating
+	SeverityDisplayIcons = "icons" // Adds a 0-5 warning icon severity rating
+)
+
+// Strategies for distributing recipients across SendGrid subusers
+const (
+	SubuserStrategyHash       = "hash"        // Stable hash of the recipient address (default)
+	SubuserStrategyRoundRobin = "round_robin" // Rotate through subusers per message
+)
+
+// SendGrid suppression bypasses allowed for reports flagged critical
+const (
+	CriticalBypassOff         = "off"         // Critical reports respect every suppression (default)
+	CriticalBypassUnsubscribe = "unsubscribe" // bypass_unsubscribe_management: skip global and group unsubscribes only
+	CriticalBypassList        = "list"        // bypass_list_management: skip all suppressions, including bounces and spam reports
+)
+
+// Layouts for the combined report and map image
+const (
+	CompositeSideBySide = "side_by_side" // Report on the left, map on the right (default)
+	CompositeStacked    = "stacked"      // Report above the map
+)
+
+// Strategies for picking a subject A/B variant per recipient
+const (
+	SubjectVariantHash   = "hash"   // Stable hash of the recipient address (default)
+	SubjectVariantRandom = "random" // Uniformly random per send
+)
+
+// SendGridSubuser is an additional SendGrid identity used to spread sending load
+type SendGridSubuser struct {
+	Name      string `json:"name"`       // Subuser username, also used for On-Behalf-Of when APIKey is empty
+	APIKey    string `json:"api_key"`    // Subuser API key; empty sends through the parent key
+	FromName  string `json:"from_name"`  // Optional From name override
+	FromEmail string `json:"from_email"` // Optional From email override
+	IPPool    string `json:"ip_pool"`    // Optional SendGrid IP pool
+
+	APIKeyEnv  string `json:"api_key_env"`  // Name of an environment variable holding the API key, over APIKey
+	APIKeyFile string `json:"api_key_file"` // Path of a mounted secret file holding the API key, over APIKeyEnv
+}
+
+// Brand is the sender identity and links of one brand served by the service, selected
+// by an analysis's BrandID. Unset fields fall back to the global settings.
+type Brand struct {
+	FromName     string `json:"from_name"`     // From name, over SendGridFromName
+	FromEmail    string `json:"from_email"`    // From address, over SendGridFromEmail
+	DashboardURL string `json:"dashboard_url"` // Digital dashboard, optionally with a {brand} placeholder, over BrandDashboardURL
+	LogoURL      string `json:"logo_url"`      // Logo under the signature, over ThemeLogoURL
+	OptOutURL    string `json:"opt_out_url"`   // Opt-out page, over OptOutURL
+}
+
+// DefaultSendProfile is the send profile used when a send doesn't select one
+const DefaultSendProfile = "transactional"
+
+// SendProfile bundles the delivery settings for one kind of send, e.g. single-report
+// alerts versus bulk digital blasts, so callers select them together by name
+type SendProfile struct {
+	Concurrency        int     `json:"concurrency"`         // Recipients sent to in parallel; 0 uses SendConcurrency
+	RatePerSecond      float64 `json:"rate_per_second"`     // Upper bound on messages per second; 0 is unlimited
+	MaintenanceRetries int     `json:"maintenance_retries"` // 503 retries per message; 0 uses SendMaintenanceRetries, negative disables
+	IPPool             string  `json:"ip_pool"`             // SendGrid IP pool, overriding the account's
+}
+
 // Config holds all configuration for the email service
 type Config struct {
 	// Database configuration
@@ -16,21 +99,212 @@ type Config struct {
 	DBName     string
 
 	// SendGrid configuration
-	SendGridAPIKey    string
-	SendGridFromName  string
-	SendGridFromEmail string
+	SendGridAPIKey     string // Literal API key; replaced by ResolveSendGridAPIKey when a file or env source is set
+	SendGridAPIKeyEnv  string // Name of an environment variable holding the API key
+	SendGridAPIKeyFile string // Path of a mounted secret file holding the API key
+	SendGridFromName   string
+	SendGridFromEmail  string
+	OnBehalfOfName     string            // Analysis email From name with a {brand} placeholder, e.g. "CleanApp on behalf of {brand}" (default: unset)
+	SenderAddress      string            // Sent as the Sender and X-Sender headers of analysis emails (default: unset)
+	ReplyTo            string            // Reply-To of brand emails, e.g. a support inbox (default: unset, replies go to From)
+	Headers            map[string]string // Custom headers of brand emails, e.g. X-CleanApp-Env=staging (default: none)
+	DomainFroms        map[string]string // From identity per lowercase recipient domain, e.g. acme.com=Acme Alerts <alerts@acme.cleanapp.io>
+	Categories         map[string]string // SendGrid category of analysis emails per classification (default: digital=brand-alert,physical=physical-report)
+
+	// SendGrid open and click tracking of every message, e.g. off for privacy-sensitive
+	// brands; nil leaves the account's tracking settings in effect (default: unset)
+	EnableOpenTracking  *bool
+	EnableClickTracking *bool
+
+	// Base64 public key verifying SendGrid's signed event webhook (default: unset, webhook disabled)
+	SendGridWebhookVerificationKey string
+
+	// SMTP fallback used when SendGrid can't be reached or fails with a 5xx after retries
+	SmtpHost     string // Fallback SMTP server (default: unset, no fallback)
+	SmtpPort     int    // Fallback SMTP port, using STARTTLS when the server offers it (default: 587)
+	SmtpUser     string // PLAIN auth username; no auth when empty
+	SmtpPassword string
+
+	// SendGrid maintenance (503) retry configuration
+	SendMaintenanceRetries    int           // Retries after a 503 Service Unavailable (default: 3)
+	SendMaintenanceRetryDelay time.Duration // Initial delay before retrying a 503 (default: 30s)
+	SendMaintenanceMaxDelay   time.Duration // Upper bound on the 503 backoff delay (default: 5m)
+
+	// Retries of transient send failures: 429, 500, 502 and network errors
+	MaxSendRetries     int           // Retries after a transient failure (default: 3, 0 disables)
+	SendRetryBaseDelay time.Duration // Delay before the first retry, doubled per retry plus jitter (default: 1s)
+
+	// Failed response body logging (all failures are still counted and returned)
+	FailureBodyLogFirstN int // Log the body of the first N failures (default: 10)
+	FailureBodyLogEveryM int // After that, log the body of every M-th failure; 0 disables (default: 100)
+
+	// Recipients sent to in parallel by send profiles without their own concurrency (default: 8)
+	SendConcurrency int
+
+	// Sender-wide cap on SendGrid mail/send calls per second, retries included, which a
+	// 429 temporarily lowers (default: 0, unlimited)
+	SendRatePerSecond float64
+
+	// SendGrid subusers to distribute recipients across (default: none, single account)
+	SendGridSubusers        []SendGridSubuser
+	SendGridSubuserStrategy string // hash or round_robin (default: hash)
+
+	// Named send profiles selected per send (default: "transactional" and "bulk")
+	SendProfiles map[string]SendProfile
+
+	// SendGrid Single Sends (Marketing Campaigns) for large analysis batches
+	SingleSendEnabled            bool          // Send large batches as a Single Send instead of per-recipient mail/send calls
+	SingleSendMinBatch           int           // Smallest batch sent as a Single Send (default: 500)
+	SingleSendSenderID           int           // Verified marketing sender identity (required when enabled)
+	SingleSendSuppressionGroupID int           // Unsubscribe group; OptOutURL is used as a custom unsubscribe URL when unset
+	SingleSendImportTimeout      time.Duration // How long to wait for the recipient list import (default: 2m)
+
+	// Message-IDs for threading and dedup (default: generated by SendGrid)
+	CustomMessageIDs bool   // Derive each analysis email's Message-ID from the report and recipient
+	MessageIDDomain  string // Right-hand side of derived Message-IDs (default: the From address's domain)
+
+	// Suppression bypass for critical safety alerts; see email.applyCriticalBypass before enabling
+	CriticalBypass string // off, unsubscribe or list (default: off)
 
 	// Service configuration
-	OptOutURL    string
-	PollInterval string
-	HTTPPort     string
+	OptOutURL        string
+	OptOutSigningKey string // HMAC key signing opt-out links; unsigned links are accepted when empty (default: unset)
+	PollInterval     string
+	HTTPPort         string
+	MetricsEnabled   bool // Count sends in Prometheus metrics served on /metrics (default: true)
 
 	// Email throttling configuration
 	ThrottleDays int // Days to throttle emails per brand+email pair (default: 7)
 
 	// Spam prevention configuration
-	DryRun                 bool // If true, log emails but don't actually send them
-	MaxDailyEmailsPerBrand int  // Maximum emails to send per brand per day (default: 10)
+	DryRun                 bool   // If true, build and log emails but don't actually send them
+	MaxDailyEmailsPerBrand int    // Maximum emails to send per brand per day (default: 10)
+	RedirectAllTo          string // If set, every email is delivered to this address instead (staging test mode)
+	MaxBatchSize           int    // Batches with more recipients are refused before sending (default: 100000)
+
+	// Frequency cap on the emails one recipient gets across all sends
+	MaxEmailsPerRecipient int           // Emails per recipient within FrequencyWindow (default: 0, uncapped)
+	FrequencyWindow       time.Duration // Sliding window the cap applies to (default: 24h)
+
+	// Quiet hours, during which non-urgent analysis emails are scheduled for the next morning
+	QuietHoursStart    int    // Hour of day, 0-23, the quiet hours start (default: 0)
+	QuietHoursEnd      int    // Hour of day, 0-23, they end; equal to QuietHoursStart disables them (default: 0)
+	QuietHoursTimezone string // IANA time zone the hours are read in (default: UTC)
+
+	// Coalescing of bursts of queued analysis emails for one brand into a digest
+	CoalesceEnabled    bool          // Buffer the service's analysis emails and QueueEmailWithAnalysis calls per brand (default: false, send immediately)
+	CoalesceInterval   time.Duration // How long the first queued report waits for others (default: 30s)
+	CoalesceMaxReports int           // Flush a brand's buffer early at this many reports (default: 10)
+
+	// Attachment configuration
+	ImageSeverityThreshold  float64 // Below this 0-10 severity, images are linked instead of attached (default: 0, always attach)
+	ReportImageMaxDimension int     // Report photos are downscaled so neither side exceeds this many pixels (default: 1600, 0 disables)
+	MapImageMaxDimension    int     // Map images are downscaled so neither side exceeds this many pixels (default: 2048, 0 disables)
+	MaxAttachmentBytes      int     // Images whose base64 encoding exceeds this are re-encoded smaller (default: 10000000, 0 disables)
+	CompositeImages         bool    // Attach one combined report+map image instead of two (default: false)
+	CompositeLayout         string  // side_by_side or stacked (default: side_by_side)
+	ImagePlaceholderColor   string  // Hex background shown behind images a client blocks (default: #e9ecef)
+	LabelFontPath           string  // TrueType or OpenType font of labels drawn onto images (default: embedded Go Regular)
+
+	// Linking a report's media page in place of its images below the image severity threshold
+	ImageSeverityThresholdByBrand map[string]float64 // Per-brand overrides of ImageSeverityThreshold keyed by lowercase brand name, e.g. acme=5
+	ReportMediaURL                string             // Page with one report's photos and map, with an {id} or {seq} placeholder (default: unset, always attach)
+
+	// Retries for loading lazily sourced images before a batch, separate from SendGrid retries
+	ImageLoadRetries    int           // Retries after a failed image load (default: 0, fail on the first error)
+	ImageLoadRetryDelay time.Duration // Delay between image load attempts (default: 1s)
+
+	// Severity floor below which analysis emails aren't sent at all
+	MinSeverity        float64            // Default 0-10 minimum (default: 0, send everything)
+	MinSeverityByBrand map[string]float64 // Per-brand overrides keyed by lowercase brand name, e.g. acme=5
+
+	// "Reported near you" note for recipients with a registered location
+	GeofenceRadiusMeters float64 // Radius within which the note is shown (default: 0, disabled)
+
+	// Location thumbnail used when no rendered map is available
+	MapThumbnailURL     string        // Static map URL template with {lat}, {lon} and optional {zoom} placeholders (default: unset, no thumbnail)
+	MapThumbnailTimeout time.Duration // Provider request timeout (default: 5s)
+	MapThumbnailZoom    int           // Map zoom level filled into {zoom} (default: 15)
+	MapThumbnailTTL     time.Duration // How long a thumbnail is reused for the same coordinates (default: 10m, 0 disables)
+
+	// Rendering configuration
+	MetricsDisplay      string // How analysis metrics are rendered: gauges, table or both (default: gauges)
+	SeverityDisplay     string // bar, or stars/icons to add a 0-5 severity rating (default: bar)
+	ShowConfidenceBadge bool   // Show an AI confidence badge next to the analysis title
+	ShowSeveritySummary bool   // Add a one-sentence severity summary to the body top and preheader
+	InboxPreviewMaxLen  int    // Warn when subject+preheader exceed this many characters; 0 disables (default: 110)
+	SubAnalysisMaxCards int    // Issue cards rendered for multi-issue physical reports; 0 keeps the single layout (default: 5)
+	ShowRiskRange       bool   // Render the digital risk range bar when the analysis carries one (default: true)
+	DescriptionMarkdown bool   // Render descriptions as sanitized Markdown in HTML emails (default: false, shown as-is)
+	HTMLClipWarnBytes   int    // Warn when HTML exceeds this many bytes, near Gmail's ~102KB clipping; 0 disables (default: 100000)
+	HTMLClipStrip       bool   // Shrink oversized HTML so the unsubscribe footer stays visible (default: false, warn only)
+	UseSvgGauges        bool   // Draw gauges as inline SVG instead of CSS div bars (default: false)
+
+	// Report text lengths, cut at a word boundary with an ellipsis
+	MaxSubjectTitleLen    int // Characters of the title kept in the subject; 0 uses the default (default: 50)
+	MaxBodyDescriptionLen int // Characters of the description shown in the body; 0 shows it whole (default: 1000)
+
+	// Gauge bands: a value is Low below the medium threshold, Medium below the high
+	// threshold and High from there
+	GaugeMediumThreshold    float64           // Probability where Medium starts (default: 0.3)
+	GaugeHighThreshold      float64           // Probability where High starts (default: 0.7)
+	SeverityMediumThreshold float64           // Severity where Medium starts on the 0-10 scale (default: 3)
+	SeverityHighThreshold   float64           // Severity where High starts on the 0-10 scale (default: 7)
+	GaugeGradients          map[string]string // CSS background per band (low, medium, high) overriding the built-in gradients
+
+	// White-label theme for the header gradient, CTA buttons and signature
+	ThemePrimaryColor string            // Hex color for buttons and the gradient start (default: #28a745)
+	ThemeAccentColor  string            // Hex color for the gradient end (default: #20c997)
+	ThemeLogoURL      string            // Logo shown under the signature (default: the CleanApp logo)
+	ThemeBrandColors  map[string]string // Per-brand primary colors keyed by lowercase brand name, e.g. acme=#ff6600
+
+	// Brands served by the service, keyed by lowercase brand ID (default: none)
+	Brands map[string]Brand
+
+	// Dashboard CTA link
+	BrandDashboardURL    string            // Digital report dashboard with a {brand} placeholder (default: https://cleanapp.io/digital/{brand})
+	DashboardFallbackURL string            // Used when a digital report has no brand dashboard (default: unset, the CTA is left out)
+	CTALabel             string            // title/aria-label template with {cta} and {brand} placeholders
+	CTAUTMParams         map[string]string // Query parameters added to the CTA link (default: utm_source=cleanapp,utm_medium=email,utm_campaign=report_alert)
+
+	// Brands and classifications whose analysis emails leave out the metrics section
+	HideMetricsBrands          []string
+	HideMetricsClassifications []string
+
+	// Subject emoji prefixes keyed by classification, or "hazard"/"litter" for physical reports
+	SubjectEmojiEnabled bool              // Prefix subjects with SubjectEmoji icons (default: false)
+	SubjectEmoji        map[string]string // e.g. hazard=⚠️,litter=🗑️ (default: none)
+
+	// Subject A/B variants with {subject}, {brand}, {count} and {title} placeholders
+	SubjectVariants        []string // Default: none, a single BuildSubject subject
+	SubjectVariantStrategy string   // hash (stable per recipient) or random (default: hash)
+
+	// Classification rendered for analyses whose classification is empty or unrecognized:
+	// physical, digital, or general for a neutral report template (default: general)
+	UnknownClassification string
+
+	// Subject used in place of an empty analysis title
+	EmptyTitleFallbackDigital  string // Digital reports (default: "Digital experience issue")
+	EmptyTitleFallbackPhysical string // Physical reports (default: "Reported issue")
+
+	// Language of recipients without their own Locale; en, es and de are translated
+	DefaultLocale string // BCP 47 tag, e.g. "es-MX" (default: en)
+
+	// "What happens next" copy keyed by classification (physical, digital, general),
+	// e.g. "Our team will review within 24h" (default: none)
+	NextSteps map[string]string
+
+	// Reporter confirmation configuration
+	ReporterConfirmationEnabled bool // If true, consenting reporters get a confirmation copy of their report
+
+	// Recipients sent text/plain only, as addresses or "@domain" entries
+	TextOnlyRecipients []string
+
+	// Ops batch summary configuration
+	OpsSummaryTo       string // Internal address sent a delivery summary after each large batch (default: unset, disabled)
+	OpsSummaryMinBatch int    // Smallest batch that gets a summary (default: 50)
+
+	loadProblems []string // Settings Load couldn't use, reported by Validate
 }
 
 // Load loads configuration from environment variables and flags
@@ -46,13 +320,156 @@ func Load() *Config {
 
 	// SendGrid configuration
 	cfg.SendGridAPIKey = getEnv("SENDGRID_API_KEY", "")
+	cfg.SendGridAPIKeyEnv = getEnv("SENDGRID_API_KEY_ENV", "")
+	cfg.SendGridAPIKeyFile = getEnv("SENDGRID_API_KEY_FILE", "")
 	cfg.SendGridFromName = getEnv("SENDGRID_FROM_NAME", "CleanApp")
 	cfg.SendGridFromEmail = getEnv("SENDGRID_FROM_EMAIL", "info@cleanapp.io")
+	cfg.OnBehalfOfName = getEnv("SENDGRID_ON_BEHALF_OF_NAME", "")
+	cfg.SenderAddress = getEnv("SENDGRID_SENDER_ADDRESS", "")
+	if cfg.SenderAddress != "" {
+		if addr, err := mail.ParseAddress(cfg.SenderAddress); err != nil || addr.Address != cfg.SenderAddress {
+			log.Printf("Ignoring invalid SENDGRID_SENDER_ADDRESS %q: expected a bare address", cfg.SenderAddress)
+			cfg.SenderAddress = ""
+		}
+	}
+	cfg.ReplyTo = getEnv("SENDGRID_REPLY_TO", "")
+	if cfg.ReplyTo != "" {
+		if addr, err := mail.ParseAddress(cfg.ReplyTo); err != nil || addr.Address != cfg.ReplyTo {
+			log.Printf("Ignoring invalid SENDGRID_REPLY_TO %q: expected a bare address", cfg.ReplyTo)
+			cfg.ReplyTo = ""
+		}
+	}
+	cfg.Headers = getEnvMap("EMAIL_HEADERS")
+	cfg.SendGridWebhookVerificationKey = getEnv("SENDGRID_WEBHOOK_VERIFICATION_KEY", "")
+	cfg.EnableOpenTracking = getEnvOptionalBool("SENDGRID_OPEN_TRACKING")
+	cfg.EnableClickTracking = getEnvOptionalBool("SENDGRID_CLICK_TRACKING")
+	cfg.Categories = map[string]string{"digital": "brand-alert", "physical": "physical-report"}
+	if categories := getEnvMap("SENDGRID_CATEGORIES"); len(categories) > 0 {
+		cfg.Categories = make(map[string]string, len(categories))
+		for classification, category := range categories {
+			cfg.Categories[strings.ToLower(classification)] = category
+		}
+	}
+	cfg.DomainFroms = make(map[string]string)
+	for domain, from := range getEnvMap("SENDGRID_DOMAIN_FROMS") {
+		if _, err := mail.ParseAddress(from); err != nil {
+			log.Printf("Ignoring invalid SENDGRID_DOMAIN_FROMS entry %s=%s: %v", domain, from, err)
+			continue
+		}
+		cfg.DomainFroms[strings.ToLower(domain)] = from
+	}
+
+	// SMTP fallback configuration
+	cfg.SmtpHost = getEnv("SMTP_HOST", "")
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil || smtpPort <= 0 || smtpPort > 65535 {
+		smtpPort = 587
+	}
+	cfg.SmtpPort = smtpPort
+	cfg.SmtpUser = getEnv("SMTP_USER", "")
+	cfg.SmtpPassword = getEnv("SMTP_PASSWORD", "")
+
+	// SendGrid maintenance (503) retry configuration
+	maintenanceRetries, err := strconv.Atoi(getEnv("SENDGRID_MAINTENANCE_RETRIES", "3"))
+	if err != nil || maintenanceRetries < 0 {
+		maintenanceRetries = 3
+	}
+	cfg.SendMaintenanceRetries = maintenanceRetries
+	cfg.SendMaintenanceRetryDelay = getEnvDuration("SENDGRID_MAINTENANCE_RETRY_DELAY", 30*time.Second)
+	cfg.SendMaintenanceMaxDelay = getEnvDuration("SENDGRID_MAINTENANCE_MAX_DELAY", 5*time.Minute)
+
+	// Transient failure retry configuration
+	maxSendRetries, err := strconv.Atoi(getEnv("SENDGRID_MAX_SEND_RETRIES", "3"))
+	if err != nil || maxSendRetries < 0 {
+		maxSendRetries = 3
+	}
+	cfg.MaxSendRetries = maxSendRetries
+	cfg.SendRetryBaseDelay = getEnvDuration("SENDGRID_SEND_RETRY_BASE_DELAY", time.Second)
+
+	// Failed response body logging
+	firstN, err := strconv.Atoi(getEnv("SENDGRID_FAILURE_BODY_LOG_FIRST", "10"))
+	if err != nil || firstN < 0 {
+		firstN = 10
+	}
+	cfg.FailureBodyLogFirstN = firstN
+	everyM, err := strconv.Atoi(getEnv("SENDGRID_FAILURE_BODY_LOG_EVERY", "100"))
+	if err != nil || everyM < 0 {
+		everyM = 100
+	}
+	cfg.FailureBodyLogEveryM = everyM
+
+	// Parallel sends
+	sendConcurrency, err := strconv.Atoi(getEnv("SENDGRID_SEND_CONCURRENCY", "8"))
+	if err != nil || sendConcurrency < 1 {
+		sendConcurrency = 8
+	}
+	cfg.SendConcurrency = sendConcurrency
+	sendRate, err := strconv.ParseFloat(getEnv("SENDGRID_SEND_RATE_PER_SECOND", "0"), 64)
+	if err != nil || sendRate < 0 {
+		sendRate = 0
+	}
+	cfg.SendRatePerSecond = sendRate
+
+	// SendGrid subusers, e.g. [{"name":"bulk-a","api_key":"SG...","ip_pool":"bulk"}]
+	if subusers := getEnv("SENDGRID_SUBUSERS", ""); subusers != "" {
+		if err := json.Unmarshal([]byte(subusers), &cfg.SendGridSubusers); err != nil {
+			cfg.loadProblems = append(cfg.loadProblems, fmt.Sprintf("SENDGRID_SUBUSERS is not a valid JSON list of subusers: %v", err))
+			cfg.SendGridSubusers = nil
+		}
+	}
+	cfg.SendGridSubuserStrategy = getEnv("SENDGRID_SUBUSER_STRATEGY", SubuserStrategyHash)
+	if cfg.SendGridSubuserStrategy != SubuserStrategyRoundRobin {
+		cfg.SendGridSubuserStrategy = SubuserStrategyHash
+	}
+
+	// Send profiles, e.g. {"bulk":{"concurrency":8,"rate_per_second":50,"ip_pool":"bulk"}};
+	// configured profiles replace the built-in ones of the same name
+	cfg.SendProfiles = map[string]SendProfile{
+		DefaultSendProfile: {},
+		"bulk":             {Concurrency: 4},
+	}
+	if profiles := getEnv("SENDGRID_SEND_PROFILES", ""); profiles != "" {
+		var configured map[string]SendProfile
+		if err := json.Unmarshal([]byte(profiles), &configured); err != nil {
+			log.Printf("Ignoring invalid SENDGRID_SEND_PROFILES: %v", err)
+		}
+		for name, profile := range configured {
+			profile.Concurrency = max(profile.Concurrency, 0)
+			cfg.SendProfiles[name] = profile
+		}
+	}
+
+	// SendGrid Single Sends configuration
+	cfg.SingleSendEnabled = getEnv("SENDGRID_SINGLE_SEND_ENABLED", "false") == "true"
+	singleSendMin, err := strconv.Atoi(getEnv("SENDGRID_SINGLE_SEND_MIN_BATCH", "500"))
+	if err != nil || singleSendMin < 1 {
+		singleSendMin = 500
+	}
+	cfg.SingleSendMinBatch = singleSendMin
+	cfg.SingleSendSenderID, _ = strconv.Atoi(getEnv("SENDGRID_SINGLE_SEND_SENDER_ID", "0"))
+	cfg.SingleSendSuppressionGroupID, _ = strconv.Atoi(getEnv("SENDGRID_SINGLE_SEND_SUPPRESSION_GROUP_ID", "0"))
+	cfg.SingleSendImportTimeout = getEnvDuration("SENDGRID_SINGLE_SEND_IMPORT_TIMEOUT", 2*time.Minute)
+	if cfg.SingleSendEnabled && cfg.SingleSendSenderID <= 0 {
+		log.Printf("SENDGRID_SINGLE_SEND_ENABLED requires SENDGRID_SINGLE_SEND_SENDER_ID, disabling Single Sends")
+		cfg.SingleSendEnabled = false
+	}
+
+	// Message-ID configuration
+	cfg.CustomMessageIDs = getEnv("EMAIL_CUSTOM_MESSAGE_ID", "false") == "true"
+	cfg.MessageIDDomain = getEnv("EMAIL_MESSAGE_ID_DOMAIN", "")
+
+	// Critical alert suppression bypass
+	cfg.CriticalBypass = getEnv("SENDGRID_CRITICAL_BYPASS", CriticalBypassOff)
+	if cfg.CriticalBypass != CriticalBypassUnsubscribe && cfg.CriticalBypass != CriticalBypassList {
+		cfg.CriticalBypass = CriticalBypassOff
+	}
 
 	// Service configuration
 	cfg.OptOutURL = getEnv("OPT_OUT_URL", "http://localhost:8080/opt-out")
+	cfg.OptOutSigningKey = getEnv("OPT_OUT_SIGNING_KEY", "")
 	cfg.PollInterval = getEnv("POLL_INTERVAL", "10s")
 	cfg.HTTPPort = getEnv("HTTP_PORT", "8080")
+	cfg.MetricsEnabled = getEnv("EMAIL_METRICS_ENABLED", "true") == "true"
 
 	// Email throttling configuration
 	throttleDays, err := strconv.Atoi(getEnv("EMAIL_THROTTLE_DAYS", "7"))
@@ -68,10 +485,344 @@ func Load() *Config {
 		maxDaily = 10 // Default: max 10 emails per brand per day
 	}
 	cfg.MaxDailyEmailsPerBrand = maxDaily
+	cfg.RedirectAllTo = getEnv("EMAIL_REDIRECT_ALL_TO", "")
+	maxBatch, err := strconv.Atoi(getEnv("EMAIL_MAX_BATCH_SIZE", "100000"))
+	if err != nil || maxBatch <= 0 {
+		maxBatch = 100000 // Always keep a finite cap
+	}
+	cfg.MaxBatchSize = maxBatch
+	maxPerRecipient, err := strconv.Atoi(getEnv("EMAIL_MAX_PER_RECIPIENT", "0"))
+	if err != nil || maxPerRecipient < 0 {
+		maxPerRecipient = 0
+	}
+	cfg.MaxEmailsPerRecipient = maxPerRecipient
+	cfg.FrequencyWindow = getEnvDuration("EMAIL_FREQUENCY_WINDOW", 24*time.Hour)
+	cfg.QuietHoursStart = getEnvHour("EMAIL_QUIET_HOURS_START")
+	cfg.QuietHoursEnd = getEnvHour("EMAIL_QUIET_HOURS_END")
+	cfg.QuietHoursTimezone = getEnv("EMAIL_QUIET_HOURS_TIMEZONE", "UTC")
+
+	// Coalescing configuration
+	cfg.CoalesceEnabled = getEnv("EMAIL_COALESCE_ENABLED", "false") == "true"
+	cfg.CoalesceInterval = getEnvDuration("EMAIL_COALESCE_INTERVAL", 30*time.Second)
+	coalesceMax, err := strconv.Atoi(getEnv("EMAIL_COALESCE_MAX_REPORTS", "10"))
+	if err != nil || coalesceMax <= 0 {
+		coalesceMax = 10
+	}
+	cfg.CoalesceMaxReports = coalesceMax
+
+	// Attachment configuration
+	imageThreshold, err := strconv.ParseFloat(getEnv("EMAIL_IMAGE_SEVERITY_THRESHOLD", "0"), 64)
+	if err != nil || imageThreshold < 0 {
+		imageThreshold = 0
+	}
+	cfg.ImageSeverityThreshold = imageThreshold
+	cfg.ImageSeverityThresholdByBrand = make(map[string]float64)
+	for brand, value := range getEnvMap("EMAIL_IMAGE_SEVERITY_THRESHOLD_BY_BRAND") {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 {
+			log.Printf("Ignoring invalid EMAIL_IMAGE_SEVERITY_THRESHOLD_BY_BRAND entry %s=%s", brand, value)
+			continue
+		}
+		cfg.ImageSeverityThresholdByBrand[strings.ToLower(brand)] = threshold
+	}
+	cfg.ReportMediaURL = getEnv("EMAIL_REPORT_MEDIA_URL", "")
+	cfg.ReportImageMaxDimension = getEnvDimension("EMAIL_REPORT_IMAGE_MAX_DIMENSION", 1600)
+	cfg.MapImageMaxDimension = getEnvDimension("EMAIL_MAP_IMAGE_MAX_DIMENSION", 2048)
+	maxAttachmentBytes, err := strconv.Atoi(getEnv("EMAIL_MAX_ATTACHMENT_BYTES", "10000000"))
+	if err != nil || maxAttachmentBytes < 0 {
+		maxAttachmentBytes = 10000000
+	}
+	cfg.MaxAttachmentBytes = maxAttachmentBytes
+	cfg.CompositeImages = getEnv("EMAIL_COMPOSITE_IMAGES", "false") == "true"
+	cfg.CompositeLayout = getEnv("EMAIL_COMPOSITE_LAYOUT", CompositeSideBySide)
+	if cfg.CompositeLayout != CompositeStacked {
+		cfg.CompositeLayout = CompositeSideBySide
+	}
+	cfg.ImagePlaceholderColor = getEnvColor("EMAIL_IMAGE_PLACEHOLDER_COLOR", "#e9ecef")
+	cfg.LabelFontPath = getEnv("EMAIL_LABEL_FONT_PATH", "")
+	imageLoadRetries, err := strconv.Atoi(getEnv("EMAIL_IMAGE_LOAD_RETRIES", "0"))
+	if err != nil || imageLoadRetries < 0 {
+		imageLoadRetries = 0
+	}
+	cfg.ImageLoadRetries = imageLoadRetries
+	cfg.ImageLoadRetryDelay = getEnvDuration("EMAIL_IMAGE_LOAD_RETRY_DELAY", time.Second)
+
+	// Minimum severity configuration
+	minSeverity, err := strconv.ParseFloat(getEnv("EMAIL_MIN_SEVERITY", "0"), 64)
+	if err != nil || minSeverity < 0 {
+		minSeverity = 0
+	}
+	cfg.MinSeverity = minSeverity
+	cfg.MinSeverityByBrand = make(map[string]float64)
+	for brand, value := range getEnvMap("EMAIL_MIN_SEVERITY_BY_BRAND") {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 {
+			log.Printf("Ignoring invalid EMAIL_MIN_SEVERITY_BY_BRAND entry %s=%s", brand, value)
+			continue
+		}
+		cfg.MinSeverityByBrand[strings.ToLower(brand)] = threshold
+	}
+
+	// Geofence note configuration
+	geofenceRadius, err := strconv.ParseFloat(getEnv("EMAIL_GEOFENCE_RADIUS_METERS", "0"), 64)
+	if err != nil || geofenceRadius < 0 {
+		geofenceRadius = 0
+	}
+	cfg.GeofenceRadiusMeters = geofenceRadius
+
+	// Location thumbnail configuration
+	cfg.MapThumbnailURL = getEnv("MAP_THUMBNAIL_URL", "")
+	cfg.MapThumbnailTimeout = getEnvDuration("MAP_THUMBNAIL_TIMEOUT", 5*time.Second)
+	thumbnailZoom, err := strconv.Atoi(getEnv("MAP_THUMBNAIL_ZOOM", "15"))
+	if err != nil || thumbnailZoom < 1 || thumbnailZoom > 22 {
+		thumbnailZoom = 15
+	}
+	cfg.MapThumbnailZoom = thumbnailZoom
+	cfg.MapThumbnailTTL = getEnvDuration("MAP_THUMBNAIL_TTL", 10*time.Minute)
+
+	// Rendering configuration
+	cfg.MetricsDisplay = getEnv("EMAIL_METRICS_DISPLAY", MetricsDisplayGauges)
+	switch cfg.MetricsDisplay {
+	case MetricsDisplayGauges, MetricsDisplayTable, MetricsDisplayBoth:
+	default:
+		cfg.MetricsDisplay = MetricsDisplayGauges
+	}
+	cfg.SeverityDisplay = getEnv("EMAIL_SEVERITY_DISPLAY", SeverityDisplayBar)
+	switch cfg.SeverityDisplay {
+	case SeverityDisplayBar, SeverityDisplayStars, SeverityDisplayIcons:
+	default:
+		cfg.SeverityDisplay = SeverityDisplayBar
+	}
+
+	cfg.UseSvgGauges = getEnv("EMAIL_SVG_GAUGES", "false") == "true"
+	cfg.GaugeMediumThreshold, cfg.GaugeHighThreshold = getEnvThresholds("EMAIL_GAUGE_MEDIUM_THRESHOLD", "EMAIL_GAUGE_HIGH_THRESHOLD", 0.3, 0.7)
+	cfg.SeverityMediumThreshold, cfg.SeverityHighThreshold = getEnvThresholds("EMAIL_SEVERITY_MEDIUM_THRESHOLD", "EMAIL_SEVERITY_HIGH_THRESHOLD", 3, 7)
+	cfg.GaugeGradients = make(map[string]string)
+	for _, band := range []string{"low", "medium", "high"} {
+		key := "EMAIL_GAUGE_GRADIENT_" + strings.ToUpper(band)
+		gradient := strings.TrimSpace(getEnv(key, ""))
+		if gradient == "" {
+			continue
+		}
+		if strings.ContainsAny(gradient, ";{}<>\"") {
+			log.Printf("Ignoring invalid %s=%q: expected a single CSS background value", key, gradient)
+			continue
+		}
+		cfg.GaugeGradients[band] = gradient
+	}
+	cfg.ShowConfidenceBadge = getEnv("EMAIL_SHOW_CONFIDENCE_BADGE", "false") == "true"
+	cfg.ShowSeveritySummary = getEnv("EMAIL_SHOW_SEVERITY_SUMMARY", "false") == "true"
+	previewMaxLen, err := strconv.Atoi(getEnv("EMAIL_INBOX_PREVIEW_MAX_LENGTH", "110"))
+	if err != nil || previewMaxLen < 0 {
+		previewMaxLen = 110
+	}
+	cfg.InboxPreviewMaxLen = previewMaxLen
+	subAnalysisMaxCards, err := strconv.Atoi(getEnv("EMAIL_SUB_ANALYSIS_MAX_CARDS", "5"))
+	if err != nil || subAnalysisMaxCards < 0 {
+		subAnalysisMaxCards = 5
+	}
+	cfg.SubAnalysisMaxCards = subAnalysisMaxCards
+	cfg.ShowRiskRange = getEnv("EMAIL_SHOW_RISK_RANGE", "true") == "true"
+	cfg.DescriptionMarkdown = getEnv("EMAIL_DESCRIPTION_MARKDOWN", "false") == "true"
+	maxSubjectTitleLen, err := strconv.Atoi(getEnv("EMAIL_MAX_SUBJECT_TITLE_LENGTH", "50"))
+	if err != nil || maxSubjectTitleLen < 0 {
+		maxSubjectTitleLen = 50
+	}
+	cfg.MaxSubjectTitleLen = maxSubjectTitleLen
+	maxBodyDescriptionLen, err := strconv.Atoi(getEnv("EMAIL_MAX_BODY_DESCRIPTION_LENGTH", "1000"))
+	if err != nil || maxBodyDescriptionLen < 0 {
+		maxBodyDescriptionLen = 1000
+	}
+	cfg.MaxBodyDescriptionLen = maxBodyDescriptionLen
+	clipWarnBytes, err := strconv.Atoi(getEnv("EMAIL_HTML_CLIP_WARN_BYTES", "100000"))
+	if err != nil || clipWarnBytes < 0 {
+		clipWarnBytes = 100000
+	}
+	cfg.HTMLClipWarnBytes = clipWarnBytes
+	cfg.HTMLClipStrip = getEnv("EMAIL_HTML_CLIP_STRIP", "false") == "true"
+	cfg.CTALabel = getEnv("EMAIL_CTA_LABEL", "{cta} on the CleanApp dashboard")
+	cfg.CTAUTMParams = map[string]string{"utm_source": "cleanapp", "utm_medium": "email", "utm_campaign": "report_alert"}
+	if os.Getenv("EMAIL_CTA_UTM") != "" {
+		cfg.CTAUTMParams = getEnvMap("EMAIL_CTA_UTM")
+	}
+	cfg.BrandDashboardURL = getEnv("EMAIL_BRAND_DASHBOARD_URL", "https://cleanapp.io/digital/{brand}")
+	cfg.DashboardFallbackURL = getEnv("EMAIL_DASHBOARD_FALLBACK_URL", "")
+	cfg.ThemePrimaryColor = getEnvColor("EMAIL_THEME_PRIMARY_COLOR", "#28a745")
+	cfg.ThemeAccentColor = getEnvColor("EMAIL_THEME_ACCENT_COLOR", "#20c997")
+	cfg.ThemeLogoURL = getEnv("EMAIL_THEME_LOGO_URL", "https://cleanapp.io/cleanapp-logo.png")
+	cfg.ThemeBrandColors = make(map[string]string)
+	for brand, color := range getEnvMap("EMAIL_THEME_BRAND_COLORS") {
+		if !hexColorPattern.MatchString(color) {
+			log.Printf("Ignoring invalid EMAIL_THEME_BRAND_COLORS entry %s=%s (want #rgb or #rrggbb)", brand, color)
+			continue
+		}
+		cfg.ThemeBrandColors[strings.ToLower(brand)] = color
+	}
+
+	// Brand registry, e.g. {"acme":{"from_email":"alerts@acme.example.com","dashboard_url":"https://acme.example.com/reports"}}
+	if brands := getEnv("EMAIL_BRANDS", ""); brands != "" {
+		var configured map[string]Brand
+		if err := json.Unmarshal([]byte(brands), &configured); err != nil {
+			log.Printf("Ignoring invalid EMAIL_BRANDS: %v", err)
+		}
+		cfg.Brands = make(map[string]Brand, len(configured))
+		for id, brand := range configured {
+			cfg.Brands[strings.ToLower(id)] = brand
+		}
+	}
+
+	cfg.HideMetricsBrands = getEnvList("EMAIL_HIDE_METRICS_BRANDS")
+	cfg.HideMetricsClassifications = getEnvList("EMAIL_HIDE_METRICS_CLASSIFICATIONS")
+	cfg.SubjectEmojiEnabled = getEnv("EMAIL_SUBJECT_EMOJI_ENABLED", "false") == "true"
+	cfg.SubjectEmoji = getEnvMap("EMAIL_SUBJECT_EMOJI")
+	for _, variant := range strings.Split(getEnv("EMAIL_SUBJECT_VARIANTS", ""), "|") {
+		if variant = strings.TrimSpace(variant); variant != "" {
+			cfg.SubjectVariants = append(cfg.SubjectVariants, variant)
+		}
+	}
+	cfg.SubjectVariantStrategy = getEnv("EMAIL_SUBJECT_VARIANT_STRATEGY", SubjectVariantHash)
+	if cfg.SubjectVariantStrategy != SubjectVariantRandom {
+		cfg.SubjectVariantStrategy = SubjectVariantHash
+	}
+	cfg.UnknownClassification = strings.ToLower(getEnv("EMAIL_UNKNOWN_CLASSIFICATION", "general"))
+	switch cfg.UnknownClassification {
+	case "physical", "digital", "general":
+	default:
+		log.Printf("Ignoring invalid EMAIL_UNKNOWN_CLASSIFICATION=%q (want physical, digital or general), using general", cfg.UnknownClassification)
+		cfg.UnknownClassification = "general"
+	}
+	cfg.EmptyTitleFallbackDigital = getEnv("EMAIL_EMPTY_TITLE_FALLBACK_DIGITAL", "Digital experience issue")
+	cfg.EmptyTitleFallbackPhysical = getEnv("EMAIL_EMPTY_TITLE_FALLBACK_PHYSICAL", "Reported issue")
+	cfg.DefaultLocale = getEnv("EMAIL_DEFAULT_LOCALE", "en")
+	cfg.NextSteps = make(map[string]string)
+	for _, classification := range []string{"physical", "digital", "general"} {
+		if steps := strings.TrimSpace(getEnv("EMAIL_NEXT_STEPS_"+strings.ToUpper(classification), "")); steps != "" {
+			cfg.NextSteps[classification] = steps
+		}
+	}
+
+	// Reporter confirmation configuration
+	cfg.ReporterConfirmationEnabled = getEnv("EMAIL_REPORTER_CONFIRMATION", "false") == "true"
+
+	// Text-only recipients
+	cfg.TextOnlyRecipients = getEnvList("EMAIL_TEXT_ONLY_RECIPIENTS")
+
+	// Ops batch summary configuration
+	cfg.OpsSummaryTo = getEnv("EMAIL_OPS_SUMMARY_TO", "")
+	opsMinBatch, err := strconv.Atoi(getEnv("EMAIL_OPS_SUMMARY_MIN_BATCH", "50"))
+	if err != nil || opsMinBatch < 1 {
+		opsMinBatch = 50
+	}
+	cfg.OpsSummaryMinBatch = opsMinBatch
 
 	return cfg
 }
 
+// ResolveSendGridAPIKey resolves the SendGrid API key from its configured source:
+// a secret file (SendGridAPIKeyFile), then an env var indirection (SendGridAPIKeyEnv),
+// then the literal SendGridAPIKey. Each subuser's key is resolved the same way from its
+// APIKeyFile, APIKeyEnv or APIKey, where no key at all sends through the parent key.
+// Errors never include a key itself.
+func (c *Config) ResolveSendGridAPIKey() error {
+	key, ok, err := readAPIKey("SendGrid API key", c.SendGridAPIKeyFile, c.SendGridAPIKeyEnv)
+	switch {
+	case err != nil:
+		return err
+	case ok:
+		c.SendGridAPIKey = key
+	case strings.TrimSpace(c.SendGridAPIKey) == "":
+		return fmt.Errorf("SendGrid API key is not configured (set SENDGRID_API_KEY, SENDGRID_API_KEY_ENV or SENDGRID_API_KEY_FILE)")
+	}
+
+	for i := range c.SendGridSubusers {
+		sub := &c.SendGridSubusers[i]
+		key, ok, err := readAPIKey(fmt.Sprintf("SendGrid subuser %s API key", sub.Name), sub.APIKeyFile, sub.APIKeyEnv)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			key = strings.TrimSpace(sub.APIKey)
+		}
+		sub.APIKey = key
+	}
+	return nil
+}
+
+// readAPIKey reads the API key described by what from file, or else from the env var
+// named env, trimmed of surrounding whitespace; ok is false when neither is set. A
+// source that is set but holds no key is an error.
+func readAPIKey(what, file, env string) (key string, ok bool, err error) {
+	switch {
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s file %s: %w", what, file, err)
+		}
+		if key = strings.TrimSpace(string(data)); key == "" {
+			return "", false, fmt.Errorf("%s file %s is empty", what, file)
+		}
+	case env != "":
+		if key = strings.TrimSpace(os.Getenv(env)); key == "" {
+			return "", false, fmt.Errorf("%s env var %s is not set", what, env)
+		}
+	default:
+		return "", false, nil
+	}
+	return key, true, nil
+}
+
+// Validate checks the settings sending depends on, so a misconfiguration fails at startup
+// instead of surfacing as SendGrid errors on every send: the API key must be set, the
+// From addresses must be bare email addresses, the opt-out URL must be set and every
+// configured URL, including the brands', must be an absolute http(s) URL. Settings Load
+// couldn't parse at all, such as malformed SENDGRID_SUBUSERS JSON, are reported too. The
+// error lists every problem found. Call it after ResolveSendGridAPIKey.
+func (c *Config) Validate() error {
+	problems := slices.Clone(c.loadProblems)
+	if strings.TrimSpace(c.SendGridAPIKey) == "" {
+		problems = append(problems, "SendGrid API key is not configured")
+	}
+	if addr, err := mail.ParseAddress(c.SendGridFromEmail); err != nil || addr.Address != c.SendGridFromEmail {
+		problems = append(problems, fmt.Sprintf("SENDGRID_FROM_EMAIL %q is not a valid email address", c.SendGridFromEmail))
+	}
+	if c.OptOutURL == "" {
+		problems = append(problems, "OPT_OUT_URL is not configured")
+	}
+	urls := []struct{ env, value string }{
+		{"OPT_OUT_URL", c.OptOutURL},
+		{"MAP_THUMBNAIL_URL", c.MapThumbnailURL},
+		{"EMAIL_THEME_LOGO_URL", c.ThemeLogoURL},
+		{"EMAIL_BRAND_DASHBOARD_URL", c.BrandDashboardURL},
+		{"EMAIL_DASHBOARD_FALLBACK_URL", c.DashboardFallbackURL},
+		{"EMAIL_REPORT_MEDIA_URL", c.ReportMediaURL},
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Brands)) {
+		brand := c.Brands[id]
+		if brand.FromEmail != "" {
+			if addr, err := mail.ParseAddress(brand.FromEmail); err != nil || addr.Address != brand.FromEmail {
+				problems = append(problems, fmt.Sprintf("EMAIL_BRANDS %s from_email %q is not a valid email address", id, brand.FromEmail))
+			}
+		}
+		urls = append(urls, []struct{ env, value string }{
+			{"EMAIL_BRANDS " + id + " dashboard_url", brand.DashboardURL},
+			{"EMAIL_BRANDS " + id + " logo_url", brand.LogoURL},
+			{"EMAIL_BRANDS " + id + " opt_out_url", brand.OptOutURL},
+		}...)
+	}
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s %q is not an absolute http(s) URL", u.env, u.value))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 // GetPollInterval returns the parsed poll interval duration
 func (c *Config) GetPollInterval() time.Duration {
 	duration, err := time.ParseDuration(c.PollInterval)
@@ -99,3 +850,121 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvMap parses a comma-separated list of key=value pairs, skipping malformed
+// entries and values that aren't valid UTF-8
+func getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(getEnv(key, ""), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" || !utf8.ValidString(v) {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// getEnvOptionalBool parses a true/false environment variable, returning nil when it is
+// unset or not a boolean
+func getEnvOptionalBool(key string) *bool {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Ignoring invalid %s %q: expected true or false", key, value)
+		return nil
+	}
+	return &enabled
+}
+
+// getEnvList parses a comma-separated list, trimming and lowercasing entries and
+// skipping empty ones
+func getEnvList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Bounds for image max-dimension settings; smaller values make photos unreadable and
+// larger ones don't bound the attachment size meaningfully
+const (
+	minImageDimension = 256
+	maxImageDimension = 8192
+)
+
+// getEnvDimension gets an image max-dimension environment variable, where 0 disables
+// downscaling. Values that aren't integers or fall outside the allowed bounds are
+// reported and replaced with the fallback.
+func getEnvDimension(key string, fallback int) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	dimension, err := strconv.Atoi(value)
+	if err != nil || (dimension != 0 && (dimension < minImageDimension || dimension > maxImageDimension)) {
+		log.Printf("Ignoring invalid %s=%q (want 0 or %d-%d), using %d", key, value, minImageDimension, maxImageDimension, fallback)
+		return fallback
+	}
+	return dimension
+}
+
+// hexColorPattern matches CSS hex colors in #rgb or #rrggbb form
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// getEnvColor gets a hex color environment variable; values that aren't #rgb or
+// #rrggbb are reported and replaced with the fallback
+func getEnvColor(key, fallback string) string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	if !hexColorPattern.MatchString(value) {
+		log.Printf("Ignoring invalid %s=%q (want #rgb or #rrggbb), using %s", key, value, fallback)
+		return fallback
+	}
+	return value
+}
+
+// getEnvThresholds gets a pair of ascending band thresholds; a pair that doesn't parse or
+// isn't positive and ascending is reported and replaced with the fallbacks
+func getEnvThresholds(mediumKey, highKey string, medium, high float64) (float64, float64) {
+	m, errM := strconv.ParseFloat(getEnv(mediumKey, strconv.FormatFloat(medium, 'g', -1, 64)), 64)
+	h, errH := strconv.ParseFloat(getEnv(highKey, strconv.FormatFloat(high, 'g', -1, 64)), 64)
+	if errM != nil || errH != nil || m <= 0 || h <= m {
+		log.Printf("Ignoring invalid %s/%s, using %g and %g", mediumKey, highKey, medium, high)
+		return medium, high
+	}
+	return m, h
+}
+
+// getEnvDuration gets a duration environment variable with a fallback default value
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil || duration < 0 {
+		return fallback
+	}
+	return duration
+}
+
+// getEnvHour gets an hour of day environment variable, 0-23; an unset or invalid value
+// is 0, and an invalid one is reported
+func getEnvHour(key string) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return 0
+	}
+	hour, err := strconv.Atoi(value)
+	if err != nil || hour < 0 || hour > 23 {
+		log.Printf("Ignoring invalid %s %q, using 0", key, value)
+		return 0
+	}
+	return hour
+}
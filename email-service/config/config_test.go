@@ -0,0 +1,206 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig returns a Config that passes Validate
+func validConfig() *Config {
+	return &Config{
+		SendGridAPIKey:    "SG.test",
+		SendGridFromEmail: "info@cleanapp.io",
+		OptOutURL:         "https://cleanapp.io/opt-out",
+		BrandDashboardURL: "https://cleanapp.io/digital/{brand}",
+		MapThumbnailURL:   "https://maps.example.com/static?center={lat},{lon}",
+	}
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateMissingAPIKey(t *testing.T) {
+	cfg := validConfig()
+	cfg.SendGridAPIKey = " "
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "SendGrid API key is not configured") {
+		t.Errorf("Validate() = %v, want a missing API key error", err)
+	}
+}
+
+func TestValidateMalformedFromEmail(t *testing.T) {
+	for _, from := range []string{"", "not an address", "CleanApp <info@cleanapp.io>"} {
+		cfg := validConfig()
+		cfg.SendGridFromEmail = from
+
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "SENDGRID_FROM_EMAIL") {
+			t.Errorf("Validate() with from %q = %v, want a from email error", from, err)
+		}
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.SendGridAPIKey = ""
+	cfg.SendGridFromEmail = "cleanapp.io"
+	cfg.OptOutURL = ""
+	cfg.ThemeLogoURL = "/logo.png"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"API key", "SENDGRID_FROM_EMAIL", "OPT_OUT_URL is not configured", "EMAIL_THEME_LOGO_URL"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateBrands(t *testing.T) {
+	cfg := validConfig()
+	cfg.Brands = map[string]Brand{
+		"acme":   {FromEmail: "alerts@acme.example.com", DashboardURL: "https://acme.example.com/reports"},
+		"globex": {FromEmail: "Globex <alerts@globex.example.com>", OptOutURL: "globex.example.com/opt-out"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"EMAIL_BRANDS globex from_email", "EMAIL_BRANDS globex opt_out_url"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the error to mention %s, got %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "acme") {
+		t.Errorf("expected the valid brand accepted, got %v", err)
+	}
+}
+
+func TestResolveSendGridAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "sendgrid_api_key")
+	if err := os.WriteFile(keyFile, []byte("SG.from-file\n \t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SENDGRID_API_KEY", " SG.from-env\n")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"literal", Config{SendGridAPIKey: "SG.literal"}, "SG.literal"},
+		{"env indirection", Config{SendGridAPIKey: "SG.literal", SendGridAPIKeyEnv: "TEST_SENDGRID_API_KEY"}, "SG.from-env"},
+		{"file with trailing whitespace", Config{SendGridAPIKeyFile: keyFile, SendGridAPIKeyEnv: "TEST_SENDGRID_API_KEY"}, "SG.from-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if err := cfg.ResolveSendGridAPIKey(); err != nil {
+				t.Fatalf("ResolveSendGridAPIKey() = %v, want nil", err)
+			}
+			if cfg.SendGridAPIKey != tt.want {
+				t.Errorf("SendGridAPIKey = %q, want %q", cfg.SendGridAPIKey, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSendGridAPIKeyErrors(t *testing.T) {
+	dir := t.TempDir()
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte(" \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"missing file", Config{SendGridAPIKeyFile: filepath.Join(dir, "missing")}, "failed to read SendGrid API key file"},
+		// A directory can't be read as a file, even by root, unlike a file with mode 000
+		{"unreadable file", Config{SendGridAPIKeyFile: dir}, "failed to read SendGrid API key file"},
+		{"empty file", Config{SendGridAPIKeyFile: emptyFile}, "is empty"},
+		{"unset env var", Config{SendGridAPIKeyEnv: "TEST_SENDGRID_API_KEY_UNSET"}, "env var TEST_SENDGRID_API_KEY_UNSET is not set"},
+		{"nothing configured", Config{SendGridAPIKey: " "}, "SendGrid API key is not configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.ResolveSendGridAPIKey()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveSendGridAPIKey() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveSubuserAPIKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "bulk_api_key")
+	if err := os.WriteFile(keyFile, []byte("SG.bulk-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SUBUSER_API_KEY", "SG.alerts-env ")
+
+	cfg := Config{
+		SendGridAPIKey: "SG.parent",
+		SendGridSubusers: []SendGridSubuser{
+			{Name: "bulk", APIKey: "SG.ignored", APIKeyFile: keyFile},
+			{Name: "alerts", APIKeyEnv: "TEST_SUBUSER_API_KEY"},
+			{Name: "literal", APIKey: " SG.literal\n"},
+			{Name: "parent"},
+		},
+	}
+	if err := cfg.ResolveSendGridAPIKey(); err != nil {
+		t.Fatalf("ResolveSendGridAPIKey() = %v, want nil", err)
+	}
+	for i, want := range []string{"SG.bulk-file", "SG.alerts-env", "SG.literal", ""} {
+		if got := cfg.SendGridSubusers[i].APIKey; got != want {
+			t.Errorf("subuser %s APIKey = %q, want %q", cfg.SendGridSubusers[i].Name, got, want)
+		}
+	}
+}
+
+func TestResolveSubuserAPIKeyErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		sub     SendGridSubuser
+		wantErr string
+	}{
+		{"missing file", SendGridSubuser{Name: "bulk", APIKeyFile: filepath.Join(dir, "missing")}, "failed to read SendGrid subuser bulk API key file"},
+		{"unset env var", SendGridSubuser{Name: "bulk", APIKeyEnv: "TEST_SUBUSER_API_KEY_UNSET"}, "SendGrid subuser bulk API key env var TEST_SUBUSER_API_KEY_UNSET is not set"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{SendGridAPIKey: "SG.parent", SendGridSubusers: []SendGridSubuser{tt.sub}}
+			err := cfg.ResolveSendGridAPIKey()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveSendGridAPIKey() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsInvalidSubusers(t *testing.T) {
+	t.Setenv("SENDGRID_SUBUSERS", `[{"name":"bulk"`)
+	cfg := Load()
+	cfg.SendGridAPIKey = "SG.test"
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "SENDGRID_SUBUSERS is not a valid JSON list of subusers") {
+		t.Errorf("Validate() = %v, want the invalid SENDGRID_SUBUSERS reported", err)
+	}
+	if cfg.SendGridSubusers != nil {
+		t.Errorf("SendGridSubusers = %+v, want none from invalid JSON", cfg.SendGridSubusers)
+	}
+}
@@ -0,0 +1,98 @@
+package email
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+
+	"email-service/config"
+
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// sendAccount is one SendGrid identity (the main account or a subuser) that recipients
+// are distributed across to spread quota and sender reputation
+type sendAccount struct {
+	name      string
+	client    *sendgrid.Client
+	fromName  string // Overrides SendGridFromName when set
+	fromEmail string // Overrides SendGridFromEmail when set
+	ipPool    string // SendGrid IP pool for this account's mail
+}
+
+// newSendAccounts builds the configured subuser accounts, or a single default
+// account using SendGridAPIKey when no subusers are configured
+func newSendAccounts(cfg *config.Config) []*sendAccount {
+	if len(cfg.SendGridSubusers) == 0 {
+		return []*sendAccount{{name: "default", client: sendgrid.NewSendClient(cfg.SendGridAPIKey)}}
+	}
+
+	accounts := make([]*sendAccount, 0, len(cfg.SendGridSubusers))
+	for _, sub := range cfg.SendGridSubusers {
+		var client *sendgrid.Client
+		if sub.APIKey != "" {
+			client = sendgrid.NewSendClient(sub.APIKey)
+		} else {
+			// Without its own key, send as the subuser through the parent account
+			request := sendgrid.GetRequestSubuser(cfg.SendGridAPIKey, "/v3/mail/send", "", sub.Name)
+			request.Method = "POST"
+			client = &sendgrid.Client{Request: request}
+		}
+		accounts = append(accounts, &sendAccount{
+			name:      sub.Name,
+			client:    client,
+			fromName:  sub.FromName,
+			fromEmail: sub.FromEmail,
+			ipPool:    sub.IPPool,
+		})
+	}
+	return accounts
+}
+
+// accountFor picks the account that sends to recipient, either by a stable hash of
+// the address or round-robin depending on SendGridSubuserStrategy
+func (e *EmailSender) accountFor(recipient string) *sendAccount {
+	if len(e.accounts) == 1 {
+		return e.accounts[0]
+	}
+
+	if e.config.SendGridSubuserStrategy == config.SubuserStrategyRoundRobin {
+		n := atomic.AddUint64(&e.nextAccount, 1) - 1
+		return e.accounts[n%uint64(len(e.accounts))]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(recipient)))
+	return e.accounts[h.Sum32()%uint32(len(e.accounts))]
+}
+
+// apply sets the account's From identity and IP pool on the message
+func (a *sendAccount) apply(message *mail.SGMailV3) {
+	if a.fromEmail != "" {
+		name := a.fromName
+		if name == "" && message.From != nil {
+			name = message.From.Name
+		}
+		message.SetFrom(mail.NewEmail(name, a.fromEmail))
+	}
+	if a.ipPool != "" {
+		message.SetIPPoolID(a.ipPool)
+	}
+}
+
+// recordAccount notes the account, a subuser name, "default" or smtpFallbackAccount,
+// that recipients were sent through, for SendResult.Accounts
+func (b *batch) recordAccount(account string, recipients ...string) {
+	if b == nil || account == "" {
+		return
+	}
+	b.accountsMu.Lock()
+	defer b.accountsMu.Unlock()
+	if b.accounts == nil {
+		b.accounts = make(map[string]string, len(recipients))
+	}
+	for _, recipient := range recipients {
+		b.accounts[recipient] = account
+	}
+}
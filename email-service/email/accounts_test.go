@@ -0,0 +1,157 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+// testSubusers are two subusers with their own From identity and IP pool
+func testSubusers() []config.SendGridSubuser {
+	return []config.SendGridSubuser{
+		{Name: "alerts", APIKey: "SG.alerts", FromName: "CleanApp Alerts", FromEmail: "alerts@cleanapp.io", IPPool: "alerts-pool"},
+		{Name: "reports", APIKey: "SG.reports", FromEmail: "reports@cleanapp.io", IPPool: "reports-pool"},
+	}
+}
+
+func TestSubuserHashStrategyIsStable(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromName:        "CleanApp",
+		SendGridFromEmail:       "info@cleanapp.io",
+		SendGridSubusers:        testSubusers(),
+		SendGridSubuserStrategy: config.SubuserStrategyHash,
+	}, transport)
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	var first, second SendResult
+	if err := e.SendEmails(recipients, nil, nil, WithResult(&first)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if err := e.SendEmails(recipients, nil, nil, WithResult(&second)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+
+	for _, recipient := range recipients {
+		want := e.accountFor(recipient).name
+		if got := first.Accounts[recipient]; got != want {
+			t.Errorf("Accounts[%s] = %q, want %q", recipient, got, want)
+		}
+		if got := second.Accounts[recipient]; got != want {
+			t.Errorf("second batch sent %s through %q, want the same account %q", recipient, got, want)
+		}
+		if got := e.accountFor(strings.ToUpper(recipient)); got.name != want {
+			t.Errorf("accountFor(%q) = %q, want the account of the lower-case address %q", strings.ToUpper(recipient), got.name, want)
+		}
+	}
+}
+
+func TestSubuserRoundRobinStrategyRotates(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromEmail:       "info@cleanapp.io",
+		SendGridSubusers:        testSubusers(),
+		SendGridSubuserStrategy: config.SubuserStrategyRoundRobin,
+	}, transport)
+
+	var result SendResult
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	if err := e.SendEmails(recipients, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, recipient := range recipients {
+		counts[result.Accounts[recipient]]++
+	}
+	if counts["alerts"] != 2 || counts["reports"] != 2 {
+		t.Errorf("expected 4 sends split evenly across both subusers, got %v", counts)
+	}
+}
+
+func TestSubuserFromAndIPPool(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromName:        "CleanApp",
+		SendGridFromEmail:       "info@cleanapp.io",
+		SendGridSubusers:        testSubusers(),
+		SendGridSubuserStrategy: config.SubuserStrategyRoundRobin,
+	}, transport)
+
+	var result SendResult
+	if err := e.SendEmails([]string{"a@example.com", "b@example.com"}, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(transport.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(transport.messages))
+	}
+
+	want := map[string]struct{ fromName, fromEmail, ipPool string }{
+		"alerts":  {"CleanApp Alerts", "alerts@cleanapp.io", "alerts-pool"},
+		"reports": {"CleanApp", "reports@cleanapp.io", "reports-pool"},
+	}
+	for _, message := range transport.messages {
+		recipient := message.Personalizations[0].To[0].Address
+		w, ok := want[result.Accounts[recipient]]
+		if !ok {
+			t.Fatalf("unexpected account %q for %s", result.Accounts[recipient], recipient)
+		}
+		if message.From.Name != w.fromName || message.From.Address != w.fromEmail {
+			t.Errorf("From for %s = %q <%s>, want %q <%s>", recipient, message.From.Name, message.From.Address, w.fromName, w.fromEmail)
+		}
+		if message.IPPoolID != w.ipPool {
+			t.Errorf("IP pool for %s = %q, want %q", recipient, message.IPPoolID, w.ipPool)
+		}
+	}
+}
+
+func TestSendResultDefaultAccount(t *testing.T) {
+	e := NewEmailSenderWithTransport(&config.Config{}, &fakeTransport{})
+
+	var result SendResult
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if got := result.Accounts["brand@example.com"]; got != "default" {
+		t.Errorf("Accounts = %v, want brand@example.com sent through the default account", result.Accounts)
+	}
+}
+
+func TestSendBatchGroupsRecipientsBySubuser(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromEmail:       "info@cleanapp.io",
+		SendGridSubusers:        testSubusers(),
+		SendGridSubuserStrategy: config.SubuserStrategyHash,
+	}, transport)
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com", "f@example.com"}
+	var result SendResult
+	if err := e.SendBatch(context.Background(), recipients, nil, nil, goldenAnalysis(), WithResult(&result)); err != nil {
+		t.Fatalf("SendBatch returned error: %v", err)
+	}
+
+	if len(transport.messages) != 2 {
+		t.Fatalf("expected one batched message per subuser, got %d", len(transport.messages))
+	}
+	fromEmail := map[string]string{"alerts": "alerts@cleanapp.io", "reports": "reports@cleanapp.io"}
+	addressed := 0
+	for _, message := range transport.messages {
+		for _, p := range message.Personalizations {
+			recipient := p.To[0].Address
+			addressed++
+			want := e.accountFor(recipient).name
+			if got := result.Accounts[recipient]; got != want {
+				t.Errorf("Accounts[%s] = %q, want its hashed subuser %q", recipient, got, want)
+			}
+			if message.From.Address != fromEmail[want] {
+				t.Errorf("%s was batched into a message from %s, want %s", recipient, message.From.Address, fromEmail[want])
+			}
+		}
+	}
+	if addressed != len(recipients) {
+		t.Errorf("expected %d recipients addressed, got %d", len(recipients), addressed)
+	}
+}
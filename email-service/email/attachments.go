@@ -0,0 +1,176 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"email-service/models"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// maxFilenameStemLen bounds the sanitized part of an attachment filename
+const maxFilenameStemLen = 60
+
+// inlineImage is an image whose base64 encoding is computed once per batch and
+// shared by every recipient's message instead of being re-encoded per recipient
+type inlineImage struct {
+	raw       []byte // Original bytes, used for format sniffing
+	encoded   string // Base64 attachment content
+	composite bool   // Report and map combined into one image
+	width     int    // Detected pixel dimensions; 0 when the format isn't decodable
+	height    int
+}
+
+// encodeInlineImage base64-encodes data once and detects its dimensions; it returns
+// nil for an empty image
+func encodeInlineImage(data []byte) *inlineImage {
+	if len(data) == 0 {
+		return nil
+	}
+	img := &inlineImage{raw: data, encoded: base64.StdEncoding.EncodeToString(data)}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img.width, img.height = cfg.Width, cfg.Height
+	}
+	return img
+}
+
+// newInlineAttachment builds an inline image attachment referenced from the HTML by cid
+func newInlineAttachment(img *inlineImage, contentType, filename, cid string) *mail.Attachment {
+	attachment := mail.NewAttachment()
+	attachment.SetContent(img.encoded)
+	attachment.SetType(contentType)
+	attachment.SetFilename(filename)
+	attachment.SetDisposition("inline")
+	attachment.SetContentID(cid)
+	return attachment
+}
+
+// contentIDs are the Content-IDs of a message's inline report (or composite) and map images
+type contentIDs struct {
+	report, mapImg string
+}
+
+// newContentIDs returns Content-IDs unique to one message, so its cid: references can
+// only resolve to its own attachments: several reports' images can share a message, and
+// a client caching inline images by Content-ID can't show another email's image. With
+// composite set the report ID names the combined report and map image.
+func (e *EmailSender) newContentIDs(composite bool) contentIDs {
+	report := reportImgCid
+	if composite {
+		report = compositeImgCid
+	}
+	return contentIDs{report: e.newID(report), mapImg: e.newID(mapImgCid)}
+}
+
+// validateContentIDs ensures no two inline attachments share a Content-ID, which would
+// make clients render the wrong image for one of the cid: references
+func validateContentIDs(message *mail.SGMailV3) error {
+	seen := make(map[string]string, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		if attachment.ContentID == "" {
+			continue
+		}
+		if other, ok := seen[attachment.ContentID]; ok {
+			return fmt.Errorf("duplicate attachment Content-ID %q (%s and %s)", attachment.ContentID, other, attachment.Filename)
+		}
+		seen[attachment.ContentID] = attachment.Filename
+	}
+	return nil
+}
+
+// attachmentFilename names an attachment after the report so saved files from
+// different reports don't collide, e.g. "cleanapp-report-12345.jpg", using the report
+// ID, else its sequence number, else its title. Without any of them it falls back to
+// the generic "cleanapp-report.jpg"/"cleanapp-map.png" names.
+func attachmentFilename(kind string, analysis *models.ReportAnalysis, data []byte, defaultExt string) string {
+	ext := imageExtension(data, defaultExt)
+
+	stem := ""
+	if analysis != nil {
+		stem = sanitizeFilename(analysis.ReportID)
+		if stem == "" && analysis.Seq > 0 {
+			stem = fmt.Sprintf("%d", analysis.Seq)
+		}
+		if stem == "" {
+			stem = sanitizeFilename(analysis.Title)
+		}
+	}
+	if stem == "" {
+		return "cleanapp-" + kind + ext
+	}
+	return "cleanapp-" + kind + "-" + stem + ext
+}
+
+// imageExtensions maps the content types of the image formats we attach to their
+// filename extension
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// imageContentType returns the sniffed content type of an image, so a PNG report photo
+// isn't labelled JPEG, or application/octet-stream when the format isn't recognized
+func imageContentType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if _, ok := imageExtensions[contentType]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// imageExtension returns the filename extension for the sniffed image format
+func imageExtension(data []byte, defaultExt string) string {
+	if ext, ok := imageExtensions[http.DetectContentType(data)]; ok {
+		return ext
+	}
+	return defaultExt
+}
+
+// sanitizeFilename reduces s to a filesystem-safe lowercase stem of letters,
+// digits, dashes and underscores, collapsing everything else into single dashes
+func sanitizeFilename(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= maxFilenameStemLen {
+			break
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// getReportMediaURL fills the report's ID and sequence number into ReportMediaURL,
+// returning "" when it is unset or the report has no value for one of its placeholders
+func (e *EmailSender) getReportMediaURL(analysis *models.ReportAnalysis) string {
+	link := e.config.ReportMediaURL
+	if strings.Contains(link, "{id}") {
+		if analysis.ReportID == "" {
+			return ""
+		}
+		link = strings.ReplaceAll(link, "{id}", url.PathEscape(analysis.ReportID))
+	}
+	if strings.Contains(link, "{seq}") {
+		if analysis.Seq == 0 {
+			return ""
+		}
+		link = strings.ReplaceAll(link, "{seq}", strconv.FormatInt(analysis.Seq, 10))
+	}
+	return link
+}
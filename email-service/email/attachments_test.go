@@ -0,0 +1,302 @@
+package email
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+func TestValidateContentIDsRejectsDuplicates(t *testing.T) {
+	var calls int32
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	message := mail.NewV3Mail()
+	message.SetFrom(mail.NewEmail("CleanApp", "info@cleanapp.io"))
+	message.Subject = "Duplicate CIDs"
+	p := mail.NewPersonalization()
+	p.AddTos(mail.NewEmail("", "brand@example.com"))
+	message.AddPersonalizations(p)
+	message.AddContent(mail.NewContent("text/plain", "body"))
+	message.AddAttachment(newInlineAttachment(encodeInlineImage([]byte("a")), "image/png", "first.png", "shared"))
+	message.AddAttachment(newInlineAttachment(encodeInlineImage([]byte("b")), "image/png", "second.png", "shared"))
+
+	err := e.deliver(nil, message, "brand@example.com", "Email")
+	if err == nil || !strings.Contains(err.Error(), `duplicate attachment Content-ID "shared"`) {
+		t.Fatalf("expected duplicate Content-ID error, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected no SendGrid call for an invalid message, got %d", calls)
+	}
+}
+
+func TestValidateContentIDsAllowsDistinct(t *testing.T) {
+	message := mail.NewV3Mail()
+	message.AddAttachment(newInlineAttachment(encodeInlineImage([]byte("a")), "image/jpeg", "report.jpg", reportImgCid))
+	message.AddAttachment(newInlineAttachment(encodeInlineImage([]byte("b")), "image/png", "map.png", mapImgCid))
+
+	if err := validateContentIDs(message); err != nil {
+		t.Errorf("unexpected error for distinct Content-IDs: %v", err)
+	}
+}
+
+func TestInlineImagesReferencedByUniqueContentIDs(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	for range 2 {
+		err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+			encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), goldenAnalysis())
+		if err != nil {
+			t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+		}
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 sends, got %d", len(sent))
+	}
+
+	seen := map[string]bool{}
+	for _, mail := range sent {
+		var html string
+		for _, content := range mail.Content {
+			if content.Type == "text/html" {
+				html = content.Value
+			}
+		}
+		if len(mail.Attachments) != 2 {
+			t.Fatalf("expected the report and map attachments, got %d", len(mail.Attachments))
+		}
+		for _, attachment := range mail.Attachments {
+			if !strings.Contains(html, `src="cid:`+attachment.ContentID+`"`) {
+				t.Errorf("attachment %s isn't the one the HTML references", attachment.ContentID)
+			}
+			if seen[attachment.ContentID] {
+				t.Errorf("Content-ID %s reused across messages", attachment.ContentID)
+			}
+			seen[attachment.ContentID] = true
+		}
+	}
+}
+
+func TestAttachmentTypeSniffedFromImage(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	// A PNG report photo and a JPEG map, the reverse of the usual formats
+	if err := e.SendEmails([]string{"brand@example.com"}, encodeTestImage(t, 40, 30, "png"), encodeTestImage(t, 20, 20, "jpeg")); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(sent) != 1 || len(sent[0].Attachments) != 2 {
+		t.Fatalf("expected one send with two attachments, got %+v", sent)
+	}
+	report, mapImg := sent[0].Attachments[0], sent[0].Attachments[1]
+	if report.Type != "image/png" || report.Filename != "cleanapp-report.png" {
+		t.Errorf("expected the report attached as image/png cleanapp-report.png, got %s %s", report.Type, report.Filename)
+	}
+	if mapImg.Type != "image/jpeg" || mapImg.Filename != "cleanapp-map.jpg" {
+		t.Errorf("expected the map attached as image/jpeg cleanapp-map.jpg, got %s %s", mapImg.Type, mapImg.Filename)
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	jpegData := encodeTestImage(t, 4, 4, "jpeg")
+	tests := []struct {
+		name     string
+		analysis *models.ReportAnalysis
+		want     string
+	}{
+		{"report ID", &models.ReportAnalysis{ReportID: "A1b2/C3", Seq: 12345, Title: "Overflowing bin"}, "cleanapp-report-a1b2-c3.jpg"},
+		{"sequence number", &models.ReportAnalysis{Seq: 12345, Title: "Overflowing bin"}, "cleanapp-report-12345.jpg"},
+		{"title", &models.ReportAnalysis{Title: "  Broken glass / Main St. \\ near #5  "}, "cleanapp-report-broken-glass-main-st-near-5.jpg"},
+		{"long title", &models.ReportAnalysis{Title: strings.Repeat("litter ", 30)}, "cleanapp-report-" + strings.TrimRight(strings.Repeat("litter-", 9)[:maxFilenameStemLen], "-") + ".jpg"},
+		{"unusable title", &models.ReportAnalysis{Title: "../../?*"}, "cleanapp-report.jpg"},
+		{"no analysis", nil, "cleanapp-report.jpg"},
+	}
+	for _, tt := range tests {
+		if got := attachmentFilename("report", tt.analysis, jpegData, ".png"); got != tt.want {
+			t.Errorf("%s: attachmentFilename() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImageContentType(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg"},
+		{[]byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{[]byte("GIF89a"), "image/gif"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{[]byte("not an image"), "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := imageContentType(tt.data); got != tt.want {
+			t.Errorf("imageContentType(%q) = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+// benchmarkRecipients and benchmarkImage approximate a brand batch with a phone photo
+const benchmarkRecipients = 100
+
+var benchmarkImage = make([]byte, 512*1024)
+
+// BenchmarkAttachPerRecipientEncoding is the old behavior: every recipient's message
+// re-encodes the shared image
+func BenchmarkAttachPerRecipientEncoding(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for r := 0; r < benchmarkRecipients; r++ {
+			message := mail.NewV3Mail()
+			message.AddAttachment(newInlineAttachment(encodeInlineImage(benchmarkImage), "image/jpeg", "report.jpg", reportImgCid))
+		}
+	}
+}
+
+// BenchmarkAttachSharedEncoding encodes the image once per batch and reuses it
+func BenchmarkAttachSharedEncoding(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		img := encodeInlineImage(benchmarkImage)
+		for r := 0; r < benchmarkRecipients; r++ {
+			message := mail.NewV3Mail()
+			message.AddAttachment(newInlineAttachment(img, "image/jpeg", "report.jpg", reportImgCid))
+		}
+	}
+}
+
+func TestImagesLinkedBelowSeverityThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		threshold float64
+		linked    bool
+	}{
+		{"below threshold", 7, true},
+		{"at threshold", 6.5, false},
+		{"above threshold", 5, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &fakeTransport{}
+			e := NewEmailSenderWithTransport(&config.Config{
+				ImageSeverityThreshold: tt.threshold,
+				ReportMediaURL:         "https://cleanapp.io/reports/{seq}/media",
+			}, transport)
+			analysis := goldenAnalysis() // Severity 6.5
+
+			err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+				encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), analysis)
+			if err != nil {
+				t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+			}
+			if len(transport.messages) != 1 {
+				t.Fatalf("expected 1 message, got %d", len(transport.messages))
+			}
+			message := transport.messages[0]
+			mediaURL := "https://cleanapp.io/reports/12345/media"
+
+			if tt.linked {
+				if len(message.Attachments) != 0 {
+					t.Errorf("expected no attachments below the threshold, got %d", len(message.Attachments))
+				}
+				for _, content := range message.Content {
+					want := "View the report photos and location map: " + mediaURL
+					if content.Type == "text/html" {
+						want = `<a href="` + mediaURL + `"`
+					}
+					if !strings.Contains(content.Value, want) {
+						t.Errorf("expected the %s body to link the media with %q", content.Type, want)
+					}
+					if strings.Contains(content.Value, "cid:") {
+						t.Errorf("expected no inline image references in the %s body", content.Type)
+					}
+				}
+				return
+			}
+			if len(message.Attachments) != 2 {
+				t.Errorf("expected the report and map attached, got %d attachments", len(message.Attachments))
+			}
+			for _, content := range message.Content {
+				if strings.Contains(content.Value, "View the report photos") {
+					t.Errorf("expected no media link in the %s body when the images are attached", content.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestImagesAttachedWithoutMediaLink(t *testing.T) {
+	for _, mediaURL := range []string{"", "https://cleanapp.io/reports/{id}"} {
+		transport := &fakeTransport{}
+		e := NewEmailSenderWithTransport(&config.Config{ImageSeverityThreshold: 7, ReportMediaURL: mediaURL}, transport)
+		analysis := goldenAnalysis() // Severity 6.5, no ReportID
+
+		err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+			encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), analysis)
+		if err != nil {
+			t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+		}
+		if n := len(transport.messages[0].Attachments); n != 2 {
+			t.Errorf("ReportMediaURL %q: expected the images kept without a media link, got %d attachments", mediaURL, n)
+		}
+	}
+}
+
+func TestImageSeverityThresholdByBrand(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		ImageSeverityThreshold:        7,
+		ImageSeverityThresholdByBrand: map[string]float64{"acme": 5},
+	}}
+	analysis := goldenAnalysis()
+	if got := e.imageSeverityThreshold(analysis); got != 5 {
+		t.Errorf("imageSeverityThreshold(acme) = %v, want the brand override 5", got)
+	}
+	analysis.BrandName = "Globex"
+	if got := e.imageSeverityThreshold(analysis); got != 7 {
+		t.Errorf("imageSeverityThreshold(globex) = %v, want the default 7", got)
+	}
+}
+
+func TestGetReportMediaURL(t *testing.T) {
+	tests := []struct {
+		template string
+		reportID string
+		seq      int64
+		want     string
+	}{
+		{"", "r-1", 12, ""},
+		{"https://cleanapp.io/reports/{seq}", "", 12, "https://cleanapp.io/reports/12"},
+		{"https://cleanapp.io/media/{id}", "r 1/2", 12, "https://cleanapp.io/media/r%201%2F2"},
+		{"https://cleanapp.io/media/{id}", "", 12, ""},
+		{"https://cleanapp.io/reports/{seq}", "r-1", 0, ""},
+	}
+	for _, tt := range tests {
+		e := &EmailSender{config: &config.Config{ReportMediaURL: tt.template}}
+		analysis := goldenAnalysis()
+		analysis.ReportID, analysis.Seq = tt.reportID, tt.seq
+		if got := e.getReportMediaURL(analysis); got != tt.want {
+			t.Errorf("getReportMediaURL(%q, id %q, seq %d) = %q, want %q", tt.template, tt.reportID, tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestMediaLinkEscapedInHTML(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	render := analysisRender{mediaURL: `https://cleanapp.io/media?id=1&x="><script>`}
+
+	body := e.getEmailHtmlWithAnalysis("brand@example.com", goldenAnalysis(), false, false, render)
+	if strings.Contains(body, `"><script>`) {
+		t.Error("expected the media URL escaped in the href")
+	}
+	if !strings.Contains(body, `href="https://cleanapp.io/media?id=1&amp;x=&#34;&gt;&lt;script&gt;"`) {
+		t.Error("expected the escaped media URL in the href")
+	}
+}
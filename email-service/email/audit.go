@@ -0,0 +1,35 @@
+package email
+
+import "github.com/sendgrid/sendgrid-go/helpers/mail"
+
+// AuditRecord is the final content of one message as handed to SendGrid, after the
+// HTML transform hook, for archiving what a recipient was shown
+type AuditRecord struct {
+	BatchID   string
+	Recipient string
+	Subject   string
+	Text      string
+	HTML      string // Empty for text-only recipients
+	Err       error  // Non-nil when the message wasn't accepted
+}
+
+// recordAudit passes the message to the batch's audit archive, if any
+func (b *batch) recordAudit(message *mail.SGMailV3, recipient string, err error) {
+	if b == nil || b.audit == nil {
+		return
+	}
+
+	record := AuditRecord{BatchID: b.id, Recipient: recipient, Subject: message.Subject, Err: err}
+	for _, content := range message.Content {
+		switch content.Type {
+		case "text/plain":
+			record.Text = content.Value
+		case "text/html":
+			record.HTML = content.Value
+		}
+	}
+
+	b.auditMu.Lock()
+	defer b.auditMu.Unlock()
+	b.audit(record)
+}
@@ -0,0 +1,46 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestWithAudit(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{TextOnlyRecipients: []string{"plain@example.com"}}, captureSends(t, &sent),
+		WithHTMLTransform(func(html string) (string, error) {
+			return strings.Replace(html, "</body>", "<!-- partner badge --></body>", 1), nil
+		}))
+
+	var records []AuditRecord
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com", "plain@example.com"}, nil, nil, goldenAnalysis(),
+		WithAudit(func(r AuditRecord) { records = append(records, r) }))
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 audit records, got %d", len(records))
+	}
+
+	html := records[0]
+	if html.Recipient != "brand@example.com" || html.Subject != sent[0].Subject || html.Err != nil {
+		t.Errorf("unexpected audit record %+v", html)
+	}
+	if html.Text != sent[0].Content[0].Value || !strings.Contains(html.HTML, "<!-- partner badge -->") {
+		t.Error("expected the audited bodies to match what was sent, after the HTML transform")
+	}
+	if plain := records[1]; plain.Text == "" || plain.HTML != "" {
+		t.Errorf("expected a text-only audit record for plain@example.com, got HTML %q", plain.HTML)
+	}
+
+	// Without the option nothing is recorded
+	records = nil
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no audit records without WithAudit, got %d", len(records))
+	}
+}
@@ -0,0 +1,442 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	netmail "net/mail"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// maxSummaryDomains bounds the failing domains listed in the ops summary
+const maxSummaryDomains = 5
+
+// errSkipped marks a recipient deliberately not sent to; a send func wraps it to have
+// the recipient counted as skipped rather than failed
+var errSkipped = errors.New("skipped")
+
+// ErrBatchTooLarge is returned when a batch has more recipients than MaxBatchSize, a
+// fuse against runaway sends such as a query returning every contact
+var ErrBatchTooLarge = errors.New("batch exceeds the maximum batch size")
+
+// errDuplicate marks a recipient skipped because the batch already sent to its address
+var errDuplicate = fmt.Errorf("%w: duplicate recipient in batch", errSkipped)
+
+// batchReport is the outcome of one batch send. Every recipient ends up sent, invalid
+// (rejected by address validation), skipped (deliberately not sent) or failed.
+type batchReport struct {
+	id        string
+	kind      string // e.g. "email with analysis"
+	total     int
+	succeeded []string
+	invalid   []batchFailure
+	skipped   []batchFailure
+	failures  []batchFailure
+}
+
+// batchFailure is one recipient the batch didn't deliver to, with the reason
+type batchFailure struct {
+	recipient string
+	err       error
+}
+
+// sent returns the number of recipients the batch delivered to
+func (r *batchReport) sent() int {
+	return r.total - len(r.invalid) - len(r.skipped) - len(r.failures)
+}
+
+// BatchError is returned when some recipients of a batch weren't sent to because their
+// address was invalid or their send failed. Invalid addresses are counted apart from
+// failures since retrying won't help them. Skipped recipients alone don't make a batch
+// fail, but are counted here when it does.
+type BatchError struct {
+	Total   int
+	Sent    int
+	Invalid int
+	Skipped int
+	Failed  int
+
+	plural string // e.g. "emails with analysis"
+	first  error  // First failure, or the first invalid address when none failed
+}
+
+func (e *BatchError) Error() string {
+	msg := fmt.Sprintf("%d/%d %s failed", e.Failed, e.Total, e.plural)
+	if e.Invalid > 0 {
+		msg += fmt.Sprintf(", %d invalid", e.Invalid)
+	}
+	if e.Skipped > 0 {
+		msg += fmt.Sprintf(", %d skipped", e.Skipped)
+	}
+	return msg + ": " + e.first.Error()
+}
+
+func (e *BatchError) Unwrap() error { return e.first }
+
+// err returns the BatchError for the report, or nil when nothing failed or was invalid
+func (r *batchReport) err(plural string) error {
+	if len(r.failures) == 0 && len(r.invalid) == 0 {
+		return nil
+	}
+
+	first := r.invalid
+	if len(r.failures) > 0 {
+		first = r.failures
+	}
+	return &BatchError{
+		Total:   r.total,
+		Sent:    r.sent(),
+		Invalid: len(r.invalid),
+		Skipped: len(r.skipped),
+		Failed:  len(r.failures),
+		plural:  plural,
+		first:   first[0].err,
+	}
+}
+
+// CancelledError is returned when a batch's context is done before every recipient was
+// sent to. Sent counts the emails SendGrid accepted before then, so a caller retrying
+// the batch knows that many recipients already have the email; sends abandoned in
+// flight may or may not have been accepted and are counted as failures.
+type CancelledError struct {
+	BatchID string
+	Sent    int // Emails accepted by SendGrid before cancellation
+	Total   int // Recipients taken from the batch before cancellation
+	Err     error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("batch %s cancelled after %d/%d sent: %v", e.BatchID, e.Sent, e.Total, e.Err)
+}
+
+func (e *CancelledError) Unwrap() error { return e.Err }
+
+// validateRecipient checks that recipient is a bare, well-formed email address
+func validateRecipient(recipient string) error {
+	addr, err := netmail.ParseAddress(recipient)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", recipient, err)
+	}
+	if addr.Address != recipient {
+		return fmt.Errorf("invalid address %q: expected a bare address", recipient)
+	}
+	return nil
+}
+
+// checkBatchSize refuses a batch of n recipients over MaxBatchSize before anything is sent
+func (e *EmailSender) checkBatchSize(b *batch, n int) error {
+	if e.config.MaxBatchSize <= 0 || n <= e.config.MaxBatchSize {
+		return nil
+	}
+	log.Errorf("Refusing batch %s of %d recipients: over the maximum of %d, check the recipient query", b.id, n, e.config.MaxBatchSize)
+	return fmt.Errorf("batch %s has %d recipients, over the maximum of %d: %w", b.id, n, e.config.MaxBatchSize, ErrBatchTooLarge)
+}
+
+// isSenderAddress reports whether recipient is the From address of the sender, one of
+// its subuser accounts, a brand or a per-domain From, which would have us mailing
+// ourselves and risk mail loops
+func (e *EmailSender) isSenderAddress(recipient string) bool {
+	if strings.EqualFold(recipient, e.config.SendGridFromEmail) {
+		return true
+	}
+	for _, brand := range e.config.Brands {
+		if brand.FromEmail != "" && strings.EqualFold(recipient, brand.FromEmail) {
+			return true
+		}
+	}
+	for _, account := range e.accounts {
+		if account.fromEmail != "" && strings.EqualFold(recipient, account.fromEmail) {
+			return true
+		}
+	}
+	e.domainFromsMu.RLock()
+	defer e.domainFromsMu.RUnlock()
+	for _, from := range e.domainFroms {
+		if strings.EqualFold(recipient, from.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+// runBatch sends to every valid recipient, continuing past failures, and returns a
+// BatchError summarizing invalid and failed recipients. Our own sender addresses are
+// skipped, as are repeats of an address already seen in the batch, matched case
+// insensitively. Recipients are sent to with the concurrency and rate limit of the batch's
+// profile. Large batches are reported to the ops address when configured. kind names a
+// single email in log lines and plural names the batch in the error.
+func (e *EmailSender) runBatch(b *batch, kind, plural string, recipients []string, send func(recipient string) error) error {
+	return e.streamBatch(context.Background(), b, kind, plural, slices.Values(recipientsFromEmails(recipients)), func(r Recipient) error {
+		return send(r.Email)
+	})
+}
+
+// streamBatch is runBatch over recipients pulled one at a time. A recipient is only
+// pulled once a worker is free to take it, so a slow send holds back the source rather
+// than buffering it. When ctx is done no further recipients are sent to, requests in
+// flight are abandoned, and a CancelledError is joined to the batch's error. A
+// source that turns out longer than MaxBatchSize is cut off the same way with
+// ErrBatchTooLarge, since its size couldn't be checked up front.
+func (e *EmailSender) streamBatch(ctx context.Context, b *batch, kind, plural string, recipients iter.Seq[Recipient], send func(r Recipient) error) error {
+	report := &batchReport{id: b.id, kind: kind}
+	ctx, span := e.startBatchSpan(ctx, b, kind)
+
+	var throttle <-chan time.Time
+	if b.profile.RatePerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / b.profile.RatePerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var mu sync.Mutex
+	var unsent int // Handed to a worker but cancelled before the send started
+	seen := make(map[string]bool)
+	queue := make(chan Recipient)
+	var wg sync.WaitGroup
+	for range e.concurrency(b) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range queue {
+				if throttle != nil {
+					select {
+					case <-throttle:
+					case <-ctx.Done():
+					}
+				}
+				if ctx.Err() != nil {
+					mu.Lock()
+					unsent++
+					mu.Unlock()
+					continue
+				}
+				err := send(r)
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					report.succeeded = append(report.succeeded, r.Email)
+				case errors.Is(err, errSkipped):
+					report.skipped = append(report.skipped, batchFailure{r.Email, err})
+					log.Infof("Skipped %s to %s: %v", kind, r.Email, err)
+				default:
+					report.failures = append(report.failures, batchFailure{r.Email, err})
+					log.Warnf("Error sending %s to %s: %v", kind, r.Email, err)
+					// Continue with other recipients
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var overCap error
+pull:
+	for r := range recipients {
+		if ctx.Err() != nil {
+			break
+		}
+		if e.config.MaxBatchSize > 0 && report.total >= e.config.MaxBatchSize {
+			overCap = fmt.Errorf("batch %s stopped after %d recipients, the maximum batch size: %w", b.id, report.total, ErrBatchTooLarge)
+			log.Errorf("%v", overCap)
+			break
+		}
+		report.total++
+
+		r.Email = normalizeRecipient(r.Email)
+		if err := e.screenRecipient(b, kind, seen, r.Email); err != nil {
+			mu.Lock()
+			report.reject(r.Email, err)
+			mu.Unlock()
+			continue
+		}
+
+		select {
+		case queue <- r:
+		case <-ctx.Done():
+			// Never handed to a worker, so it counts as neither sent nor failed
+			report.total--
+			break pull
+		}
+	}
+	close(queue)
+	wg.Wait()
+	report.total -= unsent
+
+	e.refundFrequency(b, report)
+	e.sendOpsSummary(report)
+	b.recordResult(report, plural)
+
+	err := errors.Join(report.err(plural), overCap)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		log.Warnf("Batch %s cancelled after %d/%d %s sent: %v", b.id, report.sent(), report.total, plural, ctxErr)
+		err = errors.Join(err, &CancelledError{BatchID: b.id, Sent: report.sent(), Total: report.total, Err: ctxErr})
+	}
+	endBatchSpan(span, report, err)
+	return err
+}
+
+// normalizeRecipient trims and lowercases an address so that repeats differing only in
+// case or surrounding whitespace are recognized as duplicates
+func normalizeRecipient(recipient string) string {
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
+
+// screenAddress checks a normalized address before any email is sent to it, returning
+// an invalid-address error or an errSkipped error for our own sender addresses and
+// suppressed addresses
+func (e *EmailSender) screenAddress(kind, recipient string) error {
+	if err := validateRecipient(recipient); err != nil {
+		log.Warnf("Not sending %s: %v", kind, err)
+		return err
+	}
+	if e.isSenderAddress(recipient) {
+		log.Warnf("Not sending %s to %s: it is one of our own sender addresses, check the recipient source", kind, recipient)
+		return fmt.Errorf("%w: recipient is a sender address", errSkipped)
+	}
+	if e.isSuppressed(recipient) {
+		log.Infof("Not sending %s to %s: the address is suppressed", kind, recipient)
+		return ErrSuppressed
+	}
+	return nil
+}
+
+// screenRecipient checks a normalized recipient before it is handed to a send, returning
+// the screenAddress errors, or an errSkipped error for repeats of an address already in
+// seen and recipients at their frequency cap; seen is updated, and the send counted
+// towards the cap
+func (e *EmailSender) screenRecipient(b *batch, kind string, seen map[string]bool, recipient string) error {
+	if err := e.screenAddress(kind, recipient); err != nil {
+		return err
+	}
+	// A last guard for sources merged upstream that repeat an address
+	if seen[recipient] {
+		log.Infof("Not sending %s to %s: already sent to in batch %s", kind, recipient, b.id)
+		return errDuplicate
+	}
+	seen[recipient] = true
+	if !e.allowFrequency(b, recipient) {
+		log.Infof("Not sending %s to %s: %d emails within %s already sent", kind, recipient, e.config.MaxEmailsPerRecipient, e.config.FrequencyWindow)
+		return ErrThrottled
+	}
+	return nil
+}
+
+// reject records a recipient screenRecipient turned away as skipped or invalid
+func (r *batchReport) reject(recipient string, err error) {
+	if errors.Is(err, errSkipped) {
+		r.skipped = append(r.skipped, batchFailure{recipient, err})
+	} else {
+		r.invalid = append(r.invalid, batchFailure{recipient, err})
+	}
+}
+
+// failureCategory groups a send error for the ops summary breakdown
+func failureCategory(err error) string {
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.infrastructure:
+		return fmt.Sprintf("infrastructure error (status %d)", statusErr.status)
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("SendGrid status %d", statusErr.status)
+	case errors.Is(err, errInvalidMessage):
+		return "invalid message"
+	default:
+		return "request error"
+	}
+}
+
+// countEntry is a label with its occurrence count
+type countEntry struct {
+	label string
+	count int
+}
+
+// sortedCounts orders counts by descending count, then label
+func sortedCounts(counts map[string]int) []countEntry {
+	entries := make([]countEntry, 0, len(counts))
+	for label, count := range counts {
+		entries = append(entries, countEntry{label, count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].label < entries[j].label
+	})
+	return entries
+}
+
+// getOpsSummaryText renders the batch report as a plain text summary
+func getOpsSummaryText(report *batchReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Batch %s (%s)\n\n", report.id, report.kind)
+	fmt.Fprintf(&b, "Sent %d/%d; %d failed", report.sent(), report.total, len(report.failures))
+	if len(report.invalid) > 0 {
+		fmt.Fprintf(&b, ", %d invalid", len(report.invalid))
+	}
+	if len(report.skipped) > 0 {
+		fmt.Fprintf(&b, ", %d skipped", len(report.skipped))
+	}
+	b.WriteString("\n")
+	if len(report.failures) == 0 {
+		return b.String()
+	}
+
+	categories := make(map[string]int)
+	domains := make(map[string]int)
+	for _, f := range report.failures {
+		categories[failureCategory(f.err)]++
+		domain := "(no domain)"
+		if at := strings.LastIndex(f.recipient, "@"); at >= 0 {
+			domain = strings.ToLower(f.recipient[at+1:])
+		}
+		domains[domain]++
+	}
+
+	b.WriteString("\nFailures by error:\n")
+	for _, c := range sortedCounts(categories) {
+		fmt.Fprintf(&b, "- %s: %d\n", c.label, c.count)
+	}
+
+	b.WriteString("\nTop failing domains:\n")
+	for i, d := range sortedCounts(domains) {
+		if i == maxSummaryDomains {
+			break
+		}
+		fmt.Fprintf(&b, "- %s: %d\n", d.label, d.count)
+	}
+	return b.String()
+}
+
+// sendOpsSummary emails the batch report to OpsSummaryTo. Small batches, including
+// single sends, are skipped so the ops inbox only hears about bulk sends.
+func (e *EmailSender) sendOpsSummary(report *batchReport) {
+	if e.config.OpsSummaryTo == "" || report.total < e.config.OpsSummaryMinBatch {
+		return
+	}
+	if e.isSuppressed(e.config.OpsSummaryTo) {
+		log.Infof("Not sending ops summary for batch %s: %s is suppressed", report.id, e.config.OpsSummaryTo)
+		return
+	}
+
+	message := mail.NewV3Mail()
+	message.SetFrom(mail.NewEmail(e.config.SendGridFromName, e.config.SendGridFromEmail))
+	message.Subject = fmt.Sprintf("[CleanApp ops] Sent %d/%d (%s, batch %s)", report.sent(), report.total, report.kind, report.id)
+
+	p := mail.NewPersonalization()
+	p.AddTos(mail.NewEmail(e.config.OpsSummaryTo, e.config.OpsSummaryTo))
+	message.AddPersonalizations(p)
+	message.AddContent(mail.NewContent("text/plain", getOpsSummaryText(report)))
+
+	if err := e.deliver(nil, message, e.config.OpsSummaryTo, "Ops summary"); err != nil {
+		log.Warnf("Failed to send ops summary for batch %s: %v", report.id, err)
+	}
+}
@@ -0,0 +1,203 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestOpsSummaryAfterLargeBatch(t *testing.T) {
+	var mu sync.Mutex
+	var summaries []capturedMail
+	e := newTestSender(t, &config.Config{
+		OpsSummaryTo:       "ops@cleanapp.io",
+		OpsSummaryMinBatch: 4,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		var m capturedMail
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		to := m.Personalizations[0].To[0].Email
+		switch {
+		case to == "ops@cleanapp.io":
+			mu.Lock()
+			summaries = append(summaries, m)
+			mu.Unlock()
+		case strings.HasSuffix(to, "@bounce.example.com"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Invalid recipient","field":"personalizations.0.to"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	recipients := []string{"a@example.com", "b@bounce.example.com", "c@bounce.example.com", "d@example.com"}
+	if err := e.SendEmails(recipients, nil, nil); err == nil {
+		t.Fatal("expected an error for the failed recipients")
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 ops summary, got %d", len(summaries))
+	}
+
+	summary := summaries[0]
+	if !strings.HasPrefix(summary.Subject, "[CleanApp ops] Sent 2/4") {
+		t.Errorf("unexpected summary subject %q", summary.Subject)
+	}
+	body := summary.Content[0].Value
+	for _, want := range []string{"Sent 2/4; 2 failed", "- SendGrid status 400: 2", "- bounce.example.com: 2"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("summary body missing %q:\n%s", want, body)
+		}
+	}
+
+	// Batches below the minimum don't send a summary
+	if err := e.SendEmails([]string{"a@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(summaries) != 1 {
+		t.Errorf("expected no summary for a single send, got %d summaries", len(summaries))
+	}
+}
+
+func TestBatchOutcomes(t *testing.T) {
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		var m capturedMail
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if strings.HasSuffix(m.Personalizations[0].To[0].Email, "@bounce.example.com") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Invalid recipient"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	send := func(recipient string) error {
+		if recipient == "skip@example.com" {
+			return fmt.Errorf("%w: test", errSkipped)
+		}
+		return e.sendOneEmail(nil, Recipient{Email: recipient}, nil, nil)
+	}
+
+	recipients := []string{"ok@example.com", "not an address", "skip@example.com", "fail@bounce.example.com"}
+	err := e.runBatch(&batch{id: "batch-1"}, "email", "emails", recipients, send)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	got := [4]int{batchErr.Sent, batchErr.Invalid, batchErr.Skipped, batchErr.Failed}
+	if want := [4]int{1, 1, 1, 1}; got != want || batchErr.Total != 4 {
+		t.Errorf("sent/invalid/skipped/failed = %v of %d, want %v of 4", got, batchErr.Total, want)
+	}
+	if want := "1/4 emails failed, 1 invalid, 1 skipped: sendgrid returned status 400"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %q, want prefix %q", err, want)
+	}
+	if got := failureCategory(err); got != "SendGrid status 400" {
+		t.Errorf("failureCategory() = %q, want the first failure's category", got)
+	}
+
+	// Skipped recipients alone don't fail the batch
+	if err := e.runBatch(&batch{id: "batch-2"}, "email", "emails", []string{"ok@example.com", "skip@example.com"}, send); err != nil {
+		t.Errorf("expected no error when recipients are only skipped, got %v", err)
+	}
+
+	// Without failures, invalid addresses are still reported
+	err = e.runBatch(&batch{id: "batch-3"}, "email", "emails", []string{"ok@example.com", "Name <a@example.com>"}, send)
+	if !errors.As(err, &batchErr) || batchErr.Invalid != 1 || batchErr.Failed != 0 {
+		t.Errorf("expected 1 invalid and no failures, got %v", err)
+	}
+}
+
+func TestSenderAddressSkipped(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{SendGridFromEmail: "info@cleanapp.io"}, captureSends(t, &sent))
+
+	if err := e.SendEmails([]string{"brand@example.com", "Info@CleanApp.io"}, nil, nil); err != nil {
+		t.Fatalf("expected skipping the From address not to fail the batch, got %v", err)
+	}
+	if len(sent) != 1 || sent[0].Personalizations[0].To[0].Email != "brand@example.com" {
+		t.Fatalf("expected only brand@example.com to be sent to, got %+v", sent)
+	}
+
+	// The skip is counted when the batch reports an error
+	var batchErr *BatchError
+	err := e.SendEmails([]string{"not an address", "info@cleanapp.io"}, nil, nil)
+	if !errors.As(err, &batchErr) || batchErr.Skipped != 1 || batchErr.Invalid != 1 {
+		t.Errorf("expected 1 skipped and 1 invalid recipient, got %v", err)
+	}
+}
+
+func TestBrandSenderAddressSkipped(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		SendGridFromEmail: "info@cleanapp.io",
+		Brands:            map[string]config.Brand{"acme": {FromEmail: "reports@acme.cleanapp.io"}},
+	}, captureSends(t, &sent))
+
+	var result SendResult
+	if err := e.SendEmails([]string{"brand@example.com", "Reports@Acme.CleanApp.io"}, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("expected skipping a brand From address not to fail the batch, got %v", err)
+	}
+	if len(sent) != 1 || sent[0].Personalizations[0].To[0].Email != "brand@example.com" {
+		t.Fatalf("expected only brand@example.com to be sent to, got %+v", sent)
+	}
+	if _, ok := result.Skipped["reports@acme.cleanapp.io"]; !ok {
+		t.Errorf("expected the brand From address skipped, got %v", result.Skipped)
+	}
+}
+
+func TestOverCapBatchRejectedBeforeSending(t *testing.T) {
+	var calls int
+	e := newTestSender(t, &config.Config{MaxBatchSize: 2}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+	err := e.SendEmailsWithAnalysis(recipients, nil, nil, goldenAnalysis())
+	if !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("expected ErrBatchTooLarge, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "3 recipients, over the maximum of 2") {
+		t.Errorf("expected the batch and cap sizes in the error, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected no send attempts for an over-cap batch, got %d", calls)
+	}
+
+	if err := e.SendEmails(recipients[:2], nil, nil); err != nil {
+		t.Fatalf("expected a batch at the cap to send, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 sends at the cap, got %d", calls)
+	}
+}
+
+func TestSendEmailsNormalizesAndDeduplicatesRecipients(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	err := e.SendEmails([]string{"a@example.com", " A@Example.com ", "", "not-an-email", "B@example.com", "a@example.com"}, nil, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError for the invalid addresses, got %v", err)
+	}
+	if batchErr.Total != 6 || batchErr.Sent != 2 || batchErr.Invalid != 2 || batchErr.Skipped != 2 || batchErr.Failed != 0 {
+		t.Errorf("expected 2 sent, 2 invalid and 2 duplicates skipped, got %+v", batchErr)
+	}
+
+	var got []string
+	for _, m := range sent {
+		got = append(got, m.Personalizations[0].To[0].Email)
+	}
+	if fmt.Sprint(got) != "[a@example.com b@example.com]" {
+		t.Errorf("expected sends to the normalized addresses once each, got %v", got)
+	}
+}
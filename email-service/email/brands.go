@@ -0,0 +1,22 @@
+package email
+
+import (
+	"cmp"
+	"strings"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+// brandFor returns the configured brand of the analysis's BrandID with its unset fields
+// filled from the global settings. An analysis without a BrandID, or with one missing
+// from Brands, gets the global settings.
+func (e *EmailSender) brandFor(analysis *models.ReportAnalysis) config.Brand {
+	brand := e.config.Brands[strings.ToLower(analysis.BrandID)]
+	brand.FromName = cmp.Or(brand.FromName, e.config.SendGridFromName)
+	brand.FromEmail = cmp.Or(brand.FromEmail, e.config.SendGridFromEmail)
+	brand.DashboardURL = cmp.Or(brand.DashboardURL, e.config.BrandDashboardURL)
+	brand.LogoURL = cmp.Or(brand.LogoURL, e.config.ThemeLogoURL)
+	brand.OptOutURL = cmp.Or(brand.OptOutURL, e.config.OptOutURL)
+	return brand
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestBrandsUseOwnFromAndDashboard(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromName:  "CleanApp",
+		SendGridFromEmail: "info@cleanapp.io",
+		OptOutURL:         "https://cleanapp.io/opt-out",
+		BrandDashboardURL: "https://cleanapp.io/digital/{brand}",
+		Brands: map[string]config.Brand{
+			"acme":   {FromName: "Acme Alerts", FromEmail: "alerts@acme.example.com", DashboardURL: "https://acme.example.com/reports"},
+			"globex": {FromEmail: "reports@globex.example.com", DashboardURL: "https://globex.example.com/brands/{brand}", OptOutURL: "https://globex.example.com/opt-out"},
+		},
+	}, transport)
+
+	tests := []struct {
+		brandID                   string
+		fromName, fromEmail, link string
+		optOut                    string
+	}{
+		{"acme", "Acme Alerts", "alerts@acme.example.com", "https://acme.example.com/reports", "https://cleanapp.io/opt-out"},
+		{"GLOBEX", "CleanApp", "reports@globex.example.com", "https://globex.example.com/brands/acme", "https://globex.example.com/opt-out"},
+		{"", "CleanApp", "info@cleanapp.io", "https://cleanapp.io/digital/acme", "https://cleanapp.io/opt-out"},
+	}
+	for _, tt := range tests {
+		analysis := goldenAnalysis()
+		analysis.Classification = "digital"
+		analysis.BrandID = tt.brandID
+		if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis); err != nil {
+			t.Fatalf("brand %q: SendEmailsWithAnalysis returned error: %v", tt.brandID, err)
+		}
+		message := transport.messages[len(transport.messages)-1]
+		if message.From.Name != tt.fromName || message.From.Address != tt.fromEmail {
+			t.Errorf("brand %q: From = %s <%s>, want %s <%s>", tt.brandID, message.From.Name, message.From.Address, tt.fromName, tt.fromEmail)
+		}
+		for _, content := range message.Content {
+			if !strings.Contains(content.Value, tt.link) {
+				t.Errorf("brand %q: %s body has no dashboard link %s", tt.brandID, content.Type, tt.link)
+			}
+			if !strings.Contains(content.Value, tt.optOut) {
+				t.Errorf("brand %q: %s body has no opt-out link %s", tt.brandID, content.Type, tt.optOut)
+			}
+		}
+	}
+}
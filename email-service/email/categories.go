@@ -0,0 +1,56 @@
+package email
+
+import (
+	"fmt"
+	"slices"
+
+	"email-service/models"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// maxCategories is the number of categories SendGrid accepts on a message
+const maxCategories = 10
+
+// WithCategories tags every message of the send with categories, e.g. "weekly-digest",
+// instead of the category configured for the report's classification. Subject variant
+// categories are still added.
+func WithCategories(categories ...string) SendOption {
+	return func(o *sendOptions) {
+		o.categories = append(o.categories, categories...)
+	}
+}
+
+// applyCategories tags an analysis email so its performance can be segmented in
+// SendGrid's statistics: with the per-send categories or else the configured category of
+// the report's classification, plus the subject variant so open rates can be compared.
+// Empty and repeated categories are skipped and only SendGrid's first 10 kept.
+func (e *EmailSender) applyCategories(b *batch, message *mail.SGMailV3, analysis *models.ReportAnalysis, variant string) {
+	categories := []string{e.config.Categories[analysis.Classification]}
+	if b != nil && len(b.categories) > 0 {
+		categories = b.categories
+	}
+	for _, category := range append(slices.Clip(categories), variant) {
+		if category == "" || slices.Contains(message.Categories, category) {
+			continue
+		}
+		if len(message.Categories) == maxCategories {
+			break
+		}
+		message.AddCategories(category)
+	}
+}
+
+// setAnalysisCustomArgs sets the report ID, or its sequence number without one, and the
+// classification as custom args, which SendGrid echoes in event webhooks
+func setAnalysisCustomArgs(p *mail.Personalization, analysis *models.ReportAnalysis) {
+	switch {
+	case analysis.ReportID != "":
+		p.SetCustomArg("report_id", analysis.ReportID)
+	case analysis.Seq > 0:
+		p.SetCustomArg("report_id", fmt.Sprintf("%d", analysis.Seq))
+	}
+	if analysis.Classification != "" {
+		p.SetCustomArg("classification", analysis.Classification)
+	}
+}
@@ -0,0 +1,71 @@
+package email
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func newCategoriesSender(t *testing.T, sent *[]capturedMail) *EmailSender {
+	return newTestSender(t, &config.Config{
+		Categories: map[string]string{"digital": "brand-alert", "physical": "physical-report"},
+	}, captureSends(t, sent))
+}
+
+func TestDigitalEmailTaggedBrandAlert(t *testing.T) {
+	var sent []capturedMail
+	e := newCategoriesSender(t, &sent)
+
+	analysis := &models.ReportAnalysis{ReportID: "rpt-42", Classification: "digital", BrandName: "acme", Title: "Checkout broken"}
+	if err := e.SendEmailsWithAnalysis([]string{"brand@acme.com"}, nil, nil, analysis); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	if !slices.Equal(sent[0].Categories, []string{"brand-alert"}) {
+		t.Errorf("categories = %v, want [brand-alert]", sent[0].Categories)
+	}
+	args := sent[0].Personalizations[0].CustomArgs
+	if args["report_id"] != "rpt-42" || args["classification"] != "digital" {
+		t.Errorf("custom args = %v, want report_id rpt-42 and classification digital", args)
+	}
+}
+
+func TestBatchedEmailTaggedByClassification(t *testing.T) {
+	var sent []capturedMail
+	e := newCategoriesSender(t, &sent)
+
+	if err := e.SendBatch(context.Background(), []string{"a@example.com", "b@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendBatch returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 batched send, got %d", len(sent))
+	}
+	if !slices.Equal(sent[0].Categories, []string{"physical-report"}) {
+		t.Errorf("categories = %v, want [physical-report]", sent[0].Categories)
+	}
+	for _, p := range sent[0].Personalizations {
+		if p.CustomArgs["report_id"] != "12345" || p.CustomArgs["classification"] != "physical" {
+			t.Errorf("custom args = %v, want report_id 12345 and classification physical", p.CustomArgs)
+		}
+	}
+}
+
+func TestWithCategoriesOverridesClassification(t *testing.T) {
+	var sent []capturedMail
+	e := newCategoriesSender(t, &sent)
+	e.config.SubjectVariants = []string{"{subject}", "{subject}!"}
+
+	analysis := &models.ReportAnalysis{Classification: "digital", BrandName: "acme", Title: "Checkout broken"}
+	if err := e.SendEmailsWithAnalysis([]string{"brand@acme.com"}, nil, nil, analysis, WithCategories("weekly-review", "", "weekly-review")); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	_, variant := e.subjectVariant("brand@acme.com", "", analysis)
+	if want := []string{"weekly-review", variant}; !slices.Equal(sent[0].Categories, want) {
+		t.Errorf("categories = %v, want %v", sent[0].Categories, want)
+	}
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"strings"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+)
+
+// normalizeClassification returns the analysis with its classification lowercased, or
+// replaced by UnknownClassification when empty or unrecognized so the email doesn't
+// fall through to the physical template with a blank type. The caller's analysis is
+// left untouched.
+func (e *EmailSender) normalizeClassification(analysis *models.ReportAnalysis) *models.ReportAnalysis {
+	classification := strings.ToLower(strings.TrimSpace(analysis.Classification))
+	if classification != "physical" && classification != "digital" {
+		fallback := e.config.UnknownClassification
+		if fallback == "" {
+			fallback = "general"
+		}
+		log.Warnf("Report %d has unrecognized classification %q, rendering it as %s", analysis.Seq, analysis.Classification, fallback)
+		classification = fallback
+	}
+	if classification == analysis.Classification {
+		return analysis
+	}
+
+	normalized := *analysis
+	normalized.Classification = classification
+	return &normalized
+}
@@ -0,0 +1,57 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestNormalizeClassification(t *testing.T) {
+	tests := []struct {
+		classification string
+		fallback       string
+		want           string
+	}{
+		{"digital", "", "digital"},
+		{" Physical ", "", "physical"},
+		{"", "", "general"},
+		{"%$garbage", "", "general"},
+		{"", "physical", "physical"},
+		{"unknown-type", "digital", "digital"},
+	}
+
+	for _, tt := range tests {
+		e := &EmailSender{config: &config.Config{UnknownClassification: tt.fallback}}
+		analysis := &models.ReportAnalysis{Classification: tt.classification}
+		if got := e.normalizeClassification(analysis).Classification; got != tt.want {
+			t.Errorf("normalizeClassification(%q) with fallback %q = %q, want %q", tt.classification, tt.fallback, got, tt.want)
+		}
+		if analysis.Classification != tt.classification {
+			t.Errorf("normalizeClassification(%q) modified the caller's analysis", tt.classification)
+		}
+	}
+}
+
+func TestUnknownClassificationRendersNeutralType(t *testing.T) {
+	for _, classification := range []string{"", "garbage"} {
+		var sent []capturedMail
+		e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+		analysis := goldenAnalysis()
+		analysis.Classification = classification
+		if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis); err != nil {
+			t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+		}
+		for _, content := range sent[0].Content {
+			want := "Type: general Issue"
+			if content.Type == "text/html" {
+				want = "<strong>Type:</strong> general</p>"
+			}
+			if !strings.Contains(content.Value, want) {
+				t.Errorf("classification %q: expected %s to contain %q", classification, content.Type, want)
+			}
+		}
+	}
+}
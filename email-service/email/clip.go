@@ -0,0 +1,95 @@
+package email
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// Markers around HTML sections that can be dropped when a message would be clipped
+const (
+	clipOptionalStart = "<!--clip:optional-->"
+	clipOptionalEnd   = "<!--/clip:optional-->"
+)
+
+var (
+	// interTagWhitespace matches template indentation between tags; it always contains
+	// a newline, so collapsing it to one newline doesn't change the rendered spacing
+	interTagWhitespace = regexp.MustCompile(`>[ \t]*\n\s*<`)
+	optionalSection    = regexp.MustCompile(`(?s)` + regexp.QuoteMeta(clipOptionalStart) + `.*?` + regexp.QuoteMeta(clipOptionalEnd))
+	unsubscribeLine    = regexp.MustCompile(`(?s)<p>To unsubscribe from these emails.*?</p>`)
+)
+
+// clipOptional marks section as droppable by shrinkHTML; an empty section stays empty
+func clipOptional(section string) string {
+	if section == "" {
+		return ""
+	}
+	return clipOptionalStart + section + clipOptionalEnd
+}
+
+// checkHTMLClipping warns when the message's HTML is over HTMLClipWarnBytes, since
+// Gmail clips messages past ~102KB behind a "View entire message" link and the
+// unsubscribe footer at the bottom goes with it. With HTMLClipStrip set the HTML is
+// shrunk in place before sending.
+func (e *EmailSender) checkHTMLClipping(message *mail.SGMailV3, recipient, kind string) {
+	limit := e.config.HTMLClipWarnBytes
+	if limit <= 0 {
+		return
+	}
+	for _, content := range message.Content {
+		if content.Type != "text/html" || len(content.Value) <= limit {
+			continue
+		}
+		fields := log.Fields{
+			"kind":      kind,
+			"recipient": recipient,
+			"bytes":     len(content.Value),
+			"limit":     limit,
+		}
+		if !e.config.HTMLClipStrip {
+			log.WithFields(fields).Warn("HTML is near the Gmail clipping size; the unsubscribe footer may be hidden")
+			continue
+		}
+
+		shrunk, steps := shrinkHTML(content.Value, limit)
+		content.Value = shrunk
+		fields["shrunk_bytes"] = len(shrunk)
+		fields["steps"] = strings.Join(steps, ",")
+		log.WithFields(fields).Warn("HTML was near the Gmail clipping size and was shrunk to keep the unsubscribe footer visible")
+	}
+}
+
+// shrinkHTML reduces body below limit bytes, least destructive step first: collapsing
+// template indentation, dropping optional sections, and finally copying the unsubscribe
+// line to the top of the body so it's visible even if the rest is clipped. It returns
+// the HTML and the steps applied.
+func shrinkHTML(body string, limit int) (string, []string) {
+	var steps []string
+
+	body = interTagWhitespace.ReplaceAllString(body, ">\n<")
+	steps = append(steps, "whitespace")
+	if len(body) <= limit {
+		return body, steps
+	}
+
+	if optionalSection.MatchString(body) {
+		body = optionalSection.ReplaceAllString(body, "")
+		steps = append(steps, "optional_sections")
+		if len(body) <= limit {
+			return body, steps
+		}
+	}
+
+	if unsubscribe := unsubscribeLine.FindString(body); unsubscribe != "" {
+		if i := strings.Index(body, "<body>"); i >= 0 {
+			i += len("<body>")
+			top := `<div style="font-size: 0.85em; color: #999;">` + unsubscribe + "</div>"
+			body = body[:i] + top + body[i:]
+			steps = append(steps, "unsubscribe_on_top")
+		}
+	}
+	return body, steps
+}
@@ -0,0 +1,55 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestShrinkHTMLKeepsUnsubscribeVisible(t *testing.T) {
+	filler := strings.Repeat("x", 500)
+	body := "<html>\n<body>\n    <div>intro</div>\n    " + clipOptional("<div>"+filler+"</div>") +
+		"\n    <div>" + filler + "</div>\n    <div class=\"footer\">\n        <p>To unsubscribe from these emails, please <a href=\"https://example.com/opt-out\">click here</a></p>\n    </div>\n</body>\n</html>"
+
+	compact, steps := shrinkHTML(body, len(body)-10)
+	if strings.Join(steps, ",") != "whitespace" || !strings.Contains(compact, filler) {
+		t.Errorf("expected only whitespace collapsed, got steps %v", steps)
+	}
+
+	dropped, steps := shrinkHTML(body, len(body)-400)
+	if strings.Join(steps, ",") != "whitespace,optional_sections" || strings.Count(dropped, filler) != 1 {
+		t.Errorf("expected the optional section dropped, got steps %v", steps)
+	}
+
+	hoisted, steps := shrinkHTML(body, 100)
+	if strings.Join(steps, ",") != "whitespace,optional_sections,unsubscribe_on_top" {
+		t.Errorf("expected the unsubscribe line moved up, got steps %v", steps)
+	}
+	if top := strings.Index(hoisted, "To unsubscribe"); top < 0 || top > strings.Index(hoisted, filler) {
+		t.Errorf("expected the unsubscribe line ahead of the content, got %s", hoisted)
+	}
+}
+
+func TestOversizedHTMLStrippedBeforeSending(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{HTMLClipWarnBytes: 1000, HTMLClipStrip: true}, captureSends(t, &sent))
+
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	for _, content := range sent[0].Content {
+		if content.Type != "text/html" {
+			continue
+		}
+		if strings.Contains(content.Value, clipOptionalStart) || strings.Contains(content.Value, "Trash is cash") {
+			t.Error("expected optional sections dropped from oversized HTML")
+		}
+		if !strings.Contains(content.Value, "<body><div style=\"font-size: 0.85em; color: #999;\"><p>To unsubscribe") {
+			t.Error("expected the unsubscribe line at the top of the body")
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+)
+
+// coalescer buffers queued analysis emails per brand so a burst of reports about one
+// brand goes out as a single digest. The zero value is ready to use.
+type coalescer struct {
+	mu      sync.Mutex
+	pending map[string]*pendingBrand // Keyed by lowercase brand name
+	closed  bool
+	timers  sync.WaitGroup // Flushes started by a timer that may still be running
+}
+
+// pendingBrand is the buffer of one brand waiting to be flushed
+type pendingBrand struct {
+	reports    []pendingReport
+	recipients []string // Union of the reports' recipients, in arrival order
+	seen       map[string]bool
+	timer      *time.Timer
+}
+
+// pendingReport is one queued analysis email
+type pendingReport struct {
+	recipients            []string
+	reportImage, mapImage []byte
+	analysis              *models.ReportAnalysis
+	opts                  []SendOption
+}
+
+// QueueEmailWithAnalysis sends an analysis email like SendEmailsWithAnalysis, except
+// that with CoalesceEnabled it is buffered per brand: the buffer is flushed
+// CoalesceInterval after its first report, or as soon as it holds CoalesceMaxReports.
+// A flush of one report sends it as usual. A flush of several sends each recipient of
+// any of them one digest, as by SendDigest, with a card and the images of every report
+// addressed to them. A recipient's digest goes out with the send options of the first
+// of those reports, except that every report's WithResult and WithResultFunc get the
+// outcome of its own recipients. Flushes started by the interval report errors to the
+// log only; call Close to flush what's left on shutdown. Reports without a brand, or
+// under the brand's severity floor, aren't buffered.
+func (e *EmailSender) QueueEmailWithAnalysis(recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	return e.QueueEmailWithAnalysisContext(context.Background(), recipients, reportImage, mapImage, analysis, opts...)
+}
+
+// QueueEmailWithAnalysisContext is QueueEmailWithAnalysis with reports that aren't
+// buffered sent bounded by ctx, as by SendEmailsWithAnalysisContext. A buffered report
+// outlives ctx; it is sent when its brand's buffer is flushed.
+func (e *EmailSender) QueueEmailWithAnalysisContext(ctx context.Context, recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	if analysis == nil {
+		return e.SendEmailsWithAnalysisContext(ctx, recipients, reportImage, mapImage, nil, opts...)
+	}
+	key := strings.ToLower(analysis.BrandName)
+	if !e.config.CoalesceEnabled || key == "" || analysis.SeverityLevel < e.minSeverity(analysis) {
+		return e.SendEmailsWithAnalysisContext(ctx, recipients, reportImage, mapImage, analysis, opts...)
+	}
+
+	c := &e.coalesce
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return e.SendEmailsWithAnalysisContext(ctx, recipients, reportImage, mapImage, analysis, opts...)
+	}
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingBrand)
+	}
+	p, ok := c.pending[key]
+	if !ok {
+		p = &pendingBrand{seen: make(map[string]bool)}
+		c.pending[key] = p
+		c.timers.Add(1)
+		p.timer = time.AfterFunc(e.config.CoalesceInterval, func() {
+			defer c.timers.Done()
+			if err := e.flushBrand(key, p); err != nil {
+				log.Errorf("Failed to send coalesced emails for brand %s: %v", analysis.BrandName, err)
+			}
+		})
+	}
+	p.add(pendingReport{recipients, reportImage, mapImage, analysis, opts})
+	queued := len(p.reports)
+	full := queued >= e.config.CoalesceMaxReports
+	if full && p.timer.Stop() {
+		c.timers.Done()
+	}
+	c.mu.Unlock()
+
+	if full {
+		return e.flushBrand(key, p)
+	}
+	log.Infof("Queued email with analysis for report %d, brand %s (%d pending)", analysis.Seq, analysis.BrandName, queued)
+	return nil
+}
+
+// Close flushes every brand's pending buffer and waits for flushes already under way.
+// Emails queued after Close are sent immediately.
+func (e *EmailSender) Close() error {
+	c := &e.coalesce
+	c.mu.Lock()
+	c.closed = true
+	pending := c.pending
+	for _, p := range pending {
+		if p.timer.Stop() {
+			c.timers.Done()
+		}
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for key, p := range pending {
+		errs = append(errs, e.flushBrand(key, p))
+	}
+	c.timers.Wait()
+	return errors.Join(errs...)
+}
+
+// add appends a report to the buffer, merging its recipients into the union
+func (p *pendingBrand) add(r pendingReport) {
+	p.reports = append(p.reports, r)
+	for _, recipient := range r.recipients {
+		if key := strings.ToLower(recipient); !p.seen[key] {
+			p.seen[key] = true
+			p.recipients = append(p.recipients, recipient)
+		}
+	}
+}
+
+// flushBrand sends brand key's buffer p unless another flush already took it
+func (e *EmailSender) flushBrand(key string, p *pendingBrand) error {
+	c := &e.coalesce
+	c.mu.Lock()
+	if c.pending[key] != p {
+		c.mu.Unlock()
+		return nil
+	}
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	if len(p.reports) == 1 {
+		r := p.reports[0]
+		return e.SendEmailsWithAnalysis(r.recipients, r.reportImage, r.mapImage, r.analysis, r.opts...)
+	}
+	log.Infof("Coalesced %d reports for brand %s into digests to %d recipients", len(p.reports), p.reports[0].analysis.BrandName, len(p.recipients))
+	return e.sendCoalesced(p)
+}
+
+// sendCoalesced sends each recipient in buffer p a digest of the reports addressed to
+// them, then hands every report's options the outcome of that report's recipients
+func (e *EmailSender) sendCoalesced(p *pendingBrand) error {
+	results := make([]SendResult, len(p.reports))
+	for i := range results {
+		results[i] = SendResult{
+			Failed:    make(map[string]error),
+			Skipped:   make(map[string]error),
+			Scheduled: make(map[string]time.Time),
+			Accounts:  make(map[string]string),
+		}
+	}
+
+	var errs []error
+	for _, recipient := range p.recipients {
+		var items []DigestItem
+		var reports []int
+		for i, r := range p.reports {
+			if slices.ContainsFunc(r.recipients, func(addr string) bool { return strings.EqualFold(addr, recipient) }) {
+				items = append(items, DigestItem{Analysis: r.analysis, ReportImage: r.reportImage, MapImage: r.mapImage})
+				reports = append(reports, i)
+			}
+		}
+
+		// The digest's own outcome is collected here, in place of the first report's
+		// result options, and merged into the result of every report it covers
+		var digest SendResult
+		opts := append(slices.Clone(p.reports[reports[0]].opts), func(o *sendOptions) {
+			o.result, o.resultFunc = &digest, nil
+		})
+		if err := e.SendDigest(recipient, items, opts...); err != nil {
+			errs = append(errs, err)
+		}
+		for _, i := range reports {
+			results[i].addDigest(&digest)
+		}
+	}
+
+	for i, r := range p.reports {
+		var o sendOptions
+		for _, opt := range r.opts {
+			opt(&o)
+		}
+		if o.result != nil {
+			*o.result = results[i]
+		}
+		if o.resultFunc != nil {
+			o.resultFunc(&results[i])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addDigest merges the outcome of a coalesced digest into r
+func (r *SendResult) addDigest(digest *SendResult) {
+	r.Succeeded = append(r.Succeeded, digest.Succeeded...)
+	maps.Copy(r.Failed, digest.Failed)
+	maps.Copy(r.Skipped, digest.Skipped)
+	maps.Copy(r.Scheduled, digest.Scheduled)
+	maps.Copy(r.Accounts, digest.Accounts)
+	r.Duration = max(r.Duration, digest.Duration)
+	r.err = errors.Join(r.err, digest.err)
+}
@@ -0,0 +1,163 @@
+package email
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"email-service/config"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+func TestQueueSendsImmediatelyWhenDisabled(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	if err := e.QueueEmailWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("QueueEmailWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 || sent[0].Subject != e.BuildSubject(goldenAnalysis()) {
+		t.Errorf("expected the analysis email sent immediately, got %+v", sent)
+	}
+}
+
+func TestQueueCoalescesBrandUntilClose(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		CoalesceEnabled:    true,
+		CoalesceInterval:   time.Hour,
+		CoalesceMaxReports: 10,
+	}, transport)
+
+	results := make([]SendResult, 3)
+	for i, recipients := range [][]string{{"a@example.com"}, {"b@example.com", "A@example.com"}, {"a@example.com"}} {
+		analysis := goldenAnalysis()
+		analysis.Seq = int64(100 + i)
+		analysis.Title = fmt.Sprintf("Report %d", 100+i)
+		if err := e.QueueEmailWithAnalysis(recipients, encodeTestImage(t, 40, 30, "jpeg"), nil, analysis, WithResult(&results[i])); err != nil {
+			t.Fatalf("QueueEmailWithAnalysis returned error: %v", err)
+		}
+	}
+	if len(transport.messages) != 0 {
+		t.Fatalf("expected nothing sent before the flush, got %d", len(transport.messages))
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if len(transport.messages) != 2 {
+		t.Fatalf("expected one digest per unique recipient, got %d sends", len(transport.messages))
+	}
+	// Each digest has a card and the image of every report addressed to its recipient
+	for _, want := range []struct {
+		recipient string
+		reports   []int
+	}{{"a@example.com", []int{100, 101, 102}}, {"b@example.com", []int{101}}} {
+		i := slices.IndexFunc(transport.messages, func(m *mail.SGMailV3) bool {
+			return m.Personalizations[0].To[0].Address == want.recipient
+		})
+		if i < 0 {
+			t.Fatalf("expected a digest to %s", want.recipient)
+		}
+		message := transport.messages[i]
+		if prefix := fmt.Sprintf("CleanApp digest: %d reports", len(want.reports)); !strings.HasPrefix(message.Subject, prefix) {
+			t.Errorf("digest subject to %s = %q, want it to start with %q", want.recipient, message.Subject, prefix)
+		}
+		if len(message.Attachments) != len(want.reports) {
+			t.Errorf("digest to %s has %d images, want %d", want.recipient, len(message.Attachments), len(want.reports))
+		}
+		for _, seq := range want.reports {
+			if !strings.Contains(message.Content[0].Value, fmt.Sprintf("Report %d", seq)) {
+				t.Errorf("digest to %s is missing report %d", want.recipient, seq)
+			}
+		}
+	}
+
+	// Every report's WithResult gets the outcome of its own recipients
+	for i, want := range [][]string{{"a@example.com"}, {"a@example.com", "b@example.com"}, {"a@example.com"}} {
+		got := slices.Sorted(slices.Values(results[i].Succeeded))
+		if !slices.Equal(got, want) {
+			t.Errorf("report %d Succeeded = %v, want %v", 100+i, got, want)
+		}
+	}
+
+	// After Close, queued emails are no longer buffered
+	if err := e.QueueEmailWithAnalysis([]string{"a@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("QueueEmailWithAnalysis after Close returned error: %v", err)
+	}
+	if len(transport.messages) != 3 || transport.messages[2].Subject != e.BuildSubject(goldenAnalysis()) {
+		t.Errorf("expected an immediate analysis email after Close, got %d sends", len(transport.messages))
+	}
+}
+
+func TestQueueCallsResultFuncOnFlush(t *testing.T) {
+	e := NewEmailSenderWithTransport(&config.Config{
+		CoalesceEnabled:    true,
+		CoalesceInterval:   time.Hour,
+		CoalesceMaxReports: 10,
+	}, &fakeTransport{})
+
+	var calls []*SendResult
+	record := WithResultFunc(func(result *SendResult) { calls = append(calls, result) })
+	for range 2 {
+		if err := e.QueueEmailWithAnalysis([]string{"a@example.com"}, nil, nil, goldenAnalysis(), record); err != nil {
+			t.Fatalf("QueueEmailWithAnalysis returned error: %v", err)
+		}
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no result before the flush, got %d", len(calls))
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected the result func called once per queued report, got %d calls", len(calls))
+	}
+	for _, result := range calls {
+		if !slices.Equal(result.Succeeded, []string{"a@example.com"}) {
+			t.Errorf("Succeeded = %v, want a@example.com", result.Succeeded)
+		}
+	}
+}
+
+func TestQueueFlushesAtMaxReports(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		CoalesceEnabled:    true,
+		CoalesceInterval:   time.Hour,
+		CoalesceMaxReports: 2,
+	}, captureSends(t, &sent))
+
+	for range 2 {
+		if err := e.QueueEmailWithAnalysis([]string{"a@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+			t.Fatalf("QueueEmailWithAnalysis returned error: %v", err)
+		}
+	}
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].Subject, "CleanApp digest: 2 reports") {
+		t.Errorf("expected the digest sent once the buffer filled, got %+v", sent)
+	}
+}
+
+func TestQueueFlushesAfterInterval(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		CoalesceEnabled:    true,
+		CoalesceInterval:   10 * time.Millisecond,
+		CoalesceMaxReports: 10,
+	}, captureSends(t, &sent))
+
+	if err := e.QueueEmailWithAnalysis([]string{"a@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("QueueEmailWithAnalysis returned error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	// Close waits for the timer's flush, so sent is safe to read afterwards
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if len(sent) != 1 || sent[0].Subject != e.BuildSubject(goldenAnalysis()) {
+		t.Errorf("expected a lone report sent as a normal analysis email, got %+v", sent)
+	}
+}
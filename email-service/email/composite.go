@@ -0,0 +1,86 @@
+package email
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	"email-service/config"
+
+	"github.com/apex/log"
+	"golang.org/x/image/draw"
+)
+
+// compositeCaptionHeight is the white band under each half that holds its caption
+const compositeCaptionHeight = 20
+
+// compositeImages combines the report and map into a single captioned image when
+// CompositeImages is set, for clients that render several inline images poorly. It
+// returns the composite as the report image and a nil map; the images are returned
+// unchanged when disabled, when either is missing, or when they can't be decoded.
+func (e *EmailSender) compositeImages(reportImg, mapImg *inlineImage) (*inlineImage, *inlineImage) {
+	if !e.config.CompositeImages || reportImg == nil || mapImg == nil {
+		return reportImg, mapImg
+	}
+
+	report, _, err := image.Decode(bytes.NewReader(reportImg.raw))
+	if err != nil {
+		log.Warnf("Failed to decode report image for compositing, sending separately: %v", err)
+		return reportImg, mapImg
+	}
+	location, _, err := image.Decode(bytes.NewReader(mapImg.raw))
+	if err != nil {
+		log.Warnf("Failed to decode map image for compositing, sending separately: %v", err)
+		return reportImg, mapImg
+	}
+
+	stacked := e.config.CompositeLayout == config.CompositeStacked
+	reportRect, mapRect, bounds := compositeLayout(report.Bounds(), location.Bounds(), stacked)
+
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, reportRect, report, report.Bounds(), draw.Over, nil)
+	draw.CatmullRom.Scale(dst, mapRect, location, location.Bounds(), draw.Over, nil)
+	for _, caption := range []struct {
+		text string
+		at   image.Rectangle
+	}{{"Report", reportRect}, {"Location map", mapRect}} {
+		if err := e.AddLabel(dst, caption.text, caption.at.Min.X+5, caption.at.Max.Y+15, LabelStyle{}); err != nil {
+			log.Warnf("Failed to caption composite image: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: resizeJPEGQuality}); err != nil {
+		log.Warnf("Failed to encode composite image, sending separately: %v", err)
+		return reportImg, mapImg
+	}
+
+	log.Infof("Composited report and map into one %dx%d image (%d bytes)", bounds.Dx(), bounds.Dy(), buf.Len())
+	composite := encodeInlineImage(capImageBytes(buf.Bytes(), e.config.MaxAttachmentBytes, "composite"))
+	composite.composite = true
+	return composite, nil
+}
+
+// compositeLayout scales the report and map to a common height side by side, or to a
+// common width when stacked, leaving a caption band under each. It returns where each
+// image is drawn and the bounds of the whole composite.
+func compositeLayout(report, location image.Rectangle, stacked bool) (reportRect, mapRect, bounds image.Rectangle) {
+	if stacked {
+		width := max(report.Dx(), location.Dx())
+		reportHeight := max(1, report.Dy()*width/report.Dx())
+		mapHeight := max(1, location.Dy()*width/location.Dx())
+		reportRect = image.Rect(0, 0, width, reportHeight)
+		mapTop := reportHeight + compositeCaptionHeight
+		mapRect = image.Rect(0, mapTop, width, mapTop+mapHeight)
+		return reportRect, mapRect, image.Rect(0, 0, width, mapRect.Max.Y+compositeCaptionHeight)
+	}
+
+	height := max(report.Dy(), location.Dy())
+	reportWidth := max(1, report.Dx()*height/report.Dy())
+	mapWidth := max(1, location.Dx()*height/location.Dy())
+	reportRect = image.Rect(0, 0, reportWidth, height)
+	mapRect = image.Rect(reportWidth, 0, reportWidth+mapWidth, height)
+	return reportRect, mapRect, image.Rect(0, 0, mapRect.Max.X, height+compositeCaptionHeight)
+}
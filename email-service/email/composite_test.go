@@ -0,0 +1,81 @@
+package email
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestCompositeImagesLayout(t *testing.T) {
+	tests := []struct {
+		layout                string
+		wantWidth, wantHeight int
+	}{
+		// Report 400x300 and map 200x200, scaled to a common height of 300
+		{config.CompositeSideBySide, 400 + 300, 300 + compositeCaptionHeight},
+		// Scaled to a common width of 400
+		{config.CompositeStacked, 400, 300 + 400 + 2*compositeCaptionHeight},
+	}
+
+	for _, tt := range tests {
+		e := &EmailSender{config: &config.Config{CompositeImages: true, CompositeLayout: tt.layout}}
+		composite, mapImg := e.compositeImages(
+			encodeInlineImage(encodeTestImage(t, 400, 300, "jpeg")),
+			encodeInlineImage(encodeTestImage(t, 200, 200, "png")),
+		)
+		if mapImg != nil || composite == nil || !composite.composite {
+			t.Fatalf("%s: expected a single composite image", tt.layout)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(composite.raw))
+		if err != nil {
+			t.Fatalf("%s: failed to decode composite: %v", tt.layout, err)
+		}
+		if format != "jpeg" || cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight {
+			t.Errorf("%s: composite is %s %dx%d, want jpeg %dx%d", tt.layout, format, cfg.Width, cfg.Height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func TestCompositeImagesDisabledOrMissing(t *testing.T) {
+	report := encodeInlineImage(encodeTestImage(t, 40, 30, "jpeg"))
+	mapImg := encodeInlineImage(encodeTestImage(t, 20, 20, "png"))
+
+	off := &EmailSender{config: &config.Config{}}
+	if r, m := off.compositeImages(report, mapImg); r != report || m != mapImg {
+		t.Error("expected images unchanged when compositing is disabled")
+	}
+
+	on := &EmailSender{config: &config.Config{CompositeImages: true}}
+	if r, m := on.compositeImages(report, nil); r != report || m != nil {
+		t.Error("expected the report unchanged without a map")
+	}
+	if r, m := on.compositeImages(&inlineImage{raw: []byte("not an image")}, mapImg); r.composite || m != mapImg {
+		t.Error("expected images unchanged when the report can't be decoded")
+	}
+}
+
+func TestCompositeImageSentAsSingleAttachment(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{CompositeImages: true}, captureSends(t, &sent))
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+		encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), goldenAnalysis())
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	if len(sent[0].Attachments) != 1 || !strings.HasPrefix(sent[0].Attachments[0].ContentID, compositeImgCid+"-") {
+		t.Fatalf("expected only the composite attachment, got %+v", sent[0].Attachments)
+	}
+	cid := sent[0].Attachments[0].ContentID
+	for _, content := range sent[0].Content {
+		if content.Type == "text/html" && (!strings.Contains(content.Value, "cid:"+cid) || strings.Contains(content.Value, "cid:"+mapImgCid)) {
+			t.Error("expected the HTML to reference only the composite image")
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package email
+
+import (
+	"fmt"
+	"math"
+
+	"email-service/models"
+)
+
+// analysisConfidence returns the AI confidence (0-1) for the analysis. When the model
+// doesn't report one it is derived from how decisive the probabilities are (0.5 being
+// least decisive). ok is false when there's nothing to derive it from.
+func analysisConfidence(analysis *models.ReportAnalysis) (confidence float64, ok bool) {
+	if analysis.Confidence > 0 {
+		return math.Min(analysis.Confidence, 1), true
+	}
+	if analysis.LitterProbability == 0 && analysis.HazardProbability == 0 {
+		return 0, false
+	}
+	decisiveness := math.Max(math.Abs(analysis.LitterProbability-0.5), math.Abs(analysis.HazardProbability-0.5))
+	return math.Min(decisiveness*2, 1), true
+}
+
+// getConfidenceBadgeHtml returns the colored confidence badge shown next to the title,
+// or an empty string when disabled or no confidence is available
+func (e *EmailSender) getConfidenceBadgeHtml(analysis *models.ReportAnalysis) string {
+	if !e.config.ShowConfidenceBadge {
+		return ""
+	}
+	confidence, ok := analysisConfidence(analysis)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(` <span class="%s" style="display: inline-block; color: white; padding: 2px 10px; border-radius: 12px; font-size: 0.8em; font-weight: bold;">%s confidence</span>`,
+		e.getGaugeColor(confidence), e.getGaugeLabel(confidence))
+}
+
+// getConfidenceText returns the confidence line for the plain text email, or an empty
+// string when disabled or no confidence is available
+func (e *EmailSender) getConfidenceText(analysis *models.ReportAnalysis) string {
+	if !e.config.ShowConfidenceBadge {
+		return ""
+	}
+	confidence, ok := analysisConfidence(analysis)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("\nAI Confidence: %s (%.0f%%)", e.getGaugeLabel(confidence), confidence*100)
+}
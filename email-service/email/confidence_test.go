@@ -0,0 +1,73 @@
+package email
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestAnalysisConfidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		analysis models.ReportAnalysis
+		want     float64
+		wantOK   bool
+	}{
+		{"reported", models.ReportAnalysis{Confidence: 0.9, LitterProbability: 0.5}, 0.9, true},
+		{"reported above 1", models.ReportAnalysis{Confidence: 1.5}, 1, true},
+		{"derived from probabilities", models.ReportAnalysis{LitterProbability: 0.9, HazardProbability: 0.6}, 0.8, true},
+		{"least decisive", models.ReportAnalysis{LitterProbability: 0.5, HazardProbability: 0.5}, 0, true},
+		{"missing", models.ReportAnalysis{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := analysisConfidence(&tt.analysis)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("analysisConfidence() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestConfidenceBadge(t *testing.T) {
+	analysis := goldenAnalysis()
+	analysis.Confidence = 0.85
+
+	e := &EmailSender{config: &config.Config{ShowConfidenceBadge: true}}
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(html, `<span class="high"`) || !strings.Contains(html, "High confidence</span>") {
+		t.Error("expected a high confidence badge in the HTML")
+	}
+	text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(text, "AI Confidence: High (85%)") {
+		t.Error("expected the confidence line in the text")
+	}
+
+	// Off unless ShowConfidenceBadge is set
+	e.config.ShowConfidenceBadge = false
+	html = e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	text = e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if strings.Contains(html, "confidence</span>") || strings.Contains(text, "AI Confidence") {
+		t.Error("expected no confidence badge with ShowConfidenceBadge off")
+	}
+}
+
+func TestConfidenceBadgeWithoutConfidence(t *testing.T) {
+	e := &EmailSender{config: &config.Config{ShowConfidenceBadge: true}}
+	analysis := goldenAnalysis()
+	analysis.Confidence, analysis.LitterProbability, analysis.HazardProbability = 0, 0, 0
+
+	if badge := e.getConfidenceBadgeHtml(analysis); badge != "" {
+		t.Errorf("expected no badge without a confidence, got %q", badge)
+	}
+	if line := e.getConfidenceText(analysis); line != "" {
+		t.Errorf("expected no confidence line without a confidence, got %q", line)
+	}
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if strings.Contains(html, "confidence</span>") {
+		t.Error("expected the HTML to render without a badge")
+	}
+}
@@ -0,0 +1,137 @@
+package email
+
+import (
+	"fmt"
+	"html"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// SendReporterConfirmation sends the person who filed a report a confirmation that it
+// was submitted and forwarded to the brand. It reuses the report images but leaves out
+// brand-facing details such as liability estimates, contacts and report counts.
+// Nothing is sent unless ReporterConfirmationEnabled is set and the reporter consented.
+// The reporter address is screened like a batch recipient: an invalid address gets an
+// error, and one of our sender addresses or a suppressed one gets an errSkipped error
+// such as ErrSuppressed. A nil analysis sends the confirmation without the report title.
+func (e *EmailSender) SendReporterConfirmation(reporterEmail string, consented bool, reportImage, mapImage []byte, analysis *models.ReportAnalysis) error {
+	if !e.config.ReporterConfirmationEnabled {
+		return nil
+	}
+	if reporterEmail == "" || !consented {
+		log.Infof("Skipping reporter confirmation: no consenting reporter email")
+		return nil
+	}
+	reporterEmail = normalizeRecipient(reporterEmail)
+	if err := e.screenAddress("reporter confirmation", reporterEmail); err != nil {
+		return err
+	}
+	if analysis == nil {
+		log.Warnf("No analysis given for the reporter confirmation to %s, sending it without the report title", reporterEmail)
+		analysis = &models.ReportAnalysis{}
+	}
+
+	from := mail.NewEmail(e.config.SendGridFromName, e.config.SendGridFromEmail)
+	to := mail.NewEmail(reporterEmail, reporterEmail)
+
+	brandDisplay := analysis.BrandDisplayName
+	if brandDisplay == "" {
+		brandDisplay = analysis.BrandName
+	}
+	if brandDisplay == "" {
+		brandDisplay = "the responsible party"
+	}
+
+	reportImg, mapImg := e.prepareImages(reportImage, mapImage)
+	hasReport := reportImg != nil
+	hasMap := mapImg != nil
+	cids := e.newContentIDs(false)
+
+	message := mail.NewV3Mail()
+	message.SetFrom(from)
+	message.Subject = "Your CleanApp report was submitted"
+
+	p := mail.NewPersonalization()
+	p.AddTos(to)
+	message.AddPersonalizations(p)
+
+	if err := e.addBodies(message, reporterEmail, e.getConfirmationText(analysis, brandDisplay, hasReport, hasMap), func() string {
+		return e.getConfirmationHtml(analysis, brandDisplay, reportImg, mapImg, cids)
+	}); err != nil {
+		return err
+	}
+
+	if hasReport {
+		e.addImage(message, reporterEmail, reportImg, attachmentFilename("report", analysis, reportImg.raw, ".jpg"), cids.report)
+	}
+	if hasMap {
+		e.addImage(message, reporterEmail, mapImg, attachmentFilename("map", analysis, mapImg.raw, ".png"), cids.mapImg)
+	}
+
+	return e.deliver(nil, message, reporterEmail, "Reporter confirmation")
+}
+
+// getConfirmationText returns the plain text content for reporter confirmations
+func (e *EmailSender) getConfirmationText(analysis *models.ReportAnalysis, brandDisplay string, hasReport, hasMap bool) string {
+	attachments := ""
+	if hasReport || hasMap {
+		attachments = "\nThis email contains:\n"
+		if hasReport {
+			attachments += "- Your report image\n"
+		}
+		if hasMap {
+			attachments += "- A map showing the location\n"
+		}
+	}
+
+	title := ""
+	if analysis.Title != "" {
+		title = "\nTitle: " + analysis.Title + "\n"
+	}
+
+	return fmt.Sprintf(`Thank you!
+
+Your report was submitted and sent to %s.
+%s%s
+Trash is cash,
+The CleanApp Team`,
+		brandDisplay,
+		title,
+		attachments)
+}
+
+// getConfirmationHtml returns the HTML content for reporter confirmations
+func (e *EmailSender) getConfirmationHtml(analysis *models.ReportAnalysis, brandDisplay string, reportImg, mapImg *inlineImage, cids contentIDs) string {
+	title := ""
+	if analysis.Title != "" {
+		title = "\n    <p><strong>Title:</strong> " + html.EscapeString(analysis.Title) + "</p>"
+	}
+	imagesSection := ""
+	if reportImg != nil {
+		imagesSection += `
+    <h3>Your Report:</h3>
+    ` + e.inlineImgTag(cids.report, "Report Image", reportImg, "max-width: 100%; height: auto; border-radius: 5px")
+	}
+	if mapImg != nil {
+		imagesSection += `
+    <h3>Location Map:</h3>
+    ` + e.inlineImgTag(cids.mapImg, "Map", mapImg, "max-width: 100%; height: auto; border-radius: 5px")
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Your CleanApp report was submitted</title>
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+    <h2>Thank you!</h2>
+    <p>Your report was submitted and sent to <strong>%s</strong>.</p>%s%s
+    <p style="margin-top: 30px; font-style: italic; color: #28a745;">Trash is cash,</p>
+    <p>The CleanApp Team</p>
+</body>
+</html>`, html.EscapeString(brandDisplay), title, imagesSection)
+}
@@ -0,0 +1,113 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestReporterConfirmationGates(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		reporter  string
+		consented bool
+		wantErr   error
+		wantSent  bool
+	}{
+		{"disabled", false, "reporter@example.com", true, nil, false},
+		{"no consent", true, "reporter@example.com", false, nil, false},
+		{"no address", true, "", true, nil, false},
+		{"sender address", true, "info@cleanapp.io", true, errSkipped, false},
+		{"consented", true, " Reporter@Example.com ", true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &fakeTransport{}
+			e := NewEmailSenderWithTransport(&config.Config{
+				SendGridFromEmail:           "info@cleanapp.io",
+				ReporterConfirmationEnabled: tt.enabled,
+			}, transport)
+
+			err := e.SendReporterConfirmation(tt.reporter, tt.consented, nil, nil, goldenAnalysis())
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SendReporterConfirmation() = %v, want %v", err, tt.wantErr)
+			}
+			if sent := len(transport.messages) == 1; sent != tt.wantSent {
+				t.Fatalf("sent %d messages, want sent=%v", len(transport.messages), tt.wantSent)
+			}
+			if tt.wantSent {
+				if to := transport.messages[0].Personalizations[0].To[0].Address; to != "reporter@example.com" {
+					t.Errorf("sent to %q, want the normalized reporter address", to)
+				}
+			}
+		})
+	}
+}
+
+func TestReporterConfirmationRejectsInvalidAddress(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{ReporterConfirmationEnabled: true}, transport)
+
+	if err := e.SendReporterConfirmation("not an address", true, nil, nil, goldenAnalysis()); err == nil {
+		t.Error("expected an error for an invalid reporter address")
+	}
+	if len(transport.messages) != 0 {
+		t.Errorf("expected nothing sent, got %d messages", len(transport.messages))
+	}
+}
+
+func TestReporterConfirmationLeavesOutBrandDetails(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{ReporterConfirmationEnabled: true}, transport)
+	analysis := goldenAnalysis()
+	analysis.InferredContactEmails = "legal@acme.example"
+
+	if err := e.SendReporterConfirmation("reporter@example.com", true, encodeTestImage(t, 40, 30, "jpeg"), nil, analysis); err != nil {
+		t.Fatalf("SendReporterConfirmation returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+	message := transport.messages[0]
+	if message.Subject != "Your CleanApp report was submitted" {
+		t.Errorf("Subject = %q", message.Subject)
+	}
+	for _, content := range message.Content {
+		for _, want := range []string{"Acme", "Overflowing trash bin"} {
+			if !strings.Contains(content.Value, want) {
+				t.Errorf("%s body is missing %q", content.Type, want)
+			}
+		}
+		for _, leaked := range []string{"$1,000 - $5,000", "legal@acme.example", "7 reports", "issue #7"} {
+			if strings.Contains(content.Value, leaked) {
+				t.Errorf("%s body contains brand-facing detail %q", content.Type, leaked)
+			}
+		}
+	}
+	if len(message.Attachments) != 1 {
+		t.Errorf("expected the report image attached, got %d attachments", len(message.Attachments))
+	}
+}
+
+func TestReporterConfirmationWithoutAnalysis(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{ReporterConfirmationEnabled: true}, transport)
+
+	if err := e.SendReporterConfirmation("reporter@example.com", true, nil, nil, nil); err != nil {
+		t.Fatalf("SendReporterConfirmation returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+	for _, content := range transport.messages[0].Content {
+		if !strings.Contains(content.Value, "the responsible party") {
+			t.Errorf("%s body is missing the fallback brand name", content.Type)
+		}
+		if strings.Contains(content.Value, "Title") {
+			t.Errorf("%s body has a title line without an analysis", content.Type)
+		}
+	}
+}
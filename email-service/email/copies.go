@@ -0,0 +1,62 @@
+package email
+
+import (
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// WithCC copies addresses, e.g. a team inbox, on every message of the send. Addresses
+// are normalized, validated and deduplicated like recipients; invalid ones are dropped
+// with a warning.
+func WithCC(addresses ...string) SendOption {
+	return func(o *sendOptions) {
+		o.cc = append(o.cc, addresses...)
+	}
+}
+
+// WithBCC blind-copies addresses, e.g. an internal auditor, on every message of the
+// send, screened as for WithCC. An address also copied with WithCC is only CC'd.
+func WithBCC(addresses ...string) SendOption {
+	return func(o *sendOptions) {
+		o.bcc = append(o.bcc, addresses...)
+	}
+}
+
+// copyAddresses normalizes and validates addresses for CC or BCC (field), dropping
+// invalid ones, repeats, and any already in seen; seen is updated
+func copyAddresses(field string, addresses []string, seen map[string]bool) []string {
+	var valid []string
+	for _, address := range addresses {
+		address = normalizeRecipient(address)
+		if err := validateRecipient(address); err != nil {
+			log.Warnf("Ignoring %s address: %v", field, err)
+			continue
+		}
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		valid = append(valid, address)
+	}
+	return valid
+}
+
+// addCopies adds the batch's CC and BCC addresses to the personalization for recipient,
+// leaving out the recipient itself, since SendGrid rejects an address repeated across
+// To, CC and BCC. A nil batch has no copies.
+func (b *batch) addCopies(p *mail.Personalization, recipient string) {
+	if b == nil {
+		return
+	}
+	recipient = normalizeRecipient(recipient)
+	for _, address := range b.cc {
+		if address != recipient {
+			p.AddCCs(mail.NewEmail("", address))
+		}
+	}
+	for _, address := range b.bcc {
+		if address != recipient {
+			p.AddBCCs(mail.NewEmail("", address))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package email
+
+import (
+	"slices"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestCopiesAddedToPersonalization(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(),
+		WithCC(" Team@Acme.com", "not an address"),
+		WithBCC("auditor@cleanapp.io", "team@acme.com", "BRAND@example.com", "auditor@cleanapp.io"))
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 || len(sent[0].Personalizations) != 1 {
+		t.Fatalf("expected 1 send with 1 personalization, got %+v", sent)
+	}
+
+	p := sent[0].Personalizations[0]
+	var cc, bcc []string
+	for _, c := range p.CC {
+		cc = append(cc, c.Email)
+	}
+	for _, c := range p.BCC {
+		bcc = append(bcc, c.Email)
+	}
+	if !slices.Equal(cc, []string{"team@acme.com"}) {
+		t.Errorf("CC = %v, want [team@acme.com]", cc)
+	}
+	// The recipient and the CC'd inbox aren't repeated in BCC
+	if !slices.Equal(bcc, []string{"auditor@cleanapp.io"}) {
+		t.Errorf("BCC = %v, want [auditor@cleanapp.io]", bcc)
+	}
+}
+
+func TestNoCopiesWithoutOptions(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if p := sent[0].Personalizations[0]; len(p.CC) != 0 || len(p.BCC) != 0 {
+		t.Errorf("expected no CC or BCC, got %+v", p)
+	}
+}
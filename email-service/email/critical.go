@@ -0,0 +1,38 @@
+package email
+
+import (
+	"email-service/config"
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// applyCriticalBypass lets a report flagged Critical reach recipients SendGrid would
+// otherwise suppress, using the setting chosen by CriticalBypass.
+//
+// Legal caveat: mailing someone who unsubscribed is only permitted for genuinely
+// transactional or safety-critical messages (e.g. CAN-SPAM's transactional exemption),
+// and some jurisdictions and SendGrid's own policy allow it only narrowly. Bypassing
+// bounce and spam-report suppressions ("list") also risks the account's sender
+// reputation. It stays off by default, and every use is logged loudly for audit.
+func (e *EmailSender) applyCriticalBypass(message *mail.SGMailV3, recipient string, analysis *models.ReportAnalysis) {
+	if !analysis.Critical {
+		return
+	}
+
+	settings := mail.NewMailSettings()
+	switch e.config.CriticalBypass {
+	case config.CriticalBypassUnsubscribe:
+		settings.SetBypassUnsubscribeManagement(mail.NewSetting(true))
+	case config.CriticalBypassList:
+		settings.SetBypassListManagement(mail.NewSetting(true))
+	default:
+		log.Infof("Report %d is flagged critical but SENDGRID_CRITICAL_BYPASS is off, suppressions apply to %s", analysis.Seq, recipient)
+		return
+	}
+
+	log.Warnf("CRITICAL ALERT: bypassing SendGrid %s suppression for %s (report %d, brand %s)",
+		e.config.CriticalBypass, recipient, analysis.Seq, analysis.BrandName)
+	message.SetMailSettings(settings)
+}
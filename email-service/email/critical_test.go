@@ -0,0 +1,42 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+func TestApplyCriticalBypass(t *testing.T) {
+	tests := []struct {
+		name            string
+		bypass          string
+		critical        bool
+		wantList        bool
+		wantUnsubscribe bool
+	}{
+		{"not critical", config.CriticalBypassList, false, false, false},
+		{"bypass off", config.CriticalBypassOff, true, false, false},
+		{"unsubscribe", config.CriticalBypassUnsubscribe, true, false, true},
+		{"list", config.CriticalBypassList, true, true, false},
+	}
+
+	for _, tt := range tests {
+		e := &EmailSender{config: &config.Config{CriticalBypass: tt.bypass}}
+		message := mail.NewV3Mail()
+		e.applyCriticalBypass(message, "brand@example.com", &models.ReportAnalysis{Critical: tt.critical})
+
+		settings := message.MailSettings
+		if settings == nil {
+			settings = &mail.MailSettings{}
+		}
+		gotList := settings.BypassListManagement != nil && *settings.BypassListManagement.Enable
+		gotUnsubscribe := settings.BypassUnsubscribeManagement != nil && *settings.BypassUnsubscribeManagement.Enable
+		if gotList != tt.wantList || gotUnsubscribe != tt.wantUnsubscribe {
+			t.Errorf("%s: bypass list=%v unsubscribe=%v, want list=%v unsubscribe=%v",
+				tt.name, gotList, gotUnsubscribe, tt.wantList, tt.wantUnsubscribe)
+		}
+	}
+}
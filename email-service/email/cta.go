@@ -0,0 +1,68 @@
+package email
+
+import (
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+
+	"email-service/models"
+)
+
+// getCTAURL returns the dashboard link for the CTA with the configured UTM parameters
+// added, or "" when there is no dashboard; parameters already in the URL are kept
+func (e *EmailSender) getCTAURL(analysis *models.ReportAnalysis) string {
+	link := e.getDashboardURL(analysis)
+	if link == "" || len(e.config.CTAUTMParams) == 0 {
+		return link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	query := u.Query()
+	keys := make([]string, 0, len(e.config.CTAUTMParams))
+	for key := range e.config.CTAUTMParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if query.Get(key) == "" {
+			query.Set(key, e.config.CTAUTMParams[key])
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// getCTALabel returns the HTML-escaped title/aria-label for the CTA anchor, falling
+// back to the button text when no label template is configured
+func (e *EmailSender) getCTALabel(ctaText, brandDisplay string) string {
+	label := ctaText
+	if e.config.CTALabel != "" {
+		label = strings.NewReplacer("{cta}", ctaText, "{brand}", brandDisplay).Replace(e.config.CTALabel)
+	}
+	return html.EscapeString(label)
+}
+
+// defaultBrandDashboardURL is the digital dashboard used when BrandDashboardURL is unset
+const defaultBrandDashboardURL = "https://cleanapp.io/digital/{brand}"
+
+// getBrandDashboardURL fills the analysis's brand into the dashboard template of its
+// BrandID, or BrandDashboardURL, returning "" when the template needs a brand and there
+// is none
+func (e *EmailSender) getBrandDashboardURL(analysis *models.ReportAnalysis) string {
+	brandName := analysis.BrandName
+	template := e.brandFor(analysis).DashboardURL
+	if template == "" {
+		template = defaultBrandDashboardURL
+	}
+	if !strings.Contains(template, "{brand}") {
+		return template
+	}
+	if brandName == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, "{brand}", url.PathEscape(brandName))
+}
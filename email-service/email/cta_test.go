@@ -0,0 +1,73 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestDigitalCTALinkAndLabel(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		CTALabel:     "{cta} on the CleanApp dashboard",
+		CTAUTMParams: map[string]string{"utm_source": "cleanapp", "utm_medium": "email", "utm_campaign": "report_alert"},
+	}}
+	analysis := &models.ReportAnalysis{BrandName: "acme", BrandDisplayName: "Acme & Co", BrandReportCount: 3, Classification: "digital"}
+
+	const wantURL = "https://cleanapp.io/digital/acme?utm_campaign=report_alert&utm_medium=email&utm_source=cleanapp"
+	if got := e.getCTAURL(analysis); got != wantURL {
+		t.Errorf("getCTAURL() = %q, want %q", got, wantURL)
+	}
+
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	const label = "View all 3 reports about Acme &amp; Co on the CleanApp dashboard"
+	// The href is HTML-escaped, so the query's & separators become &amp;
+	wantAnchor := `<a href="` + strings.ReplaceAll(wantURL, "&", "&amp;") + `" title="` + label + `" aria-label="` + label + `"`
+	if !strings.Contains(html, wantAnchor) {
+		t.Errorf("expected CTA anchor %s in HTML", wantAnchor)
+	}
+
+	e.config.CTAUTMParams = nil
+	if got := e.getCTAURL(analysis); got != "https://cleanapp.io/digital/acme" {
+		t.Errorf("expected no UTM parameters when none are configured, got %q", got)
+	}
+}
+
+func TestDigitalDashboardFallback(t *testing.T) {
+	analysis := goldenAnalysis()
+	analysis.Classification = "digital"
+	analysis.BrandName = ""
+
+	tests := []struct {
+		fallback string
+		want     string // Expected CTA link, "" when the CTA is left out
+	}{
+		{"", ""},
+		{"https://cleanapp.io/reports", "https://cleanapp.io/reports?utm_source=cleanapp"},
+	}
+
+	for _, tt := range tests {
+		e := &EmailSender{config: &config.Config{
+			DashboardFallbackURL: tt.fallback,
+			CTAUTMParams:         map[string]string{"utm_source": "cleanapp"},
+		}}
+		html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+		text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+
+		if tt.want == "" {
+			if strings.Contains(html, "aria-label=") || strings.Contains(text, "View report about") {
+				t.Errorf("expected no CTA without a dashboard URL:\n%s", text)
+			}
+			continue
+		}
+		if !strings.Contains(html, `href="`+tt.want+`"`) || !strings.Contains(text, "View all 7 reports about Acme: "+tt.want) {
+			t.Errorf("expected the CTA to link to %s", tt.want)
+		}
+	}
+
+	e := &EmailSender{config: &config.Config{BrandDashboardURL: "https://dash.example.com/{brand}/reports"}}
+	if got := e.getBrandDashboardURL(&models.ReportAnalysis{BrandName: "acme co"}); got != "https://dash.example.com/acme%20co/reports" {
+		t.Errorf("getBrandDashboardURL() = %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// DigestItem is one report of a digest email: its analysis and optional images
+type DigestItem struct {
+	Analysis    *models.ReportAnalysis
+	ReportImage []byte
+	MapImage    []byte
+}
+
+// digestCard is a digest report with its images prepared and their Content-IDs chosen
+type digestCard struct {
+	analysis          *models.ReportAnalysis
+	reportImg, mapImg *inlineImage
+	cids              contentIDs
+}
+
+// SendDigest sends recipient one email covering several reports, e.g. from a busy
+// location, instead of one email each. It opens with the report count and highest
+// severity and has a card per report in the given order, each with its own images.
+func (e *EmailSender) SendDigest(recipient string, items []DigestItem, opts ...SendOption) error {
+	if len(items) == 0 {
+		return errors.New("digest has no reports")
+	}
+	b := e.newBatch(opts)
+	log.Infof("Sending digest of %d reports to %s (batch %s)", len(items), recipient, b.id)
+
+	cards := make([]digestCard, len(items))
+	for i, item := range items {
+		reportImg, mapImg := e.prepareImages(item.ReportImage, item.MapImage)
+		cards[i] = digestCard{
+			analysis:  e.normalizeClassification(item.Analysis),
+			reportImg: reportImg,
+			mapImg:    mapImg,
+			cids:      e.newContentIDs(false),
+		}
+	}
+
+	return e.runBatch(b, "digest email", "digest emails", []string{recipient}, func(recipient string) error {
+		return e.sendDigestEmail(b, recipient, cards)
+	})
+}
+
+// sendDigestEmail sends the digest of cards to a single recipient
+func (e *EmailSender) sendDigestEmail(b *batch, recipient string, cards []digestCard) error {
+	message := mail.NewV3Mail()
+	message.SetFrom(mail.NewEmail(e.config.SendGridFromName, e.config.SendGridFromEmail))
+	message.Subject = fmt.Sprintf("CleanApp digest: %d reports, highest severity %.1f", len(cards), highestSeverity(cards))
+	e.applyHeaders(b, message, recipient)
+
+	p := mail.NewPersonalization()
+	p.AddTos(mail.NewEmail(recipient, recipient))
+	b.addCopies(p, recipient)
+	e.setListUnsubscribe(p, e.config.OptOutURL, recipient)
+	message.AddPersonalizations(p)
+
+	if err := e.addBodies(message, recipient, e.getDigestText(recipient, cards), func() string {
+		return e.getDigestHtml(recipient, cards)
+	}); err != nil {
+		return err
+	}
+
+	for _, card := range cards {
+		if card.reportImg != nil {
+			e.addImage(message, recipient, card.reportImg, attachmentFilename("report", card.analysis, card.reportImg.raw, ".jpg"), card.cids.report)
+		}
+		if card.mapImg != nil {
+			e.addImage(message, recipient, card.mapImg, attachmentFilename("map", card.analysis, card.mapImg.raw, ".png"), card.cids.mapImg)
+		}
+	}
+
+	return e.deliver(b, message, recipient, "Digest email")
+}
+
+// highestSeverity returns the highest 0-10 severity of the digest's reports
+func highestSeverity(cards []digestCard) float64 {
+	highest := 0.0
+	for _, card := range cards {
+		highest = max(highest, card.analysis.SeverityLevel)
+	}
+	return highest
+}
+
+// digestBrand returns the brand name a digest card is titled with
+func digestBrand(analysis *models.ReportAnalysis) string {
+	if analysis.BrandDisplayName != "" {
+		return analysis.BrandDisplayName
+	}
+	if analysis.BrandName != "" {
+		return analysis.BrandName
+	}
+	return "Unknown brand"
+}
+
+// getDigestText returns the plain text content of a digest email
+func (e *EmailSender) getDigestText(recipient string, cards []digestCard) string {
+	var b strings.Builder
+	highest := highestSeverity(cards)
+	fmt.Fprintf(&b, "Hello,\n\nCleanApp users submitted %d new reports. Highest severity: %.1f / 10 (%s).\n",
+		len(cards), highest, e.getSeverityGaugeLabel(highest))
+
+	for i, card := range cards {
+		a := card.analysis
+		fmt.Fprintf(&b, "\n%d. %s issue #%d: %s\n", i+1, digestBrand(a), a.BrandReportCount, a.Title)
+		fmt.Fprintf(&b, "   Severity: %.1f / 10 (%s), type: %s\n", a.SeverityLevel, e.getSeverityGaugeLabel(a.SeverityLevel), a.Classification)
+		if a.Description != "" {
+			fmt.Fprintf(&b, "   %s\n", e.bodyDescription(a.Description))
+		}
+		if link := e.getCTAURL(a); link != "" {
+			fmt.Fprintf(&b, "   View report: %s\n", link)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n---\n\nTo unsubscribe from these emails, please visit: %s", e.optOutLink(e.config.OptOutURL, recipient))
+	return b.String()
+}
+
+// getDigestHtml returns the HTML content of a digest email
+func (e *EmailSender) getDigestHtml(recipient string, cards []digestCard) string {
+	highest := highestSeverity(cards)
+	view := digestEmailView{
+		Count:       len(cards),
+		Highest:     fmt.Sprintf("%.1f", highest),
+		HighestBand: e.getSeverityGaugeLabel(highest),
+		OptOutHref:  template.HTMLAttr(`href="` + html.EscapeString(e.optOutLink(e.config.OptOutURL, recipient)) + `"`),
+	}
+	for _, card := range cards {
+		a := card.analysis
+		images := ""
+		if card.reportImg != nil {
+			images += `
+        ` + e.inlineImgTag(card.cids.report, "Report Image", card.reportImg, "max-width: 100%; height: auto; border-radius: 5px")
+		}
+		if card.mapImg != nil {
+			images += `
+        ` + e.inlineImgTag(card.cids.mapImg, "Map", card.mapImg, "max-width: 100%; height: auto; border-radius: 5px")
+		}
+		view.Cards = append(view.Cards, digestCardView{
+			Brand:          digestBrand(a),
+			ReportCount:    a.BrandReportCount,
+			Title:          a.Title,
+			Severity:       fmt.Sprintf("%.1f", a.SeverityLevel),
+			SeverityBand:   e.getSeverityGaugeLabel(a.SeverityLevel),
+			Classification: a.Classification,
+			Link:           e.getCTAURL(a),
+			Description:    template.HTML(e.getDescriptionHtml(e.bodyDescription(a.Description))),
+			Images:         template.HTML(images),
+		})
+	}
+	return renderTemplate("digest_email.html.tmpl", view)
+}
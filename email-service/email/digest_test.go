@@ -0,0 +1,62 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestSendDigestHasCardAndUniqueImagesPerReport(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	var items []DigestItem
+	for i, severity := range []float64{0.3, 0.9, 0.5} {
+		analysis := goldenAnalysis()
+		analysis.Seq = int64(100 + i)
+		analysis.Title = fmt.Sprintf("Report %d", i+1)
+		analysis.SeverityLevel = severity * 10
+		items = append(items, DigestItem{Analysis: analysis, ReportImage: encodeTestImage(t, 40, 30, "jpeg")})
+	}
+
+	if err := e.SendDigest("brand@example.com", items); err != nil {
+		t.Fatalf("SendDigest returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 digest email, got %d", len(sent))
+	}
+	mail := sent[0]
+	if !strings.Contains(mail.Subject, "3 reports") || !strings.Contains(mail.Subject, "9.0") {
+		t.Errorf("subject %q doesn't give the count and highest severity", mail.Subject)
+	}
+
+	var body string
+	for _, c := range mail.Content {
+		if c.Type == "text/html" {
+			body = c.Value
+		}
+	}
+	if n := strings.Count(body, `class="report-card"`); n != 3 {
+		t.Errorf("expected 3 report cards, got %d", n)
+	}
+
+	cids := map[string]bool{}
+	for _, a := range mail.Attachments {
+		cids[a.ContentID] = true
+		if !strings.Contains(body, `src="cid:`+a.ContentID+`"`) {
+			t.Errorf("attachment %s isn't referenced by the body", a.ContentID)
+		}
+	}
+	if len(mail.Attachments) != 3 || len(cids) != 3 {
+		t.Errorf("expected 3 attachments with distinct Content-IDs, got %v", cids)
+	}
+}
+
+func TestSendDigestRejectsNoReports(t *testing.T) {
+	e := newTestSender(t, &config.Config{}, captureSends(t, new([]capturedMail)))
+	if err := e.SendDigest("brand@example.com", nil); err == nil {
+		t.Error("expected an error for an empty digest")
+	}
+}
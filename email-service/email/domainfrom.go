@@ -0,0 +1,122 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// newDomainFroms parses the DomainFroms mapping; config.Load has already dropped
+// entries that don't parse, so any left here are skipped silently
+func newDomainFroms(mapping map[string]string) map[string]*mail.Email {
+	froms := make(map[string]*mail.Email, len(mapping))
+	for domain, from := range mapping {
+		addr, err := netmail.ParseAddress(from)
+		if err != nil {
+			continue
+		}
+		froms[strings.ToLower(domain)] = mail.NewEmail(addr.Name, addr.Address)
+	}
+	return froms
+}
+
+// domainFrom returns the From identity mapped to the recipient's domain, or nil to
+// keep the default From
+func (e *EmailSender) domainFrom(recipient string) *mail.Email {
+	_, domain, ok := strings.Cut(recipient, "@")
+	if !ok {
+		return nil
+	}
+	e.domainFromsMu.RLock()
+	defer e.domainFromsMu.RUnlock()
+	return e.domainFroms[strings.ToLower(domain)]
+}
+
+// applyDomainFrom sends from the identity mapped to the recipient's domain, when there
+// is one, so mail to a brand's own staff can align with DMARC for their domain. A
+// mapping without a display name keeps the name already on the message.
+func (e *EmailSender) applyDomainFrom(message *mail.SGMailV3, recipient string) {
+	from := e.domainFrom(recipient)
+	if from == nil {
+		return
+	}
+	name := from.Name
+	if name == "" && message.From != nil {
+		name = message.From.Name
+	}
+	message.SetFrom(mail.NewEmail(name, from.Address))
+}
+
+// verifiedSendersResponse is the body of GET /v3/verified_senders
+type verifiedSendersResponse struct {
+	Results []struct {
+		FromEmail string `json:"from_email"`
+		Verified  bool   `json:"verified"`
+	} `json:"results"`
+}
+
+// authenticatedDomain is one entry of GET /v3/whitelabel/domains
+type authenticatedDomain struct {
+	Domain string `json:"domain"`
+	Valid  bool   `json:"valid"`
+}
+
+// VerifyDomainFroms checks every DomainFroms identity against the account's verified
+// senders and authenticated domains, and drops the mappings SendGrid would reject so
+// those recipients fall back to the default From. It returns an error naming the
+// dropped mappings, or the lookup error, in which case the mappings are kept. Call it
+// at startup, before sending.
+func (e *EmailSender) VerifyDomainFroms() error {
+	e.domainFromsMu.RLock()
+	n := len(e.domainFroms)
+	e.domainFromsMu.RUnlock()
+	if n == 0 {
+		return nil
+	}
+
+	var senders verifiedSendersResponse
+	if err := e.marketingRequest(context.Background(), "GET", "/v3/verified_senders", nil, &senders); err != nil {
+		return fmt.Errorf("verify domain From identities: %w", err)
+	}
+	var domains []authenticatedDomain
+	if err := e.marketingRequest(context.Background(), "GET", "/v3/whitelabel/domains", nil, &domains); err != nil {
+		return fmt.Errorf("verify domain From identities: %w", err)
+	}
+
+	verified := make(map[string]bool)
+	for _, sender := range senders.Results {
+		if sender.Verified {
+			verified[strings.ToLower(sender.FromEmail)] = true
+		}
+	}
+	authenticated := make(map[string]bool)
+	for _, domain := range domains {
+		if domain.Valid {
+			authenticated[strings.ToLower(domain.Domain)] = true
+		}
+	}
+
+	e.domainFromsMu.Lock()
+	defer e.domainFromsMu.Unlock()
+	var dropped []string
+	for recipientDomain, from := range e.domainFroms {
+		address := strings.ToLower(from.Address)
+		_, fromDomain, _ := strings.Cut(address, "@")
+		if verified[address] || authenticated[fromDomain] {
+			continue
+		}
+		delete(e.domainFroms, recipientDomain)
+		dropped = append(dropped, recipientDomain+"="+from.Address)
+		log.Warnf("From %s for recipient domain %s is not a verified sender, using the default From", from.Address, recipientDomain)
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		return fmt.Errorf("unverified domain From identities dropped: %s", strings.Join(dropped, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestDomainFromMappingAndFallback(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		SendGridFromName:  "CleanApp",
+		SendGridFromEmail: "info@cleanapp.io",
+		DomainFroms: map[string]string{
+			"acme.com":    "Acme Alerts <alerts@acme.cleanapp.io>",
+			"example.org": "notify@example-mail.cleanapp.io",
+		},
+	}, captureSends(t, &sent))
+
+	if err := e.SendEmails([]string{"staff@ACME.com", "ops@example.org", "someone@other.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 sends, got %d", len(sent))
+	}
+	want := []struct{ name, email string }{
+		{"Acme Alerts", "alerts@acme.cleanapp.io"},
+		{"CleanApp", "notify@example-mail.cleanapp.io"}, // No mapped name keeps the default
+		{"CleanApp", "info@cleanapp.io"},
+	}
+	for i, w := range want {
+		if sent[i].From.Name != w.name || sent[i].From.Email != w.email {
+			t.Errorf("send %d From = %q <%s>, want %q <%s>", i, sent[i].From.Name, sent[i].From.Email, w.name, w.email)
+		}
+	}
+
+	if !e.isSenderAddress("Alerts@acme.cleanapp.io") {
+		t.Error("expected a mapped From to count as one of our sender addresses")
+	}
+}
+
+func TestVerifyDomainFroms(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/verified_senders":
+			w.Write([]byte(`{"results":[{"from_email":"Alerts@acme.cleanapp.io","verified":true},{"from_email":"pending@x.io","verified":false}]}`))
+		case "/v3/whitelabel/domains":
+			w.Write([]byte(`[{"domain":"mail.cleanapp.io","valid":true},{"domain":"broken.cleanapp.io","valid":false}]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewEmailSender(&config.Config{DomainFroms: map[string]string{
+		"acme.com":    "alerts@acme.cleanapp.io",   // Verified single sender
+		"example.org": "notify@mail.cleanapp.io",   // Authenticated domain
+		"pending.com": "pending@x.io",              // Unverified sender
+		"broken.com":  "alerts@broken.cleanapp.io", // Domain failing authentication
+	}})
+	e.marketingHost = srv.URL
+
+	err := e.VerifyDomainFroms()
+	if err == nil || !strings.Contains(err.Error(), "broken.com=alerts@broken.cleanapp.io, pending.com=pending@x.io") {
+		t.Fatalf("expected the unverified mappings named in the error, got %v", err)
+	}
+	for domain, kept := range map[string]bool{"acme.com": true, "example.org": true, "pending.com": false, "broken.com": false} {
+		if got := e.domainFrom("user@"+domain) != nil; got != kept {
+			t.Errorf("mapping for %s kept = %v, want %v", domain, got, kept)
+		}
+	}
+}
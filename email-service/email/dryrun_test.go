@@ -0,0 +1,35 @@
+package email
+
+import (
+	"context"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestDryRunBuildsButDoesNotSend(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{SendGridFromEmail: "info@cleanapp.io", DryRun: true}, transport)
+
+	var records []AuditRecord
+	var result SendResult
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+	err := e.SendEmailsWithAnalysis(recipients, encodeTestImage(t, 40, 30, "jpeg"), nil, goldenAnalysis(),
+		WithResult(&result), WithAudit(func(r AuditRecord) { records = append(records, r) }))
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error in dry run: %v", err)
+	}
+	if err := e.SendBatch(context.Background(), recipients, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendBatch returned error in dry run: %v", err)
+	}
+
+	if len(transport.messages) != 0 {
+		t.Errorf("expected no transport calls in dry run, got %d", len(transport.messages))
+	}
+	if len(result.Succeeded) != len(recipients) || len(result.Failed) != 0 {
+		t.Errorf("expected every recipient reported as sent, got %+v", result)
+	}
+	if len(records) != len(recipients) || records[0].HTML == "" {
+		t.Errorf("expected the full messages to be built, got %d audit records", len(records))
+	}
+}
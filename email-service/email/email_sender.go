@@ -1,112 +1,317 @@
 package email
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
-	"image"
+	"html"
+	"html/template"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"email-service/config"
 	"email-service/models"
 
 	"github.com/apex/log"
-	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
-	"golang.org/x/image/font"
-	"golang.org/x/image/font/basicfont"
-	"golang.org/x/image/math/fixed"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 const (
-	reportImgCid = "report_image"
-	mapImgCid    = "map_image"
+	reportImgCid    = "report_image"
+	mapImgCid       = "map_image"
+	compositeImgCid = "report_map_image"
+
+	// maxSubjectLen keeps subjects within the 78-character line length RFC 5322
+	// recommends, which is also about what mobile clients show before cutting off
+	maxSubjectLen             = 78
+	defaultMaxSubjectTitleLen = 50
 )
 
 // EmailSender handles email sending functionality
 type EmailSender struct {
-	config *config.Config
-	client *sendgrid.Client
+	config     *config.Config
+	accounts   []*sendAccount
+	httpClient *rest.Client // Shared by all accounts and the Marketing API
+	transport  Transport    // Replaces SendGrid for mail sends when set
+	fallback   Transport    // SMTP fallback after SendGrid connection errors and 5xx; nil when SmtpHost is unset
+
+	nextAccount     uint64 // Round-robin cursor over accounts
+	failedResponses uint64 // Non-2xx SendGrid responses, for failure body sampling
+
+	rateLimitMu sync.Mutex
+	rateLimit   RateLimitStatus
+	sendRate    *sendLimiter // Paces mail/send calls; nil when SendRatePerSecond is unset
+
+	now   func() time.Time           // Clock, injectable for deterministic rendering
+	newID func(prefix string) string // Batch/content ID generator, injectable for deterministic rendering
+
+	marketingHost string                            // SendGrid API host for Single Sends
+	htmlTransform func(html string) (string, error) // Optional post-processing of rendered HTML bodies
+	tracer        trace.Tracer                      // Batch and send spans; a no-op tracer unless one is provided
+
+	coalesce coalescer // Per-brand buffers of queued analysis emails
+
+	thumbnails thumbnailCache // Location thumbnails by request URL, reused for MapThumbnailTTL
+
+	labelFont labelFont // Font of AddLabel, loaded on first use
+
+	suppressions Suppressions   // Addresses never emailed again; in memory unless injected
+	frequency    FrequencyStore // Per-recipient send counts for MaxEmailsPerRecipient; in memory unless injected
+
+	domainFromsMu sync.RWMutex
+	domainFroms   map[string]*mail.Email // From identity per lowercase recipient domain
 }
 
 // NewEmailSender creates a new email sender
-func NewEmailSender(cfg *config.Config) *EmailSender {
-	client := sendgrid.NewSendClient(cfg.SendGridAPIKey)
-	return &EmailSender{
-		config: cfg,
-		client: client,
+func NewEmailSender(cfg *config.Config, opts ...Option) *EmailSender {
+	e := &EmailSender{
+		config:     cfg,
+		accounts:   newSendAccounts(cfg),
+		httpClient: &rest.Client{HTTPClient: newHTTPClient()},
+		now:        time.Now,
+		newID:      randomID,
+
+		marketingHost: defaultMarketingHost,
+		tracer:        noop.NewTracerProvider().Tracer(""),
+		domainFroms:   newDomainFroms(cfg.DomainFroms),
+		sendRate:      newSendLimiter(cfg.SendRatePerSecond),
+		suppressions:  NewMemorySuppressions(),
+		frequency:     NewMemoryFrequencyStore(),
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if smtp := newSMTPTransport(cfg, e.now); smtp != nil {
+		e.fallback = smtp
+	}
+	if cfg.MetricsEnabled {
+		registerSendMetrics()
+	}
+	return e
+}
+
+// NewEmailSenderWithTransport creates an email sender that hands every built message to
+// transport instead of SendGrid's mail/send API, e.g. a fake that records messages in
+// tests or another provider. Marketing API calls such as Single Sends still go to SendGrid.
+func NewEmailSenderWithTransport(cfg *config.Config, transport Transport, opts ...Option) *EmailSender {
+	e := NewEmailSender(cfg, opts...)
+	e.transport = transport
+	return e
 }
 
 // SendEmails sends emails to multiple recipients
-func (e *EmailSender) SendEmails(recipients []string, reportImage, mapImage []byte) error {
-	log.Infof("Sending email to %d recipients", len(recipients))
-
-	var firstErr error
-	failed := 0
-	for _, recipient := range recipients {
-		if err := e.sendOneEmail(recipient, reportImage, mapImage); err != nil {
-			failed++
-			if firstErr == nil {
-				firstErr = err
-			}
-			log.Warnf("Error sending email to %s: %v", recipient, err)
-			// Continue with other recipients
-		}
+func (e *EmailSender) SendEmails(recipients []string, reportImage, mapImage []byte, opts ...SendOption) error {
+	return e.SendEmailsContext(context.Background(), recipients, reportImage, mapImage, opts...)
+}
+
+// SendEmailsContext is SendEmails bounded by ctx. Once ctx is done no further recipients
+// are sent to and requests in flight are abandoned; the returned error then includes a
+// *CancelledError counting the emails SendGrid had already accepted.
+func (e *EmailSender) SendEmailsContext(ctx context.Context, recipients []string, reportImage, mapImage []byte, opts ...SendOption) error {
+	b := e.newBatch(opts)
+	if err := e.checkBatchSize(b, len(recipients)); err != nil {
+		return err
 	}
+	log.Infof("Sending email to %d recipients (batch %s)", len(recipients), b.id)
+	return e.sendEmails(ctx, b, slices.Values(recipientsFromEmails(recipients)), reportImage, mapImage)
+}
 
-	if failed > 0 {
-		return fmt.Errorf("%d/%d emails failed: %v", failed, len(recipients), firstErr)
+// sendEmails sends the report email without analysis to the batch's recipients
+func (e *EmailSender) sendEmails(ctx context.Context, b *batch, recipients iter.Seq[Recipient], reportImage, mapImage []byte) error {
+	reportImage, mapImage, err := e.loadImages(b, reportImage, mapImage)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	// Downscale and encode the shared images once rather than per recipient
+	reportImg, mapImg := e.prepareImages(reportImage, mapImage)
+
+	return e.streamBatch(ctx, b, "email", "emails", recipients, func(r Recipient) error {
+		return e.sendOneEmail(b, r, reportImg, mapImg)
+	})
 }
 
-// SendEmailsWithAnalysis sends emails to multiple recipients with analysis data
-func (e *EmailSender) SendEmailsWithAnalysis(recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis) error {
-	log.Infof("Sending email with analysis to %d recipients", len(recipients))
+// logMissingAnalysis warns that the batch is sent the report email without analysis,
+// as a nil analysis was given
+func logMissingAnalysis(b *batch, audience string) {
+	log.Warnf("No analysis given for batch %s, sending %s the report email without one", b.id, audience)
+}
 
-	var firstErr error
-	failed := 0
-	for _, recipient := range recipients {
-		if err := e.sendOneEmailWithAnalysis(recipient, reportImage, mapImage, analysis); err != nil {
-			failed++
-			if firstErr == nil {
-				firstErr = err
-			}
-			log.Warnf("Error sending email to %s: %v", recipient, err)
-			// Continue with other recipients
-		}
+// SendEmailsWithAnalysis sends emails to multiple recipients with analysis data. A nil
+// analysis is logged and sends the report email without analysis, as SendEmails does.
+func (e *EmailSender) SendEmailsWithAnalysis(recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	return e.sendEmailsWithAnalysis(context.Background(), recipientsFromEmails(recipients), reportImage, mapImage, analysis, opts)
+}
+
+// SendEmailsWithAnalysisContext is SendEmailsWithAnalysis bounded by ctx, cancelled as
+// described for SendEmailsContext. A Single Send stops at its next API request or import
+// poll once ctx is done, failing every recipient, but can't be recalled once scheduled.
+func (e *EmailSender) SendEmailsWithAnalysisContext(ctx context.Context, recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	return e.sendEmailsWithAnalysis(ctx, recipientsFromEmails(recipients), reportImage, mapImage, analysis, opts)
+}
+
+// SendEmailsWithAnalysisTo sends emails with analysis data to recipients carrying their
+// own metadata, greeting each by name and tagging their locale and brand in one pass.
+// Single Sends personalize by address only, so the metadata is dropped on that path.
+func (e *EmailSender) SendEmailsWithAnalysisTo(recipients []Recipient, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	return e.sendEmailsWithAnalysis(context.Background(), recipients, reportImage, mapImage, analysis, opts)
+}
+
+// sendEmailsWithAnalysis sends an analysis batch to recipients until ctx is done
+func (e *EmailSender) sendEmailsWithAnalysis(ctx context.Context, recipients []Recipient, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts []SendOption) error {
+	b := e.newBatch(opts)
+	if err := e.checkBatchSize(b, len(recipients)); err != nil {
+		return err
+	}
+	audience := fmt.Sprintf("%d recipients", len(recipients))
+	if analysis == nil {
+		logMissingAnalysis(b, audience)
+		return e.sendEmails(ctx, b, slices.Values(recipients), reportImage, mapImage)
+	}
+	analysis = e.normalizeClassification(analysis)
+	b.classification = analysis.Classification
+
+	if err := e.checkMinSeverity(b, analysis, audience); err != nil {
+		return err
 	}
 
-	if failed > 0 {
-		return fmt.Errorf("%d/%d emails with analysis failed: %v", failed, len(recipients), firstErr)
+	// Large campaigns go out as a single Marketing Campaigns send
+	if e.useSingleSend(recipientEmails(recipients), analysis) {
+		log.Infof("Sending email with analysis to %s as a Single Send (batch %s)", audience, b.id)
+		return e.sendSingleSendBatch(ctx, b, recipients, analysis)
 	}
-	return nil
+
+	log.Infof("Sending email with analysis to %s (batch %s)", audience, b.id)
+	reportImg, mapImg, err := e.prepareAnalysisImages(ctx, b, reportImage, mapImage, analysis)
+	if err != nil {
+		return err
+	}
+
+	return e.streamBatch(ctx, b, "email with analysis", "emails with analysis", slices.Values(recipients), func(r Recipient) error {
+		return e.sendOneEmailWithAnalysis(b, r, reportImg, mapImg, analysis)
+	})
 }
 
-// SendAggregateEmail sends an aggregate notification email for a brand
-func (e *EmailSender) SendAggregateEmail(recipients []string, summary *models.BrandReportSummary, optOutURL string) error {
-	log.Infof("Sending aggregate email for brand %s to %d recipients", summary.BrandName, len(recipients))
-
-	var firstErr error
-	failed := 0
-	for _, recipient := range recipients {
-		if err := e.sendOneAggregateEmail(recipient, summary, optOutURL); err != nil {
-			failed++
-			if firstErr == nil {
-				firstErr = err
+// SendEmailsWithAnalysisStream sends emails with analysis data to recipients as they
+// arrive on the channel, e.g. from a database cursor, and returns the aggregate
+// BatchError once it closes. A recipient is only read when a worker is free to send to
+// it, so a producer outpacing the profile's concurrency and rate limit blocks on the
+// channel instead of being buffered in memory. Cancelling ctx stops reading the channel
+// and abandons sends in flight, so producers must also watch ctx rather than expect the
+// channel to be drained. Single Sends need the full list up front, so a
+// stream always sends per recipient.
+func (e *EmailSender) SendEmailsWithAnalysisStream(ctx context.Context, recipients <-chan Recipient, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	b := e.newBatch(opts)
+	source := func(yield func(Recipient) bool) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case r, ok := <-recipients:
+				if !ok || !yield(r) {
+					return
+				}
 			}
-			log.Warnf("Error sending aggregate email to %s: %v", recipient, err)
 		}
 	}
+	if analysis == nil {
+		logMissingAnalysis(b, "a recipient stream")
+		return e.sendEmails(ctx, b, source, reportImage, mapImage)
+	}
+	analysis = e.normalizeClassification(analysis)
+	b.classification = analysis.Classification
+
+	if err := e.checkMinSeverity(b, analysis, "a recipient stream"); err != nil {
+		return err
+	}
+
+	log.Infof("Streaming email with analysis to recipients (batch %s)", b.id)
+	reportImg, mapImg, err := e.prepareAnalysisImages(ctx, b, reportImage, mapImage, analysis)
+	if err != nil {
+		return err
+	}
+	return e.streamBatch(ctx, b, "email with analysis", "emails with analysis", source, func(r Recipient) error {
+		return e.sendOneEmailWithAnalysis(b, r, reportImg, mapImg, analysis)
+	})
+}
+
+// checkMinSeverity returns ErrBelowSeverityThreshold for reports under the brand's
+// severity floor, which aren't worth an alert; audience describes the recipients in the log
+func (e *EmailSender) checkMinSeverity(b *batch, analysis *models.ReportAnalysis, audience string) error {
+	threshold := e.minSeverity(analysis)
+	if analysis.SeverityLevel >= threshold {
+		return nil
+	}
+	log.Infof("Not sending email with analysis for report %d to %s (batch %s): severity %.1f below %.1f for brand %s",
+		analysis.Seq, audience, b.id, analysis.SeverityLevel, threshold, analysis.BrandName)
+	return fmt.Errorf("severity %.1f < %.1f for brand %s: %w", analysis.SeverityLevel, threshold, analysis.BrandName, ErrBelowSeverityThreshold)
+}
 
-	if failed > 0 {
-		return fmt.Errorf("%d/%d aggregate emails failed: %v", failed, len(recipients), firstErr)
+// prepareAnalysisImages loads the batch's images and downscales and encodes them once
+// rather than per recipient, falling back to a location thumbnail when no map was
+// provided, which is fetched until ctx is done. It fails only when a lazy image source
+// can't be loaded.
+func (e *EmailSender) prepareAnalysisImages(ctx context.Context, b *batch, reportImage, mapImage []byte, analysis *models.ReportAnalysis) (*inlineImage, *inlineImage, error) {
+	reportImage, mapImage, err := e.loadImages(b, reportImage, mapImage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(mapImage) == 0 {
+		mapImage = e.locationThumbnail(ctx, analysis)
+	}
+	reportImg, mapImg := e.compositeImages(e.prepareImages(reportImage, mapImage))
+	return reportImg, mapImg, nil
+}
+
+// SendUpdatedEmailsWithAnalysis re-sends a corrected analysis to recipients of an earlier
+// email. The message carries an "Updated analysis" banner and threads under the original
+// via In-Reply-To/References, so originalMessageID must be the Message-ID stored from that send.
+// A nil analysis sends the report email without analysis, still marked as an update and
+// threaded under the original.
+func (e *EmailSender) SendUpdatedEmailsWithAnalysis(recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis, originalMessageID string, opts ...SendOption) error {
+	b := e.newBatch(opts)
+	if err := e.checkBatchSize(b, len(recipients)); err != nil {
+		return err
 	}
-	return nil
+	if analysis == nil {
+		logMissingAnalysis(b, fmt.Sprintf("%d recipients", len(recipients)))
+		b.inReplyTo = originalMessageID
+		return e.sendEmails(context.Background(), b, slices.Values(recipientsFromEmails(recipients)), reportImage, mapImage)
+	}
+	analysis = e.normalizeClassification(analysis)
+	b.classification = analysis.Classification
+	log.Infof("Sending updated analysis email to %d recipients (batch %s, in reply to %s)", len(recipients), b.id, originalMessageID)
+	reportImg, mapImg, err := e.prepareAnalysisImages(context.Background(), b, reportImage, mapImage, analysis)
+	if err != nil {
+		return err
+	}
+
+	return e.runBatch(b, "updated email", "updated emails with analysis", recipients, func(recipient string) error {
+		return e.sendAnalysisEmail(b, Recipient{Email: recipient}, reportImg, mapImg, analysis, originalMessageID)
+	})
+}
+
+// SendAggregateEmail sends an aggregate notification email for a brand
+func (e *EmailSender) SendAggregateEmail(recipients []string, summary *models.BrandReportSummary, optOutURL string, opts ...SendOption) error {
+	b := e.newBatch(opts)
+	if err := e.checkBatchSize(b, len(recipients)); err != nil {
+		return err
+	}
+	log.Infof("Sending aggregate email for brand %s to %d recipients (batch %s)", summary.BrandName, len(recipients), b.id)
+
+	return e.runBatch(b, "aggregate email", "aggregate emails", recipients, func(recipient string) error {
+		return e.sendOneAggregateEmail(b, recipient, summary, optOutURL)
+	})
 }
 
 // sendOneAggregateEmail sends an aggregate notification to a single recipient
-func (e *EmailSender) sendOneAggregateEmail(recipient string, summary *models.BrandReportSummary, optOutURL string) error {
+func (e *EmailSender) sendOneAggregateEmail(b *batch, recipient string, summary *models.BrandReportSummary, optOutURL string) error {
 	from := mail.NewEmail(e.config.SendGridFromName, e.config.SendGridFromEmail)
 
 	// Get brand display name
@@ -128,33 +333,22 @@ func (e *EmailSender) sendOneAggregateEmail(recipient string, summary *models.Br
 	message := mail.NewV3Mail()
 	message.SetFrom(from)
 	message.Subject = subject
+	e.applyHeaders(b, message, recipient)
 
 	p := mail.NewPersonalization()
 	p.AddTos(to)
+	b.addCopies(p, recipient)
+	e.setListUnsubscribe(p, optOutURL, recipient)
 	message.AddPersonalizations(p)
 
-	message.AddContent(mail.NewContent("text/plain", e.getAggregateEmailText(recipient, summary, optOutURL)))
-	message.AddContent(mail.NewContent("text/html", e.getAggregateEmailHTML(recipient, summary, optOutURL)))
-
-	// Send email
-	start := time.Now()
-	response, err := e.client.Send(message)
-	if err != nil {
+	if err := e.addBodies(message, recipient, e.getAggregateEmailText(recipient, summary, optOutURL), func() string {
+		return e.getAggregateEmailHTML(recipient, summary, optOutURL)
+	}); err != nil {
 		return err
 	}
 
-	duration := time.Since(start)
-	if response.StatusCode >= 200 && response.StatusCode < 300 {
-		msgID := response.Headers["X-Message-Id"]
-		log.Infof("Aggregate email accepted by SendGrid for %s (status=%d, id=%s, in %s)", recipient, response.StatusCode, msgID, duration)
-		return nil
-	}
-
-	body := response.Body
-	if len(body) > 512 {
-		body = body[:512] + "..."
-	}
-	return fmt.Errorf("sendgrid returned status %d for %s (in %s): %s", response.StatusCode, recipient, duration, body)
+	// Send email
+	return e.deliver(b, message, recipient, "Aggregate email")
 }
 
 // getAggregateEmailText returns the plain text content for aggregate emails
@@ -182,13 +376,12 @@ https://www.linkedin.com/in/borismamlyuk/
 
 ---
 
-To unsubscribe from these emails, please visit: %s?email=%s`,
+To unsubscribe from these emails, please visit: %s`,
 		summary.NewReportCount,
 		brandDisplay,
 		summary.TotalReportCount,
 		dashboardURL,
-		optOutURL,
-		recipient)
+		e.optOutLink(optOutURL, recipient))
 }
 
 // getAggregateEmailHTML returns the HTML content for aggregate emails
@@ -197,8 +390,10 @@ func (e *EmailSender) getAggregateEmailHTML(recipient string, summary *models.Br
 	if brandDisplay == "" {
 		brandDisplay = summary.BrandName
 	}
+	brandDisplay = html.EscapeString(brandDisplay)
 
 	dashboardURL := e.getAggregateDashboardURL(summary)
+	t := e.getTheme(summary.BrandName)
 
 	newReportText := "issue was"
 	if summary.NewReportCount != 1 {
@@ -212,12 +407,12 @@ func (e *EmailSender) getAggregateEmailHTML(recipient string, summary *models.Br
     <title>%d new report(s) about %s</title>
     <style>
         body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: linear-gradient(135deg, #28a745 0%%, #20c997 100%%); padding: 30px; border-radius: 10px; margin-bottom: 20px; color: white; text-align: center; }
+        .header { background: linear-gradient(135deg, %s 0%%, %s 100%%); padding: 30px; border-radius: 10px; margin-bottom: 20px; color: white; text-align: center; }
         .header h1 { margin: 0 0 10px 0; font-size: 2em; }
         .header p { margin: 0; font-size: 1.1em; opacity: 0.9; }
-        .count-badge { display: inline-block; background: white; color: #28a745; padding: 5px 15px; border-radius: 20px; font-weight: bold; margin-top: 10px; }
+        .count-badge { display: inline-block; background: white; color: %s; padding: 5px 15px; border-radius: 20px; font-weight: bold; margin-top: 10px; }
         .cta-section { text-align: center; margin: 30px 0; }
-        .cta-button { display: inline-block; background-color: #28a745; color: white; padding: 15px 40px; text-decoration: none; border-radius: 5px; font-weight: bold; font-size: 1.1em; }
+        .cta-button { display: inline-block; background-color: %s; color: white; padding: 15px 40px; text-decoration: none; border-radius: 5px; font-weight: bold; font-size: 1.1em; }
         .cta-hint { font-size: 0.85em; color: #666; margin-top: 10px; }
         .signature { margin-top: 30px; padding: 20px 0; border-top: 1px solid #eee; }
         .signature p { margin: 5px 0; }
@@ -236,21 +431,23 @@ func (e *EmailSender) getAggregateEmailHTML(recipient string, summary *models.Br
         <p class="cta-hint">It takes just 30 seconds to review reports, confirm the risks, and get a fix.</p>
     </div>
 
-    <div class="signature">
-        <p style="font-style: italic; color: #28a745;">Trash is cash,</p>
+    <!--clip:optional--><div class="signature">
+        <p style="font-style: italic; color: %s;">Trash is cash,</p>
         <p style="font-weight: bold;">Boris Mamlyuk (<a href="https://www.linkedin.com/in/borismamlyuk/" style="color: #0077b5; text-decoration: none;">LinkedIn</a>)</p>
         <p style="color: #666;">Founder, <a href="https://cleanapp.io" style="color: #0077b5; text-decoration: none;">CleanApp.io</a></p>
-    </div>
+    </div><!--/clip:optional-->
 
     <div class="footer">
-        <p>To unsubscribe from these emails, please <a href="%s?email=%s" style="color: #007bff; text-decoration: none;">click here</a></p>
+        <p>To unsubscribe from these emails, please <a href="%s" style="color: #007bff; text-decoration: none;">click here</a></p>
     </div>
 </body>
 </html>`,
 		summary.NewReportCount, brandDisplay,
+		t.primary, t.accent, t.primary, t.primary,
 		summary.NewReportCount, newReportText, brandDisplay, summary.TotalReportCount,
 		dashboardURL,
-		optOutURL, recipient)
+		t.primary,
+		e.optOutLink(optOutURL, recipient))
 }
 
 // getAggregateDashboardURL generates the dashboard URL for aggregate notifications
@@ -268,213 +465,332 @@ func (e *EmailSender) getAggregateDashboardURL(summary *models.BrandReportSummar
 	return fmt.Sprintf("%s/reports", baseURL)
 }
 
-// sendOneEmail sends an email to a single recipient
-func (e *EmailSender) sendOneEmail(recipient string, reportImage, mapImage []byte) error {
+// sendOneEmail sends an email to a single recipient, in their language
+func (e *EmailSender) sendOneEmail(b *batch, r Recipient, reportImage, mapImage *inlineImage) error {
+	recipient := r.Email
+	m := e.messages(r.Locale)
+	cids := e.newContentIDs(false)
 	from := mail.NewEmail(e.config.SendGridFromName, e.config.SendGridFromEmail)
-	subject := "You got a CleanApp report"
+	subject := m["subject"]
+	kind, inReplyTo := "Email", ""
+	if b != nil && b.inReplyTo != "" {
+		subject = m["updatedSubject"] + subject
+		kind, inReplyTo = "Updated email", b.inReplyTo
+	}
 	to := mail.NewEmail(recipient, recipient)
 
-	hasReport := len(reportImage) > 0
-	hasMap := len(mapImage) > 0
+	hasReport := reportImage != nil
+	hasMap := mapImage != nil
 
 	// Create message
 	message := mail.NewV3Mail()
 	message.SetFrom(from)
 	message.Subject = subject
+	e.applyHeaders(b, message, recipient)
+	setThreadHeaders(message, inReplyTo)
 
 	p := mail.NewPersonalization()
 	p.AddTos(to)
+	b.addCopies(p, recipient)
+	e.setListUnsubscribe(p, e.config.OptOutURL, recipient)
 	message.AddPersonalizations(p)
 
-	message.AddContent(mail.NewContent("text/plain", e.getEmailText(recipient, hasReport, hasMap)))
-	message.AddContent(mail.NewContent("text/html", e.getEmailHtml(recipient, hasReport, hasMap)))
+	if err := e.addBodies(message, recipient, e.getEmailText(recipient, m, hasReport, hasMap), func() string {
+		return e.getEmailHtml(recipient, m, reportImage, mapImage, cids)
+	}); err != nil {
+		return err
+	}
 
 	if hasReport {
-		encodedReportImage := base64.StdEncoding.EncodeToString(reportImage)
-		reportAttachment := mail.NewAttachment()
-		reportAttachment.SetContent(encodedReportImage)
-		reportAttachment.SetType("image/jpeg")
-		reportAttachment.SetFilename("report.jpg")
-		reportAttachment.SetDisposition("inline")
-		reportAttachment.SetContentID(reportImgCid)
-		message.AddAttachment(reportAttachment)
+		e.addImage(message, recipient, reportImage, attachmentFilename("report", nil, reportImage.raw, ".jpg"), cids.report)
 	}
 
 	// Add map attachment only if mapImage is provided
 	if hasMap {
-		encodedMapImage := base64.StdEncoding.EncodeToString(mapImage)
-		mapAttachment := mail.NewAttachment()
-		mapAttachment.SetContent(encodedMapImage)
-		mapAttachment.SetType("image/png")
-		mapAttachment.SetFilename("map.png")
-		mapAttachment.SetDisposition("inline")
-		mapAttachment.SetContentID(mapImgCid)
-		message.AddAttachment(mapAttachment)
+		e.addImage(message, recipient, mapImage, attachmentFilename("map", nil, mapImage.raw, ".png"), cids.mapImg)
 	}
 
 	// Send email
-	start := time.Now()
-	response, err := e.client.Send(message)
+	return e.deliver(b, message, recipient, kind)
+}
+
+// analysisRender carries per-send rendering choices for the analysis email bodies
+type analysisRender struct {
+	mediaURL  string       // Link shown in place of attachments withheld below the severity threshold
+	updated   bool         // Render the "Updated analysis" banner for corrections
+	textOnly  bool         // No HTML part is sent, so the text body carries the full metrics
+	composite bool         // The report image is the combined report and map image
+	reportImg *inlineImage // Attached report (or composite) image, for sizing its tag
+	mapImg    *inlineImage // Attached map image, for sizing its tag
+	cids      contentIDs   // Content-IDs of the attached images, unique to the message
+	name      string       // Recipient name for the greeting, if known
+	locale    string       // Recipient locale choosing the copy's language; "" for DefaultLocale
+}
+
+// sendOneEmailWithAnalysis sends an email to a single recipient with analysis data
+func (e *EmailSender) sendOneEmailWithAnalysis(b *batch, recipient Recipient, reportImage, mapImage *inlineImage, analysis *models.ReportAnalysis) error {
+	return e.sendAnalysisEmail(b, recipient, reportImage, mapImage, analysis, "")
+}
+
+// sendAnalysisEmail sends an analysis email to a single recipient; a non-empty
+// inReplyTo marks it as an update threaded under that earlier Message-ID
+func (e *EmailSender) sendAnalysisEmail(b *batch, r Recipient, reportImage, mapImage *inlineImage, analysis *models.ReportAnalysis, inReplyTo string) error {
+	message, err := e.buildAnalysisEmail(b, r, reportImage, mapImage, analysis, inReplyTo)
 	if err != nil {
 		return err
 	}
 
-	duration := time.Since(start)
-	if response.StatusCode >= 200 && response.StatusCode < 300 {
-		msgID := response.Headers["X-Message-Id"]
-		log.Infof("Email accepted by SendGrid for %s (status=%d, id=%s, in %s)", recipient, response.StatusCode, msgID, duration)
-		return nil
-	}
-
-	body := response.Body
-	if len(body) > 512 {
-		body = body[:512] + "..."
+	// Send email
+	kind := "Email with analysis"
+	if inReplyTo != "" {
+		kind = "Updated email with analysis"
 	}
-	return fmt.Errorf("sendgrid returned status %d for %s (in %s): %s", response.StatusCode, recipient, duration, body)
+	return e.deliver(b, message, r.Email, kind)
 }
 
-// sendOneEmailWithAnalysis sends an email to a single recipient with analysis data
-func (e *EmailSender) sendOneEmailWithAnalysis(recipient string, reportImage, mapImage []byte, analysis *models.ReportAnalysis) error {
-	from := mail.NewEmail(e.config.SendGridFromName, e.config.SendGridFromEmail)
+// buildAnalysisEmail builds the analysis email sendAnalysisEmail sends to r
+func (e *EmailSender) buildAnalysisEmail(b *batch, r Recipient, reportImage, mapImage *inlineImage, analysis *models.ReportAnalysis, inReplyTo string) (*mail.SGMailV3, error) {
+	recipient := r.Email
+	brand := e.brandFor(analysis)
+	from := mail.NewEmail(brand.FromName, brand.FromEmail)
 
-	// Create data-driven subject line: "Brand issue #N: Title"
-	brandDisplay := analysis.BrandDisplayName
-	if brandDisplay == "" {
-		brandDisplay = analysis.BrandName
+	subject, variant := e.subjectVariant(recipient, r.Locale, analysis)
+	render := analysisRender{updated: inReplyTo != "", textOnly: e.textOnly(recipient), name: r.Name, locale: r.Locale}
+	if render.updated {
+		subject = e.messages(r.Locale)["updatedSubject"] + subject
 	}
-	if brandDisplay == "" {
-		brandDisplay = "Unknown"
-	}
-	
-	// Truncate title to ~50 chars for subject line
-	shortTitle := analysis.Title
-	if len(shortTitle) > 50 {
-		shortTitle = shortTitle[:47] + "..."
-	}
-	
-	subject := fmt.Sprintf("%s issue #%d: %s", brandDisplay, analysis.BrandReportCount, shortTitle)
+	e.checkInboxPreviewLength(b, subject, render, analysis)
 
 	to := mail.NewEmail(recipient, recipient)
-
-	hasReport := len(reportImage) > 0
-	hasMap := len(mapImage) > 0
+	hasReport, hasMap := e.analysisImages(recipient, reportImage, mapImage, analysis, &render)
 
 	// Create message
 	message := mail.NewV3Mail()
 	message.SetFrom(from)
 	message.Subject = subject
+	e.applyHeaders(b, message, recipient)
+	e.applyCategories(b, message, analysis, variant)
+
+	setThreadHeaders(message, inReplyTo)
+	if id := e.messageID(r, analysis, inReplyTo); id != "" {
+		message.SetHeader("Message-ID", id)
+	}
+	if r.Locale != "" {
+		message.SetHeader("Content-Language", r.Locale)
+	}
+	e.applyCriticalBypass(message, recipient, analysis)
+	e.applyOnBehalfOf(message, recipient, analysis)
+	e.applyQuietHours(message, recipient, analysis)
 
 	p := mail.NewPersonalization()
 	p.AddTos(to)
+	b.addCopies(p, recipient)
+	if r.Brand != "" {
+		p.SetCustomArg("brand", r.Brand)
+	}
+	setAnalysisCustomArgs(p, analysis)
+	e.setListUnsubscribe(p, brand.OptOutURL, recipient)
 	message.AddPersonalizations(p)
 
-	message.AddContent(mail.NewContent("text/plain", e.getEmailTextWithAnalysis(recipient, analysis, hasReport, hasMap)))
-	message.AddContent(mail.NewContent("text/html", e.getEmailHtmlWithAnalysis(recipient, analysis, hasReport, hasMap)))
+	if err := e.addBodies(message, recipient, e.getEmailTextWithAnalysis(recipient, analysis, hasReport, hasMap, render), func() string {
+		return e.getEmailHtmlWithAnalysis(recipient, analysis, hasReport, hasMap, render)
+	}); err != nil {
+		return nil, err
+	}
 
+	e.addAnalysisImages(message, recipient, render, analysis)
+	return message, nil
+}
+
+// analysisImages decides which of the images an analysis email attaches and records
+// them on render. Below the brand's image severity threshold the report's media page is
+// linked instead, unless there is no link for the report. It returns whether the report
+// and map are attached.
+func (e *EmailSender) analysisImages(recipient string, reportImage, mapImage *inlineImage, analysis *models.ReportAnalysis, render *analysisRender) (hasReport, hasMap bool) {
+	hasReport = reportImage != nil
+	hasMap = mapImage != nil
+
+	if threshold := e.imageSeverityThreshold(analysis); (hasReport || hasMap) && analysis.SeverityLevel < threshold {
+		if link := e.getReportMediaURL(analysis); link != "" {
+			log.Infof("Severity %.1f below image threshold %.1f for %s, linking media instead of attaching",
+				analysis.SeverityLevel, threshold, recipient)
+			hasReport, hasMap = false, false
+			render.mediaURL = link
+		} else {
+			log.Warnf("Severity %.1f below image threshold %.1f for %s, but there is no media link for report %d; attaching the images",
+				analysis.SeverityLevel, threshold, recipient, analysis.Seq)
+		}
+	}
+	render.composite = hasReport && reportImage.composite
+	render.cids = e.newContentIDs(render.composite)
 	if hasReport {
-		encodedReportImage := base64.StdEncoding.EncodeToString(reportImage)
-		reportAttachment := mail.NewAttachment()
-		reportAttachment.SetContent(encodedReportImage)
-		reportAttachment.SetType("image/jpeg")
-		reportAttachment.SetFilename("report.jpg")
-		reportAttachment.SetDisposition("inline")
-		reportAttachment.SetContentID(reportImgCid)
-		message.AddAttachment(reportAttachment)
+		render.reportImg = reportImage
+	}
+	if hasMap {
+		render.mapImg = mapImage
+	}
+	return hasReport, hasMap
+}
+
+// addAnalysisImages attaches the images analysisImages chose for the message
+func (e *EmailSender) addAnalysisImages(message *mail.SGMailV3, recipient string, render analysisRender, analysis *models.ReportAnalysis) {
+	if render.composite {
+		e.addImage(message, recipient, render.reportImg, attachmentFilename("report-map", analysis, render.reportImg.raw, ".jpg"), render.cids.report)
+	} else if render.reportImg != nil {
+		e.addImage(message, recipient, render.reportImg, attachmentFilename("report", analysis, render.reportImg.raw, ".jpg"), render.cids.report)
 	}
 
 	// Add map attachment only if mapImage is provided
-	if hasMap {
-		encodedMapImage := base64.StdEncoding.EncodeToString(mapImage)
-		mapAttachment := mail.NewAttachment()
-		mapAttachment.SetContent(encodedMapImage)
-		mapAttachment.SetType("image/png")
-		mapAttachment.SetFilename("map.png")
-		mapAttachment.SetDisposition("inline")
-		mapAttachment.SetContentID(mapImgCid)
-		message.AddAttachment(mapAttachment)
+	if render.mapImg != nil {
+		e.addImage(message, recipient, render.mapImg, attachmentFilename("map", analysis, render.mapImg.raw, ".png"), render.cids.mapImg)
 	}
+}
 
-	// Send email
-	start := time.Now()
-	response, err := e.client.Send(message)
-	if err != nil {
-		return err
+// BuildSubject creates the data-driven subject line "Brand issue #N: Title" in the
+// DefaultLocale language. An empty title is replaced with the configured
+// per-classification fallback, and the configured classification emoji, if any, is prefixed.
+func (e *EmailSender) BuildSubject(analysis *models.ReportAnalysis) string {
+	return e.buildSubject(analysis, "")
+}
+
+// buildSubject is BuildSubject in the language of locale
+func (e *EmailSender) buildSubject(analysis *models.ReportAnalysis, locale string) string {
+	subject := e.buildSubjectText(analysis, locale)
+	if emoji := e.subjectEmoji(analysis); emoji != "" {
+		return emoji + " " + subject
 	}
+	return subject
+}
 
-	duration := time.Since(start)
-	if response.StatusCode >= 200 && response.StatusCode < 300 {
-		msgID := response.Headers["X-Message-Id"]
-		log.Infof("Email with analysis accepted by SendGrid for %s (status=%d, id=%s, in %s)", recipient, response.StatusCode, msgID, duration)
-		return nil
+// buildSubjectText builds the subject line in the language of locale without any emoji prefix
+func (e *EmailSender) buildSubjectText(analysis *models.ReportAnalysis, locale string) string {
+	brandDisplay := analysis.BrandDisplayName
+	if brandDisplay == "" {
+		brandDisplay = analysis.BrandName
 	}
-	body := response.Body
-	if len(body) > 512 {
-		body = body[:512] + "..."
+	if brandDisplay == "" {
+		brandDisplay = "Unknown"
+	}
+
+	shortTitle := strings.TrimSpace(analysis.Title)
+	if shortTitle == "" {
+		if analysis.Classification == "digital" {
+			shortTitle = e.config.EmptyTitleFallbackDigital
+		} else {
+			shortTitle = e.config.EmptyTitleFallbackPhysical
+		}
 	}
-	return fmt.Errorf("sendgrid returned status %d for %s (in %s): %s", response.StatusCode, recipient, duration, body)
+
+	issue := fmt.Sprintf(e.messages(locale)["subjectIssue"], brandDisplay, analysis.BrandReportCount)
+	issues := e.getSubAnalysisSubject(analysis)
+
+	// Shorten the title so the whole subject, emoji included, fits maxSubjectLen
+	room := maxSubjectLen - utf8.RuneCountInString(issue+": "+issues)
+	if emoji := e.subjectEmoji(analysis); emoji != "" {
+		room -= utf8.RuneCountInString(emoji + " ")
+	}
+	shortTitle = truncateWords(shortTitle, min(e.subjectTitleLen(), room), "...")
+	if shortTitle == "" {
+		return issue + issues
+	}
+	return issue + ": " + shortTitle + issues
+}
+
+// subjectTitleLen returns the configured length the subject's title is cut to
+func (e *EmailSender) subjectTitleLen() int {
+	if e.config.MaxSubjectTitleLen > 0 {
+		return e.config.MaxSubjectTitleLen
+	}
+	return defaultMaxSubjectTitleLen
+}
+
+// bodyDescription returns the report description cut to MaxBodyDescriptionLen for the body
+func (e *EmailSender) bodyDescription(description string) string {
+	if e.config.MaxBodyDescriptionLen <= 0 {
+		return description
+	}
+	return truncateWords(description, e.config.MaxBodyDescriptionLen, "...")
+}
+
+// subjectEmoji returns the configured subject icon for the analysis. The classification
+// is looked up first; physical reports may also use the "hazard" or "litter" icon,
+// whichever probability dominates. SendGrid's JSON API carries the subject as UTF-8 and
+// handles its MIME encoding.
+func (e *EmailSender) subjectEmoji(analysis *models.ReportAnalysis) string {
+	if !e.config.SubjectEmojiEnabled {
+		return ""
+	}
+	if emoji, ok := e.config.SubjectEmoji[analysis.Classification]; ok {
+		return emoji
+	}
+	if analysis.Classification == "digital" {
+		return ""
+	}
+	if analysis.HazardProbability >= analysis.LitterProbability {
+		return e.config.SubjectEmoji["hazard"]
+	}
+	return e.config.SubjectEmoji["litter"]
 }
 
-// addLabel adds text to an image
-func (e *EmailSender) addLabel(img *image.RGBA, text string, x, y int) {
-	point := fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
+// setThreadHeaders threads a correction under the original email's Message-ID with
+// In-Reply-To and References, or does nothing for an empty ID
+func setThreadHeaders(message *mail.SGMailV3, inReplyTo string) {
+	if inReplyTo == "" {
+		return
+	}
+	originalID := formatMessageID(inReplyTo)
+	message.SetHeader("In-Reply-To", originalID)
+	message.SetHeader("References", originalID)
+}
 
-	d := &font.Drawer{
-		Dst:  img,
-		Src:  image.Black,
-		Face: basicfont.Face7x13,
-		Dot:  point,
+// formatMessageID wraps a bare message ID in the angle brackets RFC 5322 requires
+func formatMessageID(id string) string {
+	id = strings.TrimSpace(id)
+	if strings.HasPrefix(id, "<") && strings.HasSuffix(id, ">") {
+		return id
 	}
-	d.DrawString(text)
+	return "<" + strings.Trim(id, "<>") + ">"
 }
 
 // getEmailText returns the plain text content for emails
-func (e *EmailSender) getEmailText(recipient string, hasReport, hasMap bool) string {
+func (e *EmailSender) getEmailText(recipient string, m messages, hasReport, hasMap bool) string {
 	sections := ""
 	if hasReport || hasMap {
-		sections = "\nThis email contains:\n"
+		sections = "\n" + m["emailContains"] + "\n"
 		if hasReport {
-			sections += "- The report image\n"
+			sections += "- " + m["reportImageItem"] + "\n"
 		}
 		if hasMap {
-			sections += "- A map showing the location\n"
+			sections += "- " + m["mapItem"] + "\n"
 		}
 	}
-	return fmt.Sprintf(`Hello,
+	return fmt.Sprintf(`%s
 
-You have received a new CleanApp report.%s
-Best regards,
-The CleanApp Team`, sections)
+%s%s
+%s
+%s`, m["hello"], m["newReport"], sections, m["regards"], m["team"])
 }
 
 // getEmailHtml returns the HTML content for emails
-func (e *EmailSender) getEmailHtml(recipient string, hasReport, hasMap bool) string {
+func (e *EmailSender) getEmailHtml(recipient string, m messages, reportImg, mapImg *inlineImage, cids contentIDs) string {
 	imagesSection := ""
-	if hasReport {
-		imagesSection += fmt.Sprintf(`
-    <h3>Report Image:</h3>
-    <img src="cid:%s" alt="Report Image" style="max-width: 100%%; height: auto;">`, reportImgCid)
+	if reportImg != nil {
+		imagesSection += `
+    <h3>` + html.EscapeString(m["reportImageHeading"]) + `</h3>
+    ` + e.inlineImgTag(cids.report, "Report Image", reportImg, "max-width: 100%; height: auto")
 	}
-	if hasMap {
-		imagesSection += fmt.Sprintf(`
-    <h3>Location Map:</h3>
-    <img src="cid:%s" alt="Map" style="max-width: 100%%; height: auto;">`, mapImgCid)
+	if mapImg != nil {
+		imagesSection += `
+    <h3>` + html.EscapeString(m["mapHeading"]) + `</h3>
+    ` + e.inlineImgTag(cids.mapImg, "Map", mapImg, "max-width: 100%; height: auto")
 	}
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="utf-8">
-    <title>CleanApp Report</title>
-</head>
-<body>
-    <h2>Hello,</h2>
-    <p>You have received a new CleanApp report.</p>%s
-    <p>Best regards,<br>The CleanApp Team</p>
-</body>
-</html>`, imagesSection)
+	return renderTemplate("email.html.tmpl", emailView{T: m, Images: template.HTML(imagesSection)})
 }
 
 // getEmailTextWithAnalysis returns the plain text content for emails with analysis data
-func (e *EmailSender) getEmailTextWithAnalysis(recipient string, analysis *models.ReportAnalysis, hasReport, hasMap bool) string {
+func (e *EmailSender) getEmailTextWithAnalysis(recipient string, analysis *models.ReportAnalysis, hasReport, hasMap bool, render analysisRender) string {
+	m := e.messages(render.locale)
+
 	// Get brand display name
 	brandDisplay := analysis.BrandDisplayName
 	if brandDisplay == "" {
@@ -484,14 +800,15 @@ func (e *EmailSender) getEmailTextWithAnalysis(recipient string, analysis *model
 		brandDisplay = "this product"
 	}
 
-	// Get the dashboard URL
-	ctaURL := e.getDashboardURL(analysis)
-
-	// Dynamic CTA text
+	// Dynamic CTA text, left out when there is no dashboard to link to
 	ctaText := fmt.Sprintf("View all %d reports about %s", analysis.BrandReportCount, brandDisplay)
 	if analysis.BrandReportCount <= 1 {
 		ctaText = fmt.Sprintf("View report about %s", brandDisplay)
 	}
+	cta := ""
+	if ctaURL := e.getCTAURL(analysis); ctaURL != "" {
+		cta = fmt.Sprintf("%s: %s", ctaText, ctaURL)
+	}
 
 	// Get the AI-generated cost estimate or provide a default
 	costEstimate := analysis.LegalRiskEstimate
@@ -503,34 +820,62 @@ func (e *EmailSender) getEmailTextWithAnalysis(recipient string, analysis *model
 		}
 	}
 
+	liability := fmt.Sprintf("\nESTIMATED LIABILITY:\n%s\n", costEstimate)
+	if r, ok := e.riskRange(analysis); ok {
+		liability = fmt.Sprintf("\nESTIMATED RISK RANGE:\n%s\n", getRiskRangeText(r))
+	} else if analysis.Classification == "digital" && analysis.LegalRiskEstimate == "" {
+		liability = ""
+	}
+
 	attachments := ""
 	if hasReport || hasMap {
 		attachments = "\nThis email contains:\n"
-		if hasReport {
+		if render.composite {
+			attachments += "- The report image and a map showing the location, side by side\n"
+		} else if hasReport {
 			attachments += "- The report image\n"
 		}
 		if hasMap {
 			attachments += "- A map showing the location\n"
 		}
+	} else if render.mediaURL != "" {
+		attachments = fmt.Sprintf("\n%s: %s\n", m["mediaLink"], render.mediaURL)
 	}
 
-	legalRiskPercent := analysis.HazardProbability * 100
+	// Notices shown above the report details
+	intro := getGreeting(render.name, m)
+	if render.updated {
+		intro += strings.ToUpper(m["updatedLabel"]) + " " + m["updatedNotice"] + "\n\n"
+	}
+	if sentence := e.getSeveritySentence(analysis); sentence != "" {
+		intro += sentence + ".\n\n"
+	}
+	if note := e.getGeofenceNote(recipient, analysis); note != "" {
+		intro += note + "\n\n"
+	}
 
-	content := fmt.Sprintf(`This is the #%d report CleanApp users have submitted about %s. Here's what they're seeing:
+	legalRiskPercent := analysis.HazardProbability * 100
 
-REPORT DETAILS:
-Title: %s
-Description: %s
-Type: %s Issue
+	metrics := ""
+	if !e.hideMetrics(analysis) {
+		// Text-only recipients don't get the HTML gauges, so spell the metrics out
+		if render.textOnly {
+			metrics = fmt.Sprintf("\nMETRICS:\n%s\n", e.getMetricsText(analysis, m))
+		}
+		metrics += e.getSubAnalysisText(analysis, m)
+		metrics += fmt.Sprintf("\n%s: %.1f%%\n%s", strings.ToUpper(m["legalRiskFactor"]), legalRiskPercent, liability)
+	}
 
-LEGAL RISK FACTOR: %.1f%%
+	content := fmt.Sprintf(`%s%s
 
-ESTIMATED LIABILITY:
-%s
+%s:
+%s %s%s
+Description: %s
+%s %s
+%s%s%s
 %s
-%s: %s
 
-It takes just 30 seconds to review reports, confirm the risks, and get a fix.
+It takes just 30 seconds to review reports, confirm the risks, and get a fix.%s
 
 ---
 
@@ -542,26 +887,32 @@ https://www.linkedin.com/in/borismamlyuk/
 
 ---
 
-To unsubscribe from these emails, please visit: %s?email=%s
-You can also reply to this email with "UNSUBSCRIBE" in the subject line.`,
-		analysis.BrandReportCount,
-		brandDisplay,
+%s
+%s`,
+		intro,
+		fmt.Sprintf(m["intro"], fmt.Sprintf("#%d", analysis.BrandReportCount), brandDisplay),
+		strings.ToUpper(m["reportDetails"]),
+		m["titleLabel"],
 		analysis.Title,
-		analysis.Description,
-		analysis.Classification,
-		legalRiskPercent,
-		costEstimate,
+		e.getConfidenceText(analysis),
+		e.bodyDescription(analysis.Description),
+		m["typeLabel"],
+		fmt.Sprintf(m["typeIssue"], analysis.Classification),
+		e.getReportMetaText(recipient, analysis, m),
+		metrics,
 		attachments,
-		ctaText,
-		ctaURL,
-		e.config.OptOutURL,
-		recipient)
+		cta,
+		e.getNextStepsText(analysis),
+		fmt.Sprintf(m["unsubscribeText"], e.optOutLink(e.brandFor(analysis).OptOutURL, recipient)),
+		m["unsubscribeReply"])
 
 	return content
 }
 
 // getEmailHtmlWithAnalysis returns the HTML content for emails with analysis data
-func (e *EmailSender) getEmailHtmlWithAnalysis(recipient string, analysis *models.ReportAnalysis, hasReport, hasMap bool) string {
+func (e *EmailSender) getEmailHtmlWithAnalysis(recipient string, analysis *models.ReportAnalysis, hasReport, hasMap bool, render analysisRender) string {
+	m := e.messages(render.locale)
+
 	// Calculate gauge colors based on values
 	litterColor := e.getGaugeColor(analysis.LitterProbability)
 	hazardColor := e.getGaugeColor(analysis.HazardProbability)
@@ -579,100 +930,91 @@ func (e *EmailSender) getEmailHtmlWithAnalysis(recipient string, analysis *model
 		brandDisplay = "this product"
 	}
 
+	brand := e.brandFor(analysis)
+	t := e.getTheme(analysis.BrandName)
+	if brand.LogoURL != "" {
+		t.logoURL = html.EscapeString(brand.LogoURL)
+	}
+
+	metricsSection := ""
+	if !e.hideMetrics(analysis) {
+		metricsSection = clipOptional(e.getMetricsSection(analysis, m, isDigital, brandDisplay, litterColor, hazardColor, severityColor))
+	}
+
 	imagesSection := ""
-	if hasReport {
+	if render.composite {
 		imagesSection += fmt.Sprintf(`
         <div class="image-container">
-            <h3>Report Image:</h3>
-            <img src="cid:%s" alt="Report Image" style="max-width: 100%%; height: auto; border-radius: 5px;">
-        </div>`, reportImgCid)
+            <h3>Report Image and Location Map:</h3>
+            %s
+        </div>`, e.inlineImgTag(render.cids.report, "Report image and location map", render.reportImg, "max-width: 100%; height: auto; border-radius: 5px"))
+	} else if hasReport {
+		imagesSection += fmt.Sprintf(`
+        <div class="image-container">
+            <h3>%s</h3>
+            %s
+        </div>`, html.EscapeString(m["reportImageHeading"]), e.inlineImgTag(render.cids.report, "Report Image", render.reportImg, "max-width: 100%; height: auto; border-radius: 5px"))
 	}
 	if hasMap {
 		imagesSection += fmt.Sprintf(`
         <div class="image-container">
-            <h3>Location Map:</h3>
-            <img src="cid:%s" alt="Map" style="max-width: 100%%; height: auto; border-radius: 5px;">
-        </div>`, mapImgCid)
+            <h3>%s</h3>
+            %s
+        </div>`, html.EscapeString(m["mapHeading"]), e.inlineImgTag(render.cids.mapImg, "Map", render.mapImg, "max-width: 100%; height: auto; border-radius: 5px"))
+	}
+	if render.mediaURL != "" {
+		imagesSection += fmt.Sprintf(`
+        <div class="image-container">
+            <p><a href="%s" style="color: #007bff; text-decoration: none;">%s</a></p>
+        </div>`, html.EscapeString(render.mediaURL), html.EscapeString(m["mediaLink"]))
 	}
 
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="utf-8">
-    <title>%s issue #%d</title>
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-        .header { background-color: #f8f9fa; padding: 20px; border-radius: 5px; margin-bottom: 20px; }
-        .header h2 { margin: 0 0 10px 0; color: #333; }
-        .header p { margin: 0; color: #555; font-size: 1.1em; }
-        .report-count { font-weight: bold; color: #dc3545; }
-        .brand-name { font-weight: bold; }
-        .analysis-section { background-color: #e9ecef; padding: 15px; border-radius: 5px; margin: 15px 0; }
-        .gauge-grid { display: grid; grid-template-columns: repeat(3, 1fr); gap: 15px; margin: 20px 0; }
-        .gauge-item { background-color: #fff; padding: 15px; border-radius: 8px; text-align: center; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }
-        .gauge-title { font-size: 0.9em; font-weight: bold; margin-bottom: 10px; color: #555; }
-        .gauge-container { position: relative; width: 100%%; height: 60px; background: #f0f0f0; border-radius: 30px; overflow: hidden; margin: 10px 0; }
-        .gauge-fill { height: 100%%; border-radius: 30px; transition: width 0.3s ease; position: relative; }
-        .gauge-fill::after { content: ''; position: absolute; top: 2px; right: 2px; width: 8px; height: calc(100%% - 4px); background: rgba(255,255,255,0.3); border-radius: 4px; }
-        .gauge-value { font-size: 1.3em; font-weight: bold; margin-top: 8px; }
-        .gauge-label { font-size: 0.8em; color: #666; margin-top: 5px; }
-        .images { margin: 20px 0; }
-        .image-container { margin: 15px 0; }
-        .low { background: linear-gradient(90deg, #28a745, #20c997); }
-        .medium { background: linear-gradient(90deg, #ffc107, #fd7e14); }
-        .high { background: linear-gradient(90deg, #dc3545, #e83e8c); }
-        .digital-notice { background-color: #fff3cd; padding: 15px; border-radius: 5px; margin: 15px 0; border-left: 4px solid #ffc107; }
-    </style>
-</head>
-<body>
-    <div class="header">
-        <h2>New Issue Reported</h2>
-        <p>This is the <span class="report-count">#%d</span> report CleanApp users have submitted about <span class="brand-name">%s</span>. Here's what they're seeing:</p>
-    </div>
-    
-    <div class="analysis-section">
-        <h3>Report Details</h3>
-        <p><strong>Title:</strong> %s</p>
-        <p><strong>Description:</strong> %s</p>
-        <p><strong>Type:</strong> %s</p>
-    </div>
-    
-    %s
-    
-    <div class="images">%s
-    </div>
-    
-    <div style="margin-top: 30px; padding: 20px 0; border-top: 1px solid #eee;">
-        <p style="margin: 0; font-style: italic; color: #28a745;">Trash is cash,</p>
-        <p style="margin: 10px 0 0 0; font-weight: bold; color: #333;">Boris Mamlyuk (<a href="https://www.linkedin.com/in/borismamlyuk/" style="color: #0077b5; text-decoration: none;">LinkedIn</a>)</p>
-        <p style="margin: 0; color: #666;">Founder, <a href="https://cleanapp.io" style="color: #0077b5; text-decoration: none;">CleanApp.io</a></p>
-        <p style="margin: 15px 0 0 0;"><img src="https://cleanapp.io/cleanapp-logo.png" alt="CleanApp" style="max-width: 150px; height: auto;"></p>
+	heading := m["heading"]
+	updateBanner := ""
+	if render.updated {
+		heading = m["updatedHeading"]
+		updateBanner = fmt.Sprintf(`
+    <div class="digital-notice">
+        <strong>%s</strong> %s
     </div>
-    
-    <div style="margin-top: 20px; padding-top: 15px; border-top: 1px solid #eee; font-size: 0.85em; color: #999;">
-        <p>To unsubscribe from these emails, please <a href="%s?email=%s" style="color: #007bff; text-decoration: none;">click here</a></p>
-    </div>
-</body>
-</html>`,
-		brandDisplay,
-		analysis.BrandReportCount,
-		analysis.BrandReportCount,
-		brandDisplay,
-		analysis.Title,
-		analysis.Description,
-		analysis.Classification,
-		e.getMetricsSection(analysis, isDigital, brandDisplay, litterColor, hazardColor, severityColor),
-		imagesSection,
-		e.config.OptOutURL,
-		recipient)
+    `, html.EscapeString(m["updatedLabel"]), html.EscapeString(m["updatedNotice"]))
+	}
+
+	intro := fmt.Sprintf(m["intro"],
+		fmt.Sprintf(`<span class="report-count">#%d</span>`, analysis.BrandReportCount),
+		`<span class="brand-name">`+html.EscapeString(brandDisplay)+`</span>`)
+
+	return renderTemplate("analysis_email.html.tmpl", analysisEmailView{
+		T:                m,
+		DocTitle:         fmt.Sprintf(m["subjectIssue"], brandDisplay, analysis.BrandReportCount),
+		Heading:          heading,
+		Title:            analysis.Title,
+		Classification:   analysis.Classification,
+		OptOutHref:       template.HTMLAttr(`href="` + html.EscapeString(e.optOutLink(brand.OptOutURL, recipient)) + `"`),
+		GaugeLow:         template.CSS(e.getGaugeGradient("low")),
+		GaugeMedium:      template.CSS(e.getGaugeGradient("medium")),
+		GaugeHigh:        template.CSS(e.getGaugeGradient("high")),
+		SignatureColor:   template.CSS(t.primary),
+		Preheader:        template.HTML(e.getPreheaderHtml(analysis)),
+		Greeting:         template.HTML(getGreetingHtml(render.name, m)),
+		Intro:            template.HTML(intro),
+		UpdateBanner:     template.HTML(updateBanner),
+		SeveritySentence: template.HTML(e.getSeveritySentenceHtml(analysis)),
+		GeofenceNote:     template.HTML(e.getGeofenceNoteHtml(recipient, analysis)),
+		ConfidenceBadge:  template.HTML(e.getConfidenceBadgeHtml(analysis)),
+		Description:      template.HTML(e.getDescriptionHtml(e.bodyDescription(analysis.Description))),
+		ReportMeta:       template.HTML(e.getReportMetaHtml(recipient, analysis, m)),
+		Metrics:          template.HTML(metricsSection),
+		Images:           template.HTML(imagesSection),
+		NextSteps:        template.HTML(e.getNextStepsHtml(analysis)),
+		Logo:             template.HTML(e.imgTag(t.logoURL, "CleanApp", 150, 0, "max-width: 150px; height: auto")),
+	})
 }
 
 // getMetricsSection returns the Legal Risk Factor section with AI cost estimate
-func (e *EmailSender) getMetricsSection(analysis *models.ReportAnalysis, isDigital bool, brandDisplay, litterColor, hazardColor, severityColor string) string {
+func (e *EmailSender) getMetricsSection(analysis *models.ReportAnalysis, m messages, isDigital bool, brandDisplay, litterColor, hazardColor, severityColor string) string {
 	// Get the Legal Risk Factor gauge (based on hazard probability)
 	legalRiskColor := hazardColor
-	legalRiskValue := analysis.HazardProbability * 100
-	legalRiskLabel := e.getGaugeLabel(analysis.HazardProbability)
 
 	// Get the AI-generated cost estimate or provide a default
 	costEstimate := analysis.LegalRiskEstimate
@@ -685,53 +1027,142 @@ func (e *EmailSender) getMetricsSection(analysis *models.ReportAnalysis, isDigit
 	}
 
 	// Generate CTA button URL based on report type
-	ctaURL := e.getDashboardURL(analysis)
-	
+	ctaURL := e.getCTAURL(analysis)
+
 	// Dynamic CTA text: "View all N reports about Brand"
 	ctaText := fmt.Sprintf("View all %d reports about %s", analysis.BrandReportCount, brandDisplay)
 	if analysis.BrandReportCount <= 1 {
 		ctaText = fmt.Sprintf("View report about %s", brandDisplay)
 	}
+	ctaLabel := e.getCTALabel(ctaText, brandDisplay)
 
-	return fmt.Sprintf(`
+	ctaSection := ""
+	if ctaURL != "" {
+		ctaSection = fmt.Sprintf(`
+
+    <div style="text-align: center; margin: 25px 0;">
+        <a href="%s" title="%s" aria-label="%s" style="display: inline-block; background-color: %s; color: white; padding: 15px 40px; text-decoration: none; border-radius: 5px; font-weight: bold; font-size: 1.1em;">%s</a>
+        <p style="font-size: 0.85em; color: #666; margin-top: 10px;">It takes just 30 seconds to review reports, confirm the risks, and get a fix.</p>
+    </div>`, html.EscapeString(ctaURL), ctaLabel, ctaLabel, e.getTheme(analysis.BrandName).primary, html.EscapeString(ctaText))
+	}
+
+	// Reports with several distinct issues get a card with its own gauges per issue
+	gaugeSection := e.getSubAnalysisCardsHtml(analysis, m)
+	if gaugeSection == "" {
+		gaugeSection = e.getGaugeSection(analysis, m, legalRiskColor)
+	}
+
+	liabilitySection := fmt.Sprintf(`
+    <div style="background-color: #fff3cd; padding: 15px; border-radius: 5px; margin: 15px 0; border-left: 4px solid #ffc107;">
+        <p style="margin: 0; font-weight: bold; color: #856404;">💰 Estimated Liability</p>
+        <p style="margin: 5px 0 0 0; color: #856404;">%s</p>
+    </div>`, html.EscapeString(costEstimate))
+
+	// Digital reports show the estimated risk range when known, and skip the generic copy otherwise
+	if r, ok := e.riskRange(analysis); ok {
+		liabilitySection = e.getRiskRangeHtml(r)
+	} else if isDigital && analysis.LegalRiskEstimate == "" {
+		liabilitySection = ""
+	}
+
+	return fmt.Sprintf(`%s
+%s%s`,
+		gaugeSection,
+		liabilitySection,
+		ctaSection)
+}
+
+// getGaugeSection renders the Legal Risk Factor gauge for the analysis in color,
+// with the accessible table and severity rating when configured
+func (e *EmailSender) getGaugeSection(analysis *models.ReportAnalysis, m messages, legalRiskColor string) string {
+	legalRiskValue := analysis.HazardProbability * 100
+	legalRiskLabel := m[e.getGaugeColor(analysis.HazardProbability)]
+
+	gaugeSection := fmt.Sprintf(`
     <div style="margin: 20px 0;">
         <div style="background-color: #fff; padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1);">
-            <div style="font-size: 0.9em; font-weight: bold; margin-bottom: 10px; color: #555;">Legal Risk Factor</div>
-            <div style="position: relative; width: 100%%; height: 40px; background: #f0f0f0; border-radius: 20px; overflow: hidden; margin: 10px 0;">
-                <div class="%s" style="height: 100%%; width: %.1f%%; border-radius: 20px;"></div>
-            </div>
+            <div style="font-size: 0.9em; font-weight: bold; margin-bottom: 10px; color: #555;">%s</div>%s
             <div style="display: flex; justify-content: space-between; align-items: center;">
                 <div style="font-size: 1.5em; font-weight: bold;">%.1f%%</div>
                 <div style="font-size: 0.9em; color: #666;">%s</div>
             </div>
         </div>
-    </div>
+    </div>`,
+		html.EscapeString(m["legalRiskFactor"]), e.getGaugeBarHtml(m["legalRiskFactor"], analysis.HazardProbability, legalRiskColor), legalRiskValue, legalRiskLabel)
+
+	// Screen readers and plain clients get the metrics as a table instead of, or next to, the gauge
+	switch e.config.MetricsDisplay {
+	case config.MetricsDisplayTable:
+		gaugeSection = e.getMetricsTable(analysis, m)
+	case config.MetricsDisplayBoth:
+		gaugeSection += e.getMetricsTable(analysis, m)
+	}
+	return gaugeSection + e.getSeverityRatingHtml(analysis)
+}
 
-    <div style="background-color: #fff3cd; padding: 15px; border-radius: 5px; margin: 15px 0; border-left: 4px solid #ffc107;">
-        <p style="margin: 0; font-weight: bold; color: #856404;">💰 Estimated Liability</p>
-        <p style="margin: 5px 0 0 0; color: #856404;">%s</p>
-    </div>
+// getMetricsTable renders the analysis metrics as an accessible table of metric, value and band
+func (e *EmailSender) getMetricsTable(analysis *models.ReportAnalysis, m messages) string {
+	rows := e.metricRows(analysis, m)
 
-    <div style="text-align: center; margin: 25px 0;">
-        <a href="%s" style="display: inline-block; background-color: #28a745; color: white; padding: 15px 40px; text-decoration: none; border-radius: 5px; font-weight: bold; font-size: 1.1em;">%s</a>
-        <p style="font-size: 0.85em; color: #666; margin-top: 10px;">It takes just 30 seconds to review reports, confirm the risks, and get a fix.</p>
-    </div>`,
-		legalRiskColor, legalRiskValue, legalRiskValue, legalRiskLabel,
-		costEstimate,
-		ctaURL, ctaText)
+	cell := `style="padding: 8px; border-bottom: 1px solid #ddd; text-align: left;"`
+	body := ""
+	for _, row := range rows {
+		body += fmt.Sprintf(`
+            <tr><th scope="row" %s>%s</th><td %s>%s</td><td %s>%s</td></tr>`,
+			cell, row.metric, cell, row.value, cell, row.band)
+	}
+
+	return fmt.Sprintf(`
+    <table style="width: 100%%; border-collapse: collapse; margin: 20px 0; background-color: #fff;">
+        <caption style="text-align: left; font-weight: bold; color: #555; padding-bottom: 8px;">Analysis metrics</caption>
+        <thead>
+            <tr><th scope="col" %s>Metric</th><th scope="col" %s>Value</th><th scope="col" %s>Band</th></tr>
+        </thead>
+        <tbody>%s
+        </tbody>
+    </table>`, cell, cell, cell, body)
+}
+
+// metricRow is one analysis metric with its formatted value and band label
+type metricRow struct {
+	metric, value, band string
 }
 
-// getDashboardURL generates the appropriate dashboard URL based on report type
+// metricRows returns the litter, hazard and severity metrics shared by the
+// accessible table and the text-only body
+func (e *EmailSender) metricRows(analysis *models.ReportAnalysis, m messages) []metricRow {
+	return []metricRow{
+		{m["litterProbability"], fmt.Sprintf("%.1f%%", analysis.LitterProbability*100), m[e.getGaugeColor(analysis.LitterProbability)]},
+		{m["hazardProbability"], fmt.Sprintf("%.1f%%", analysis.HazardProbability*100), m[e.getGaugeColor(analysis.HazardProbability)]},
+		{m["severity"], fmt.Sprintf("%.1f / 10", analysis.SeverityLevel), m[e.getSeverityGaugeColor(analysis.SeverityLevel)]},
+	}
+}
+
+// getMetricsText returns the analysis metrics as plain text lines
+func (e *EmailSender) getMetricsText(analysis *models.ReportAnalysis, m messages) string {
+	lines := make([]string, 0, 3)
+	for _, row := range e.metricRows(analysis, m) {
+		lines = append(lines, fmt.Sprintf("- %s: %s (%s)", row.metric, row.value, row.band))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getDashboardURL generates the appropriate dashboard URL based on report type. It
+// returns "" for a digital report with no brand dashboard and no fallback configured.
 func (e *EmailSender) getDashboardURL(analysis *models.ReportAnalysis) string {
 	baseURL := "https://cleanapp.io"
 
 	if analysis.Classification == "digital" {
 		// For digital reports, link to brand-specific dashboard
-		brandSlug := analysis.BrandName
-		if brandSlug == "" {
-			brandSlug = "reports"
+		if link := e.getBrandDashboardURL(analysis); link != "" {
+			return link
 		}
-		return fmt.Sprintf("%s/digital/%s", baseURL, brandSlug)
+		if e.config.DashboardFallbackURL == "" {
+			log.Warnf("No dashboard URL for digital report %d without a brand, leaving out the dashboard button", analysis.Seq)
+			return ""
+		}
+		log.Warnf("No dashboard URL for digital report %d without a brand, using fallback %s", analysis.Seq, e.config.DashboardFallbackURL)
+		return e.config.DashboardFallbackURL
 	}
 
 	// For physical reports, link to the general reports dashboard
@@ -741,44 +1172,52 @@ func (e *EmailSender) getDashboardURL(analysis *models.ReportAnalysis) string {
 
 // getGaugeColor returns the CSS class for gauge color based on value
 func (e *EmailSender) getGaugeColor(value float64) string {
-	if value < 0.3 {
-		return "low"
-	} else if value < 0.7 {
-		return "medium"
-	} else {
-		return "high"
-	}
+	return gaugeBand(value, e.config.GaugeMediumThreshold, e.config.GaugeHighThreshold, 0.3, 0.7)
 }
 
 // getGaugeLabel returns a descriptive label based on the value
 func (e *EmailSender) getGaugeLabel(value float64) string {
-	if value < 0.3 {
-		return "Low"
-	} else if value < 0.7 {
-		return "Medium"
-	} else {
-		return "High"
-	}
+	return bandLabels[e.getGaugeColor(value)]
 }
 
 // getSeverityGaugeColor returns the CSS class for severity gauge color based on 0-10 scale
 func (e *EmailSender) getSeverityGaugeColor(value float64) string {
-	if value < 3.0 {
+	return gaugeBand(value, e.config.SeverityMediumThreshold, e.config.SeverityHighThreshold, 3.0, 7.0)
+}
+
+// getSeverityGaugeLabel returns a descriptive label for severity based on 0-10 scale
+func (e *EmailSender) getSeverityGaugeLabel(value float64) string {
+	return bandLabels[e.getSeverityGaugeColor(value)]
+}
+
+// bandLabels are the descriptive labels of the gauge bands
+var bandLabels = map[string]string{"low": "Low", "medium": "Medium", "high": "High"}
+
+// gaugeBand returns the low, medium or high band of value for the configured thresholds,
+// using the defaults while they are unset
+func gaugeBand(value, medium, high, defaultMedium, defaultHigh float64) string {
+	if medium <= 0 || high <= medium {
+		medium, high = defaultMedium, defaultHigh
+	}
+	if value < medium {
 		return "low"
-	} else if value < 7.0 {
+	} else if value < high {
 		return "medium"
-	} else {
-		return "high"
 	}
+	return "high"
 }
 
-// getSeverityGaugeLabel returns a descriptive label for severity based on 0-10 scale
-func (e *EmailSender) getSeverityGaugeLabel(value float64) string {
-	if value < 3.0 {
-		return "Low"
-	} else if value < 7.0 {
-		return "Medium"
-	} else {
-		return "High"
+// defaultGaugeGradients are the built-in CSS backgrounds of the gauge bands
+var defaultGaugeGradients = map[string]string{
+	"low":    "linear-gradient(90deg, #28a745, #20c997)",
+	"medium": "linear-gradient(90deg, #ffc107, #fd7e14)",
+	"high":   "linear-gradient(90deg, #dc3545, #e83e8c)",
+}
+
+// getGaugeGradient returns the CSS background of a gauge band, configured or built in
+func (e *EmailSender) getGaugeGradient(band string) string {
+	if gradient := e.config.GaugeGradients[band]; gradient != "" {
+		return gradient
 	}
+	return defaultGaugeGradients[band]
 }
@@ -0,0 +1,100 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestNilAnalysisFallsBackToReportEmail(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	err := e.SendEmailsWithAnalysis([]string{"a@example.com", "b@example.com"},
+		encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), nil)
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error for a nil analysis: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected both recipients sent the report email, got %d sends", len(sent))
+	}
+	for _, mail := range sent {
+		if want := e.messages("")["subject"]; mail.Subject != want {
+			t.Errorf("subject = %q, want the report email's %q", mail.Subject, want)
+		}
+		if len(mail.Attachments) != 2 {
+			t.Errorf("expected the report and map attached, got %d attachments", len(mail.Attachments))
+		}
+	}
+}
+
+func TestNilAnalysisFallsBackInBatchAndStream(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	if err := e.SendBatch(context.Background(), []string{"a@example.com"}, nil, nil, nil); err != nil {
+		t.Fatalf("SendBatch returned error for a nil analysis: %v", err)
+	}
+	recipients := make(chan Recipient, 1)
+	recipients <- Recipient{Email: "b@example.com"}
+	close(recipients)
+	if err := e.SendEmailsWithAnalysisStream(context.Background(), recipients, nil, nil, nil); err != nil {
+		t.Fatalf("SendEmailsWithAnalysisStream returned error for a nil analysis: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("expected 2 report emails, got %d", len(sent))
+	}
+}
+
+func TestSendUpdatedEmailsWithAnalysisThreadsUnderOriginal(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{}, transport)
+
+	if err := e.SendUpdatedEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(), "original-1@cleanapp.io"); err != nil {
+		t.Fatalf("SendUpdatedEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+	message := transport.messages[0]
+	for _, header := range []string{"In-Reply-To", "References"} {
+		if got := message.Headers[header]; got != "<original-1@cleanapp.io>" {
+			t.Errorf("%s = %q, want the original Message-ID in angle brackets", header, got)
+		}
+	}
+	if !strings.HasPrefix(message.Subject, "Updated: ") {
+		t.Errorf("Subject = %q, want the update prefix", message.Subject)
+	}
+	for _, content := range message.Content {
+		want := "UPDATED ANALYSIS: The analysis of this report was corrected"
+		if content.Type == "text/html" {
+			want = "<strong>Updated analysis:</strong> The analysis of this report was corrected"
+		}
+		if !strings.Contains(content.Value, want) {
+			t.Errorf("expected the %s body to carry the update banner", content.Type)
+		}
+	}
+}
+
+func TestSendUpdatedEmailsWithoutAnalysisKeepsThreading(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{}, transport)
+
+	if err := e.SendUpdatedEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, nil, "<original-1@cleanapp.io>"); err != nil {
+		t.Fatalf("SendUpdatedEmailsWithAnalysis returned error for a nil analysis: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+	message := transport.messages[0]
+	if want := "Updated: " + e.messages("")["subject"]; message.Subject != want {
+		t.Errorf("Subject = %q, want %q", message.Subject, want)
+	}
+	for _, header := range []string{"In-Reply-To", "References"} {
+		if got := message.Headers[header]; got != "<original-1@cleanapp.io>" {
+			t.Errorf("%s = %q, want the original Message-ID", header, got)
+		}
+	}
+}
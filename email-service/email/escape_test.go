@@ -0,0 +1,34 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestAnalysisHtmlEscapesReportFields(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	analysis := goldenAnalysis()
+	analysis.Title = "<script>alert(1)</script>"
+	analysis.Description = `Bins & bags </div><img src=x onerror="alert(2)">`
+	analysis.BrandDisplayName = "<b>Acme</b>"
+	analysis.LegalRiskEstimate = "<i>$5,000</i>"
+
+	body := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	for _, raw := range []string{"<script>", "</div><img", "<b>Acme</b>", "<i>$5,000</i>"} {
+		if strings.Contains(body, raw) {
+			t.Errorf("expected %q escaped in the HTML", raw)
+		}
+	}
+	for _, escaped := range []string{"&lt;script&gt;alert(1)&lt;/script&gt;", "Bins &amp; bags &lt;/div&gt;&lt;img", "&lt;b&gt;Acme&lt;/b&gt;", "&lt;i&gt;$5,000&lt;/i&gt;"} {
+		if !strings.Contains(body, escaped) {
+			t.Errorf("expected %q in the HTML", escaped)
+		}
+	}
+
+	text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(text, "Title: <script>alert(1)</script>") {
+		t.Error("expected the text version to keep the title as-is")
+	}
+}
@@ -0,0 +1,165 @@
+package email
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"github.com/apex/log"
+)
+
+// JPEG markers stripJPEGMetadata handles
+const (
+	jpegSOI  = 0xD8 // Start of image
+	jpegEOI  = 0xD9 // End of image
+	jpegSOS  = 0xDA // Start of scan, followed by the entropy-coded data
+	jpegAPP1 = 0xE1 // EXIF or XMP metadata
+)
+
+// exifOrientationTag is the TIFF tag of the EXIF orientation
+const exifOrientationTag = 0x0112
+
+// stripJPEGMetadata removes the APP1 segments, EXIF with its GPS position and device
+// details and XMP, from a phone photo without re-encoding it. A photo whose EXIF
+// orientation would have displayed it rotated or flipped is turned upright and
+// re-encoded instead, as the orientation goes with the metadata. Anything that isn't a
+// well-formed JPEG is returned unchanged.
+func stripJPEGMetadata(data []byte, kind string) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return data
+	}
+
+	out := make([]byte, 2, len(data))
+	copy(out, data[:2])
+	orientation, stripped := 1, 0
+	for i := 2; ; {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return data
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// Fill byte before a marker
+			i++
+			continue
+		}
+		if marker == jpegSOS || marker == jpegEOI {
+			out = append(out, data[i:]...)
+			break
+		}
+		if i+4 > len(data) {
+			return data
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return data
+		}
+		if marker == jpegAPP1 {
+			if o := exifOrientation(data[i+4 : end]); o != 0 {
+				orientation = o
+			}
+			stripped++
+		} else {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	if stripped == 0 {
+		return data
+	}
+	if orientation != 1 {
+		return orientJPEG(out, orientation, kind)
+	}
+	log.Debugf("Stripped %d metadata segments from %s image", stripped, kind)
+	return out
+}
+
+// exifOrientation returns the 1-8 orientation of an APP1 segment's EXIF payload, or 0
+// when it isn't EXIF or has no valid orientation
+func exifOrientation(payload []byte) int {
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := uint64(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > uint64(len(tiff)) {
+		return 0
+	}
+	entries := tiff[ifd+2:]
+	for range order.Uint16(tiff[ifd:]) {
+		if len(entries) < 12 {
+			return 0
+		}
+		if order.Uint16(entries) == exifOrientationTag {
+			if o := int(order.Uint16(entries[8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+		entries = entries[12:]
+	}
+	return 0
+}
+
+// orientJPEG decodes a metadata-free JPEG, applies the EXIF orientation to its pixels and
+// re-encodes it, falling back to the unrotated data when that fails
+func orientJPEG(data []byte, orientation int, kind string) []byte {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Warnf("Failed to decode %s image to apply its orientation: %v", kind, err)
+		return data
+	}
+	out, err := encodeJPEG(orientImage(src, orientation), resizeJPEGQuality)
+	if err != nil {
+		log.Warnf("Failed to re-encode %s image after applying its orientation: %v", kind, err)
+		return data
+	}
+	log.Debugf("Stripped metadata from %s image and applied orientation %d", kind, orientation)
+	return out
+}
+
+// orientImage returns src as displayed under EXIF orientation 1-8, e.g. rotated 90°
+// clockwise for 6
+func orientImage(src image.Image, orientation int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
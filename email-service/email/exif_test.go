@@ -0,0 +1,84 @@
+package email
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"email-service/config"
+)
+
+// gpsLatitudeRef is the bytes of the GPSLatitudeRef entry the test EXIF carries
+var gpsLatitudeRef = []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 'N', 0x00, 0x00, 0x00}
+
+// withEXIF inserts an APP1 EXIF segment with the given orientation and a GPS IFD holding
+// GPSLatitudeRef after the SOI marker of a JPEG
+func withEXIF(t *testing.T, jpegData []byte, orientation uint16) []byte {
+	t.Helper()
+	be := binary.BigEndian
+	tiff := []byte("MM\x00\x2A\x00\x00\x00\x08")
+	// IFD0: orientation and the GPS IFD pointer, then no next IFD
+	tiff = be.AppendUint16(tiff, 2)
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = be.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0x00, 0x00)
+	tiff = append(tiff, 0x88, 0x25, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01)
+	tiff = be.AppendUint32(tiff, 8+2+2*12+4)
+	tiff = be.AppendUint32(tiff, 0)
+	// GPS IFD
+	tiff = be.AppendUint16(tiff, 1)
+	tiff = append(tiff, gpsLatitudeRef...)
+	tiff = be.AppendUint32(tiff, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := be.AppendUint16([]byte{0xFF, jpegAPP1}, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return append(append(append([]byte{}, jpegData[:2]...), segment...), jpegData[2:]...)
+}
+
+func TestStripJPEGMetadataRemovesGPS(t *testing.T) {
+	original := encodeTestImage(t, 40, 20, "jpeg")
+	data := withEXIF(t, original, 1)
+	if !bytes.Contains(data, gpsLatitudeRef) {
+		t.Fatal("test JPEG doesn't carry the GPS tag")
+	}
+
+	got := stripJPEGMetadata(data, "report")
+	if bytes.Contains(got, gpsLatitudeRef) || bytes.Contains(got, []byte("Exif")) {
+		t.Error("expected the EXIF and GPS data to be stripped")
+	}
+	if !bytes.Equal(got, original) {
+		t.Error("expected the JPEG without its EXIF segment to be otherwise unchanged")
+	}
+}
+
+func TestStripJPEGMetadataAppliesOrientation(t *testing.T) {
+	got := stripJPEGMetadata(withEXIF(t, encodeTestImage(t, 40, 20, "jpeg"), 6), "report")
+	if bytes.Contains(got, gpsLatitudeRef) {
+		t.Error("expected the GPS data to be stripped")
+	}
+	if w, h, format := imageSize(t, got); w != 20 || h != 40 || format != "jpeg" {
+		t.Errorf("rotated image = %dx%d %s, want 20x40 jpeg", w, h, format)
+	}
+}
+
+func TestStripJPEGMetadataLeavesOtherImages(t *testing.T) {
+	for _, data := range [][]byte{encodeTestImage(t, 40, 20, "png"), encodeTestImage(t, 40, 20, "jpeg"), []byte("not an image")} {
+		if got := stripJPEGMetadata(data, "report"); !bytes.Equal(got, data) {
+			t.Error("expected an image without metadata to be returned unchanged")
+		}
+	}
+}
+
+func TestPrepareImagesStripsOnlyReportMetadata(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	data := withEXIF(t, encodeTestImage(t, 40, 20, "jpeg"), 1)
+
+	report, mapImg := e.prepareImages(data, data)
+	if bytes.Contains(report.raw, gpsLatitudeRef) {
+		t.Error("expected the report image's GPS data to be stripped")
+	}
+	if !bytes.Equal(mapImg.raw, data) {
+		t.Error("expected the map image to be left as rendered")
+	}
+}
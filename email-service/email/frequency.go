@@ -0,0 +1,123 @@
+package email
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is the SendResult.Skipped reason of a recipient who already got
+// MaxEmailsPerRecipient emails within FrequencyWindow
+var ErrThrottled = fmt.Errorf("%w: recipient frequency cap reached", errSkipped)
+
+// FrequencyStore counts the emails sent to each recipient for frequency capping.
+// Implementations must be safe for concurrent use.
+type FrequencyStore interface {
+	// Allow reports whether email got fewer than limit emails within the window ending
+	// at now and, if so, counts one more at now. Checking and counting are one step so
+	// concurrent sends can't both take the last slot.
+	Allow(email string, now time.Time, window time.Duration, limit int) bool
+
+	// Refund takes back the email Allow counted for email at at, once the send it was
+	// counted for failed or was never made, e.g. in a dry run
+	Refund(email string, at time.Time)
+}
+
+// MemoryFrequencyStore is the in-memory FrequencyStore an EmailSender uses by default,
+// keeping each recipient's send times within the window. Counts are per process and
+// lost on restart.
+type MemoryFrequencyStore struct {
+	mu    sync.Mutex
+	sends map[string][]time.Time // Send times per address, oldest first
+}
+
+// NewMemoryFrequencyStore returns an empty in-memory frequency store
+func NewMemoryFrequencyStore() *MemoryFrequencyStore {
+	return &MemoryFrequencyStore{sends: make(map[string][]time.Time)}
+}
+
+func (s *MemoryFrequencyStore) Allow(email string, now time.Time, window time.Duration, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sends := s.sends[email]
+	start := 0
+	for start < len(sends) && !sends[start].After(now.Add(-window)) {
+		start++
+	}
+	sends = sends[start:]
+	if len(sends) >= limit {
+		s.sends[email] = sends
+		return false
+	}
+	s.sends[email] = append(sends, now)
+	return true
+}
+
+func (s *MemoryFrequencyStore) Refund(email string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sends := s.sends[email]
+	for i := len(sends) - 1; i >= 0; i-- {
+		if sends[i].Equal(at) {
+			s.sends[email] = slices.Delete(sends, i, i+1)
+			return
+		}
+	}
+}
+
+// WithFrequencyStore counts sends for the frequency cap in store, e.g. one shared by
+// several instances, instead of the in-memory default
+func WithFrequencyStore(store FrequencyStore) Option {
+	return func(e *EmailSender) {
+		e.frequency = store
+	}
+}
+
+// allowFrequency reports whether recipient is under MaxEmailsPerRecipient and counts the
+// send when so, noting it on the batch for refundFrequency; it always allows when the
+// cap is off
+func (e *EmailSender) allowFrequency(b *batch, recipient string) bool {
+	if e.config.MaxEmailsPerRecipient <= 0 || e.frequency == nil {
+		return true
+	}
+	window := e.config.FrequencyWindow
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	now := e.now()
+	if !e.frequency.Allow(recipient, now, window, e.config.MaxEmailsPerRecipient) {
+		return false
+	}
+	if b != nil {
+		if b.counted == nil {
+			b.counted = make(map[string]time.Time)
+		}
+		b.counted[recipient] = now
+	}
+	return true
+}
+
+// refundFrequency takes back the frequency cap count of every recipient of the batch
+// that SendGrid didn't accept, e.g. failed, cancelled or skipped sends, and of every
+// recipient in DryRun mode, so only delivered emails use up the cap. Call it once the
+// batch's sends are done.
+func (e *EmailSender) refundFrequency(b *batch, report *batchReport) {
+	if len(b.counted) == 0 {
+		return
+	}
+	sent := make(map[string]bool, len(report.succeeded))
+	if !e.config.DryRun {
+		for _, recipient := range report.succeeded {
+			sent[recipient] = true
+		}
+	}
+	for recipient, at := range b.counted {
+		if !sent[recipient] {
+			e.frequency.Refund(recipient, at)
+		}
+	}
+	b.counted = nil
+}
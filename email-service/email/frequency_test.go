@@ -0,0 +1,153 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"email-service/config"
+)
+
+func TestFrequencyCapThrottlesRecipient(t *testing.T) {
+	const limit = 3
+	transport := &fakeTransport{}
+	now := time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC)
+	e := NewEmailSenderWithTransport(&config.Config{MaxEmailsPerRecipient: limit, FrequencyWindow: time.Hour}, transport,
+		WithClock(func() time.Time { return now }))
+
+	for i := range limit + 1 {
+		var result SendResult
+		if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(), WithResult(&result)); err != nil {
+			t.Fatalf("send %d returned error: %v", i+1, err)
+		}
+		throttled := errors.Is(result.Skipped["brand@example.com"], ErrThrottled)
+		if want := i == limit; throttled != want {
+			t.Errorf("send %d: throttled = %v, want %v", i+1, throttled, want)
+		}
+		now = now.Add(time.Minute)
+	}
+	if len(transport.messages) != limit {
+		t.Errorf("expected %d messages, got %d", limit, len(transport.messages))
+	}
+
+	// Once the first send leaves the window the recipient has room again
+	now = now.Add(time.Hour - 3*time.Minute)
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("send after the window returned error: %v", err)
+	}
+	if len(transport.messages) != limit+1 {
+		t.Errorf("expected the send after the window to go out, got %d messages", len(transport.messages))
+	}
+}
+
+func TestFrequencyCapAfterSuppression(t *testing.T) {
+	transport := &fakeTransport{}
+	store := NewMemoryFrequencyStore()
+	e := NewEmailSenderWithTransport(&config.Config{MaxEmailsPerRecipient: 1, FrequencyWindow: time.Hour}, transport, WithFrequencyStore(store))
+	e.Suppressions().Add("gone@example.com", "bounce")
+
+	var result SendResult
+	if err := e.SendEmails([]string{"gone@example.com", "a@example.com", "a@example.com"}, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if !errors.Is(result.Skipped["gone@example.com"], ErrSuppressed) {
+		t.Errorf("expected the suppressed address skipped as suppressed, got %v", result.Skipped)
+	}
+	if errors.Is(result.Skipped["a@example.com"], ErrThrottled) {
+		t.Error("expected a repeat within the batch skipped as a duplicate, not throttled")
+	}
+	// Suppressed and duplicate recipients don't use up the cap
+	if !store.Allow("gone@example.com", time.Now(), time.Hour, 1) {
+		t.Error("expected no send counted for the suppressed address")
+	}
+	if len(transport.messages) != 1 {
+		t.Errorf("expected 1 message, got %d", len(transport.messages))
+	}
+}
+
+func TestFrequencyCapOffByDefault(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{}, transport)
+	for range 5 {
+		if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+			t.Fatalf("SendEmails returned error: %v", err)
+		}
+	}
+	if len(transport.messages) != 5 {
+		t.Errorf("expected every send uncapped, got %d messages", len(transport.messages))
+	}
+}
+
+func TestFrequencyCapRefundsUnsentEmails(t *testing.T) {
+	analysis := goldenAnalysis()
+	tests := []struct {
+		name string
+		cfg  config.Config
+		send func(e *EmailSender) error
+	}{
+		{"failed send", config.Config{}, func(e *EmailSender) error {
+			return e.SendEmails([]string{"brand@example.com"}, nil, nil)
+		}},
+		{"failed batched request", config.Config{}, func(e *EmailSender) error {
+			return e.SendBatch(context.Background(), []string{"brand@example.com", "ops@example.com"}, nil, nil, analysis)
+		}},
+		{"dry run", config.Config{DryRun: true}, func(e *EmailSender) error {
+			return e.SendEmails([]string{"brand@example.com"}, nil, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryFrequencyStore()
+			cfg := tt.cfg
+			cfg.MaxEmailsPerRecipient, cfg.FrequencyWindow = 1, time.Hour
+			e := NewEmailSenderWithTransport(&cfg, &flakyTransport{failures: 1, status: http.StatusBadRequest}, WithFrequencyStore(store))
+
+			err := tt.send(e)
+			if cfg.DryRun && err != nil || !cfg.DryRun && err == nil {
+				t.Fatalf("send returned %v", err)
+			}
+			if !store.Allow("brand@example.com", time.Now(), time.Hour, 1) {
+				t.Error("expected the unsent email not to count towards the cap")
+			}
+		})
+	}
+}
+
+func TestFrequencyCapCountsAcceptedEmails(t *testing.T) {
+	store := NewMemoryFrequencyStore()
+	e := NewEmailSenderWithTransport(&config.Config{MaxEmailsPerRecipient: 1, FrequencyWindow: time.Hour},
+		&flakyTransport{failures: 1, status: http.StatusBadRequest}, WithFrequencyStore(store))
+
+	// The first send fails and is refunded, so the retry isn't throttled
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err == nil {
+		t.Fatal("expected the first send to fail")
+	}
+	var result SendResult
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(result.Succeeded) != 1 {
+		t.Fatalf("expected the retry sent, got %+v", result)
+	}
+	if store.Allow("brand@example.com", time.Now(), time.Hour, 1) {
+		t.Error("expected the accepted email to count towards the cap")
+	}
+}
+
+func TestMemoryFrequencyStoreRefund(t *testing.T) {
+	store := NewMemoryFrequencyStore()
+	now := time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC)
+	store.Allow("brand@example.com", now, time.Hour, 2)
+	store.Allow("brand@example.com", now.Add(time.Minute), time.Hour, 2)
+
+	store.Refund("brand@example.com", now)
+	store.Refund("brand@example.com", now) // Already refunded, nothing left at that time
+	if !store.Allow("brand@example.com", now.Add(2*time.Minute), time.Hour, 2) {
+		t.Error("expected one slot free after the refund")
+	}
+	if store.Allow("brand@example.com", now.Add(3*time.Minute), time.Hour, 2) {
+		t.Error("expected the send after the refund to take the last slot")
+	}
+}
@@ -0,0 +1,59 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestGaugeBandsDefault(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	tests := []struct {
+		value, severity float64
+		want            string
+	}{
+		{0.29, 2.9, "Low"},
+		{0.3, 3, "Medium"},
+		{0.69, 6.9, "Medium"},
+		{0.7, 7, "High"},
+	}
+	for _, tt := range tests {
+		if got := e.getGaugeLabel(tt.value); got != tt.want {
+			t.Errorf("getGaugeLabel(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+		if got := e.getSeverityGaugeLabel(tt.severity); got != tt.want {
+			t.Errorf("getSeverityGaugeLabel(%v) = %s, want %s", tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestGaugeBandsCustomThresholds(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		GaugeMediumThreshold:    0.5,
+		GaugeHighThreshold:      0.9,
+		SeverityMediumThreshold: 5,
+		SeverityHighThreshold:   9,
+	}}
+	if got := e.getGaugeLabel(0.4); got != "Low" {
+		t.Errorf("getGaugeLabel(0.4) = %s, want Low with a 0.5 threshold", got)
+	}
+	if got := e.getGaugeColor(0.8); got != "medium" {
+		t.Errorf("getGaugeColor(0.8) = %s, want medium below 0.9", got)
+	}
+	if got := e.getSeverityGaugeLabel(4); got != "Low" {
+		t.Errorf("getSeverityGaugeLabel(4) = %s, want Low with a threshold of 5", got)
+	}
+}
+
+func TestGaugeGradientOverride(t *testing.T) {
+	e := &EmailSender{config: &config.Config{GaugeGradients: map[string]string{"high": "linear-gradient(90deg, #000, #111)"}}}
+	body := e.getEmailHtmlWithAnalysis("brand@example.com", goldenAnalysis(), false, false, analysisRender{})
+
+	if !strings.Contains(body, ".high { background: linear-gradient(90deg, #000, #111); }") {
+		t.Error("expected the configured high gradient")
+	}
+	if !strings.Contains(body, ".low { background: "+defaultGaugeGradients["low"]+"; }") {
+		t.Error("expected the built-in low gradient without an override")
+	}
+}
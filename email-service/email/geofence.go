@@ -0,0 +1,62 @@
+package email
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"email-service/models"
+)
+
+// earthRadiusMeters is the mean Earth radius used for haversine distances
+const earthRadiusMeters = 6371000
+
+// haversineMeters returns the great-circle distance between two points in meters
+func haversineMeters(a, b models.Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// getGeofenceNote returns "This report is within 500m of your registered location."
+// when the recipient's registered location is within GeofenceRadiusMeters of the
+// report, or an empty string when disabled, out of range or either location is unknown
+func (e *EmailSender) getGeofenceNote(recipient string, analysis *models.ReportAnalysis) string {
+	if e.config.GeofenceRadiusMeters <= 0 {
+		return ""
+	}
+	home, ok := analysis.RecipientLocations[strings.ToLower(recipient)]
+	if !ok {
+		home, ok = analysis.RecipientLocations[recipient]
+	}
+	report := models.Location{Latitude: analysis.Latitude, Longitude: analysis.Longitude}
+	if !ok || home == (models.Location{}) || report == (models.Location{}) {
+		return ""
+	}
+	if haversineMeters(home, report) > e.config.GeofenceRadiusMeters {
+		return ""
+	}
+	return fmt.Sprintf("This report is within %s of your registered location.", formatDistance(e.config.GeofenceRadiusMeters))
+}
+
+// formatDistance formats meters as "500m" below a kilometer and "1.5km" above
+func formatDistance(meters float64) string {
+	if meters < 1000 {
+		return fmt.Sprintf("%.0fm", meters)
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", meters/1000), ".0") + "km"
+}
+
+// getGeofenceNoteHtml returns the geofence note for the analysis header, or an empty
+// string when there is no note for the recipient
+func (e *EmailSender) getGeofenceNoteHtml(recipient string, analysis *models.ReportAnalysis) string {
+	note := e.getGeofenceNote(recipient, analysis)
+	if note == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+        <p class="geofence-note" style="margin-top: 10px;">📍 %s</p>`, note)
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestHaversineMeters(t *testing.T) {
+	// One degree of latitude is about 111.2km
+	got := haversineMeters(models.Location{Latitude: 40, Longitude: -74}, models.Location{Latitude: 41, Longitude: -74})
+	if math.Abs(got-111195) > 100 {
+		t.Errorf("haversineMeters() = %.0f, want about 111195", got)
+	}
+}
+
+func TestGeofenceNote(t *testing.T) {
+	e := &EmailSender{config: &config.Config{GeofenceRadiusMeters: 500}}
+	analysis := &models.ReportAnalysis{
+		Latitude:  40.7580,
+		Longitude: -73.9855,
+		RecipientLocations: map[string]models.Location{
+			"near@example.com": {Latitude: 40.7600, Longitude: -73.9840}, // ~250m away
+			"far@example.com":  {Latitude: 40.7800, Longitude: -73.9855}, // ~2.4km away
+		},
+	}
+
+	const want = "This report is within 500m of your registered location."
+	if got := e.getGeofenceNote("Near@Example.com", analysis); got != want {
+		t.Errorf("getGeofenceNote(near) = %q, want %q", got, want)
+	}
+	if got := e.getGeofenceNote("far@example.com", analysis); got != "" {
+		t.Errorf("expected no note outside the radius, got %q", got)
+	}
+	if got := e.getGeofenceNote("other@example.com", analysis); got != "" {
+		t.Errorf("expected no note without a registered location, got %q", got)
+	}
+
+	html := e.getEmailHtmlWithAnalysis("near@example.com", analysis, false, false, analysisRender{})
+	text := e.getEmailTextWithAnalysis("near@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(html, want) || !strings.Contains(text, want) {
+		t.Error("expected the note in both the HTML and text bodies")
+	}
+
+	analysis.Latitude, analysis.Longitude = 0, 0
+	if got := e.getGeofenceNote("near@example.com", analysis); got != "" {
+		t.Errorf("expected no note without report coordinates, got %q", got)
+	}
+}
+
+func TestFormatDistance(t *testing.T) {
+	for meters, want := range map[float64]string{250: "250m", 1000: "1km", 1500: "1.5km"} {
+		if got := formatDistance(meters); got != want {
+			t.Errorf("formatDistance(%v) = %q, want %q", meters, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// newGoldenSender returns an EmailSender with a fixed clock and sequential IDs so
+// rendered output is stable across runs
+func newGoldenSender() *EmailSender {
+	cfg := &config.Config{
+		SendGridFromName:  "CleanApp",
+		SendGridFromEmail: "info@cleanapp.io",
+		OptOutURL:         "https://cleanapp.io/opt-out",
+		MetricsDisplay:    config.MetricsDisplayGauges,
+	}
+	fixed := time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+	return NewEmailSender(cfg, WithClock(func() time.Time { return fixed }), WithIDGenerator(SequentialIDs()))
+}
+
+// goldenAnalysis is a representative physical report analysis
+func goldenAnalysis() *models.ReportAnalysis {
+	return &models.ReportAnalysis{
+		Seq:               12345,
+		Title:             "Overflowing trash bin",
+		Description:       "A public trash bin next to the bus stop is overflowing onto the sidewalk.",
+		BrandName:         "acme",
+		BrandDisplayName:  "Acme",
+		LitterProbability: 0.82,
+		HazardProbability: 0.42,
+		SeverityLevel:     6.5,
+		Classification:    "physical",
+		LegalRiskEstimate: "$1,000 - $5,000",
+		BrandReportCount:  7,
+	}
+}
+
+// assertGolden compares got with testdata/name, rewriting it when -update is set
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update golden file %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file %s (run with -update to create it): %v", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("rendered output differs from %s; run go test ./email -update and review the diff", path)
+	}
+}
+
+func TestAnalysisEmailGolden(t *testing.T) {
+	e := newGoldenSender()
+	analysis := goldenAnalysis()
+	render := analysisRender{cids: e.newContentIDs(false)}
+
+	assertGolden(t, "analysis_email.html", e.getEmailHtmlWithAnalysis("brand@example.com", analysis, true, true, render))
+	assertGolden(t, "analysis_email.txt", e.getEmailTextWithAnalysis("brand@example.com", analysis, true, true, render))
+}
+
+func TestEmailGolden(t *testing.T) {
+	e := newGoldenSender()
+	reportImg := encodeInlineImage(encodeTestImage(t, 40, 30, "jpeg"))
+	mapImg := encodeInlineImage(encodeTestImage(t, 20, 20, "png"))
+
+	assertGolden(t, "email.html", e.getEmailHtml("brand@example.com", e.messages(""), reportImg, mapImg, e.newContentIDs(false)))
+}
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// reservedHeaders are the lowercase names SendGrid refuses in the headers object, as
+// they are set from other fields of the request
+var reservedHeaders = map[string]bool{
+	"x-sg-id": true, "x-sg-eid": true, "received": true, "dkim-signature": true,
+	"content-type": true, "content-transfer-encoding": true,
+	"to": true, "from": true, "subject": true, "reply-to": true, "cc": true, "bcc": true,
+}
+
+// WithHeaders sets custom headers, e.g. X-CleanApp-Report-Id, on every message of the
+// send. They are added to the configured Headers, replacing any of the same name.
+func WithHeaders(headers map[string]string) SendOption {
+	return func(o *sendOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string, len(headers))
+		}
+		maps.Copy(o.headers, headers)
+	}
+}
+
+// applyHeaders sets the configured Reply-To and the configured and per-send custom
+// headers on a brand email. It is called before the message's own headers are set, so
+// a custom header can't replace e.g. its Message-ID. Reserved names, and names or values
+// that could inject other headers, are skipped with a warning.
+func (e *EmailSender) applyHeaders(b *batch, message *mail.SGMailV3, recipient string) {
+	if e.config.ReplyTo != "" {
+		message.SetReplyTo(mail.NewEmail("", e.config.ReplyTo))
+	}
+
+	headers := e.config.Headers
+	if b != nil && len(b.headers) > 0 {
+		headers = make(map[string]string, len(e.config.Headers)+len(b.headers))
+		maps.Copy(headers, e.config.Headers)
+		maps.Copy(headers, b.headers)
+	}
+	for name, value := range headers {
+		if reservedHeaders[strings.ToLower(name)] || !validHeaderName(name) {
+			log.Warnf("Not setting header %q for %s: the name is reserved or invalid", name, recipient)
+			continue
+		}
+		if err := validateHeaderValue(value); err != nil {
+			log.Warnf("Not setting header %s for %s: %v", name, recipient, err)
+			continue
+		}
+		message.SetHeader(name, value)
+	}
+}
+
+// validHeaderName reports whether name is a non-empty RFC 5322 field name: printable
+// ASCII without spaces or colons
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r > '~' || r == ':' {
+			return false
+		}
+	}
+	return true
+}
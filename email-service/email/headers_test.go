@@ -0,0 +1,55 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+)
+
+func TestReplyToAndCustomHeaders(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		ReplyTo: "support@cleanapp.io",
+		Headers: map[string]string{"X-CleanApp-Env": "staging", "X-CleanApp-Report-Id": "0", "Subject": "spoofed"},
+	}, captureSends(t, &sent))
+
+	headers := WithHeaders(map[string]string{"X-CleanApp-Report-Id": "12345", "X-Bad": "a\r\nBcc: evil@example.com"})
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(), headers); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil, headers); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 sends, got %d", len(sent))
+	}
+
+	for i, m := range sent {
+		if m.ReplyTo.Email != "support@cleanapp.io" {
+			t.Errorf("send %d: Reply-To = %q, want support@cleanapp.io", i, m.ReplyTo.Email)
+		}
+		if got := m.Headers["X-CleanApp-Report-Id"]; got != "12345" {
+			t.Errorf("send %d: X-CleanApp-Report-Id = %q, want the per-send 12345", i, got)
+		}
+		if got := m.Headers["X-CleanApp-Env"]; got != "staging" {
+			t.Errorf("send %d: X-CleanApp-Env = %q, want staging", i, got)
+		}
+		for _, name := range []string{"Subject", "X-Bad"} {
+			if _, ok := m.Headers[name]; ok {
+				t.Errorf("send %d: expected the %s header to be skipped", i, name)
+			}
+		}
+	}
+}
+
+func TestNoReplyToByDefault(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if sent[0].ReplyTo.Email != "" {
+		t.Errorf("expected no Reply-To, got %q", sent[0].ReplyTo.Email)
+	}
+}
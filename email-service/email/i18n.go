@@ -0,0 +1,152 @@
+package email
+
+import (
+	"maps"
+	"strings"
+)
+
+// messages is the email copy of one language, keyed by message name
+type messages map[string]string
+
+// translations is the message catalog per language. English is complete; a key missing
+// from another language falls back to the English copy.
+var translations = map[string]messages{
+	"en": {
+		"subject":            "You got a CleanApp report",
+		"subjectIssue":       "%s issue #%d",
+		"updatedSubject":     "Updated: ",
+		"hello":              "Hello,",
+		"helloName":          "Hello %s,",
+		"newReport":          "You have received a new CleanApp report.",
+		"emailContains":      "This email contains:",
+		"reportImageItem":    "The report image",
+		"mapItem":            "A map showing the location",
+		"reportImageHeading": "Report Image:",
+		"mapHeading":         "Location Map:",
+		"regards":            "Best regards,",
+		"team":               "The CleanApp Team",
+		"heading":            "New Issue Reported",
+		"updatedHeading":     "Updated Analysis",
+		"updatedLabel":       "Updated analysis:",
+		"updatedNotice":      "The analysis of this report was corrected since our previous email. The details below replace the earlier version.",
+		"mediaLink":          "View the report photos and location map",
+		"intro":              "This is the %s report CleanApp users have submitted about %s. Here's what they're seeing:",
+		"reportDetails":      "Report Details",
+		"titleLabel":         "Title:",
+		"typeLabel":          "Type:",
+		"reportedAtLabel":    "Reported at:",
+		"referenceLabel":     "Reference:",
+		"typeIssue":          "%s Issue",
+		"legalRiskFactor":    "Legal Risk Factor",
+		"litterProbability":  "Litter probability",
+		"hazardProbability":  "Hazard probability",
+		"severity":           "Severity",
+		"low":                "Low",
+		"medium":             "Medium",
+		"high":               "High",
+		"unsubscribe":        "To unsubscribe from these emails, please",
+		"unsubscribeLink":    "click here",
+		"unsubscribeText":    "To unsubscribe from these emails, please visit: %s",
+		"unsubscribeReply":   `You can also reply to this email with "UNSUBSCRIBE" in the subject line.`,
+	},
+	"es": {
+		"subject":            "Has recibido un informe de CleanApp",
+		"subjectIssue":       "%s: incidencia n.º %d",
+		"updatedSubject":     "Actualizado: ",
+		"hello":              "Hola,",
+		"helloName":          "Hola %s,",
+		"newReport":          "Has recibido un nuevo informe de CleanApp.",
+		"emailContains":      "Este correo contiene:",
+		"reportImageItem":    "La imagen del informe",
+		"mapItem":            "Un mapa con la ubicación",
+		"reportImageHeading": "Imagen del informe:",
+		"mapHeading":         "Mapa de ubicación:",
+		"regards":            "Saludos cordiales,",
+		"team":               "El equipo de CleanApp",
+		"heading":            "Nueva incidencia reportada",
+		"updatedHeading":     "Análisis actualizado",
+		"updatedLabel":       "Análisis actualizado:",
+		"updatedNotice":      "El análisis de este informe se corrigió desde nuestro correo anterior. Los detalles a continuación sustituyen a la versión anterior.",
+		"mediaLink":          "Ver las fotos del informe y el mapa de ubicación",
+		"intro":              "Este es el informe %s que los usuarios de CleanApp han enviado sobre %s. Esto es lo que están viendo:",
+		"reportDetails":      "Detalles del informe",
+		"titleLabel":         "Título:",
+		"typeLabel":          "Tipo:",
+		"reportedAtLabel":    "Reportado el:",
+		"referenceLabel":     "Referencia:",
+		"typeIssue":          "Incidencia %s",
+		"legalRiskFactor":    "Factor de riesgo legal",
+		"litterProbability":  "Probabilidad de basura",
+		"hazardProbability":  "Probabilidad de peligro",
+		"severity":           "Gravedad",
+		"low":                "Bajo",
+		"medium":             "Medio",
+		"high":               "Alto",
+		"unsubscribe":        "Para darte de baja de estos correos, por favor",
+		"unsubscribeLink":    "haz clic aquí",
+		"unsubscribeText":    "Para darte de baja de estos correos, visita: %s",
+		"unsubscribeReply":   `También puedes responder a este correo con "UNSUBSCRIBE" en el asunto.`,
+	},
+	"de": {
+		"subject":            "Sie haben einen CleanApp-Bericht erhalten",
+		"subjectIssue":       "%s: Problem Nr. %d",
+		"updatedSubject":     "Aktualisiert: ",
+		"hello":              "Hallo,",
+		"helloName":          "Hallo %s,",
+		"newReport":          "Sie haben einen neuen CleanApp-Bericht erhalten.",
+		"emailContains":      "Diese E-Mail enthält:",
+		"reportImageItem":    "Das Berichtsbild",
+		"mapItem":            "Eine Karte mit dem Standort",
+		"reportImageHeading": "Berichtsbild:",
+		"mapHeading":         "Standortkarte:",
+		"regards":            "Mit freundlichen Grüßen,",
+		"team":               "Ihr CleanApp-Team",
+		"heading":            "Neues Problem gemeldet",
+		"updatedHeading":     "Aktualisierte Analyse",
+		"updatedLabel":       "Aktualisierte Analyse:",
+		"updatedNotice":      "Die Analyse dieses Berichts wurde seit unserer letzten E-Mail korrigiert. Die folgenden Angaben ersetzen die frühere Version.",
+		"mediaLink":          "Fotos des Berichts und Standortkarte ansehen",
+		"intro":              "Dies ist Bericht %s, den CleanApp-Nutzer zu %s eingereicht haben. Das sehen sie:",
+		"reportDetails":      "Berichtsdetails",
+		"titleLabel":         "Titel:",
+		"typeLabel":          "Typ:",
+		"reportedAtLabel":    "Gemeldet am:",
+		"referenceLabel":     "Referenz:",
+		"typeIssue":          "Problem (%s)",
+		"legalRiskFactor":    "Rechtlicher Risikofaktor",
+		"litterProbability":  "Müllwahrscheinlichkeit",
+		"hazardProbability":  "Gefahrenwahrscheinlichkeit",
+		"severity":           "Schweregrad",
+		"low":                "Niedrig",
+		"medium":             "Mittel",
+		"high":               "Hoch",
+		"unsubscribe":        "Um diese E-Mails abzubestellen, bitte",
+		"unsubscribeLink":    "hier klicken",
+		"unsubscribeText":    "Um diese E-Mails abzubestellen, besuchen Sie: %s",
+		"unsubscribeReply":   `Sie können auch auf diese E-Mail mit "UNSUBSCRIBE" im Betreff antworten.`,
+	},
+}
+
+// catalogs is translations with every language's missing keys filled in from English
+var catalogs = func() map[string]messages {
+	filled := make(map[string]messages, len(translations))
+	for lang, m := range translations {
+		merged := maps.Clone(translations["en"])
+		maps.Copy(merged, m)
+		filled[lang] = merged
+	}
+	return filled
+}()
+
+// messages returns the copy for a BCP 47 locale such as "es-MX", matched on its language.
+// An empty locale uses DefaultLocale, and an unsupported language falls back to English.
+func (e *EmailSender) messages(locale string) messages {
+	if locale == "" {
+		locale = e.config.DefaultLocale
+	}
+	lang, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(locale, "_", "-")), "-")
+	if m, ok := catalogs[lang]; ok {
+		return m
+	}
+	return catalogs["en"]
+}
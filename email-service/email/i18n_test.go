@@ -0,0 +1,118 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestTranslationsCoverEnglishKeys(t *testing.T) {
+	for lang, m := range translations {
+		for key := range translations["en"] {
+			if m[key] == "" {
+				t.Errorf("%s translation is missing %q", lang, key)
+			}
+		}
+	}
+}
+
+func TestMessagesResolvesLocale(t *testing.T) {
+	e := &EmailSender{config: &config.Config{DefaultLocale: "de"}}
+	tests := []struct {
+		locale, want string
+	}{
+		{"es-MX", "Hola,"},
+		{"es_ES", "Hola,"},
+		{"EN-us", "Hello,"},
+		{"", "Hallo,"},
+		{"fr-FR", "Hello,"},
+	}
+	for _, tt := range tests {
+		if got := e.messages(tt.locale)["hello"]; got != tt.want {
+			t.Errorf("messages(%q) greets %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestPhysicalReportEmailInSpanish(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{OptOutURL: "https://cleanapp.io/opt-out", MetricsDisplay: config.MetricsDisplayBoth}, captureSends(t, &sent))
+
+	recipients := []Recipient{{Email: "ops@acme.es", Name: "Ana", Locale: "es-ES"}}
+	if err := e.SendEmailsWithAnalysisTo(recipients, encodeTestImage(t, 40, 30, "jpeg"), nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysisTo returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	if want := "Acme: incidencia n.º 7: Overflowing trash bin"; sent[0].Subject != want {
+		t.Errorf("subject = %q, want %q", sent[0].Subject, want)
+	}
+
+	for _, content := range sent[0].Content {
+		want := []string{"Hola Ana,", "Este es el informe #7", "Título:", "Tipo:", "FACTOR DE RIESGO LEGAL", "Para darte de baja de estos correos, visita:"}
+		if content.Type == "text/html" {
+			want = []string{"<p>Hola Ana,</p>", "Detalles del informe", "Factor de riesgo legal", "Probabilidad de basura", "Gravedad", "Medio", "Imagen del informe:", "haz clic aquí"}
+		}
+		for _, s := range want {
+			if !strings.Contains(content.Value, s) {
+				t.Errorf("expected %s body to contain %q", content.Type, s)
+			}
+		}
+		if strings.Contains(content.Value, "Hello") || strings.Contains(content.Value, "To unsubscribe") {
+			t.Errorf("expected no English copy in the %s body", content.Type)
+		}
+	}
+}
+
+func TestReportEmailFallsBackToEnglish(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(sent) != 1 || sent[0].Subject != "You got a CleanApp report" {
+		t.Fatalf("expected the English subject, got %+v", sent)
+	}
+	for _, content := range sent[0].Content {
+		if !strings.Contains(content.Value, "You have received a new CleanApp report.") {
+			t.Errorf("expected the English %s body", content.Type)
+		}
+	}
+}
+
+func TestUpdatedBannerAndMediaLinkLocalized(t *testing.T) {
+	e := NewEmailSender(&config.Config{})
+	for _, tt := range []struct {
+		locale     string
+		text, html []string
+		notEnglish bool
+	}{
+		{"en", []string{"UPDATED ANALYSIS: The analysis of this report was corrected", "View the report photos and location map: https://cleanapp.io/media"},
+			[]string{"<strong>Updated analysis:</strong> The analysis of this report was corrected", ">View the report photos and location map</a>"}, false},
+		{"es", []string{"ANÁLISIS ACTUALIZADO: El análisis de este informe se corrigió", "Ver las fotos del informe y el mapa de ubicación: https://cleanapp.io/media"},
+			[]string{"<strong>Análisis actualizado:</strong> El análisis de este informe se corrigió", ">Ver las fotos del informe y el mapa de ubicación</a>"}, true},
+		{"de", []string{"AKTUALISIERTE ANALYSE: Die Analyse dieses Berichts wurde", "Fotos des Berichts und Standortkarte ansehen: https://cleanapp.io/media"},
+			[]string{"<strong>Aktualisierte Analyse:</strong> Die Analyse dieses Berichts wurde", ">Fotos des Berichts und Standortkarte ansehen</a>"}, true},
+	} {
+		t.Run(tt.locale, func(t *testing.T) {
+			render := analysisRender{updated: true, mediaURL: "https://cleanapp.io/media", locale: tt.locale}
+			bodies := map[string]string{
+				"text": e.getEmailTextWithAnalysis("brand@example.com", goldenAnalysis(), false, false, render),
+				"html": e.getEmailHtmlWithAnalysis("brand@example.com", goldenAnalysis(), false, false, render),
+			}
+			for kind, want := range map[string][]string{"text": tt.text, "html": tt.html} {
+				for _, s := range want {
+					if !strings.Contains(bodies[kind], s) {
+						t.Errorf("expected the %s body to contain %q", kind, s)
+					}
+				}
+				if tt.notEnglish && (strings.Contains(bodies[kind], "was corrected") || strings.Contains(bodies[kind], "View the report photos")) {
+					t.Errorf("expected no English banner or media link in the %s body", kind)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package email
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ImageSource loads an image on demand, e.g. from object storage, so a batch can be
+// started before the image has been fetched
+type ImageSource func() ([]byte, error)
+
+// WithImageSources loads the report and map images from the given sources when the
+// batch starts, in place of the image bytes passed to the send. Either source may be
+// nil. A source that still fails after ImageLoadRetries retries fails the whole batch
+// before anything is sent.
+func WithImageSources(report, mapImage ImageSource) SendOption {
+	return func(o *sendOptions) {
+		o.reportSrc = report
+		o.mapSrc = mapImage
+	}
+}
+
+// loadImages returns the batch's report and map images, loading them from the batch's
+// sources when set and falling back to the bytes passed to the send otherwise
+func (e *EmailSender) loadImages(b *batch, reportImage, mapImage []byte) ([]byte, []byte, error) {
+	var err error
+	if b.reportSrc != nil {
+		if reportImage, err = e.loadImage(b, "report", b.reportSrc); err != nil {
+			return nil, nil, err
+		}
+	}
+	if b.mapSrc != nil {
+		if mapImage, err = e.loadImage(b, "map", b.mapSrc); err != nil {
+			return nil, nil, err
+		}
+	}
+	return reportImage, mapImage, nil
+}
+
+// loadImage calls source, retrying up to ImageLoadRetries times ImageLoadRetryDelay
+// apart; kind names the image in logs and the error
+func (e *EmailSender) loadImage(b *batch, kind string, source ImageSource) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := source()
+		if err == nil {
+			return data, nil
+		}
+		if attempt >= e.config.ImageLoadRetries {
+			return nil, fmt.Errorf("loading %s image for batch %s failed after %d attempts: %w", kind, b.id, attempt+1, err)
+		}
+		log.Warnf("Failed to load %s image for batch %s (attempt %d of %d), retrying in %s: %v",
+			kind, b.id, attempt+1, e.config.ImageLoadRetries+1, e.config.ImageLoadRetryDelay, err)
+		time.Sleep(e.config.ImageLoadRetryDelay)
+	}
+}
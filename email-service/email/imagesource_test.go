@@ -0,0 +1,56 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestImageSourceRetriesThenSucceeds(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{ImageLoadRetries: 2}, captureSends(t, &sent))
+
+	calls := 0
+	flaky := func() ([]byte, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return encodeTestImage(t, 40, 30, "jpeg"), nil
+	}
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(), WithImageSources(flaky, nil))
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error after the source recovered: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 loads, got %d", calls)
+	}
+	if len(sent) != 1 || len(sent[0].Attachments) != 1 || !strings.HasPrefix(sent[0].Attachments[0].ContentID, reportImgCid+"-") {
+		t.Errorf("expected one send with the loaded report image, got %+v", sent)
+	}
+}
+
+func TestImageSourceFailureFailsBatch(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	calls := 0
+	failing := func() ([]byte, error) {
+		calls++
+		return nil, errors.New("connection reset")
+	}
+
+	err := e.SendEmails([]string{"brand@example.com"}, nil, nil, WithImageSources(nil, failing))
+	if err == nil {
+		t.Fatal("expected an error when the image can't be loaded")
+	}
+	if calls != 1 {
+		t.Errorf("expected no retries by default, got %d loads", calls)
+	}
+	if len(sent) != 0 {
+		t.Errorf("expected nothing sent, got %d sends", len(sent))
+	}
+}
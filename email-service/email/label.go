@@ -0,0 +1,97 @@
+package email
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// defaultLabelSize is the label font size used when LabelStyle.Size is unset
+const defaultLabelSize = 13
+
+// LabelAlign positions a label horizontally relative to its x coordinate
+type LabelAlign int
+
+const (
+	AlignLeft   LabelAlign = iota // The text starts at x
+	AlignCenter                   // The text is centered on x
+	AlignRight                    // The text ends at x
+)
+
+// LabelStyle is how AddLabel draws its text
+type LabelStyle struct {
+	Color color.Color // Text color (default: black)
+	Size  float64     // Font size in pixels (default: 13)
+	Align LabelAlign
+}
+
+// labelFont is the parsed label font, loaded once per sender
+type labelFont struct {
+	once sync.Once
+	font *opentype.Font
+	err  error
+}
+
+// AddLabel draws text onto img with its baseline at y, aligned to x, e.g. to stamp a
+// "CleanApp" watermark or a severity banner onto a report image before attaching it. The
+// font is the configured LabelFontPath, or the embedded Go Regular font when unset; an
+// error is returned when the font can't be loaded or sized.
+func (e *EmailSender) AddLabel(img draw.Image, text string, x, y int, style LabelStyle) error {
+	e.labelFont.once.Do(func() {
+		e.labelFont.font, e.labelFont.err = loadLabelFont(e.config.LabelFontPath)
+	})
+	if e.labelFont.err != nil {
+		return e.labelFont.err
+	}
+
+	size := style.Size
+	if size <= 0 {
+		size = defaultLabelSize
+	}
+	face, err := opentype.NewFace(e.labelFont.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return fmt.Errorf("failed to size label font to %gpx: %w", size, err)
+	}
+	defer face.Close()
+
+	textColor := style.Color
+	if textColor == nil {
+		textColor = color.Black
+	}
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(textColor), Face: face}
+	dot := fixed.I(x)
+	switch style.Align {
+	case AlignCenter:
+		dot -= d.MeasureString(text) / 2
+	case AlignRight:
+		dot -= d.MeasureString(text)
+	}
+	d.Dot = fixed.Point26_6{X: dot, Y: fixed.I(y)}
+	d.DrawString(text)
+	return nil
+}
+
+// loadLabelFont parses the font file at path, or the embedded Go Regular font when path
+// is empty
+func loadLabelFont(path string) (*opentype.Font, error) {
+	data := goregular.TTF
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read label font: %w", err)
+		}
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse label font %s: %w", path, err)
+	}
+	return f, nil
+}
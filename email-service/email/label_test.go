@@ -0,0 +1,99 @@
+package email
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+
+	"email-service/config"
+)
+
+// inkBounds returns the bounding box of the pixels of img that aren't white
+func inkBounds(img *image.RGBA) image.Rectangle {
+	var ink image.Rectangle
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			if img.RGBAAt(x, y) != (color.RGBA{255, 255, 255, 255}) {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return ink
+}
+
+func newWhiteImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return img
+}
+
+func TestAddLabelDrawsAtPosition(t *testing.T) {
+	e := NewEmailSender(&config.Config{})
+	red := color.RGBA{220, 0, 0, 255}
+
+	tests := []struct {
+		align LabelAlign
+		check func(ink image.Rectangle) bool
+	}{
+		{AlignLeft, func(ink image.Rectangle) bool { return ink.Min.X >= 200 && ink.Min.X < 205 }},
+		{AlignCenter, func(ink image.Rectangle) bool {
+			return ink.Min.X < 200 && ink.Max.X > 200 && abs(200-ink.Min.X-(ink.Max.X-200)) <= 4
+		}},
+		{AlignRight, func(ink image.Rectangle) bool { return ink.Max.X <= 200 && ink.Max.X > 195 }},
+	}
+	for _, tt := range tests {
+		img := newWhiteImage(400, 100)
+		if err := e.AddLabel(img, "CleanApp", 200, 60, LabelStyle{Color: red, Size: 32, Align: tt.align}); err != nil {
+			t.Fatalf("AddLabel returned error: %v", err)
+		}
+
+		ink := inkBounds(img)
+		if ink.Empty() {
+			t.Fatalf("align %d: expected the label to be drawn", tt.align)
+		}
+		// Capitals of a 32px font rise about 23px above the baseline, the p descends below it
+		if ink.Min.Y < 30 || ink.Min.Y > 40 || ink.Max.Y <= 60 || ink.Max.Y > 70 {
+			t.Errorf("align %d: label spans rows %d-%d, want it on the baseline at 60", tt.align, ink.Min.Y, ink.Max.Y)
+		}
+		if !tt.check(ink) {
+			t.Errorf("align %d: label spans columns %d-%d around x=200", tt.align, ink.Min.X, ink.Max.X)
+		}
+	}
+}
+
+func TestAddLabelUsesColor(t *testing.T) {
+	e := NewEmailSender(&config.Config{})
+	img := newWhiteImage(200, 60)
+	if err := e.AddLabel(img, "IIII", 10, 45, LabelStyle{Color: color.RGBA{0, 0, 255, 255}, Size: 40}); err != nil {
+		t.Fatalf("AddLabel returned error: %v", err)
+	}
+
+	solid := false
+	for y := range 60 {
+		for x := range 200 {
+			if img.RGBAAt(x, y) == (color.RGBA{0, 0, 255, 255}) {
+				solid = true
+			}
+		}
+	}
+	if !solid {
+		t.Error("expected fully inked pixels in the label color")
+	}
+}
+
+func TestAddLabelFontLoadError(t *testing.T) {
+	e := NewEmailSender(&config.Config{LabelFontPath: filepath.Join(t.TempDir(), "missing.ttf")})
+	img := newWhiteImage(100, 40)
+	if err := e.AddLabel(img, "CleanApp", 10, 30, LabelStyle{}); err == nil {
+		t.Fatal("expected an error for a missing font file")
+	}
+	if !inkBounds(img).Empty() {
+		t.Error("expected nothing drawn when the font fails to load")
+	}
+}
+
+func abs(n int) int {
+	return max(n, -n)
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/apex/log"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// markdown renders descriptions with goldmark's defaults, which omit raw HTML in the
+// source rather than passing it through
+var markdown = goldmark.New()
+
+// markdownPolicy allows only the basic formatting AI descriptions use. Scripts and
+// styles are dropped with their content; links and images are dropped but the link
+// text is kept, so a description can't smuggle a tracking or phishing URL into a mail
+// sent under our name.
+var markdownPolicy = bluemonday.NewPolicy().
+	AllowElements("p", "br", "strong", "b", "em", "i", "ul", "ol", "li",
+		"code", "pre", "blockquote", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "del")
+
+// renderMarkdown converts Markdown to sanitized HTML, falling back to the escaped
+// source if rendering fails
+func renderMarkdown(source string) string {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		log.Warnf("Failed to render description Markdown, showing it as text: %v", err)
+		return html.EscapeString(source)
+	}
+	return markdownPolicy.Sanitize(buf.String())
+}
+
+// getDescriptionHtml returns the escaped Description line of the report details. With
+// DescriptionMarkdown set the description is rendered as sanitized Markdown in a block,
+// since lists and paragraphs can't sit inside a <p>.
+func (e *EmailSender) getDescriptionHtml(description string) string {
+	if !e.config.DescriptionMarkdown {
+		return "<p><strong>Description:</strong> " + html.EscapeString(description) + "</p>"
+	}
+	return "<div><strong>Description:</strong>\n" + renderMarkdown(description) + "</div>"
+}
+
+// getSubAnalysisDescriptionHtml returns an issue card's description, rendered as
+// Markdown like the report description when DescriptionMarkdown is set
+func (e *EmailSender) getSubAnalysisDescriptionHtml(description string) string {
+	if !e.config.DescriptionMarkdown {
+		return `<p style="margin: 0; color: #555;">` + html.EscapeString(description) + "</p>"
+	}
+	return `<div style="color: #555;">` + renderMarkdown(description) + "</div>"
+}
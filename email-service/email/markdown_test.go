@@ -0,0 +1,50 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	got := renderMarkdown("Overflowing **bins**:\n\n- north gate\n- [parking lot](https://evil.example.com)\n\n<script>alert(1)</script>\n\n![x](https://evil.example.com/x.png)")
+
+	for _, want := range []string{"<strong>bins</strong>", "<li>north gate</li>", "<li>parking lot</li>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in rendered Markdown, got %s", want, got)
+		}
+	}
+	for _, banned := range []string{"<script", "alert(1)", "<a ", "href", "<img", "evil.example.com"} {
+		if strings.Contains(got, banned) {
+			t.Errorf("expected %q to be stripped, got %s", banned, got)
+		}
+	}
+}
+
+func TestDescriptionMarkdownOnlyInHTML(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{DescriptionMarkdown: true}, captureSends(t, &sent))
+
+	analysis := goldenAnalysis()
+	analysis.Description = "Litter found:\n\n- **plastic** bottles\n- cans"
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+
+	for _, content := range sent[0].Content {
+		switch content.Type {
+		case "text/html":
+			if !strings.Contains(content.Value, "<li><strong>plastic</strong> bottles</li>") || strings.Contains(content.Value, "**plastic**") {
+				t.Errorf("expected the HTML description rendered as Markdown, got %s", content.Value)
+			}
+		case "text/plain":
+			if !strings.Contains(content.Value, "- **plastic** bottles") {
+				t.Errorf("expected the raw Markdown in the text version, got %s", content.Value)
+			}
+		}
+	}
+}
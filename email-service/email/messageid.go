@@ -0,0 +1,50 @@
+package email
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+)
+
+// messageIDPattern matches an RFC 5322 msg-id: "<left@right>" with no whitespace,
+// nested brackets or second "@"
+var messageIDPattern = regexp.MustCompile(`^<[^<>@\s]+@[^<>@\s]+>$`)
+
+// messageID returns the Message-ID header for an analysis email to r, or "" to let
+// SendGrid generate one. A Message-ID provided on the recipient wins; otherwise, with
+// CustomMessageIDs set, one is derived from the report and recipient so a resend of
+// the same email carries the same ID and can be deduplicated. Updates also hash the
+// Message-ID they reply to, so they aren't mistaken for the original.
+func (e *EmailSender) messageID(r Recipient, analysis *models.ReportAnalysis, inReplyTo string) string {
+	if r.MessageID != "" {
+		id := formatMessageID(r.MessageID)
+		if messageIDPattern.MatchString(id) {
+			return id
+		}
+		log.Warnf("Ignoring invalid Message-ID %q for %s", r.MessageID, r.Email)
+	}
+	if !e.config.CustomMessageIDs {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(strings.ToLower(r.Email) + "\x00" + inReplyTo))
+	return fmt.Sprintf("<report-%d.%s@%s>", analysis.Seq, hex.EncodeToString(sum[:8]), e.messageIDDomain())
+}
+
+// messageIDDomain returns the right-hand side of generated Message-IDs: the configured
+// domain, or else the From address's domain
+func (e *EmailSender) messageIDDomain() string {
+	if e.config.MessageIDDomain != "" {
+		return e.config.MessageIDDomain
+	}
+	if at := strings.LastIndex(e.config.SendGridFromEmail, "@"); at >= 0 && at < len(e.config.SendGridFromEmail)-1 {
+		return e.config.SendGridFromEmail[at+1:]
+	}
+	return "cleanapp.io"
+}
@@ -0,0 +1,40 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestMessageID(t *testing.T) {
+	analysis := &models.ReportAnalysis{Seq: 42}
+	off := &EmailSender{config: &config.Config{SendGridFromEmail: "info@cleanapp.io"}}
+	on := &EmailSender{config: &config.Config{SendGridFromEmail: "info@cleanapp.io", CustomMessageIDs: true}}
+
+	if got := off.messageID(Recipient{Email: "a@example.com"}, analysis, ""); got != "" {
+		t.Errorf("expected a provider-generated Message-ID by default, got %q", got)
+	}
+	if got := off.messageID(Recipient{Email: "a@example.com", MessageID: "abc@acme.com"}, analysis, ""); got != "<abc@acme.com>" {
+		t.Errorf("expected the provided Message-ID in brackets, got %q", got)
+	}
+	for _, invalid := range []string{"no-at-sign", "a b@acme.com", "a@b@acme.com", "<<a@acme.com>>"} {
+		if got := off.messageID(Recipient{Email: "a@example.com", MessageID: invalid}, analysis, ""); got != "" {
+			t.Errorf("expected invalid Message-ID %q to be ignored, got %q", invalid, got)
+		}
+	}
+
+	first := on.messageID(Recipient{Email: "a@example.com"}, analysis, "")
+	if !messageIDPattern.MatchString(first) || first != on.messageID(Recipient{Email: "A@Example.com"}, analysis, "") {
+		t.Errorf("expected a valid Message-ID stable per report and recipient, got %q", first)
+	}
+	if first[:10] != "<report-42" || first[len(first)-13:] != "@cleanapp.io>" {
+		t.Errorf("unexpected derived Message-ID %q", first)
+	}
+	if other := on.messageID(Recipient{Email: "b@example.com"}, analysis, ""); other == first {
+		t.Error("expected different recipients to get different Message-IDs")
+	}
+	if update := on.messageID(Recipient{Email: "a@example.com"}, analysis, first); update == first {
+		t.Error("expected an update to get a Message-ID distinct from the original")
+	}
+}
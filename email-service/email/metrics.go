@@ -0,0 +1,81 @@
+package email
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// Send metrics, registered on the default Prometheus registry by the first EmailSender
+// created with MetricsEnabled. An email is one personalization, so a batched request
+// counts once per recipient it carries.
+var (
+	emailsSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cleanapp_emails_sent_total",
+		Help: "Emails accepted by SendGrid.",
+	})
+	emailsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cleanapp_emails_failed_total",
+		Help: "Emails SendGrid didn't accept, by status code class (4xx, 5xx) or network.",
+	}, []string{"status"})
+	sendDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cleanapp_email_send_duration_seconds",
+		Help:    "Duration of SendGrid send requests, including retries.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	rateLimitLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cleanapp_sendgrid_ratelimit_limit",
+		Help: "Requests SendGrid allows in the current rate-limit window (X-RateLimit-Limit).",
+	})
+	rateLimitRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cleanapp_sendgrid_ratelimit_remaining",
+		Help: "Requests left in the current SendGrid rate-limit window (X-RateLimit-Remaining).",
+	})
+	rateLimitReset = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cleanapp_sendgrid_ratelimit_reset_timestamp_seconds",
+		Help: "Unix time the current SendGrid rate-limit window resets (X-RateLimit-Reset).",
+	})
+
+	registerMetrics sync.Once
+)
+
+// registerSendMetrics registers the send metrics on the default registry once
+func registerSendMetrics() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(emailsSent, emailsFailed, sendDuration, rateLimitLimit, rateLimitRemaining, rateLimitReset)
+	})
+}
+
+// recordSendMetrics counts a SendGrid send of message that took duration and ended with
+// statusCode, or with err before any response when statusCode is 0
+func (e *EmailSender) recordSendMetrics(message *mail.SGMailV3, statusCode int, err error, duration time.Duration) {
+	if !e.config.MetricsEnabled {
+		return
+	}
+	emails := float64(max(len(message.Personalizations), 1))
+	sendDuration.Observe(duration.Seconds())
+	switch {
+	case err != nil || statusCode == 0:
+		emailsFailed.WithLabelValues("network").Add(emails)
+	case statusCode >= 200 && statusCode < 300:
+		emailsSent.Add(emails)
+	default:
+		emailsFailed.WithLabelValues(strconv.Itoa(statusCode/100) + "xx").Add(emails)
+	}
+}
+
+// recordRateLimitMetrics exports the latest SendGrid rate-limit headers as gauges
+func (e *EmailSender) recordRateLimitMetrics(status RateLimitStatus) {
+	if !e.config.MetricsEnabled {
+		return
+	}
+	rateLimitLimit.Set(float64(status.Limit))
+	rateLimitRemaining.Set(float64(status.Remaining))
+	if !status.Reset.IsZero() {
+		rateLimitReset.Set(float64(status.Reset.Unix()))
+	}
+}
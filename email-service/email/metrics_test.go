@@ -0,0 +1,44 @@
+package email
+
+import (
+	"net/http"
+	"testing"
+
+	"email-service/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSendMetrics(t *testing.T) {
+	transport := &flakyTransport{failures: 1, status: http.StatusBadRequest}
+	e := NewEmailSenderWithTransport(&config.Config{MetricsEnabled: true}, transport)
+
+	sent := testutil.ToFloat64(emailsSent)
+	failed := testutil.ToFloat64(emailsFailed.WithLabelValues("4xx"))
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err == nil {
+		t.Fatal("expected the first send to fail with status 400")
+	}
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+
+	if got := testutil.ToFloat64(emailsSent) - sent; got != 1 {
+		t.Errorf("sent counter increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(emailsFailed.WithLabelValues("4xx")) - failed; got != 1 {
+		t.Errorf("4xx failed counter increased by %v, want 1", got)
+	}
+}
+
+func TestSendMetricsDisabled(t *testing.T) {
+	e := NewEmailSenderWithTransport(&config.Config{}, &fakeTransport{})
+
+	sent := testutil.ToFloat64(emailsSent)
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if got := testutil.ToFloat64(emailsSent) - sent; got != 0 {
+		t.Errorf("sent counter increased by %v with metrics disabled, want 0", got)
+	}
+}
@@ -0,0 +1,38 @@
+package email
+
+import (
+	"fmt"
+	"html"
+
+	"email-service/models"
+)
+
+// getNextSteps returns the configured "what happens next" copy for the analysis's
+// classification, or "" when none is set
+func (e *EmailSender) getNextSteps(analysis *models.ReportAnalysis) string {
+	return e.config.NextSteps[analysis.Classification]
+}
+
+// getNextStepsText returns the next-steps block for the text email, or ""
+func (e *EmailSender) getNextStepsText(analysis *models.ReportAnalysis) string {
+	steps := e.getNextSteps(analysis)
+	if steps == "" {
+		return ""
+	}
+	return "\n\nWHAT HAPPENS NEXT:\n" + steps
+}
+
+// getNextStepsHtml returns the next-steps block shown after the report, ahead of the
+// signature and unsubscribe footer, or ""
+func (e *EmailSender) getNextStepsHtml(analysis *models.ReportAnalysis) string {
+	steps := e.getNextSteps(analysis)
+	if steps == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+    
+    <div class="next-steps" style="background-color: #f8f9fa; padding: 15px; border-radius: 5px; margin: 15px 0; border-left: 4px solid #007bff;">
+        <h3 style="margin: 0 0 5px 0;">What happens next</h3>
+        <p style="margin: 0;">%s</p>
+    </div>`, html.EscapeString(steps))
+}
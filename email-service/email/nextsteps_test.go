@@ -0,0 +1,43 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestNextStepsPerClassification(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{NextSteps: map[string]string{
+		"physical": "Our team will review within 24h & follow up",
+	}}, captureSends(t, &sent))
+
+	physical := goldenAnalysis()
+	digital := goldenAnalysis()
+	digital.Classification = "digital"
+	for _, analysis := range []*models.ReportAnalysis{physical, digital} {
+		if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis); err != nil {
+			t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+		}
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 sends, got %d", len(sent))
+	}
+
+	for _, content := range sent[0].Content {
+		want := "WHAT HAPPENS NEXT:\nOur team will review within 24h & follow up"
+		if content.Type == "text/html" {
+			want = "Our team will review within 24h &amp; follow up"
+		}
+		if !strings.Contains(content.Value, want) {
+			t.Errorf("expected the physical next steps in %s, got %s", content.Type, content.Value)
+		}
+	}
+	for _, content := range sent[1].Content {
+		if strings.Contains(content.Value, "Our team will review") {
+			t.Errorf("expected no next steps for a digital report in %s", content.Type)
+		}
+	}
+}
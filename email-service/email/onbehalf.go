@@ -0,0 +1,53 @@
+package email
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"unicode"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// applyOnBehalfOf shows that CleanApp sends the analysis email for the brand, using the
+// OnBehalfOfName From name and the SenderAddress Sender/X-Sender headers when configured.
+// Only the display name mentions the brand: DMARC aligns on the From address, so it
+// must stay on our authenticated domain, and the Sender header is ignored by DMARC.
+// A name or header that would carry control characters, e.g. from a brand name with
+// a line break in it, is left out rather than risking header injection.
+func (e *EmailSender) applyOnBehalfOf(message *mail.SGMailV3, recipient string, analysis *models.ReportAnalysis) {
+	brandDisplay := analysis.BrandDisplayName
+	if brandDisplay == "" {
+		brandDisplay = analysis.BrandName
+	}
+	if e.config.OnBehalfOfName != "" && brandDisplay != "" && message.From != nil {
+		name := strings.ReplaceAll(e.config.OnBehalfOfName, "{brand}", brandDisplay)
+		if err := validateHeaderValue(name); err != nil {
+			log.Warnf("Not sending on behalf of brand %q to %s, using the plain From name: %v", brandDisplay, recipient, err)
+		} else {
+			message.SetFrom(mail.NewEmail(name, message.From.Address))
+		}
+	}
+
+	if e.config.SenderAddress != "" {
+		sender := (&netmail.Address{Name: e.config.SendGridFromName, Address: e.config.SenderAddress}).String()
+		if err := validateHeaderValue(sender); err != nil {
+			log.Warnf("Not setting the Sender header for %s: %v", recipient, err)
+			return
+		}
+		message.SetHeader("Sender", sender)
+		message.SetHeader("X-Sender", e.config.SenderAddress)
+	}
+}
+
+// validateHeaderValue rejects values with control characters, which could end the
+// header early and inject others
+func validateHeaderValue(value string) error {
+	if i := strings.IndexFunc(value, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("header value %q has a control character at byte %d", value, i)
+	}
+	return nil
+}
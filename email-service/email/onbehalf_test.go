@@ -0,0 +1,54 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+)
+
+func TestOnBehalfOfHeaders(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		SendGridFromName:  "CleanApp",
+		SendGridFromEmail: "info@cleanapp.io",
+		OnBehalfOfName:    "CleanApp on behalf of {brand}",
+		SenderAddress:     "alerts@cleanapp.io",
+	}, captureSends(t, &sent))
+
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	m := sent[0]
+	if m.From.Name != "CleanApp on behalf of Acme" || m.From.Email != "info@cleanapp.io" {
+		t.Errorf("From = %q <%s>, want the brand in the name and our address", m.From.Name, m.From.Email)
+	}
+	if got := m.Headers["Sender"]; got != `"CleanApp" <alerts@cleanapp.io>` {
+		t.Errorf("Sender = %q", got)
+	}
+	if got := m.Headers["X-Sender"]; got != "alerts@cleanapp.io" {
+		t.Errorf("X-Sender = %q", got)
+	}
+}
+
+func TestOnBehalfOfRejectsControlCharacters(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		SendGridFromName: "CleanApp",
+		OnBehalfOfName:   "CleanApp on behalf of {brand}",
+	}, captureSends(t, &sent))
+
+	analysis := goldenAnalysis()
+	analysis.BrandDisplayName = "Acme\r\nBcc: victim@example.com"
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 || sent[0].From.Name != "CleanApp" {
+		t.Errorf("expected the plain From name for an unsafe brand name, got %+v", sent)
+	}
+	if err := validateHeaderValue("CleanApp on behalf of Acme"); err != nil {
+		t.Errorf("validateHeaderValue() rejected a plain name: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package email
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sendgrid/rest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option customizes an EmailSender at construction
+type Option func(*EmailSender)
+
+// WithClock replaces time.Now, e.g. with a fixed clock for golden-file tests
+func WithClock(now func() time.Time) Option {
+	return func(e *EmailSender) {
+		e.now = now
+	}
+}
+
+// WithIDGenerator replaces the random generator used for batch and content IDs,
+// e.g. with SequentialIDs for golden-file tests
+func WithIDGenerator(newID func(prefix string) string) Option {
+	return func(e *EmailSender) {
+		e.newID = newID
+	}
+}
+
+// WithHTMLTransform sets a hook that post-processes every rendered HTML body before it
+// is attached, e.g. to add a deployment-specific tracking pixel or partner badge. An
+// error from the hook aborts that send.
+func WithHTMLTransform(transform func(html string) (string, error)) Option {
+	return func(e *EmailSender) {
+		e.htmlTransform = transform
+	}
+}
+
+// WithHTTPClient sends SendGrid requests through client instead of the default tuned
+// client, e.g. with a transport sized for a deployment's concurrency or a custom TLS config
+func WithHTTPClient(client *http.Client) Option {
+	return func(e *EmailSender) {
+		e.httpClient = &rest.Client{HTTPClient: client}
+	}
+}
+
+// WithTracer emits OpenTelemetry spans from tracer: one per batch and a child per
+// SendGrid send. Without it sends are not traced.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(e *EmailSender) {
+		e.tracer = tracer
+	}
+}
+
+// SequentialIDs returns a deterministic ID generator yielding "prefix-1", "prefix-2", ...
+func SequentialIDs() func(prefix string) string {
+	var n uint64
+	return func(prefix string) string {
+		return fmt.Sprintf("%s-%d", prefix, atomic.AddUint64(&n, 1))
+	}
+}
+
+// transformHTML applies the HTMLTransform hook, if any, to a rendered HTML body
+func (e *EmailSender) transformHTML(html string) (string, error) {
+	if e.htmlTransform == nil {
+		return html, nil
+	}
+	return e.htmlTransform(html)
+}
+
+// randomID returns prefix followed by 16 random hex characters
+func randomID(prefix string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms; fall back to the clock
+		return fmt.Sprintf("%s-%x", prefix, time.Now().UnixNano())
+	}
+	return prefix + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestHTMLTransformPostProcessesBody(t *testing.T) {
+	var sent []capturedMail
+	const pixel = `<img src="https://track.example.com/p.gif" alt="">`
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent), WithHTMLTransform(func(html string) (string, error) {
+		return strings.Replace(html, "</body>", pixel+"</body>", 1), nil
+	}))
+
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 || len(sent[0].Content) != 2 {
+		t.Fatalf("expected one multipart email, got %+v", sent)
+	}
+	if !strings.Contains(sent[0].Content[1].Value, pixel+"</body>") {
+		t.Error("expected the transformed HTML body")
+	}
+	if strings.Contains(sent[0].Content[0].Value, pixel) {
+		t.Error("expected the text body to be left alone")
+	}
+}
+
+func TestHTMLTransformErrorAbortsSend(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent), WithHTMLTransform(func(html string) (string, error) {
+		return "", errors.New("badge service unavailable")
+	}))
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis())
+	if err == nil || !strings.Contains(err.Error(), "html transform for brand@example.com: badge service unavailable") {
+		t.Errorf("expected the transform error, got %v", err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("expected nothing sent after a transform error, got %d", len(sent))
+	}
+}
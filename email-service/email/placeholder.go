@@ -0,0 +1,40 @@
+package email
+
+import (
+	"fmt"
+	"html"
+)
+
+// defaultImagePlaceholderColor is the background behind blocked images when unset
+const defaultImagePlaceholderColor = "#e9ecef"
+
+// imgTag renders an <img> with width/height attributes and a tinted background, so a
+// client that blocks images keeps the space the image would take and shows its alt text
+// on a placeholder instead of collapsing the layout. A zero width or height is left out
+// when the dimension isn't known; style is the tag's own CSS, without a trailing
+// semicolon.
+func (e *EmailSender) imgTag(src, alt string, width, height int, style string) string {
+	color := e.config.ImagePlaceholderColor
+	if color == "" {
+		color = defaultImagePlaceholderColor
+	}
+
+	dims := ""
+	if width > 0 {
+		dims += fmt.Sprintf(` width="%d"`, width)
+	}
+	if height > 0 {
+		dims += fmt.Sprintf(` height="%d"`, height)
+	}
+	return fmt.Sprintf(`<img src="%s" alt="%s"%s style="%s; background-color: %s; color: #666; font-size: 14px;">`,
+		src, html.EscapeString(alt), dims, style, color)
+}
+
+// inlineImgTag renders the tag for an inline image referenced by cid, sized to it
+func (e *EmailSender) inlineImgTag(cid, alt string, img *inlineImage, style string) string {
+	var width, height int
+	if img != nil {
+		width, height = img.width, img.height
+	}
+	return e.imgTag("cid:"+cid, alt, width, height, style)
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestImageTagsCarryDimensionsAndPlaceholder(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{ImagePlaceholderColor: "#abcdef"}, captureSends(t, &sent))
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+		encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 10, "png"), goldenAnalysis())
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+
+	var html string
+	for _, content := range sent[0].Content {
+		if content.Type == "text/html" {
+			html = content.Value
+		}
+	}
+	if len(sent[0].Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(sent[0].Attachments))
+	}
+	for _, want := range []string{
+		`<img src="cid:` + sent[0].Attachments[0].ContentID + `" alt="Report Image" width="40" height="30"`,
+		`<img src="cid:` + sent[0].Attachments[1].ContentID + `" alt="Map" width="20" height="10"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected HTML to contain %s", want)
+		}
+	}
+	if n := strings.Count(html, "<img "); n != strings.Count(html, "background-color: #abcdef") {
+		t.Errorf("expected every one of the %d images to have the placeholder background", n)
+	}
+}
+
+func TestImgTagUnknownDimensions(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	tag := e.inlineImgTag(reportImgCid, `Report "photo"`, &inlineImage{raw: []byte("not an image")}, "max-width: 100%")
+	want := `<img src="cid:report_image" alt="Report &#34;photo&#34;" style="max-width: 100%; background-color: ` + defaultImagePlaceholderColor
+	if !strings.HasPrefix(tag, want) {
+		t.Errorf("inlineImgTag() = %s, want prefix %s", tag, want)
+	}
+}
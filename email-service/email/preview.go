@@ -0,0 +1,40 @@
+package email
+
+import (
+	"unicode/utf8"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+)
+
+// checkInboxPreviewLength warns when the subject and preheader together exceed
+// InboxPreviewMaxLen characters, since clients that show them on one line truncate
+// the preview mid-sentence. The check is advisory and warns once per subject in a
+// batch; text-only messages have no preheader, so only their subject counts.
+func (e *EmailSender) checkInboxPreviewLength(b *batch, subject string, render analysisRender, analysis *models.ReportAnalysis) {
+	if e.config.InboxPreviewMaxLen <= 0 {
+		return
+	}
+	preheader := ""
+	if !render.textOnly {
+		preheader = e.getSeveritySentence(analysis)
+	}
+
+	subjectLen, preheaderLen := utf8.RuneCountInString(subject), utf8.RuneCountInString(preheader)
+	if subjectLen+preheaderLen <= e.config.InboxPreviewMaxLen {
+		return
+	}
+	if b != nil {
+		if _, warned := b.previewWarned.LoadOrStore(subject, true); warned {
+			return
+		}
+	}
+	log.WithFields(log.Fields{
+		"report":        analysis.Seq,
+		"subject":       subject,
+		"subject_len":   subjectLen,
+		"preheader_len": preheaderLen,
+		"max_len":       e.config.InboxPreviewMaxLen,
+	}).Warn("Subject and preheader exceed the inbox preview length")
+}
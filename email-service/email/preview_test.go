@@ -0,0 +1,58 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+)
+
+func TestInboxPreviewLengthWarnsOncePerBatch(t *testing.T) {
+	logger := log.Log.(*log.Logger)
+	previous := logger.Handler
+	handler := memory.New()
+	logger.Handler = handler
+	t.Cleanup(func() { logger.Handler = previous })
+
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{ShowSeveritySummary: true, InboxPreviewMaxLen: 40}, captureSends(t, &sent))
+	if err := e.SendEmailsWithAnalysis([]string{"a@example.com", "b@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+
+	var warnings []*log.Entry
+	for _, entry := range handler.Entries {
+		if entry.Message == "Subject and preheader exceed the inbox preview length" {
+			warnings = append(warnings, entry)
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning for the batch, got %d", len(warnings))
+	}
+	w := warnings[0]
+	if w.Level != log.WarnLevel || w.Fields["max_len"] != 40 || w.Fields["subject"] != sent[0].Subject {
+		t.Errorf("unexpected warning %v: %v", w.Level, w.Fields)
+	}
+	if w.Fields["preheader_len"].(int) == 0 {
+		t.Error("expected the preheader to count toward the length")
+	}
+	if len(sent) != 2 {
+		t.Errorf("expected the check not to block sends, got %d sends", len(sent))
+	}
+}
+
+func TestInboxPreviewLengthWithinLimit(t *testing.T) {
+	logger := log.Log.(*log.Logger)
+	previous := logger.Handler
+	handler := memory.New()
+	logger.Handler = handler
+	t.Cleanup(func() { logger.Handler = previous })
+
+	e := &EmailSender{config: &config.Config{InboxPreviewMaxLen: 200}}
+	e.checkInboxPreviewLength(&batch{}, e.BuildSubject(goldenAnalysis()), analysisRender{}, goldenAnalysis())
+	if len(handler.Entries) != 0 {
+		t.Errorf("expected no warning within the limit, got %v", handler.Entries[0].Message)
+	}
+}
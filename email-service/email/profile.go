@@ -0,0 +1,118 @@
+package email
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"email-service/config"
+
+	"github.com/apex/log"
+)
+
+// SendOption customizes a single send, as opposed to an Option which customizes the
+// sender at construction
+type SendOption func(*sendOptions)
+
+// sendOptions are the per-send settings chosen by SendOptions
+type sendOptions struct {
+	profile    string
+	audit      func(AuditRecord)
+	reportSrc  ImageSource
+	mapSrc     ImageSource
+	result     *SendResult
+	resultFunc func(*SendResult)
+	cc, bcc    []string
+	headers    map[string]string
+
+	categories []string
+}
+
+// WithSendProfile sends the batch with the named profile from SendProfiles instead of
+// the transactional default, e.g. "bulk" for large digital blasts
+func WithSendProfile(name string) SendOption {
+	return func(o *sendOptions) {
+		o.profile = name
+	}
+}
+
+// WithAudit passes the final rendered content of every message in the send to archive,
+// so callers can keep exactly what each recipient was shown. Records are handed over one
+// at a time rather than collected, keeping memory flat for large batches.
+func WithAudit(archive func(AuditRecord)) SendOption {
+	return func(o *sendOptions) {
+		o.audit = archive
+	}
+}
+
+// batch is the per-send state shared by the per-recipient sends of one batch
+type batch struct {
+	id      string
+	profile config.SendProfile
+
+	auditMu sync.Mutex        // Serializes audit calls from concurrent sends
+	audit   func(AuditRecord) // Optional archive of rendered messages
+
+	reportSrc, mapSrc ImageSource // Lazy image sources, loaded once when the batch starts
+
+	ctx            context.Context // Carries the batch span, the parent of its send spans
+	classification string          // Report classification of analysis batches, for tracing
+
+	previewWarned sync.Map // Subjects already warned about as too long, to warn once per batch
+
+	start      time.Time         // When the batch was started, for SendResult.Duration
+	result     *SendResult       // Optional per-recipient outcome, filled when the batch ends
+	resultFunc func(*SendResult) // Optional callback with the outcome once the batch ends
+
+	cc, bcc []string          // Screened addresses copied on every message
+	headers map[string]string // Custom headers of every message, over the configured ones
+
+	categories []string // SendGrid categories replacing the classification's configured one
+
+	inReplyTo string // Message-ID an updated batch sent without analysis threads under
+
+	counted map[string]time.Time // When each screened recipient was counted towards the frequency cap; screening runs on one goroutine
+
+	scheduledMu sync.Mutex           // Serializes recordScheduled calls from concurrent sends
+	scheduled   map[string]time.Time // Recipients SendGrid holds until a send_at time, e.g. quiet hours
+
+	accountsMu sync.Mutex        // Serializes recordAccount calls from concurrent sends
+	accounts   map[string]string // Account each sent recipient went out through, for SendResult.Accounts
+}
+
+// newBatch starts a batch with a fresh ID and the profile selected by opts. An unknown
+// profile is reported and replaced with the default so the send still goes out.
+func (e *EmailSender) newBatch(opts []SendOption) *batch {
+	o := sendOptions{profile: config.DefaultSendProfile}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	profile, ok := e.config.SendProfiles[o.profile]
+	if !ok && o.profile != config.DefaultSendProfile {
+		log.Warnf("Unknown send profile %q, using %s", o.profile, config.DefaultSendProfile)
+		profile = e.config.SendProfiles[config.DefaultSendProfile]
+	}
+	copied := make(map[string]bool)
+	cc := copyAddresses("CC", o.cc, copied)
+	bcc := copyAddresses("BCC", o.bcc, copied)
+	return &batch{id: e.newID("batch"), profile: profile, audit: o.audit, reportSrc: o.reportSrc, mapSrc: o.mapSrc,
+		start: time.Now(), result: o.result, resultFunc: o.resultFunc, cc: cc, bcc: bcc, headers: o.headers, categories: o.categories}
+}
+
+// concurrency returns the number of recipients batch b sends to in parallel
+func (e *EmailSender) concurrency(b *batch) int {
+	if b.profile.Concurrency > 0 {
+		return b.profile.Concurrency
+	}
+	return max(e.config.SendConcurrency, 1)
+}
+
+// maintenanceRetries returns the 503 retry budget for a message sent in batch b, which
+// is nil for one-off sends outside a batch
+func (e *EmailSender) maintenanceRetries(b *batch) int {
+	if b == nil || b.profile.MaintenanceRetries == 0 {
+		return e.config.SendMaintenanceRetries
+	}
+	return max(b.profile.MaintenanceRetries, 0)
+}
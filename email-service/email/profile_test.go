@@ -0,0 +1,126 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"email-service/config"
+)
+
+func TestSendProfile(t *testing.T) {
+	var mu sync.Mutex
+	pools := make(map[string]int)
+	var inFlight, maxInFlight, calls int32
+	e := newTestSender(t, &config.Config{
+		SendMaintenanceRetries: 3,
+		SendProfiles: map[string]config.SendProfile{
+			config.DefaultSendProfile: {Concurrency: 1},
+			"bulk":                    {Concurrency: 4, RatePerSecond: 1000, MaintenanceRetries: -1, IPPool: "bulk"},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&calls, 1)
+
+		var body struct {
+			IPPoolName string `json:"ip_pool_name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		mu.Lock()
+		pools[body.IPPoolName]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com", "f@example.com"}
+	err := e.SendEmails(recipients, nil, nil, WithSendProfile("bulk"))
+	if err == nil {
+		t.Fatal("expected an error while SendGrid is in maintenance")
+	}
+	// Retries are disabled by the profile, so each recipient is tried once
+	if calls != int32(len(recipients)) {
+		t.Errorf("expected %d calls without retries, got %d", len(recipients), calls)
+	}
+	if pools["bulk"] != len(recipients) {
+		t.Errorf("expected every message in the bulk IP pool, got %v", pools)
+	}
+	if maxInFlight > 4 {
+		t.Errorf("expected at most 4 concurrent sends, saw %d", maxInFlight)
+	}
+}
+
+func TestUnknownSendProfileUsesDefault(t *testing.T) {
+	e := &EmailSender{
+		config: &config.Config{SendProfiles: map[string]config.SendProfile{
+			config.DefaultSendProfile: {Concurrency: 1, IPPool: "transactional"},
+		}},
+		newID: SequentialIDs(),
+	}
+
+	if b := e.newBatch([]SendOption{WithSendProfile("nope")}); b.profile.IPPool != "transactional" {
+		t.Errorf("expected the default profile for an unknown name, got %+v", b.profile)
+	}
+	if b := e.newBatch(nil); b.profile.IPPool != "transactional" || b.id != "batch-2" {
+		t.Errorf("expected the default profile without options, got %+v", b)
+	}
+}
+
+func TestSendConcurrencyForProfilesWithoutOne(t *testing.T) {
+	var inFlight, maxInFlight int32
+	e := newTestSender(t, &config.Config{SendConcurrency: 3}, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		var m capturedMail
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if strings.HasPrefix(m.Personalizations[0].To[0].Email, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	var recipients []string
+	for i := range 12 {
+		prefix := "ok"
+		if i%3 == 0 {
+			prefix = "fail"
+		}
+		recipients = append(recipients, fmt.Sprintf("%s%d@example.com", prefix, i))
+	}
+	err := e.SendEmails(recipients, nil, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if batchErr.Total != 12 || batchErr.Failed != 4 || batchErr.Sent != 8 {
+		t.Errorf("expected 4/12 failed and 8 sent, got %+v", batchErr)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("expected at most 3 concurrent sends, saw %d", maxInFlight)
+	}
+}
@@ -0,0 +1,86 @@
+package email
+
+import (
+	"time"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// quietHoursEnd returns when the quiet hours containing now end, or the zero time when
+// now is outside them or they are disabled. The hours are read in QuietHoursTimezone and
+// wrap past midnight when QuietHoursStart is after QuietHoursEnd, e.g. 22 to 7.
+func (e *EmailSender) quietHoursEnd(now time.Time) time.Time {
+	start, end := e.config.QuietHoursStart, e.config.QuietHoursEnd
+	if start == end {
+		return time.Time{}
+	}
+
+	loc := time.UTC
+	if name := e.config.QuietHoursTimezone; name != "" {
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			log.Warnf("Unknown quiet hours time zone %q, using UTC: %v", name, err)
+			loc = time.UTC
+		}
+	}
+	local := now.In(loc)
+	hour := local.Hour()
+	if start < end && (hour < start || hour >= end) || start > end && hour < start && hour >= end {
+		return time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end, 0, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// quietHoursDeferral returns when an analysis email sent now is delivered: the end of
+// the quiet hours it falls in, or the zero time to deliver immediately. Digital brand
+// alerts and reports flagged Critical are urgent and are never deferred.
+func (e *EmailSender) quietHoursDeferral(analysis *models.ReportAnalysis) time.Time {
+	if analysis.Classification == "digital" || analysis.Critical {
+		return time.Time{}
+	}
+	return e.quietHoursEnd(e.now())
+}
+
+// applyQuietHours schedules an analysis email built during quiet hours for their end
+// with SendGrid's send_at
+func (e *EmailSender) applyQuietHours(message *mail.SGMailV3, recipient string, analysis *models.ReportAnalysis) {
+	until := e.quietHoursDeferral(analysis)
+	if until.IsZero() {
+		return
+	}
+	log.Infof("Quiet hours: scheduling report %d for %s at %s", analysis.Seq, recipient, until.Format(time.RFC3339))
+	message.SetSendAt(int(until.Unix()))
+}
+
+// recordScheduled notes recipients of a message SendGrid accepted for delivery at its
+// send_at time, for SendResult.Scheduled
+func (b *batch) recordScheduled(message *mail.SGMailV3, recipients ...string) {
+	if message.SendAt == 0 {
+		return
+	}
+	b.recordScheduledAt(time.Unix(int64(message.SendAt), 0), recipients...)
+}
+
+// recordScheduledAt notes recipients SendGrid accepted for delivery at a later time, or
+// does nothing for the zero time
+func (b *batch) recordScheduledAt(at time.Time, recipients ...string) {
+	if b == nil || at.IsZero() {
+		return
+	}
+	b.scheduledMu.Lock()
+	defer b.scheduledMu.Unlock()
+	if b.scheduled == nil {
+		b.scheduled = make(map[string]time.Time, len(recipients))
+	}
+	for _, recipient := range recipients {
+		b.scheduled[recipient] = at
+	}
+}
@@ -0,0 +1,139 @@
+package email
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/config"
+)
+
+func TestQuietHoursDeferPhysicalReports(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, time.March, 5, 3, 0, 0, 0, berlin)
+	morning := time.Date(2025, time.March, 5, 7, 0, 0, 0, berlin)
+	cfg := &config.Config{QuietHoursStart: 22, QuietHoursEnd: 7, QuietHoursTimezone: "Europe/Berlin"}
+
+	for _, tt := range []struct {
+		classification string
+		wantSendAt     time.Time
+	}{
+		{"physical", morning},
+		{"digital", time.Time{}},
+	} {
+		t.Run(tt.classification, func(t *testing.T) {
+			transport := &fakeTransport{}
+			e := NewEmailSenderWithTransport(cfg, transport, WithClock(func() time.Time { return now }))
+			analysis := goldenAnalysis()
+			analysis.Classification = tt.classification
+
+			var result SendResult
+			if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis, WithResult(&result)); err != nil {
+				t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+			}
+			if len(transport.messages) != 1 {
+				t.Fatalf("expected 1 message, got %d", len(transport.messages))
+			}
+			sendAt := transport.messages[0].SendAt
+			if tt.wantSendAt.IsZero() {
+				if sendAt != 0 {
+					t.Errorf("expected no send_at, got %v", time.Unix(int64(sendAt), 0).In(berlin))
+				}
+				if len(result.Scheduled) != 0 {
+					t.Errorf("expected nothing scheduled, got %v", result.Scheduled)
+				}
+				return
+			}
+			if sendAt != int(tt.wantSendAt.Unix()) {
+				t.Errorf("send_at = %v, want %v", time.Unix(int64(sendAt), 0).In(berlin), tt.wantSendAt)
+			}
+			if at := result.Scheduled["brand@example.com"]; !at.Equal(tt.wantSendAt) {
+				t.Errorf("Scheduled = %v, want %v", at, tt.wantSendAt)
+			}
+		})
+	}
+}
+
+func TestQuietHoursDeferSingleSend(t *testing.T) {
+	singleSendPollInterval = time.Millisecond
+
+	var mu sync.Mutex
+	var schedule map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v3/marketing/lists":
+			w.Write([]byte(`{"result":[]}`))
+		case "POST /v3/marketing/lists":
+			w.Write([]byte(`{"id":"list-1"}`))
+		case "PUT /v3/marketing/contacts":
+			w.Write([]byte(`{"job_id":"job-1"}`))
+		case "GET /v3/marketing/contacts/imports/job-1":
+			w.Write([]byte(`{"status":"completed"}`))
+		case "POST /v3/marketing/singlesends":
+			w.Write([]byte(`{"id":"ss-1"}`))
+		case "PUT /v3/marketing/singlesends/ss-1/schedule":
+			if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+				t.Errorf("failed to decode schedule: %v", err)
+			}
+			w.Write([]byte(`{"status":"scheduled"}`))
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2025, time.March, 5, 3, 0, 0, 0, time.UTC)
+	e := NewEmailSender(&config.Config{
+		SingleSendEnabled:       true,
+		SingleSendMinBatch:      2,
+		SingleSendImportTimeout: time.Second,
+		QuietHoursStart:         22,
+		QuietHoursEnd:           7,
+	}, WithClock(func() time.Time { return now }))
+	e.marketingHost = srv.URL
+
+	var result SendResult
+	if err := e.SendEmailsWithAnalysis([]string{"a@example.com", "b@example.com"}, nil, nil, goldenAnalysis(), WithResult(&result)); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if want := "2025-03-05T07:00:00Z"; schedule["send_at"] != want {
+		t.Errorf("Single Send send_at = %q, want %s", schedule["send_at"], want)
+	}
+	if at := result.Scheduled["a@example.com"]; !at.Equal(time.Date(2025, time.March, 5, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("Scheduled = %v, want both recipients at 07:00", result.Scheduled)
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	day := func(d, hour, minute int) time.Time {
+		return time.Date(2025, time.March, d, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		start, end int
+		now        time.Time
+		want       time.Time
+	}{
+		{"disabled", 0, 0, day(5, 3, 0), time.Time{}},
+		{"before midnight", 22, 7, day(5, 23, 30), day(6, 7, 0)},
+		{"after midnight", 22, 7, day(5, 6, 59), day(5, 7, 0)},
+		{"at the end", 22, 7, day(5, 7, 0), time.Time{}},
+		{"daytime", 22, 7, day(5, 12, 0), time.Time{}},
+		{"same day window", 1, 6, day(5, 2, 0), day(5, 6, 0)},
+		{"outside same day window", 1, 6, day(5, 23, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EmailSender{config: &config.Config{QuietHoursStart: tt.start, QuietHoursEnd: tt.end}}
+			if got := e.quietHoursEnd(tt.now); !got.Equal(tt.want) {
+				t.Errorf("quietHoursEnd(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
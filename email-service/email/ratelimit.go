@@ -0,0 +1,144 @@
+package email
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitStatus is the latest SendGrid rate-limit window reported in response headers
+type RateLimitStatus struct {
+	Limit     int       // X-RateLimit-Limit: requests allowed in the current window
+	Remaining int       // X-RateLimit-Remaining: requests left in the current window
+	Reset     time.Time // X-RateLimit-Reset: when the window resets
+	UpdatedAt time.Time // When the headers were last seen
+}
+
+// RateLimitStatus returns the most recently observed SendGrid rate-limit headers.
+// The zero value means SendGrid has not reported any yet.
+func (e *EmailSender) RateLimitStatus() RateLimitStatus {
+	e.rateLimitMu.Lock()
+	defer e.rateLimitMu.Unlock()
+	return e.rateLimit
+}
+
+// recordRateLimit captures the X-RateLimit-* headers of a SendGrid response and returns
+// them, or false when the response carried none
+func (e *EmailSender) recordRateLimit(headers map[string][]string) (RateLimitStatus, bool) {
+	remaining, ok := headerInt(headers, "X-RateLimit-Remaining")
+	if !ok {
+		return RateLimitStatus{}, false
+	}
+
+	status := RateLimitStatus{Remaining: remaining, UpdatedAt: e.now()}
+	if limit, ok := headerInt(headers, "X-RateLimit-Limit"); ok {
+		status.Limit = limit
+	}
+	if reset, ok := headerInt(headers, "X-RateLimit-Reset"); ok {
+		status.Reset = time.Unix(int64(reset), 0)
+	}
+
+	e.rateLimitMu.Lock()
+	e.rateLimit = status
+	e.rateLimitMu.Unlock()
+	e.recordRateLimitMetrics(status)
+
+	fields := log.Fields{"limit": status.Limit, "remaining": status.Remaining, "reset": status.Reset.Format(time.RFC3339)}
+	if status.Limit > 0 && status.Remaining*10 < status.Limit {
+		log.WithFields(fields).Warn("SendGrid rate limit nearly exhausted")
+	} else {
+		log.WithFields(fields).Debug("SendGrid rate limit")
+	}
+	return status, true
+}
+
+// headerInt returns the first value of a response header parsed as an integer
+func headerInt(headers map[string][]string, key string) (int, bool) {
+	value := http.Header(headers).Get(key)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// sendLimiter paces SendGrid mail/send calls across every batch to SendRatePerSecond. A
+// 429 halves the rate, down to a sixteenth of the configured one, and each accepted send
+// then restores a tenth of the configured rate until it is back. Once the rate-limit
+// window is nearly exhausted, pace spreads the requests left over the time until it
+// resets so sends slow down before SendGrid starts answering 429.
+type sendLimiter struct {
+	mu      sync.Mutex // Serializes adjustments of the limit
+	limiter *rate.Limiter
+	max     rate.Limit
+}
+
+// newSendLimiter returns the limiter for perSecond calls, or nil when it is unlimited
+func newSendLimiter(perSecond float64) *sendLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &sendLimiter{limiter: rate.NewLimiter(rate.Limit(perSecond), 1), max: rate.Limit(perSecond)}
+}
+
+// wait blocks until the next call may go out or ctx is done; a nil limiter never waits
+func (l *sendLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// throttle backs the rate off after SendGrid answered 429 Too Many Requests
+func (l *sendLimiter) throttle() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit := max(l.limiter.Limit()/2, l.max/16); limit < l.limiter.Limit() {
+		l.limiter.SetLimit(limit)
+		log.Warnf("SendGrid rate limited the account, slowing sends to %.2f/s", float64(limit))
+	}
+}
+
+// restore raises a throttled rate back toward the configured one after an accepted send
+func (l *sendLimiter) restore() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit := l.limiter.Limit(); limit < l.max {
+		l.limiter.SetLimit(min(limit+l.max/10, l.max))
+	}
+}
+
+// pace slows the rate when fewer than a tenth of the window's requests remain, to the
+// requests left spread over the time until the window resets, down to a sixteenth of
+// the configured rate
+func (l *sendLimiter) pace(status RateLimitStatus, now time.Time) {
+	if l == nil || status.Limit <= 0 || status.Remaining*10 >= status.Limit {
+		return
+	}
+	untilReset := status.Reset.Sub(now)
+	if untilReset <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := max(rate.Limit(float64(status.Remaining)/untilReset.Seconds()), l.max/16)
+	if limit < l.limiter.Limit() {
+		l.limiter.SetLimit(limit)
+		log.Warnf("SendGrid rate limit nearly exhausted (%d left), slowing sends to %.2f/s until %s",
+			status.Remaining, float64(limit), status.Reset.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,127 @@
+package email
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"email-service/config"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"golang.org/x/time/rate"
+)
+
+func TestSendRatePerSecondPacesSends(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{SendRatePerSecond: 2, SendConcurrency: 4}, transport)
+
+	start := time.Now()
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	if err := e.SendEmails(recipients, nil, nil); err != nil {
+		t.Fatalf("SendEmails failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 1400*time.Millisecond {
+		t.Errorf("expected 4 sends at 2/s to take at least 1.5s, took %v", elapsed)
+	}
+	if len(transport.messages) != 4 {
+		t.Errorf("expected 4 sends, got %d", len(transport.messages))
+	}
+}
+
+func TestSendRateBacksOffOnTooManyRequests(t *testing.T) {
+	transport := &flakyTransport{failures: 2, status: http.StatusTooManyRequests}
+	e := NewEmailSenderWithTransport(&config.Config{SendRatePerSecond: 100, MaxSendRetries: 3, SendRetryBaseDelay: time.Millisecond}, transport)
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("expected success after two 429s, got %v", err)
+	}
+	// Halved twice to 25/s, then raised by a tenth of the configured rate on success
+	if got := e.sendRate.limiter.Limit(); got != rate.Limit(35) {
+		t.Errorf("expected the rate to recover to 35/s, got %v", got)
+	}
+
+	for range 10 {
+		e.sendRate.restore()
+	}
+	if got := e.sendRate.limiter.Limit(); got != rate.Limit(100) {
+		t.Errorf("expected the rate to recover no further than 100/s, got %v", got)
+	}
+}
+
+func TestSendRateUnlimitedByDefault(t *testing.T) {
+	e := NewEmailSenderWithTransport(&config.Config{}, &fakeTransport{})
+	if e.sendRate != nil {
+		t.Error("expected no send limiter without SendRatePerSecond")
+	}
+}
+
+// rateLimitTransport accepts every message with the given X-RateLimit-* headers
+type rateLimitTransport struct {
+	headers map[string][]string
+}
+
+func (r *rateLimitTransport) Send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	return &rest.Response{StatusCode: http.StatusAccepted, Headers: r.headers}, nil
+}
+
+func TestRecordRateLimitHeaders(t *testing.T) {
+	now := time.Date(2025, time.March, 5, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(time.Second)
+	transport := &rateLimitTransport{headers: map[string][]string{
+		"X-Ratelimit-Limit":     {"600"},
+		"X-Ratelimit-Remaining": {"20"},
+		"X-Ratelimit-Reset":     {"1741176001"},
+	}}
+	e := NewEmailSenderWithTransport(&config.Config{SendRatePerSecond: 100, MetricsEnabled: true}, transport,
+		WithClock(func() time.Time { return now }))
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails failed: %v", err)
+	}
+
+	want := RateLimitStatus{Limit: 600, Remaining: 20, Reset: reset, UpdatedAt: now}
+	if got := e.RateLimitStatus(); got.Limit != want.Limit || got.Remaining != want.Remaining ||
+		!got.Reset.Equal(want.Reset) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("RateLimitStatus() = %+v, want %+v", got, want)
+	}
+	if got := testutil.ToFloat64(rateLimitLimit); got != 600 {
+		t.Errorf("limit gauge = %v, want 600", got)
+	}
+	if got := testutil.ToFloat64(rateLimitRemaining); got != 20 {
+		t.Errorf("remaining gauge = %v, want 20", got)
+	}
+	if got := testutil.ToFloat64(rateLimitReset); got != float64(reset.Unix()) {
+		t.Errorf("reset gauge = %v, want %d", got, reset.Unix())
+	}
+	// 20 requests left over the second until the window resets
+	if got := e.sendRate.limiter.Limit(); got != rate.Limit(20) {
+		t.Errorf("expected sends paced to 20/s, got %v", got)
+	}
+}
+
+func TestSendLimiterPace(t *testing.T) {
+	now := time.Date(2025, time.March, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status RateLimitStatus
+		want   rate.Limit
+	}{
+		{"plenty left", RateLimitStatus{Limit: 600, Remaining: 300, Reset: now.Add(time.Minute)}, 100},
+		{"nearly exhausted", RateLimitStatus{Limit: 600, Remaining: 50, Reset: now.Add(time.Second)}, 50},
+		{"exhausted", RateLimitStatus{Limit: 600, Remaining: 0, Reset: now.Add(time.Minute)}, 100.0 / 16},
+		{"window already reset", RateLimitStatus{Limit: 600, Remaining: 0, Reset: now.Add(-time.Second)}, 100},
+		{"no limit reported", RateLimitStatus{Remaining: 0, Reset: now.Add(time.Minute)}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newSendLimiter(100)
+			l.pace(tt.status, now)
+			if got := l.limiter.Limit(); got != tt.want {
+				t.Errorf("limit = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
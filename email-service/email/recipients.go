@@ -0,0 +1,51 @@
+package email
+
+import (
+	"fmt"
+	"html"
+)
+
+// Recipient is an email address with the per-recipient metadata used to personalize
+// a send: the greeting name, the content language and the brand the contact belongs to
+type Recipient struct {
+	Email  string
+	Name   string // Greets the recipient by name when set
+	Locale string // BCP 47 language tag sent as Content-Language, e.g. "en-US"
+	Brand  string // Tagged on the message as the "brand" custom arg for event routing
+
+	MessageID string // Message-ID header for threading and dedup; derived or provider-generated when empty
+}
+
+// recipientsFromEmails wraps plain addresses as Recipients without metadata
+func recipientsFromEmails(emails []string) []Recipient {
+	recipients := make([]Recipient, len(emails))
+	for i, email := range emails {
+		recipients[i] = Recipient{Email: email}
+	}
+	return recipients
+}
+
+// recipientEmails returns the addresses of recipients, in order
+func recipientEmails(recipients []Recipient) []string {
+	emails := make([]string, len(recipients))
+	for i, r := range recipients {
+		emails[i] = r.Email
+	}
+	return emails
+}
+
+// getGreeting returns the text greeting for a named recipient, or "" without a name
+func getGreeting(name string, m messages) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(m["helloName"], name) + "\n\n"
+}
+
+// getGreetingHtml returns the HTML greeting for a named recipient, or "" without a name
+func getGreetingHtml(name string, m messages) string {
+	if name == "" {
+		return ""
+	}
+	return "\n    <p>" + fmt.Sprintf(m["helloName"], html.EscapeString(name)) + "</p>"
+}
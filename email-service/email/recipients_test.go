@@ -0,0 +1,54 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestSendEmailsWithAnalysisToPersonalizes(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	recipients := []Recipient{
+		{Email: "ops@acme.com", Name: "Dana <Ops>", Locale: "de-DE", Brand: "acme"},
+		{Email: "plain@example.com"},
+	}
+	if err := e.SendEmailsWithAnalysisTo(recipients, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysisTo returned error: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 sends, got %d", len(sent))
+	}
+
+	named := sent[0]
+	if got := named.Headers["Content-Language"]; got != "de-DE" {
+		t.Errorf("Content-Language = %q, want de-DE", got)
+	}
+	if got := named.Personalizations[0].CustomArgs["brand"]; got != "acme" {
+		t.Errorf("brand custom arg = %q, want acme", got)
+	}
+	for _, content := range named.Content {
+		want := "Hallo Dana <Ops>,"
+		if content.Type == "text/html" {
+			want = "<p>Hallo Dana &lt;Ops&gt;,</p>"
+		}
+		if !strings.Contains(content.Value, want) {
+			t.Errorf("expected %s greeting %q", content.Type, want)
+		}
+	}
+
+	plain := sent[1]
+	if _, ok := plain.Headers["Content-Language"]; ok {
+		t.Errorf("expected no Content-Language for a plain recipient, got headers %v", plain.Headers)
+	}
+	if _, ok := plain.Personalizations[0].CustomArgs["brand"]; ok {
+		t.Errorf("expected no brand custom arg for a plain recipient, got %v", plain.Personalizations[0].CustomArgs)
+	}
+	for _, content := range plain.Content {
+		if strings.Contains(content.Value, "Hello") {
+			t.Errorf("expected no %s greeting without a name", content.Type)
+		}
+	}
+}
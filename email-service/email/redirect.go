@@ -0,0 +1,28 @@
+package email
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// redirectRecipients rewrites every recipient of the message to RedirectAllTo for
+// staging tests, keeping the intended recipient in X-Original-To and the subject.
+// It is a no-op unless RedirectAllTo is configured.
+func (e *EmailSender) redirectRecipients(message *mail.SGMailV3, recipient string) {
+	target := e.config.RedirectAllTo
+	if target == "" {
+		return
+	}
+
+	for _, p := range message.Personalizations {
+		p.To = []*mail.Email{mail.NewEmail(target, target)}
+		p.CC = nil
+		p.BCC = nil
+	}
+	message.SetHeader("X-Original-To", recipient)
+	message.Subject = fmt.Sprintf("[To: %s] %s", recipient, message.Subject)
+
+	log.Warnf("Redirecting email for %s to %s (RedirectAllTo test mode)", recipient, target)
+}
@@ -0,0 +1,56 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+)
+
+func TestRedirectAllTo(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{RedirectAllTo: "qa@cleanapp.io"}, transport)
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(),
+		WithCC("legal@example.com"), WithBCC("archive@example.com"))
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+	message := transport.messages[0]
+
+	for _, p := range message.Personalizations {
+		if len(p.To) != 1 || p.To[0].Address != "qa@cleanapp.io" {
+			t.Errorf("To = %+v, want only the redirect address", p.To)
+		}
+		if len(p.CC) != 0 || len(p.BCC) != 0 {
+			t.Errorf("expected CC and BCC dropped, got CC %+v and BCC %+v", p.CC, p.BCC)
+		}
+	}
+	if got := message.Headers["X-Original-To"]; got != "brand@example.com" {
+		t.Errorf("X-Original-To = %q, want the intended recipient", got)
+	}
+	if want := "[To: brand@example.com] " + e.BuildSubject(goldenAnalysis()); message.Subject != want {
+		t.Errorf("Subject = %q, want %q", message.Subject, want)
+	}
+}
+
+func TestRedirectAllToOffByDefault(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{}, transport)
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil, WithCC("legal@example.com")); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	message := transport.messages[0]
+	if to := message.Personalizations[0].To[0].Address; to != "brand@example.com" {
+		t.Errorf("To = %q, want the recipient", to)
+	}
+	if len(message.Personalizations[0].CC) != 1 {
+		t.Errorf("expected the CC kept, got %+v", message.Personalizations[0].CC)
+	}
+	if _, ok := message.Headers["X-Original-To"]; ok {
+		t.Error("expected no X-Original-To header without RedirectAllTo")
+	}
+}
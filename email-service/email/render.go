@@ -0,0 +1,38 @@
+package email
+
+import (
+	"email-service/models"
+
+	"github.com/apex/log"
+)
+
+// RenderAnalysisEmail returns the HTML and text bodies of the analysis email recipient
+// would be sent, without sending it, e.g. for designers to preview or to snapshot. The
+// email is built by the same code as a real send, including the HTMLTransform hook;
+// hasReport and hasMap stand in placeholder images of unknown size for the report and
+// map. html is empty for a text-only recipient; both are empty when the HTMLTransform
+// hook fails.
+func (e *EmailSender) RenderAnalysisEmail(recipient string, analysis *models.ReportAnalysis, hasReport, hasMap bool) (html, text string) {
+	var reportImg, mapImg *inlineImage
+	if hasReport {
+		reportImg = &inlineImage{}
+	}
+	if hasMap {
+		mapImg = &inlineImage{}
+	}
+
+	message, err := e.buildAnalysisEmail(nil, Recipient{Email: recipient}, reportImg, mapImg, e.normalizeClassification(analysis), "")
+	if err != nil {
+		log.Warnf("Rendering the analysis email for %s: %v", recipient, err)
+		return "", ""
+	}
+	for _, content := range message.Content {
+		switch content.Type {
+		case "text/html":
+			html = content.Value
+		case "text/plain":
+			text = content.Value
+		}
+	}
+	return html, text
+}
@@ -0,0 +1,63 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestRenderAnalysisEmailMatchesSend(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{OptOutURL: "https://cleanapp.io/opt-out"}, captureSends(t, &sent))
+
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	html, text := e.RenderAnalysisEmail("brand@example.com", goldenAnalysis(), false, false)
+
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	for _, content := range sent[0].Content {
+		switch content.Type {
+		case "text/html":
+			if html != content.Value {
+				t.Error("rendered HTML differs from the sent HTML")
+			}
+		case "text/plain":
+			if text != content.Value {
+				t.Error("rendered text differs from the sent text")
+			}
+		}
+	}
+}
+
+func TestRenderAnalysisEmailImages(t *testing.T) {
+	e := NewEmailSender(&config.Config{})
+
+	html, _ := e.RenderAnalysisEmail("brand@example.com", goldenAnalysis(), true, false)
+	if !strings.Contains(html, `src="cid:`+reportImgCid) || strings.Contains(html, `src="cid:`+mapImgCid) {
+		t.Errorf("expected only a report image tag in:\n%s", html)
+	}
+}
+
+func TestRenderAnalysisEmailTextOnly(t *testing.T) {
+	e := NewEmailSender(&config.Config{TextOnlyRecipients: []string{"plain@example.com"}})
+
+	html, text := e.RenderAnalysisEmail("plain@example.com", goldenAnalysis(), false, false)
+	if html != "" || !strings.Contains(text, goldenAnalysis().Title) {
+		t.Errorf("expected only a text body, got html=%q text=%q", html, text)
+	}
+}
+
+func TestRenderAnalysisEmailTransformError(t *testing.T) {
+	e := NewEmailSender(&config.Config{}, WithHTMLTransform(func(string) (string, error) {
+		return "", errors.New("boom")
+	}))
+
+	if html, text := e.RenderAnalysisEmail("brand@example.com", goldenAnalysis(), false, false); html != "" || text != "" {
+		t.Errorf("expected no bodies when the transform fails, got html=%q text=%q", html, text)
+	}
+}
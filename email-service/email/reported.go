@@ -0,0 +1,71 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo database
+
+	"email-service/models"
+
+	"github.com/apex/log"
+)
+
+// reportedAtLayout formats the report time, e.g. "Mar 5, 2025 at 2:30 PM CET"
+const reportedAtLayout = "Jan 2, 2006 at 3:04 PM MST"
+
+// recipientLocation returns the recipient's time zone from the analysis, or UTC when it
+// is absent or unknown
+func recipientLocation(recipient string, analysis *models.ReportAnalysis) *time.Location {
+	name, ok := analysis.RecipientTimezones[strings.ToLower(recipient)]
+	if !ok {
+		name, ok = analysis.RecipientTimezones[recipient]
+	}
+	if !ok || name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Warnf("Unknown time zone %q for %s, using UTC: %v", name, recipient, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// getReportedAt returns when the report was submitted in the recipient's time zone, or
+// an empty string when the time is unknown
+func getReportedAt(recipient string, analysis *models.ReportAnalysis) string {
+	if analysis.ReportedAt.IsZero() {
+		return ""
+	}
+	return analysis.ReportedAt.In(recipientLocation(recipient, analysis)).Format(reportedAtLayout)
+}
+
+// getReportMetaText returns the "Reported at" and reference lines of the plain text
+// report details, each ending in a newline, leaving out whichever is unknown
+func (e *EmailSender) getReportMetaText(recipient string, analysis *models.ReportAnalysis, m messages) string {
+	meta := ""
+	if reportedAt := getReportedAt(recipient, analysis); reportedAt != "" {
+		meta += fmt.Sprintf("%s %s\n", m["reportedAtLabel"], reportedAt)
+	}
+	if analysis.ReportID != "" {
+		meta += fmt.Sprintf("%s %s\n", m["referenceLabel"], analysis.ReportID)
+	}
+	return meta
+}
+
+// getReportMetaHtml returns the "Reported at" and reference paragraphs of the report
+// details card, or an empty string when both are unknown
+func (e *EmailSender) getReportMetaHtml(recipient string, analysis *models.ReportAnalysis, m messages) string {
+	meta := ""
+	if reportedAt := getReportedAt(recipient, analysis); reportedAt != "" {
+		meta += fmt.Sprintf(`
+        <p><strong>%s</strong> %s</p>`, html.EscapeString(m["reportedAtLabel"]), html.EscapeString(reportedAt))
+	}
+	if analysis.ReportID != "" {
+		meta += fmt.Sprintf(`
+        <p><strong>%s</strong> <code>%s</code></p>`, html.EscapeString(m["referenceLabel"]), html.EscapeString(analysis.ReportID))
+	}
+	return meta
+}
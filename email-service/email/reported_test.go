@@ -0,0 +1,50 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"email-service/config"
+)
+
+func TestReportedAtAndReferenceRendered(t *testing.T) {
+	e := NewEmailSender(&config.Config{})
+	analysis := goldenAnalysis()
+	analysis.ReportID = "rpt-8f3a2c"
+	analysis.ReportedAt = time.Date(2025, time.March, 5, 13, 30, 0, 0, time.UTC)
+	analysis.RecipientTimezones = map[string]string{"berlin@example.com": "Europe/Berlin"}
+
+	tests := []struct {
+		recipient string
+		want      string
+	}{
+		{"Berlin@Example.com", "Mar 5, 2025 at 2:30 PM CET"},
+		{"brand@example.com", "Mar 5, 2025 at 1:30 PM UTC"},
+	}
+	for _, tt := range tests {
+		html, text := e.RenderAnalysisEmail(tt.recipient, analysis, false, false)
+		if !strings.Contains(text, "Reported at: "+tt.want+"\n") || !strings.Contains(text, "Reference: rpt-8f3a2c\n") {
+			t.Errorf("%s: expected the report time %q and reference in the text, got:\n%s", tt.recipient, tt.want, text)
+		}
+		if !strings.Contains(html, "<strong>Reported at:</strong> "+tt.want+"</p>") || !strings.Contains(html, "<code>rpt-8f3a2c</code>") {
+			t.Errorf("%s: expected the report time %q and reference in the HTML", tt.recipient, tt.want)
+		}
+	}
+}
+
+func TestReportedAtOmittedWhenUnknown(t *testing.T) {
+	e := NewEmailSender(&config.Config{})
+	html, text := e.RenderAnalysisEmail("brand@example.com", goldenAnalysis(), false, false)
+	if strings.Contains(text, "Reported at") || strings.Contains(html, "Reference:") {
+		t.Error("expected no report time or reference without them in the analysis")
+	}
+}
+
+func TestRecipientLocationFallsBackToUTC(t *testing.T) {
+	analysis := goldenAnalysis()
+	analysis.RecipientTimezones = map[string]string{"brand@example.com": "Mars/Olympus_Mons"}
+	if loc := recipientLocation("brand@example.com", analysis); loc != time.UTC {
+		t.Errorf("expected UTC for an unknown time zone, got %v", loc)
+	}
+}
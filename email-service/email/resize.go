@@ -0,0 +1,155 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"github.com/apex/log"
+	"golang.org/x/image/draw"
+)
+
+// resizeJPEGQuality is the quality used when re-encoding downscaled JPEGs
+const resizeJPEGQuality = 85
+
+// capQualities are the JPEG qualities capImageBytes steps through at each size before
+// scaling the image down, by at least capScaleStep and more the further the smallest
+// encoding was over the limit, giving up below capMinDimension pixels
+var capQualities = []int{resizeJPEGQuality, 70, 55, 40}
+
+const (
+	capScaleStep    = 0.75
+	capMinDimension = 64
+)
+
+// prepareImages checks the report and map images for a swap, strips the report photo's
+// metadata, downscales them to their configured max dimensions and attachment size, and
+// base64-encodes them once for the batch. The server-rendered map carries no metadata.
+func (e *EmailSender) prepareImages(reportImage, mapImage []byte) (*inlineImage, *inlineImage) {
+	checkImageSwap(reportImage, mapImage)
+	reportImage = stripJPEGMetadata(reportImage, "report")
+	reportImage = downscaleImage(reportImage, e.config.ReportImageMaxDimension, "report")
+	mapImage = downscaleImage(mapImage, e.config.MapImageMaxDimension, "map")
+	reportImage = capImageBytes(reportImage, e.config.MaxAttachmentBytes, "report")
+	mapImage = capImageBytes(mapImage, e.config.MaxAttachmentBytes, "map")
+	return encodeInlineImage(reportImage), encodeInlineImage(mapImage)
+}
+
+// downscaleImage scales a JPEG or PNG down so neither side exceeds maxDim, keeping the
+// aspect ratio and format. The data is returned unchanged when it already fits, maxDim
+// is 0, or the image can't be decoded or re-encoded.
+func downscaleImage(data []byte, maxDim int, kind string) []byte {
+	if len(data) == 0 || maxDim <= 0 {
+		return data
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (cfg.Width <= maxDim && cfg.Height <= maxDim) {
+		return data
+	}
+	if format != "jpeg" && format != "png" {
+		return data
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Warnf("Failed to decode %s image for downscaling: %v", kind, err)
+		return data
+	}
+
+	width, height := cfg.Width, cfg.Height
+	if width >= height {
+		height = max(1, height*maxDim/width)
+		width = maxDim
+	} else {
+		width = max(1, width*maxDim/height)
+		height = maxDim
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: resizeJPEGQuality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		log.Warnf("Failed to re-encode downscaled %s image: %v", kind, err)
+		return data
+	}
+
+	log.Infof("Downscaled %s image from %dx%d (%d bytes) to %dx%d (%d bytes)",
+		kind, cfg.Width, cfg.Height, len(data), width, height, buf.Len())
+	return buf.Bytes()
+}
+
+// capImageBytes re-encodes an image whose base64 encoding exceeds maxBytes as a JPEG,
+// stepping down the quality and then the dimensions until it fits, so one large photo
+// can't push the message past SendGrid's size limit. Transparent areas are flattened
+// onto white. The data is returned unchanged when it already fits, maxBytes is 0, or it
+// can't be decoded or made to fit.
+func capImageBytes(data []byte, maxBytes int, kind string) []byte {
+	if len(data) == 0 || maxBytes <= 0 || base64.StdEncoding.EncodedLen(len(data)) <= maxBytes {
+		return data
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Warnf("Failed to decode %s image of %d bytes to fit the %d-byte attachment limit: %v", kind, len(data), maxBytes, err)
+		return data
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	for width >= capMinDimension && height >= capMinDimension {
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		if width == bounds.Dx() && height == bounds.Dy() {
+			draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+		} else {
+			draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+		}
+
+		// Only try the higher qualities once the lowest one fits at this size
+		lowest, err := encodeJPEG(dst, capQualities[len(capQualities)-1])
+		if err != nil {
+			log.Warnf("Failed to re-encode %s image to fit the attachment limit: %v", kind, err)
+			return data
+		}
+		if encoded := base64.StdEncoding.EncodedLen(len(lowest)); encoded > maxBytes {
+			// The encoded size scales roughly with the pixel count
+			scale := min(capScaleStep, 0.95*math.Sqrt(float64(maxBytes)/float64(encoded)))
+			width, height = int(float64(width)*scale), int(float64(height)*scale)
+			continue
+		}
+
+		for _, quality := range capQualities {
+			out := lowest
+			if quality != capQualities[len(capQualities)-1] {
+				if out, err = encodeJPEG(dst, quality); err != nil || base64.StdEncoding.EncodedLen(len(out)) > maxBytes {
+					continue
+				}
+			}
+			log.Infof("Re-encoded %s image from %dx%d (%d bytes) to %dx%d at quality %d (%d bytes) to fit the %d-byte attachment limit",
+				kind, bounds.Dx(), bounds.Dy(), len(data), width, height, quality, len(out), maxBytes)
+			return out
+		}
+	}
+
+	log.Warnf("Could not fit %s image of %d bytes under the %d-byte attachment limit, attaching it unchanged", kind, len(data), maxBytes)
+	return data
+}
+
+// encodeJPEG encodes img as a JPEG at quality
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
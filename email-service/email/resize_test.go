@@ -0,0 +1,120 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+
+	"email-service/config"
+)
+
+// encodeTestImage returns a blank width x height image in the given format
+func encodeTestImage(t *testing.T, width, height int, format string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// imageSize decodes the dimensions and format of data
+func imageSize(t *testing.T, data []byte) (int, int, string) {
+	t.Helper()
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+	return cfg.Width, cfg.Height, format
+}
+
+func TestPrepareImagesUsesSeparateLimits(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		ReportImageMaxDimension: 500,
+		MapImageMaxDimension:    1500,
+	}}
+
+	report, mapImg := e.prepareImages(encodeTestImage(t, 3000, 1000, "jpeg"), encodeTestImage(t, 1000, 3000, "png"))
+
+	if w, h, format := imageSize(t, report.raw); w != 500 || h != 166 || format != "jpeg" {
+		t.Errorf("report image = %dx%d %s, want 500x166 jpeg", w, h, format)
+	}
+	if w, h, format := imageSize(t, mapImg.raw); w != 500 || h != 1500 || format != "png" {
+		t.Errorf("map image = %dx%d %s, want 500x1500 png", w, h, format)
+	}
+}
+
+func TestDownscaleImageKeepsSmallImages(t *testing.T) {
+	data := encodeTestImage(t, 800, 600, "png")
+	if got := downscaleImage(data, 1000, "report"); !bytes.Equal(got, data) {
+		t.Error("expected an image within the limit to be returned unchanged")
+	}
+	if got := downscaleImage(data, 0, "report"); !bytes.Equal(got, data) {
+		t.Error("expected a zero limit to disable downscaling")
+	}
+}
+
+// encodeNoiseImage returns a width x height JPEG of random pixels, which compresses
+// poorly the way a detailed phone photo does
+func encodeNoiseImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rng.UintN(256))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 50}); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOversizedImageIsCappedBeforeAttaching(t *testing.T) {
+	const maxBytes = 500000
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{MaxAttachmentBytes: maxBytes}, captureSends(t, &sent))
+
+	report := encodeNoiseImage(t, 5000, 5000)
+	if len(report) <= maxBytes {
+		t.Fatalf("test image is only %d bytes, want more than %d", len(report), maxBytes)
+	}
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, report, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 || len(sent[0].Attachments) == 0 {
+		t.Fatalf("expected a send with the report attached, got %+v", sent)
+	}
+	attached := sent[0].Attachments[0]
+	if len(attached.Content) > maxBytes {
+		t.Errorf("attached report is %d base64 bytes, want at most %d", len(attached.Content), maxBytes)
+	}
+	data, err := base64.StdEncoding.DecodeString(attached.Content)
+	if err != nil {
+		t.Fatalf("failed to decode attachment: %v", err)
+	}
+	if w, h, format := imageSize(t, data); w >= 5000 || w != h || format != "jpeg" {
+		t.Errorf("attached report = %dx%d %s, want a smaller square jpeg", w, h, format)
+	}
+}
+
+func TestCapImageBytesKeepsImagesWithinLimit(t *testing.T) {
+	data := encodeTestImage(t, 800, 600, "png")
+	if got := capImageBytes(data, 1<<20, "report"); !bytes.Equal(got, data) {
+		t.Error("expected an image within the limit to be returned unchanged")
+	}
+	if got := capImageBytes(data, 0, "report"); !bytes.Equal(got, data) {
+		t.Error("expected a zero limit to disable the cap")
+	}
+}
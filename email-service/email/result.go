@@ -0,0 +1,83 @@
+package email
+
+import (
+	"maps"
+	"time"
+)
+
+// SendResult is the per-recipient outcome of a batch, for callers that act on it rather
+// than on the summary error, e.g. retrying only the failed addresses or logging each one.
+type SendResult struct {
+	Succeeded []string             // Addresses SendGrid accepted, in the order their sends finished
+	Failed    map[string]error     // Invalid addresses and failed sends, with the reason
+	Skipped   map[string]error     // Addresses deliberately not sent to, e.g. suppressed or duplicates, with the reason
+	Scheduled map[string]time.Time // Succeeded addresses SendGrid holds until a later time, e.g. the end of quiet hours
+	Accounts  map[string]string    // Succeeded addresses' SendGrid account: the subuser name, "default", or "smtp" for the SMTP fallback
+	Duration  time.Duration        // From the start of the batch until its last send finished
+
+	err error
+}
+
+// Err returns the batch's summary error, e.g. "2/5 emails failed: ...", or nil when
+// every recipient was sent to or skipped. Cancellation isn't part of it; that is only
+// reported by the send method's error.
+func (r *SendResult) Err() error {
+	return r.err
+}
+
+// WithResult fills result with the outcome of every recipient once the batch ends. A
+// batch refused as a whole, e.g. over MaxBatchSize or below the severity threshold,
+// leaves result empty; the send method's error says why. Recipients of a Single Send
+// all succeed or fail together, with whether SendGrid scheduled it, and have no account
+// as they go out through the Marketing Campaigns API.
+func WithResult(result *SendResult) SendOption {
+	return func(o *sendOptions) {
+		o.result = result
+	}
+}
+
+// WithResultFunc calls fn with the outcome of every recipient once the batch ends, as
+// WithResult fills it, e.g. for an email queued with QueueEmailWithAnalysis whose batch
+// ends after the call returns. fn isn't called for a batch refused as a whole.
+func WithResultFunc(fn func(*SendResult)) SendOption {
+	return func(o *sendOptions) {
+		o.resultFunc = fn
+	}
+}
+
+// recordResult fills the batch's SendResult, if one was requested, from report and
+// passes it to the batch's result func
+func (b *batch) recordResult(report *batchReport, plural string) {
+	if b.result == nil && b.resultFunc == nil {
+		return
+	}
+	if b.result == nil {
+		b.result = &SendResult{}
+	}
+	*b.result = SendResult{
+		Succeeded: report.succeeded,
+		Failed:    make(map[string]error, len(report.invalid)+len(report.failures)),
+		Skipped:   make(map[string]error, len(report.skipped)),
+		Scheduled: make(map[string]time.Time, len(b.scheduled)),
+		Accounts:  make(map[string]string, len(b.accounts)),
+		Duration:  time.Since(b.start),
+		err:       report.err(plural),
+	}
+	for _, failures := range [][]batchFailure{report.invalid, report.failures} {
+		for _, f := range failures {
+			b.result.Failed[f.recipient] = f.err
+		}
+	}
+	for _, f := range report.skipped {
+		b.result.Skipped[f.recipient] = f.err
+	}
+	b.scheduledMu.Lock()
+	maps.Copy(b.result.Scheduled, b.scheduled)
+	b.scheduledMu.Unlock()
+	b.accountsMu.Lock()
+	maps.Copy(b.result.Accounts, b.accounts)
+	b.accountsMu.Unlock()
+	if b.resultFunc != nil {
+		b.resultFunc(b.result)
+	}
+}
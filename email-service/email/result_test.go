@@ -0,0 +1,111 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestSendResultListsEachRecipient(t *testing.T) {
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		var m capturedMail
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		if strings.HasSuffix(m.Personalizations[0].To[0].Email, "@bounce.example.com") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	var result SendResult
+	err := e.SendEmailsWithAnalysis([]string{"a@example.com", "b@bounce.example.com", "not-an-email", "c@example.com", "a@example.com"},
+		nil, nil, goldenAnalysis(), WithResult(&result))
+	if err == nil {
+		t.Fatal("expected an error for the failed recipients")
+	}
+
+	if strings.Join(result.Succeeded, ",") != "a@example.com,c@example.com" {
+		t.Errorf("expected a and c to succeed, got %v", result.Succeeded)
+	}
+	if len(result.Failed) != 2 {
+		t.Fatalf("expected 2 failed recipients, got %v", result.Failed)
+	}
+	var statusErr *statusError
+	if !errors.As(result.Failed["b@bounce.example.com"], &statusErr) || statusErr.status != http.StatusBadRequest {
+		t.Errorf("expected the bounce's 400, got %v", result.Failed["b@bounce.example.com"])
+	}
+	if result.Failed["not-an-email"] == nil || !strings.Contains(result.Failed["not-an-email"].Error(), "invalid address") {
+		t.Errorf("expected the invalid address error, got %v", result.Failed["not-an-email"])
+	}
+	if result.Duration <= 0 {
+		t.Error("expected the batch duration to be recorded")
+	}
+	if result.Err() == nil || result.Err().Error() != err.Error() {
+		t.Errorf("expected Err() to match the returned error %q, got %v", err, result.Err())
+	}
+	if !strings.HasPrefix(result.Err().Error(), "1/5 emails with analysis failed, 1 invalid, 1 skipped") {
+		t.Errorf("expected the summary counts in Err(), got %q", result.Err())
+	}
+}
+
+func TestSendResultFromSendBatch(t *testing.T) {
+	transport := &flakyTransport{failures: 1, status: http.StatusBadRequest}
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromEmail:  "info@cleanapp.io",
+		TextOnlyRecipients: []string{"@plain.example"},
+	}, transport)
+
+	var result SendResult
+	err := e.SendBatch(context.Background(), []string{"a@example.com", "b@example.com", "ops@plain.example"},
+		nil, nil, goldenAnalysis(), WithResult(&result))
+	if err == nil {
+		t.Fatal("expected an error for the failed request")
+	}
+	if len(result.Failed) != 2 || result.Failed["a@example.com"] == nil || result.Failed["b@example.com"] == nil {
+		t.Errorf("expected both recipients of the failed request, got %v", result.Failed)
+	}
+	if len(result.Succeeded) != 1 || result.Succeeded[0] != "ops@plain.example" {
+		t.Errorf("expected the individual send to succeed, got %v", result.Succeeded)
+	}
+}
+
+func TestSendResultEmptyWithoutFailures(t *testing.T) {
+	e := NewEmailSenderWithTransport(&config.Config{SendGridFromEmail: "info@cleanapp.io"}, &fakeTransport{})
+
+	var result SendResult
+	if err := e.SendEmails([]string{"a@example.com"}, nil, nil, WithResult(&result)); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if result.Err() != nil || len(result.Failed) != 0 || len(result.Succeeded) != 1 {
+		t.Errorf("expected one success and no error, got %+v", result)
+	}
+}
+
+func TestWithResultFunc(t *testing.T) {
+	e := NewEmailSenderWithTransport(&config.Config{SendGridFromEmail: "info@cleanapp.io"}, &fakeTransport{})
+
+	var calls int
+	var result SendResult
+	err := e.SendEmails([]string{"a@example.com", "info@cleanapp.io"}, nil, nil, WithResult(&result), WithResultFunc(func(r *SendResult) {
+		calls++
+		if r != &result {
+			t.Error("expected the result func to get the WithResult outcome")
+		}
+		if len(r.Succeeded) != 1 || r.Succeeded[0] != "a@example.com" || r.Skipped["info@cleanapp.io"] == nil {
+			t.Errorf("expected a@example.com sent and the sender address skipped, got %+v", r)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the result func called once, got %d calls", calls)
+	}
+}
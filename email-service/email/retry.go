@@ -0,0 +1,335 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxFailureBodyRunes bounds the SendGrid response body included in failures
+const maxFailureBodyRunes = 512
+
+// maxRetryAfter bounds how long a 429's Retry-After header can hold a send
+const maxRetryAfter = 5 * time.Minute
+
+// errInvalidMessage marks messages rejected before they reach SendGrid
+var errInvalidMessage = errors.New("invalid message")
+
+// statusError is a non-2xx SendGrid response, kept typed so batch reports can
+// break failures down by status
+type statusError struct {
+	status         int
+	infrastructure bool // The body wasn't a SendGrid API error, e.g. an HTML page from a proxy or CDN
+	err            error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// sendGridErrorBody is the JSON error shape returned by the SendGrid v3 API
+type sendGridErrorBody struct {
+	Errors []struct {
+		Message string `json:"message"`
+		Field   string `json:"field"`
+	} `json:"errors"`
+}
+
+// describeFailure turns a non-2xx response body into an error detail. SendGrid API
+// errors are JSON with an errors list, whose messages are joined into a clean string.
+// Anything else, such as an HTML error page from a proxy or CDN in front of SendGrid,
+// is classified as an infrastructure error and its raw body is sampled.
+func (e *EmailSender) describeFailure(body string) (detail string, infrastructure bool) {
+	var parsed sendGridErrorBody
+	if err := json.Unmarshal([]byte(body), &parsed); err != nil || len(parsed.Errors) == 0 {
+		return e.failureBody(body), true
+	}
+
+	messages := make([]string, 0, len(parsed.Errors))
+	for _, apiErr := range parsed.Errors {
+		if apiErr.Field != "" {
+			messages = append(messages, fmt.Sprintf("%s (field %s)", apiErr.Message, apiErr.Field))
+		} else {
+			messages = append(messages, apiErr.Message)
+		}
+	}
+	return truncateRunes(strings.Join(messages, "; "), maxFailureBodyRunes, "..."), false
+}
+
+// newStatusError builds the error for a non-2xx response; what describes the request
+// (e.g. "brand@example.com (account=default, in 1.2s)")
+func (e *EmailSender) newStatusError(status int, body, what string) *statusError {
+	detail, infrastructure := e.describeFailure(body)
+	if infrastructure {
+		return &statusError{status, true, fmt.Errorf("infrastructure error (status %d, non-JSON response) for %s: %s", status, what, detail)}
+	}
+	return &statusError{status, false, fmt.Errorf("sendgrid returned status %d for %s: %s", status, what, detail)}
+}
+
+// send delivers a message through the account's transport, retrying 503
+// responses with the longer maintenance backoff up to retries times instead of giving
+// up on the recipient. Transient failures (429, 500, 502 and network errors) are
+// retried up to MaxSendRetries times with their own budget. Every attempt waits its turn
+// under SendRatePerSecond, which a 429 or a nearly exhausted rate-limit window slows
+// down. Waiting ends early when ctx is done.
+func (e *EmailSender) send(ctx context.Context, account *sendAccount, message *mail.SGMailV3, retries int) (*rest.Response, error) {
+	maintenance, transient := 0, 0
+	for {
+		if err := e.sendRate.wait(ctx); err != nil {
+			return nil, err
+		}
+		response, err := e.transportFor(account).Send(ctx, message)
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || transient >= e.config.MaxSendRetries {
+				return nil, err
+			}
+			delay = e.sendRetryDelay(transient)
+			transient++
+			log.Warnf("SendGrid request failed, retrying in %s (retry %d/%d): %v", delay, transient, e.config.MaxSendRetries, err)
+
+		case response.StatusCode == http.StatusServiceUnavailable:
+			e.recordRateLimit(response.Headers)
+			if maintenance >= retries {
+				return response, nil
+			}
+			delay = e.maintenanceDelay(maintenance)
+			maintenance++
+			log.Warnf("SendGrid provider maintenance (status 503), retrying in %s (retry %d/%d)", delay, maintenance, retries)
+
+		case isTransientStatus(response.StatusCode):
+			e.recordRateLimit(response.Headers)
+			if response.StatusCode == http.StatusTooManyRequests {
+				e.sendRate.throttle()
+			}
+			if transient >= e.config.MaxSendRetries {
+				return response, nil
+			}
+			delay = e.sendRetryDelay(transient)
+			if after, ok := e.retryAfter(response); ok && response.StatusCode == http.StatusTooManyRequests {
+				delay = after
+			}
+			transient++
+			log.Warnf("SendGrid returned status %d, retrying in %s (retry %d/%d)", response.StatusCode, delay, transient, e.config.MaxSendRetries)
+
+		default:
+			if response.StatusCode >= 200 && response.StatusCode < 300 {
+				e.sendRate.restore()
+			}
+			if status, ok := e.recordRateLimit(response.Headers); ok {
+				e.sendRate.pace(status, e.now())
+			}
+			return response, nil
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// isTransientStatus reports whether a response status is worth retrying as is: rate
+// limiting and server errors, but not client errors such as 400, 401 or 413
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway:
+		return true
+	}
+	return false
+}
+
+// sendRetryDelay returns the backoff before the given transient retry: SendRetryBaseDelay
+// doubled per attempt, plus up to half again as random jitter so senders hitting the same
+// failure don't retry in lockstep
+func (e *EmailSender) sendRetryDelay(attempt int) time.Duration {
+	delay := e.config.SendRetryBaseDelay << min(attempt, 16)
+	if delay <= 0 {
+		return 0
+	}
+	return delay + rand.N(delay/2+1)
+}
+
+// retryAfter parses a response's Retry-After header, given in seconds or as an HTTP
+// date, bounded by maxRetryAfter
+func (e *EmailSender) retryAfter(response *rest.Response) (time.Duration, bool) {
+	value := http.Header(response.Headers).Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = at.Sub(e.now())
+	} else {
+		return 0, false
+	}
+	return min(max(delay, 0), maxRetryAfter), true
+}
+
+// failureBody returns the (truncated) response body to include in a failure, or a
+// placeholder when this failure isn't sampled. Every failure is counted, but only the
+// first FailureBodyLogFirstN and then every FailureBodyLogEveryM-th carry the body,
+// so a mass outage doesn't flood the logs.
+func (e *EmailSender) failureBody(body string) string {
+	n := atomic.AddUint64(&e.failedResponses, 1)
+	sampled := n <= uint64(e.config.FailureBodyLogFirstN) ||
+		(e.config.FailureBodyLogEveryM > 0 && n%uint64(e.config.FailureBodyLogEveryM) == 0)
+	if !sampled {
+		return fmt.Sprintf("(body not logged, failure #%d)", n)
+	}
+
+	return truncateRunes(body, maxFailureBodyRunes, "...")
+}
+
+// maintenanceDelay returns the backoff before the given 503 retry, doubling
+// from SendMaintenanceRetryDelay and capped at SendMaintenanceMaxDelay
+func (e *EmailSender) maintenanceDelay(attempt int) time.Duration {
+	delay := e.config.SendMaintenanceRetryDelay
+	for i := 0; i < attempt && delay < e.config.SendMaintenanceMaxDelay; i++ {
+		delay *= 2
+	}
+	if e.config.SendMaintenanceMaxDelay > 0 && delay > e.config.SendMaintenanceMaxDelay {
+		delay = e.config.SendMaintenanceMaxDelay
+	}
+	return delay
+}
+
+// deliver sends a message through the recipient's account and converts the SendGrid
+// response into an error for non-2xx statuses; kind describes the email in log lines
+// (e.g. "Aggregate email"). The batch's profile, if any, sets the IP pool and retries,
+// and the message is passed to the batch's audit archive whatever the outcome. Each
+// send is traced as a child of the batch span.
+func (e *EmailSender) deliver(b *batch, message *mail.SGMailV3, recipient, kind string) (err error) {
+	ctx, span := e.startSendSpan(b, recipient, kind)
+	var via string
+	defer func() {
+		b.recordAudit(message, recipient, err)
+		if err == nil {
+			b.recordScheduled(message, recipient)
+			b.recordAccount(via, recipient)
+		}
+		endSendSpan(span, err)
+	}()
+
+	if err := validateContentIDs(message); err != nil {
+		return fmt.Errorf("%w for %s: %v", errInvalidMessage, recipient, err)
+	}
+	e.checkHTMLClipping(message, recipient, kind)
+	e.redirectRecipients(message, recipient)
+	e.applyTracking(message)
+	if id := message.Headers["Message-ID"]; id != "" {
+		span.SetAttributes(attrMessageID.String(id))
+	}
+
+	account := e.accountFor(recipient)
+	account.apply(message)
+	e.applyDomainFrom(message, recipient)
+	span.SetAttributes(attrAccount.String(account.name))
+	if b != nil && b.profile.IPPool != "" {
+		message.SetIPPoolID(b.profile.IPPool)
+	}
+
+	via, err = e.post(ctx, span, b, account, message, recipient, kind)
+	return err
+}
+
+// logDryRun logs what a message built in DryRun mode would have sent instead of sending it
+func logDryRun(message *mail.SGMailV3, recipient, kind string, account *sendAccount) {
+	contentBytes, attachmentBytes := 0, 0
+	for _, content := range message.Content {
+		contentBytes += len(content.Value)
+	}
+	for _, attachment := range message.Attachments {
+		attachmentBytes += len(attachment.Content)
+	}
+	log.Infof("DRY RUN: %s not sent to %s (subject=%q, attachments=%d, content=%d bytes, attachment data=%d bytes, account=%s)",
+		kind, recipient, message.Subject, len(message.Attachments), contentBytes, attachmentBytes, account.name)
+}
+
+// post sends a prepared message through account and converts the response into an
+// error for non-2xx statuses, recording the outcome on the send span; recipient
+// describes who the message is for in log lines and errors. It returns what the message
+// went out through: the account's name, or smtpFallbackAccount.
+func (e *EmailSender) post(ctx context.Context, span trace.Span, b *batch, account *sendAccount, message *mail.SGMailV3, recipient, kind string) (string, error) {
+	if e.config.DryRun {
+		logDryRun(message, recipient, kind, account)
+		return account.name, nil
+	}
+
+	retries := e.maintenanceRetries(b)
+	start := e.now()
+	response, err := e.send(ctx, account, message, retries)
+	duration := e.now().Sub(start)
+	if err != nil {
+		e.recordSendMetrics(message, 0, err, duration)
+		return e.sendFallback(ctx, message, recipient, kind, fmt.Errorf("sendgrid account %s: %w", account.name, err))
+	}
+	e.recordSendMetrics(message, response.StatusCode, nil, duration)
+
+	span.SetAttributes(attrHTTPStatus.Int(response.StatusCode))
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		msgID := response.Headers["X-Message-Id"]
+		span.SetAttributes(attrProviderID.StringSlice(msgID))
+		log.Infof("%s accepted by SendGrid for %s (status=%d, id=%s, account=%s, categories=%v, in %s)", kind, recipient, response.StatusCode, msgID, account.name, message.Categories, duration)
+		return account.name, nil
+	}
+
+	if response.StatusCode == http.StatusServiceUnavailable {
+		detail, infrastructure := e.describeFailure(response.Body)
+		log.Errorf("SendGrid still in provider maintenance for %s after %d retries (account=%s, in %s)", recipient, retries, account.name, duration)
+		return e.sendFallback(ctx, message, recipient, kind, &statusError{response.StatusCode, infrastructure, fmt.Errorf("sendgrid provider maintenance (status 503) for %s after %d retries (account=%s, in %s): %s", recipient, retries, account.name, duration, detail)})
+	}
+	err = e.newStatusError(response.StatusCode, response.Body, fmt.Sprintf("%s (account=%s, in %s)", recipient, account.name, duration))
+	if response.StatusCode >= 500 {
+		return e.sendFallback(ctx, message, recipient, kind, err)
+	}
+	return "", err
+}
+
+// smtpFallbackAccount names the SMTP fallback as the account a message went out through
+const smtpFallbackAccount = "smtp"
+
+// sendFallback sends message through the SMTP fallback after SendGrid failed with
+// sendErr, returning smtpFallbackAccount once it is accepted, or along with a
+// partialSendError when only its first personalizations were sent. sendErr is returned as is
+// without a fallback, once ctx is done or for a message scheduled with send_at, which
+// SMTP would deliver right away, and wrapped with the SMTP error when the fallback
+// fails too.
+func (e *EmailSender) sendFallback(ctx context.Context, message *mail.SGMailV3, recipient, kind string, sendErr error) (string, error) {
+	if e.fallback == nil || ctx.Err() != nil {
+		return "", sendErr
+	}
+	if message.SendAt != 0 {
+		log.Warnf("%s to %s failed through SendGrid and is scheduled for %s, not falling back to SMTP: %v", kind, recipient, time.Unix(int64(message.SendAt), 0).UTC().Format(time.RFC3339), sendErr)
+		return "", sendErr
+	}
+	log.Warnf("%s to %s failed through SendGrid, falling back to SMTP: %v", kind, recipient, sendErr)
+	start := e.now()
+	if _, err := e.fallback.Send(ctx, message); err != nil {
+		wrapped := fmt.Errorf("%w; smtp fallback: %v", sendErr, err)
+		var partial *partialSendError
+		if errors.As(err, &partial) && partial.delivered > 0 {
+			// The first recipients went out; the caller reports them sent and the rest failed
+			return smtpFallbackAccount, &partialSendError{delivered: partial.delivered, err: wrapped}
+		}
+		return "", wrapped
+	}
+	log.Infof("%s accepted by SMTP fallback for %s (in %s)", kind, recipient, e.now().Sub(start))
+	return smtpFallbackAccount, nil
+}
@@ -0,0 +1,309 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"email-service/config"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// newTestSender returns an EmailSender whose SendGrid client talks to the given handler
+func newTestSender(t testing.TB, cfg *config.Config, handler http.HandlerFunc, opts ...Option) *EmailSender {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	if cfg.SendGridFromEmail == "" {
+		cfg.SendGridFromEmail = "info@cleanapp.io"
+	}
+	e := NewEmailSender(cfg, opts...)
+	for _, account := range e.accounts {
+		account.client.BaseURL = srv.URL + "/v3/mail/send"
+	}
+	return e
+}
+
+// capturedMail is the subset of the SendGrid v3 request body the tests inspect
+type capturedMail struct {
+	From struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	} `json:"from"`
+	ReplyTo struct {
+		Email string `json:"email"`
+	} `json:"reply_to"`
+	Subject          string            `json:"subject"`
+	Categories       []string          `json:"categories"`
+	Headers          map[string]string `json:"headers"`
+	Personalizations []struct {
+		To []struct {
+			Email string `json:"email"`
+		} `json:"to"`
+		CC []struct {
+			Email string `json:"email"`
+		} `json:"cc"`
+		BCC []struct {
+			Email string `json:"email"`
+		} `json:"bcc"`
+		CustomArgs    map[string]string `json:"custom_args"`
+		Substitutions map[string]string `json:"substitutions"`
+		Headers       map[string]string `json:"headers"`
+	} `json:"personalizations"`
+	Content []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"content"`
+	Attachments []struct {
+		Content     string `json:"content"`
+		Type        string `json:"type"`
+		Filename    string `json:"filename"`
+		Disposition string `json:"disposition"`
+		ContentID   string `json:"content_id"`
+	} `json:"attachments"`
+}
+
+// captureSends returns a handler that records every request body it accepts
+func captureSends(t *testing.T, sent *[]capturedMail) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		var m capturedMail
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		*sent = append(*sent, m)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func TestMaintenanceDelay(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		SendMaintenanceRetryDelay: 30 * time.Second,
+		SendMaintenanceMaxDelay:   2 * time.Minute,
+	}}
+
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute}
+	for attempt, want := range expected {
+		if got := e.maintenanceDelay(attempt); got != want {
+			t.Errorf("maintenanceDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestSendRetriesMaintenance503(t *testing.T) {
+	var calls int32
+	e := newTestSender(t, &config.Config{
+		SendMaintenanceRetries:    3,
+		SendMaintenanceRetryDelay: time.Millisecond,
+		SendMaintenanceMaxDelay:   5 * time.Millisecond,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error after maintenance cleared: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 SendGrid calls, got %d", calls)
+	}
+}
+
+func TestSendGivesUpAfterMaintenanceRetries(t *testing.T) {
+	var calls int32
+	e := newTestSender(t, &config.Config{
+		SendMaintenanceRetries:    2,
+		SendMaintenanceRetryDelay: time.Millisecond,
+		SendMaintenanceMaxDelay:   time.Millisecond,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	err := e.SendEmails([]string{"brand@example.com"}, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error while SendGrid stays in maintenance")
+	}
+	if !strings.Contains(err.Error(), "provider maintenance") {
+		t.Errorf("expected maintenance error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 1 attempt plus 2 retries, got %d calls", calls)
+	}
+}
+
+func TestSendGridJSONErrorBody(t *testing.T) {
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"message":"The from address does not match a verified Sender Identity.","field":"from","help":null},{"message":"Invalid reply_to"}]}`))
+	})
+
+	err := e.SendEmails([]string{"brand@example.com"}, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for a 400 response")
+	}
+	want := "sendgrid returned status 400 for brand@example.com"
+	if !strings.Contains(err.Error(), want) ||
+		!strings.Contains(err.Error(), "The from address does not match a verified Sender Identity. (field from); Invalid reply_to") {
+		t.Errorf("expected parsed SendGrid messages, got %v", err)
+	}
+	if strings.Contains(err.Error(), `"errors"`) {
+		t.Errorf("expected the raw JSON to be replaced by its messages, got %v", err)
+	}
+	if got := failureCategory(err); got != "SendGrid status 400" {
+		t.Errorf("failureCategory() = %q, want SendGrid status 400", got)
+	}
+}
+
+func TestNonJSONErrorBodyIsInfrastructure(t *testing.T) {
+	e := newTestSender(t, &config.Config{FailureBodyLogFirstN: 10}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html><body><h1>502 Bad Gateway</h1></body></html>"))
+	})
+
+	err := e.SendEmails([]string{"brand@example.com"}, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for a 502 response")
+	}
+	if !strings.Contains(err.Error(), "infrastructure error (status 502, non-JSON response)") ||
+		!strings.Contains(err.Error(), "502 Bad Gateway") {
+		t.Errorf("expected an infrastructure error with the sampled body, got %v", err)
+	}
+	if got := failureCategory(err); got != "infrastructure error (status 502)" {
+		t.Errorf("failureCategory() = %q, want infrastructure error (status 502)", got)
+	}
+}
+
+// flakyTransport fails its first failures sends with status, or with a network error
+// when status is 0, then accepts every send
+type flakyTransport struct {
+	failures int
+	status   int
+	calls    int
+	onSend   func() // Called on every send, e.g. to cancel the batch
+}
+
+func (f *flakyTransport) Send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	f.calls++
+	if f.onSend != nil {
+		f.onSend()
+	}
+	if f.calls > f.failures {
+		return &rest.Response{StatusCode: http.StatusAccepted}, nil
+	}
+	if f.status == 0 {
+		return nil, errors.New("connection reset by peer")
+	}
+	return &rest.Response{StatusCode: f.status, Body: `{"errors":[{"message":"try again"}]}`}, nil
+}
+
+func TestSendRetriesTransientFailures(t *testing.T) {
+	for _, status := range []int{0, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway} {
+		transport := &flakyTransport{failures: 2, status: status}
+		e := NewEmailSenderWithTransport(&config.Config{MaxSendRetries: 3, SendRetryBaseDelay: time.Millisecond}, transport)
+
+		if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+			t.Errorf("status %d: expected success after two failures, got %v", status, err)
+		}
+		if transport.calls != 3 {
+			t.Errorf("status %d: expected 3 attempts, got %d", status, transport.calls)
+		}
+	}
+}
+
+func TestSendDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusRequestEntityTooLarge} {
+		transport := &flakyTransport{failures: 1, status: status}
+		e := NewEmailSenderWithTransport(&config.Config{MaxSendRetries: 3, SendRetryBaseDelay: time.Millisecond}, transport)
+
+		if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err == nil {
+			t.Errorf("status %d: expected an error", status)
+		}
+		if transport.calls != 1 {
+			t.Errorf("status %d: expected a single attempt, got %d", status, transport.calls)
+		}
+	}
+}
+
+func TestSendGivesUpAfterMaxSendRetries(t *testing.T) {
+	transport := &flakyTransport{failures: 10, status: http.StatusTooManyRequests}
+	e := NewEmailSenderWithTransport(&config.Config{MaxSendRetries: 2, SendRetryBaseDelay: time.Millisecond}, transport)
+
+	err := e.SendEmails([]string{"brand@example.com"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "status 429") {
+		t.Fatalf("expected the final 429 as the error, got %v", err)
+	}
+	if transport.calls != 3 {
+		t.Errorf("expected 1 attempt plus 2 retries, got %d", transport.calls)
+	}
+}
+
+func TestSendRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport := &flakyTransport{failures: 10, status: http.StatusBadGateway, onSend: cancel}
+	e := NewEmailSenderWithTransport(&config.Config{MaxSendRetries: 3, SendRetryBaseDelay: time.Hour}, transport)
+
+	start := time.Now()
+	err := e.SendEmailsContext(ctx, []string{"brand@example.com"}, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	if transport.calls != 1 || time.Since(start) > time.Minute {
+		t.Errorf("expected the retry wait abandoned after 1 attempt, got %d attempts", transport.calls)
+	}
+}
+
+func TestSendRetryDelay(t *testing.T) {
+	e := &EmailSender{config: &config.Config{SendRetryBaseDelay: 100 * time.Millisecond}}
+	for attempt, base := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		if got := e.sendRetryDelay(attempt); got < base || got > base+base/2 {
+			t.Errorf("sendRetryDelay(%d) = %s, want %s plus at most 50%% jitter", attempt, got, base)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := &EmailSender{config: &config.Config{}, now: func() time.Time { return now }}
+
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"3600", maxRetryAfter, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		response := &rest.Response{Headers: map[string][]string{}}
+		if tt.header != "" {
+			response.Headers["Retry-After"] = []string{tt.header}
+		}
+		if got, ok := e.retryAfter(response); got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"email-service/models"
+)
+
+// riskRange returns the analysis risk range when it should be rendered: the feature
+// is enabled, the report is digital and the range is well-formed
+func (e *EmailSender) riskRange(analysis *models.ReportAnalysis) (*models.RiskRange, bool) {
+	r := analysis.RiskRange
+	if !e.config.ShowRiskRange || analysis.Classification != "digital" || r == nil {
+		return nil, false
+	}
+	if r.Min < 0 || r.Max <= 0 || r.Min > r.Max {
+		return nil, false
+	}
+	return r, true
+}
+
+// getRiskRangeHtml returns the min-max range bar for the digital card. The bar spans
+// zero to the maximum, with the band starting at the minimum.
+func (e *EmailSender) getRiskRangeHtml(r *models.RiskRange) string {
+	offset := r.Min / r.Max * 100
+	return fmt.Sprintf(`
+    <div style="background-color: #fff3cd; padding: 15px; border-radius: 5px; margin: 15px 0; border-left: 4px solid #ffc107;">
+        <p style="margin: 0; font-weight: bold; color: #856404;">💰 Estimated Risk Range</p>
+        <div style="position: relative; width: 100%%; height: 16px; background: #f0f0f0; border-radius: 8px; overflow: hidden; margin: 10px 0;">
+            <div class="medium" style="position: absolute; top: 0; bottom: 0; left: %.1f%%; right: 0; border-radius: 8px;"></div>
+        </div>
+        <div style="display: flex; justify-content: space-between; color: #856404;">
+            <span>%s</span>
+            <span>%s</span>
+        </div>
+    </div>`, offset, formatAmount(r.Min, r.Currency), formatAmount(r.Max, r.Currency))
+}
+
+// getRiskRangeText returns the range as "$1,000 – $25,000" for the plain text email
+func getRiskRangeText(r *models.RiskRange) string {
+	return formatAmount(r.Min, r.Currency) + " – " + formatAmount(r.Max, r.Currency)
+}
+
+// formatAmount formats a whole-unit amount with thousands separators, as "$12,500"
+// for USD and "12,500 EUR" for other currencies
+func formatAmount(amount float64, currency string) string {
+	digits := strconv.FormatInt(int64(math.Round(amount)), 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == "USD" {
+		return "$" + b.String()
+	}
+	return b.String() + " " + currency
+}
@@ -0,0 +1,53 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{0, "", "$0"},
+		{999, "usd", "$999"},
+		{1000, "", "$1,000"},
+		{1234567.4, "USD", "$1,234,567"},
+		{25000, "eur", "25,000 EUR"},
+	}
+	for _, tt := range tests {
+		if got := formatAmount(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("formatAmount(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestDigitalRiskRangeRendering(t *testing.T) {
+	e := &EmailSender{config: &config.Config{ShowRiskRange: true}}
+	analysis := &models.ReportAnalysis{
+		Title:          "Checkout button unresponsive",
+		Classification: "digital",
+		RiskRange:      &models.RiskRange{Min: 5000, Max: 20000},
+	}
+
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(html, "Estimated Risk Range") || !strings.Contains(html, "$20,000") || !strings.Contains(html, "left: 25.0%") {
+		t.Error("expected the risk range bar in the digital HTML")
+	}
+	text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(text, "$5,000 – $20,000") {
+		t.Errorf("expected the risk range in the text body:\n%s", text)
+	}
+
+	// Without a range or estimate, the generic liability copy is omitted
+	analysis.RiskRange = nil
+	html = e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if strings.Contains(html, "Estimated Liability") || strings.Contains(html, "Estimated Risk Range") {
+		t.Error("expected no liability section for a digital report without estimates")
+	}
+}
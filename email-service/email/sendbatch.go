@@ -0,0 +1,245 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// maxPersonalizations is SendGrid's limit on personalizations in one mail/send request
+const maxPersonalizations = 1000
+
+// recipientTag stands in for the recipient's address in a batched message body, such
+// as in the unsubscribe link, and is substituted by SendGrid per personalization
+const recipientTag = "-recipient_email-"
+
+// SendBatch sends an analysis email to recipients with one SendGrid request per up to
+// 1000 of them instead of one each. Every recipient is a separate personalization, so
+// no one sees the others, and the body and images are added once with recipientTag in
+// place of the address, which SendGrid substitutes per recipient. Recipients whose
+// email is rendered or routed individually (text-only recipients, those with a
+// geofence note, domains with their own From, and everyone in RedirectAllTo mode) are
+// sent individually as by SendEmailsWithAnalysisContext. A failed request fails every
+// recipient it carried that the SMTP fallback didn't send. Sends stop when ctx is done, as described for SendEmailsContext.
+func (e *EmailSender) SendBatch(ctx context.Context, recipients []string, reportImage, mapImage []byte, analysis *models.ReportAnalysis, opts ...SendOption) error {
+	b := e.newBatch(opts)
+	if err := e.checkBatchSize(b, len(recipients)); err != nil {
+		return err
+	}
+	if analysis == nil {
+		logMissingAnalysis(b, fmt.Sprintf("%d recipients", len(recipients)))
+		return e.sendEmails(ctx, b, slices.Values(recipientsFromEmails(recipients)), reportImage, mapImage)
+	}
+	analysis = e.normalizeClassification(analysis)
+	b.classification = analysis.Classification
+
+	if err := e.checkMinSeverity(b, analysis, fmt.Sprintf("%d recipients", len(recipients))); err != nil {
+		return err
+	}
+	reportImg, mapImg, err := e.prepareAnalysisImages(ctx, b, reportImage, mapImage, analysis)
+	if err != nil {
+		return err
+	}
+
+	const kind, plural = "email with analysis", "emails with analysis"
+	report := &batchReport{id: b.id, kind: kind}
+	ctx, span := e.startBatchSpan(ctx, b, kind)
+
+	// Batched recipients are grouped by subject variant, whose category is per message,
+	// and by the account sending to them, so every recipient keeps their subuser
+	seen := make(map[string]bool)
+	var individual []string
+	var groups []*subjectGroup
+	byVariant := make(map[string]*subjectGroup)
+	batched := 0
+	for _, recipient := range recipients {
+		report.total++
+		recipient = normalizeRecipient(recipient)
+		if err := e.screenRecipient(b, kind, seen, recipient); err != nil {
+			report.reject(recipient, err)
+			continue
+		}
+		if e.sendsIndividually(recipient, analysis) {
+			individual = append(individual, recipient)
+			continue
+		}
+		subject, variant := e.subjectVariant(recipient, "", analysis)
+		account := e.accountFor(recipient)
+		key := variant + "\x00" + account.name
+		group, ok := byVariant[key]
+		if !ok {
+			group = &subjectGroup{subject: subject, variant: variant, account: account}
+			byVariant[key] = group
+			groups = append(groups, group)
+		}
+		group.recipients = append(group.recipients, recipient)
+		batched++
+	}
+	log.Infof("Sending email with analysis to %d recipients in batched requests and %d individually (batch %s)", batched, len(individual), b.id)
+
+	unsent := 0
+	for _, group := range groups {
+		for chunk := range slices.Chunk(group.recipients, maxPersonalizations) {
+			if ctx.Err() != nil {
+				unsent += len(chunk)
+				continue
+			}
+			err := e.sendPersonalized(b, group.account, group.subject, group.variant, chunk, reportImg, mapImg, analysis)
+			delivered := sentPersonalizations(err, len(chunk))
+			report.succeeded = append(report.succeeded, chunk[:delivered]...)
+			if err != nil {
+				log.Warnf("Error sending %s to %d recipients: %v", kind, len(chunk)-delivered, err)
+				for _, recipient := range chunk[delivered:] {
+					report.failures = append(report.failures, batchFailure{recipient, err})
+				}
+			}
+		}
+	}
+	for _, recipient := range individual {
+		if ctx.Err() != nil {
+			unsent++
+			continue
+		}
+		if err := e.sendOneEmailWithAnalysis(b, Recipient{Email: recipient}, reportImg, mapImg, analysis); err != nil {
+			log.Warnf("Error sending %s to %s: %v", kind, recipient, err)
+			report.failures = append(report.failures, batchFailure{recipient, err})
+			continue
+		}
+		report.succeeded = append(report.succeeded, recipient)
+	}
+	report.total -= unsent
+
+	e.refundFrequency(b, report)
+	e.sendOpsSummary(report)
+	b.recordResult(report, plural)
+
+	err = report.err(plural)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		log.Warnf("Batch %s cancelled after %d/%d %s sent: %v", b.id, report.sent(), report.total, plural, ctxErr)
+		err = errors.Join(err, &CancelledError{BatchID: b.id, Sent: report.sent(), Total: report.total, Err: ctxErr})
+	}
+	endBatchSpan(span, report, err)
+	return err
+}
+
+// sentPersonalizations returns how many of a message's n personalizations were sent
+// given its send error: all of them without one, those counted by a partialSendError,
+// or none
+func sentPersonalizations(err error, n int) int {
+	if err == nil {
+		return n
+	}
+	var partial *partialSendError
+	if errors.As(err, &partial) {
+		return min(partial.delivered, n)
+	}
+	return 0
+}
+
+// subjectGroup is the batched recipients sharing a subject variant and sending account
+type subjectGroup struct {
+	subject, variant string
+	account          *sendAccount
+	recipients       []string
+}
+
+// sendsIndividually reports whether recipient's email can't share a batched message:
+// its body differs (text-only, or a geofence note) or it is routed differently
+// (a per-domain From, or the RedirectAllTo test mode)
+func (e *EmailSender) sendsIndividually(recipient string, analysis *models.ReportAnalysis) bool {
+	return e.config.RedirectAllTo != "" ||
+		e.textOnly(recipient) ||
+		e.getGeofenceNote(recipient, analysis) != "" ||
+		e.domainFrom(recipient) != nil
+}
+
+// sendPersonalized sends one analysis email through account to recipients sharing a
+// subject variant, with a personalization per recipient carrying their address, Message-ID and the
+// substitution of recipientTag in the body
+func (e *EmailSender) sendPersonalized(b *batch, account *sendAccount, subject, variant string, recipients []string, reportImage, mapImage *inlineImage, analysis *models.ReportAnalysis) error {
+	what := fmt.Sprintf("%d recipients", len(recipients))
+	var render analysisRender
+	e.checkInboxPreviewLength(b, subject, render, analysis)
+	hasReport, hasMap := e.analysisImages(what, reportImage, mapImage, analysis, &render)
+
+	brand := e.brandFor(analysis)
+	message := mail.NewV3Mail()
+	message.SetFrom(mail.NewEmail(brand.FromName, brand.FromEmail))
+	message.Subject = subject
+	e.applyHeaders(b, message, what)
+	e.applyCategories(b, message, analysis, variant)
+	e.applyCriticalBypass(message, what, analysis)
+	e.applyOnBehalfOf(message, what, analysis)
+	e.applyQuietHours(message, what, analysis)
+
+	for _, recipient := range recipients {
+		p := mail.NewPersonalization()
+		p.AddTos(mail.NewEmail(recipient, recipient))
+		b.addCopies(p, recipient)
+		p.SetSubstitution(recipientTag, recipient)
+		p.SetSubstitution(recipientQueryTag, url.QueryEscape(recipient))
+		setAnalysisCustomArgs(p, analysis)
+		if token := e.optOutToken(recipient); token != "" {
+			p.SetSubstitution(optOutTokenTag, token)
+		}
+		e.setListUnsubscribe(p, brand.OptOutURL, recipient)
+		if id := e.messageID(Recipient{Email: recipient}, analysis, ""); id != "" {
+			p.SetHeader("Message-ID", id)
+		}
+		message.AddPersonalizations(p)
+	}
+
+	if err := e.addBodies(message, recipientTag, e.getEmailTextWithAnalysis(recipientTag, analysis, hasReport, hasMap, render), func() string {
+		return e.getEmailHtmlWithAnalysis(recipientTag, analysis, hasReport, hasMap, render)
+	}); err != nil {
+		return err
+	}
+	e.addAnalysisImages(message, recipientTag, render, analysis)
+
+	return e.deliverPersonalized(b, account, message, recipients, "Batched email with analysis")
+}
+
+// deliverPersonalized is deliver for a message addressed to several recipients through
+// personalizations. The message goes out through account, which the caller picked for
+// every recipient, and each recipient gets an audit record of the shared content. When
+// only the first recipients were sent, through the SMTP fallback, the error is a
+// partialSendError counting them.
+func (e *EmailSender) deliverPersonalized(b *batch, account *sendAccount, message *mail.SGMailV3, recipients []string, kind string) (err error) {
+	what := fmt.Sprintf("%d recipients", len(recipients))
+	ctx, span := e.startSendSpan(b, what, kind)
+	var via string
+	defer func() {
+		delivered := sentPersonalizations(err, len(recipients))
+		for i, recipient := range recipients {
+			if i < delivered {
+				b.recordAudit(message, recipient, nil)
+			} else {
+				b.recordAudit(message, recipient, err)
+			}
+		}
+		b.recordScheduled(message, recipients[:delivered]...)
+		b.recordAccount(via, recipients[:delivered]...)
+		endSendSpan(span, err)
+	}()
+
+	if err := validateContentIDs(message); err != nil {
+		return fmt.Errorf("%w for %s: %v", errInvalidMessage, what, err)
+	}
+	e.checkHTMLClipping(message, what, kind)
+	e.applyTracking(message)
+
+	account.apply(message)
+	span.SetAttributes(attrAccount.String(account.name))
+	if b != nil && b.profile.IPPool != "" {
+		message.SetIPPoolID(b.profile.IPPool)
+	}
+	via, err = e.post(ctx, span, b, account, message, what, kind)
+	return err
+}
@@ -0,0 +1,85 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestSendBatchUsesOnePersonalizationPerRecipient(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{OptOutURL: "https://cleanapp.io/optout"}, captureSends(t, &sent))
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+	if err := e.SendBatch(context.Background(), recipients, encodeTestImage(t, 40, 30, "jpeg"), nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendBatch returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(sent))
+	}
+	m := sent[0]
+	if len(m.Personalizations) != len(recipients) {
+		t.Fatalf("expected %d personalizations, got %d", len(recipients), len(m.Personalizations))
+	}
+	for i, p := range m.Personalizations {
+		if len(p.To) != 1 || p.To[0].Email != recipients[i] {
+			t.Errorf("personalization %d is addressed to %+v, want only %s", i, p.To, recipients[i])
+		}
+		if p.Substitutions[recipientTag] != recipients[i] || p.Substitutions[recipientQueryTag] != url.QueryEscape(recipients[i]) {
+			t.Errorf("personalization %d substitutes %q, want %s", i, p.Substitutions[recipientTag], recipients[i])
+		}
+	}
+	if len(m.Attachments) != 1 {
+		t.Errorf("expected the report image attached once, got %d attachments", len(m.Attachments))
+	}
+	for _, content := range m.Content {
+		if !strings.Contains(content.Value, "https://cleanapp.io/optout?email="+recipientQueryTag) {
+			t.Errorf("expected the %s opt-out link to use the substitution tag", content.Type)
+		}
+		for _, recipient := range recipients {
+			if strings.Contains(content.Value, recipient) {
+				t.Errorf("expected no recipient address in the shared %s body, found %s", content.Type, recipient)
+			}
+		}
+	}
+}
+
+func TestSendBatchChunksAtPersonalizationLimit(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{SendGridFromEmail: "info@cleanapp.io"}, transport)
+
+	recipients := make([]string, maxPersonalizations+1)
+	for i := range recipients {
+		recipients[i] = fmt.Sprintf("brand%d@example.com", i)
+	}
+	if err := e.SendBatch(context.Background(), recipients, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendBatch returned error: %v", err)
+	}
+	if len(transport.messages) != 2 || len(transport.messages[0].Personalizations) != maxPersonalizations || len(transport.messages[1].Personalizations) != 1 {
+		t.Errorf("expected requests of %d and 1 personalizations, got %d requests", maxPersonalizations, len(transport.messages))
+	}
+}
+
+func TestSendBatchSendsTextOnlyRecipientsIndividually(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{TextOnlyRecipients: []string{"@plain.example"}}, captureSends(t, &sent))
+
+	recipients := []string{"a@example.com", "b@example.com", "ops@plain.example", "not-an-email"}
+	err := e.SendBatch(context.Background(), recipients, nil, nil, goldenAnalysis())
+	if err == nil || !strings.Contains(err.Error(), "not-an-email") {
+		t.Errorf("expected the invalid address reported, got %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected a batched and an individual request, got %d", len(sent))
+	}
+	if len(sent[0].Personalizations) != 2 || len(sent[1].Personalizations) != 1 || sent[1].Personalizations[0].To[0].Email != "ops@plain.example" {
+		t.Errorf("expected 2 batched recipients and the text-only one alone, got %+v", sent)
+	}
+	if len(sent[1].Content) != 1 || sent[1].Content[0].Type != "text/plain" {
+		t.Error("expected the individual send to be text-only")
+	}
+}
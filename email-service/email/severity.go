@@ -0,0 +1,118 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+// ErrBelowSeverityThreshold is returned when an analysis email is skipped because the
+// report's severity is under the brand's minimum; no recipient was sent anything
+var ErrBelowSeverityThreshold = errors.New("skipped: below threshold")
+
+// minSeverity returns the severity floor for the analysis brand, falling back to
+// the default MinSeverity
+func (e *EmailSender) minSeverity(analysis *models.ReportAnalysis) float64 {
+	if threshold, ok := e.config.MinSeverityByBrand[strings.ToLower(analysis.BrandName)]; ok {
+		return threshold
+	}
+	return e.config.MinSeverity
+}
+
+// imageSeverityThreshold returns the severity below which the analysis brand's emails
+// link the images instead of attaching them, falling back to the default
+// ImageSeverityThreshold
+func (e *EmailSender) imageSeverityThreshold(analysis *models.ReportAnalysis) float64 {
+	if threshold, ok := e.config.ImageSeverityThresholdByBrand[strings.ToLower(analysis.BrandName)]; ok {
+		return threshold
+	}
+	return e.config.ImageSeverityThreshold
+}
+
+// getSeveritySentence summarizes the key metrics in one sentence, e.g. "Hazard
+// probability High (82%), severity 7/10", for screen readers and inbox previews.
+// It returns an empty string unless ShowSeveritySummary is set.
+func (e *EmailSender) getSeveritySentence(analysis *models.ReportAnalysis) string {
+	if !e.config.ShowSeveritySummary {
+		return ""
+	}
+	severity := strconv.FormatFloat(math.Round(analysis.SeverityLevel*10)/10, 'f', -1, 64)
+	return fmt.Sprintf("Hazard probability %s (%.0f%%), severity %s/10",
+		e.getGaugeLabel(analysis.HazardProbability), analysis.HazardProbability*100, severity)
+}
+
+// getPreheaderHtml returns the hidden preheader that inbox previews show after the
+// subject, or an empty string when there is no severity sentence
+func (e *EmailSender) getPreheaderHtml(analysis *models.ReportAnalysis) string {
+	sentence := e.getSeveritySentence(analysis)
+	if sentence == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+    <div style="display: none; max-height: 0; overflow: hidden; mso-hide: all;">%s</div>`, sentence)
+}
+
+// getSeveritySentenceHtml returns the visible severity sentence for the top of the
+// analysis body, or an empty string when disabled
+func (e *EmailSender) getSeveritySentenceHtml(analysis *models.ReportAnalysis) string {
+	sentence := e.getSeveritySentence(analysis)
+	if sentence == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+        <p class="severity-summary" style="margin-top: 10px; font-weight: bold;">%s</p>`, sentence)
+}
+
+// severityBandColors are the solid colors of the low, medium and high gauge gradients
+var severityBandColors = map[string]string{
+	"low":    "#28a745",
+	"medium": "#fd7e14",
+	"high":   "#dc3545",
+}
+
+// getSeverityRatingHtml renders the 0-10 severity as five stars or warning icons, half
+// a point each, colored by severity band; it returns "" for the default bar display
+func (e *EmailSender) getSeverityRatingHtml(analysis *models.ReportAnalysis) string {
+	var filled, empty string
+	switch e.config.SeverityDisplay {
+	case config.SeverityDisplayStars:
+		filled, empty = "★", "☆"
+	case config.SeverityDisplayIcons:
+		filled, empty = "⚠", "⚠"
+	default:
+		return ""
+	}
+
+	count := int(math.Round(math.Max(0, math.Min(10, analysis.SeverityLevel)) / 2))
+	color := severityBandColors[e.getSeverityGaugeColor(analysis.SeverityLevel)]
+	symbols := fmt.Sprintf(`<span style="color: %s;">%s</span><span style="color: #ddd;">%s</span>`,
+		color, strings.Repeat(filled, count), strings.Repeat(empty, 5-count))
+
+	return fmt.Sprintf(`
+    <div style="margin: 20px 0; background-color: #fff; padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1);">
+        <div style="font-size: 0.9em; font-weight: bold; margin-bottom: 10px; color: #555;">Severity</div>
+        <div role="img" aria-label="Severity %.1f out of 10, %s" style="font-size: 1.8em; letter-spacing: 4px;">%s</div>
+    </div>`, analysis.SeverityLevel, e.getSeverityGaugeLabel(analysis.SeverityLevel), symbols)
+}
+
+// hideMetrics reports whether the metrics section (gauges, risk factor, liability and
+// CTA) is left out for the analysis brand or classification, leaving just the
+// report details and images
+func (e *EmailSender) hideMetrics(analysis *models.ReportAnalysis) bool {
+	for _, brand := range e.config.HideMetricsBrands {
+		if strings.EqualFold(brand, analysis.BrandName) {
+			return true
+		}
+	}
+	for _, classification := range e.config.HideMetricsClassifications {
+		if strings.EqualFold(classification, analysis.Classification) {
+			return true
+		}
+	}
+	return false
+}
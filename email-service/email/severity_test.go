@@ -0,0 +1,115 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestSeveritySentence(t *testing.T) {
+	e := &EmailSender{config: &config.Config{ShowSeveritySummary: true}}
+	analysis := &models.ReportAnalysis{HazardProbability: 0.82, SeverityLevel: 7}
+
+	const want = "Hazard probability High (82%), severity 7/10"
+	if got := e.getSeveritySentence(analysis); got != want {
+		t.Errorf("getSeveritySentence() = %q, want %q", got, want)
+	}
+
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if strings.Count(html, want) != 2 {
+		t.Error("expected the sentence in both the preheader and the body")
+	}
+	if text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{}); !strings.HasPrefix(text, want+".") {
+		t.Errorf("expected the text body to open with the sentence:\n%s", text)
+	}
+
+	e.config.ShowSeveritySummary = false
+	if got := e.getSeveritySentence(analysis); got != "" {
+		t.Errorf("expected no sentence when disabled, got %q", got)
+	}
+}
+
+func TestHideMetricsForBrand(t *testing.T) {
+	e := &EmailSender{config: &config.Config{HideMetricsBrands: []string{"acme"}}}
+	analysis := goldenAnalysis()
+
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, true, true, analysisRender{})
+	text := e.getEmailTextWithAnalysis("brand@example.com", analysis, true, true, analysisRender{})
+	if strings.Contains(html, "Legal Risk Factor") || strings.Contains(text, "LEGAL RISK FACTOR") {
+		t.Error("expected the metrics section to be omitted for a hidden brand")
+	}
+	if !strings.Contains(html, analysis.Title) || !strings.Contains(html, `src="cid:`) {
+		t.Error("expected the report details and images to remain")
+	}
+
+	analysis.BrandName = "other"
+	html = e.getEmailHtmlWithAnalysis("brand@example.com", analysis, true, true, analysisRender{})
+	if !strings.Contains(html, "Legal Risk Factor") {
+		t.Error("expected metrics for brands that aren't hidden")
+	}
+}
+
+func TestMinSeverityBoundary(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		MinSeverity:        2,
+		MinSeverityByBrand: map[string]float64{"acme": 5},
+	}, captureSends(t, &sent))
+
+	tests := []struct {
+		brand    string
+		severity float64
+		skipped  bool
+	}{
+		{"Acme", 4.9, true},
+		{"Acme", 5, false},
+		{"Acme", 5.1, false},
+		{"other", 1.9, true},
+		{"other", 2, false},
+	}
+	for _, tt := range tests {
+		sent = nil
+		analysis := &models.ReportAnalysis{BrandName: tt.brand, SeverityLevel: tt.severity}
+		err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, analysis)
+
+		if tt.skipped {
+			if !errors.Is(err, ErrBelowSeverityThreshold) || len(sent) != 0 {
+				t.Errorf("%s severity %.1f: expected skip, got err=%v and %d sends", tt.brand, tt.severity, err, len(sent))
+			}
+			continue
+		}
+		if err != nil || len(sent) != 1 {
+			t.Errorf("%s severity %.1f: expected a send, got err=%v and %d sends", tt.brand, tt.severity, err, len(sent))
+		}
+	}
+}
+
+func TestSeverityRatingHtml(t *testing.T) {
+	tests := []struct {
+		display  string
+		severity float64
+		want     string // Expected filled and empty symbols, "" for no rating
+	}{
+		{config.SeverityDisplayBar, 6.5, ""},
+		{config.SeverityDisplayStars, 6.5, `<span style="color: #fd7e14;">★★★</span><span style="color: #ddd;">☆☆</span>`},
+		{config.SeverityDisplayStars, 10, `<span style="color: #dc3545;">★★★★★</span><span style="color: #ddd;"></span>`},
+		{config.SeverityDisplayIcons, 0.4, `<span style="color: #28a745;"></span><span style="color: #ddd;">⚠⚠⚠⚠⚠</span>`},
+	}
+
+	for _, tt := range tests {
+		e := &EmailSender{config: &config.Config{SeverityDisplay: tt.display}}
+		got := e.getSeverityRatingHtml(&models.ReportAnalysis{SeverityLevel: tt.severity})
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("%s: expected no rating, got %s", tt.display, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.want) || !strings.Contains(got, `role="img"`) {
+			t.Errorf("%s %.1f: rating missing %s:\n%s", tt.display, tt.severity, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go"
+)
+
+// defaultMarketingHost is the SendGrid API host for the Marketing Campaigns endpoints
+const defaultMarketingHost = "https://api.sendgrid.com"
+
+// singleSendPollInterval is how often the contact import job is polled
+var singleSendPollInterval = 2 * time.Second
+
+// singleSendEmailTag stands in for the contact's address in a Single Send body,
+// filled in by SendGrid per contact
+const singleSendEmailTag = "{{email}}"
+
+// singleSendRequest is the body of POST /v3/marketing/singlesends
+type singleSendRequest struct {
+	Name        string                `json:"name"`
+	Categories  []string              `json:"categories,omitempty"`
+	SendTo      singleSendTo          `json:"send_to"`
+	EmailConfig singleSendEmailConfig `json:"email_config"`
+}
+
+type singleSendTo struct {
+	ListIDs []string `json:"list_ids"`
+}
+
+type singleSendEmailConfig struct {
+	Subject              string `json:"subject"`
+	HTMLContent          string `json:"html_content"`
+	PlainContent         string `json:"plain_content"`
+	SenderID             int    `json:"sender_id"`
+	SuppressionGroupID   int    `json:"suppression_group_id,omitempty"`
+	CustomUnsubscribeURL string `json:"custom_unsubscribe_url,omitempty"`
+}
+
+// useSingleSend reports whether a batch goes through the Single Sends API: it must be
+// enabled, large enough, not urgent, not a dry run and not redirected to a test inbox
+// with RedirectAllTo. High severity and critical reports stay on the transactional path,
+// which delivers immediately and per recipient.
+func (e *EmailSender) useSingleSend(recipients []string, analysis *models.ReportAnalysis) bool {
+	return e.config.SingleSendEnabled &&
+		!e.config.DryRun &&
+		e.config.RedirectAllTo == "" &&
+		len(recipients) >= e.config.SingleSendMinBatch &&
+		e.getSeverityGaugeColor(analysis.SeverityLevel) != "high" &&
+		!analysis.Critical
+}
+
+// sendSingleSendBatch screens the batch's recipients as streamBatch does, so invalid,
+// sender, suppressed, duplicate and frequency-capped addresses are left out, and sends
+// the rest as one Single Send. Each recipient's outcome is recorded on the batch.
+func (e *EmailSender) sendSingleSendBatch(ctx context.Context, b *batch, recipients []Recipient, analysis *models.ReportAnalysis) error {
+	const kind, plural = "email with analysis", "emails with analysis"
+	report := &batchReport{id: b.id, kind: kind}
+	seen := make(map[string]bool)
+	var screened []string
+	for _, r := range recipients {
+		report.total++
+		recipient := normalizeRecipient(r.Email)
+		if err := e.screenRecipient(b, kind, seen, recipient); err != nil {
+			report.reject(recipient, err)
+			continue
+		}
+		screened = append(screened, recipient)
+	}
+
+	if len(screened) > 0 {
+		sendAt := e.quietHoursDeferral(analysis)
+		if err := e.sendSingleSend(ctx, b.id, screened, analysis, sendAt); err != nil {
+			log.Warnf("Error sending %s to %d recipients as a Single Send: %v", kind, len(screened), err)
+			for _, recipient := range screened {
+				report.failures = append(report.failures, batchFailure{recipient, err})
+			}
+		} else {
+			report.succeeded = screened
+			b.recordScheduledAt(sendAt, screened...)
+		}
+	}
+
+	e.refundFrequency(b, report)
+	e.sendOpsSummary(report)
+	b.recordResult(report, plural)
+	return report.err(plural)
+}
+
+// singleSendListPrefix starts the name of every contact list created for a Single Send
+const singleSendListPrefix = "cleanapp-"
+
+// singleSendListRetention is how long a batch's contact list is kept. The list must
+// outlive its Single Send, which reads the list when it goes out rather than when it is
+// scheduled, so lists are pruned by age on later batches instead of right away.
+const singleSendListRetention = 72 * time.Hour
+
+// sendSingleSend delivers an analysis batch as a Marketing Campaigns Single Send. The
+// recipients are imported into a new list for the batch, then a Single Send to that
+// list is created and scheduled for sendAt, or immediately for the zero time, e.g. at
+// the end of quiet hours. Campaign mail can't carry attachments, so
+// the report media is linked, and the body is personalized with the {{email}} tag.
+// Lists of earlier batches past singleSendListRetention are deleted first, and the
+// batch's own list is deleted again if the Single Send can't be scheduled. Every request
+// and the wait for the contact import end early when ctx is done; once the Single Send
+// is scheduled, it can only be cancelled in SendGrid.
+func (e *EmailSender) sendSingleSend(ctx context.Context, batchID string, recipients []string, analysis *models.ReportAnalysis, sendAt time.Time) (err error) {
+	e.pruneContactLists(ctx)
+	listID, err := e.createContactList(ctx, fmt.Sprintf("%s%d-%s", singleSendListPrefix, e.now().Unix(), batchID))
+	if err != nil {
+		return fmt.Errorf("single send %s: %w", batchID, err)
+	}
+	defer func() {
+		if err != nil {
+			// Clean up even when ctx was cancelled
+			e.deleteContactList(context.WithoutCancel(ctx), listID)
+		}
+	}()
+	if err := e.importContacts(ctx, listID, recipients); err != nil {
+		return fmt.Errorf("single send %s: %w", batchID, err)
+	}
+
+	if e.config.OptOutSigningKey != "" && e.config.SingleSendSuppressionGroupID == 0 {
+		log.Warnf("Single Send %s opt-out links are unsigned, so they won't verify with OPT_OUT_SIGNING_KEY set; configure SENDGRID_SINGLE_SEND_SUPPRESSION_GROUP_ID", batchID)
+	}
+	// A Single Send carries no images, so it links the report's media page, or the dashboard
+	mediaURL := e.getReportMediaURL(analysis)
+	if mediaURL == "" {
+		mediaURL = e.getDashboardURL(analysis)
+	}
+	render := analysisRender{mediaURL: mediaURL}
+	html, err := e.transformHTML(e.getEmailHtmlWithAnalysis(singleSendEmailTag, analysis, false, false, render))
+	if err != nil {
+		return fmt.Errorf("single send %s: html transform: %w", batchID, err)
+	}
+	request := singleSendRequest{
+		Name:   fmt.Sprintf("CleanApp report %d (%s)", analysis.Seq, batchID),
+		SendTo: singleSendTo{ListIDs: []string{listID}},
+		EmailConfig: singleSendEmailConfig{
+			Subject:            e.BuildSubject(analysis),
+			HTMLContent:        html,
+			PlainContent:       e.getEmailTextWithAnalysis(singleSendEmailTag, analysis, false, false, render),
+			SenderID:           e.config.SingleSendSenderID,
+			SuppressionGroupID: e.config.SingleSendSuppressionGroupID,
+		},
+	}
+	if request.EmailConfig.SuppressionGroupID == 0 {
+		request.EmailConfig.CustomUnsubscribeURL = e.brandFor(analysis).OptOutURL
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := e.marketingRequest(ctx, http.MethodPost, "/v3/marketing/singlesends", request, &created); err != nil {
+		return fmt.Errorf("single send %s: create: %w", batchID, err)
+	}
+
+	var scheduled struct {
+		Status string `json:"status"`
+	}
+	schedule := map[string]string{"send_at": "now"}
+	if !sendAt.IsZero() {
+		schedule["send_at"] = sendAt.UTC().Format(time.RFC3339)
+	}
+	if err := e.marketingRequest(ctx, http.MethodPut, "/v3/marketing/singlesends/"+created.ID+"/schedule", schedule, &scheduled); err != nil {
+		return fmt.Errorf("single send %s: schedule %s: %w", batchID, created.ID, err)
+	}
+
+	log.Infof("Single Send %s scheduled for %d recipients at %s (batch %s, list %s, status=%s)", created.ID, len(recipients), schedule["send_at"], batchID, listID, scheduled.Status)
+	return nil
+}
+
+// createContactList creates the marketing list a Single Send batch is sent to
+func (e *EmailSender) createContactList(ctx context.Context, name string) (string, error) {
+	var list struct {
+		ID string `json:"id"`
+	}
+	if err := e.marketingRequest(ctx, http.MethodPost, "/v3/marketing/lists", map[string]string{"name": name}, &list); err != nil {
+		return "", fmt.Errorf("create list: %w", err)
+	}
+	return list.ID, nil
+}
+
+// deleteContactList deletes a Single Send contact list, logging a failure rather than
+// failing the send, as the list is only left over
+func (e *EmailSender) deleteContactList(ctx context.Context, listID string) {
+	if err := e.marketingRequest(ctx, http.MethodDelete, "/v3/marketing/lists/"+listID, nil, nil); err != nil {
+		log.Warnf("Failed to delete Single Send contact list %s: %v", listID, err)
+	}
+}
+
+// pruneContactLists deletes the contact lists of earlier Single Sends, recognized by
+// singleSendListPrefix and their creation time in the name, once they are older than
+// singleSendListRetention, so lists don't pile up against the account's list limit
+func (e *EmailSender) pruneContactLists(ctx context.Context) {
+	var lists struct {
+		Result []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"result"`
+	}
+	if err := e.marketingRequest(ctx, http.MethodGet, "/v3/marketing/lists?page_size=1000", nil, &lists); err != nil {
+		log.Warnf("Failed to list Single Send contact lists for pruning: %v", err)
+		return
+	}
+	for _, list := range lists.Result {
+		suffix, ok := strings.CutPrefix(list.Name, singleSendListPrefix)
+		if !ok {
+			continue
+		}
+		created, _, _ := strings.Cut(suffix, "-")
+		unix, err := strconv.ParseInt(created, 10, 64)
+		if err != nil || e.now().Sub(time.Unix(unix, 0)) < singleSendListRetention {
+			continue
+		}
+		e.deleteContactList(ctx, list.ID)
+	}
+}
+
+// importContacts adds recipients to the list and waits for SendGrid's asynchronous
+// import job to finish, since a Single Send only reaches contacts already in its list.
+// Polling stops early when ctx is done.
+func (e *EmailSender) importContacts(ctx context.Context, listID string, recipients []string) error {
+	type contact struct {
+		Email string `json:"email"`
+	}
+	body := struct {
+		ListIDs  []string  `json:"list_ids"`
+		Contacts []contact `json:"contacts"`
+	}{ListIDs: []string{listID}}
+	for _, recipient := range recipients {
+		body.Contacts = append(body.Contacts, contact{recipient})
+	}
+
+	var job struct {
+		JobID string `json:"job_id"`
+	}
+	if err := e.marketingRequest(ctx, http.MethodPut, "/v3/marketing/contacts", body, &job); err != nil {
+		return fmt.Errorf("import contacts: %w", err)
+	}
+
+	deadline := e.now().Add(e.config.SingleSendImportTimeout)
+	for {
+		var status struct {
+			Status  string `json:"status"`
+			Results struct {
+				ErroredCount int `json:"errored_count"`
+			} `json:"results"`
+		}
+		if err := e.marketingRequest(ctx, http.MethodGet, "/v3/marketing/contacts/imports/"+job.JobID, nil, &status); err != nil {
+			return fmt.Errorf("contact import %s: %w", job.JobID, err)
+		}
+
+		switch status.Status {
+		case "completed":
+			if status.Results.ErroredCount > 0 {
+				log.Warnf("Contact import %s skipped %d of %d recipients", job.JobID, status.Results.ErroredCount, len(recipients))
+			}
+			return nil
+		case "failed", "errored":
+			return fmt.Errorf("contact import %s %s", job.JobID, status.Status)
+		}
+
+		if e.now().After(deadline) {
+			return fmt.Errorf("contact import %s still %s after %s", job.JobID, status.Status, e.config.SingleSendImportTimeout)
+		}
+		select {
+		case <-time.After(singleSendPollInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("contact import %s: %w", job.JobID, ctx.Err())
+		}
+	}
+}
+
+// marketingRequest sends a JSON request to a Marketing Campaigns endpoint and decodes
+// the response into out. Non-2xx responses become errors carrying the (sampled) body.
+// The request is abandoned when ctx is done.
+func (e *EmailSender) marketingRequest(ctx context.Context, method, path string, body, out any) error {
+	request := sendgrid.GetRequest(e.config.SendGridAPIKey, path, e.marketingHost)
+	request.Method = rest.Method(method)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		request.Body = data
+	}
+
+	response, err := e.httpClient.SendWithContext(ctx, request)
+	if err != nil {
+		return err
+	}
+	e.recordRateLimit(response.Headers)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return e.newStatusError(response.StatusCode, response.Body, method+" "+path)
+	}
+
+	if out != nil && response.Body != "" {
+		if err := json.Unmarshal([]byte(response.Body), out); err != nil {
+			return fmt.Errorf("decode %s %s response: %w", method, path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestSendEmailsWithAnalysisUsesSingleSend(t *testing.T) {
+	singleSendPollInterval = time.Millisecond
+	now := time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	var calls []string
+	var created singleSendRequest
+	imports := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v3/marketing/lists":
+			old := fmt.Sprintf("cleanapp-%d-batch-0", now.Add(-singleSendListRetention).Unix())
+			recent := fmt.Sprintf("cleanapp-%d-batch-0", now.Add(-time.Hour).Unix())
+			fmt.Fprintf(w, `{"result":[{"id":"old","name":%q},{"id":"recent","name":%q},{"id":"other","name":"newsletter"}]}`, old, recent)
+		case "DELETE /v3/marketing/lists/old":
+			w.WriteHeader(http.StatusNoContent)
+		case "POST /v3/marketing/lists":
+			w.Write([]byte(`{"id":"list-1"}`))
+		case "PUT /v3/marketing/contacts":
+			if !strings.Contains(string(body), `"list_ids":["list-1"]`) {
+				t.Errorf("contacts not imported into the batch list: %s", body)
+			}
+			if !strings.Contains(string(body), `"contacts":[{"email":"a@example.com"},{"email":"b@example.com"}]`) {
+				t.Errorf("expected only the screened recipients imported, got %s", body)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"job_id":"job-1"}`))
+		case "GET /v3/marketing/contacts/imports/job-1":
+			imports++
+			if imports == 1 {
+				w.Write([]byte(`{"status":"pending"}`))
+				return
+			}
+			w.Write([]byte(`{"status":"completed","results":{"errored_count":0}}`))
+		case "POST /v3/marketing/singlesends":
+			if err := json.Unmarshal(body, &created); err != nil {
+				t.Errorf("failed to decode single send: %v", err)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"ss-1"}`))
+		case "PUT /v3/marketing/singlesends/ss-1/schedule":
+			w.Write([]byte(`{"id":"ss-1","status":"scheduled"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewEmailSender(&config.Config{
+		SendGridFromEmail:       "info@cleanapp.io",
+		OptOutURL:               "https://cleanapp.io/opt-out",
+		SingleSendEnabled:       true,
+		SingleSendMinBatch:      2,
+		SingleSendSenderID:      42,
+		SingleSendImportTimeout: time.Second,
+	}, WithIDGenerator(SequentialIDs()), WithClock(func() time.Time { return now }))
+	e.marketingHost = srv.URL
+	e.Suppressions().Add("gone@example.com", "bounce")
+
+	analysis := &models.ReportAnalysis{Seq: 7, BrandName: "acme", Title: "Broken link", Classification: "digital", SeverityLevel: 4}
+	recipients := []string{"a@example.com", "not an address", "B@example.com", "a@example.com", "info@cleanapp.io", "gone@example.com"}
+	var result SendResult
+	err := e.SendEmailsWithAnalysis(recipients, []byte("img"), nil, analysis, WithResult(&result))
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Invalid != 1 || batchErr.Failed != 0 {
+		t.Fatalf("expected only the invalid address reported, got %v", err)
+	}
+
+	if len(calls) != 8 {
+		t.Errorf("expected list, prune, create list, import, 2 polls, create and schedule calls, got %v", calls)
+	}
+	if !slices.Equal(result.Succeeded, []string{"a@example.com", "b@example.com"}) || len(result.Skipped) != 3 {
+		t.Errorf("unexpected result: succeeded %v, skipped %v", result.Succeeded, result.Skipped)
+	}
+	if created.EmailConfig.SenderID != 42 || created.EmailConfig.CustomUnsubscribeURL != "https://cleanapp.io/opt-out" {
+		t.Errorf("unexpected single send email config %+v", created.EmailConfig)
+	}
+	if !strings.Contains(created.EmailConfig.HTMLContent, "email={{email}}") || strings.Contains(created.EmailConfig.HTMLContent, "cid:") {
+		t.Error("expected a personalized body that links media instead of referencing attachments")
+	}
+}
+
+func TestUseSingleSendKeepsUrgentAndSmallBatchesTransactional(t *testing.T) {
+	e := &EmailSender{config: &config.Config{SingleSendEnabled: true, SingleSendMinBatch: 2}}
+	recipients := []string{"a@example.com", "b@example.com"}
+
+	if !e.useSingleSend(recipients, &models.ReportAnalysis{SeverityLevel: 5}) {
+		t.Error("expected a large non-urgent batch to use Single Sends")
+	}
+	if e.useSingleSend(recipients, &models.ReportAnalysis{SeverityLevel: 8}) {
+		t.Error("expected urgent reports to stay transactional")
+	}
+	if e.useSingleSend(recipients[:1], &models.ReportAnalysis{SeverityLevel: 5}) {
+		t.Error("expected small batches to stay transactional")
+	}
+	e.config.RedirectAllTo = "qa@example.com"
+	if e.useSingleSend(recipients, &models.ReportAnalysis{SeverityLevel: 5}) {
+		t.Error("expected redirected batches to stay transactional, where they are rewritten")
+	}
+}
+
+func TestSingleSendDeletesListWhenSchedulingFails(t *testing.T) {
+	singleSendPollInterval = time.Millisecond
+
+	var mu sync.Mutex
+	deleted := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v3/marketing/lists":
+			w.Write([]byte(`{"result":[]}`))
+		case "POST /v3/marketing/lists":
+			w.Write([]byte(`{"id":"list-1"}`))
+		case "PUT /v3/marketing/contacts":
+			w.Write([]byte(`{"job_id":"job-1"}`))
+		case "GET /v3/marketing/contacts/imports/job-1":
+			w.Write([]byte(`{"status":"completed"}`))
+		case "POST /v3/marketing/singlesends":
+			w.Write([]byte(`{"id":"ss-1"}`))
+		case "PUT /v3/marketing/singlesends/ss-1/schedule":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"invalid"}]}`))
+		case "DELETE /v3/marketing/lists/list-1":
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewEmailSender(&config.Config{
+		SendGridFromEmail:       "info@cleanapp.io",
+		SingleSendEnabled:       true,
+		SingleSendMinBatch:      2,
+		SingleSendImportTimeout: time.Second,
+		MaxEmailsPerRecipient:   1,
+		FrequencyWindow:         time.Hour,
+	})
+	e.marketingHost = srv.URL
+
+	analysis := &models.ReportAnalysis{Seq: 7, Classification: "digital", SeverityLevel: 4}
+	var result SendResult
+	err := e.SendEmailsWithAnalysis([]string{"a@example.com", "b@example.com"}, nil, nil, analysis, WithResult(&result))
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Failed != 2 {
+		t.Fatalf("expected both recipients failed, got %v", err)
+	}
+	if len(result.Failed) != 2 {
+		t.Errorf("expected both recipients in the failed result, got %v", result.Failed)
+	}
+	if !deleted {
+		t.Error("expected the unused contact list to be deleted")
+	}
+	if !e.allowFrequency(nil, "a@example.com") {
+		t.Error("expected the failed Single Send not to count towards the frequency cap")
+	}
+}
+
+func TestSingleSendStopsPollingWhenContextDone(t *testing.T) {
+	singleSendPollInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	polls, deleted := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v3/marketing/lists":
+			w.Write([]byte(`{"result":[]}`))
+		case "POST /v3/marketing/lists":
+			w.Write([]byte(`{"id":"list-1"}`))
+		case "PUT /v3/marketing/contacts":
+			w.Write([]byte(`{"job_id":"job-1"}`))
+		case "GET /v3/marketing/contacts/imports/job-1":
+			polls++
+			if polls == 2 {
+				cancel()
+			}
+			w.Write([]byte(`{"status":"pending"}`))
+		case "DELETE /v3/marketing/lists/list-1":
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewEmailSender(&config.Config{
+		SendGridFromEmail:       "info@cleanapp.io",
+		SingleSendEnabled:       true,
+		SingleSendMinBatch:      2,
+		SingleSendImportTimeout: time.Minute,
+	})
+	e.marketingHost = srv.URL
+
+	analysis := &models.ReportAnalysis{Seq: 7, Classification: "digital", SeverityLevel: 4}
+	err := e.SendEmailsWithAnalysisContext(ctx, []string{"a@example.com", "b@example.com"}, nil, nil, analysis)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the Single Send to stop with context.Canceled, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if polls != 2 {
+		t.Errorf("expected polling to stop after the cancelling poll, got %d polls", polls)
+	}
+	if !deleted {
+		t.Error("expected the contact list to be deleted after cancellation")
+	}
+}
@@ -0,0 +1,286 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"email-service/config"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// smtpTransport delivers messages over SMTP, the fallback when SendGrid can't be reached
+// or still fails with a 5xx after its retries. Each personalization is sent as its own
+// MIME message with its substitutions applied, and a failure partway through a batched
+// message stops there with a partialSendError counting the recipients already sent.
+// Every session is abandoned once the send's ctx is done. SendGrid-only settings such as categories,
+// custom args, IP pools and suppression bypasses are dropped; messages scheduled with
+// send_at are never handed to it, since SMTP can't hold them.
+type smtpTransport struct {
+	addr     string
+	auth     smtp.Auth // nil without SmtpUser
+	now      func() time.Time
+	sendMail func(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error // sendMailContext, replaced in tests
+}
+
+// partialSendError is the error of a message whose personalizations were only partly
+// sent: the first delivered of them went out before err stopped the rest
+type partialSendError struct {
+	delivered int
+	err       error
+}
+
+func (e *partialSendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialSendError) Unwrap() error {
+	return e.err
+}
+
+// newSMTPTransport returns the SMTP fallback transport configured in cfg, or nil when
+// SmtpHost is unset
+func newSMTPTransport(cfg *config.Config, now func() time.Time) *smtpTransport {
+	if cfg.SmtpHost == "" {
+		return nil
+	}
+	t := &smtpTransport{addr: net.JoinHostPort(cfg.SmtpHost, strconv.Itoa(cfg.SmtpPort)), now: now, sendMail: sendMailContext}
+	if cfg.SmtpUser != "" {
+		t.auth = smtp.PlainAuth("", cfg.SmtpUser, cfg.SmtpPassword, cfg.SmtpHost)
+	}
+	return t
+}
+
+func (t *smtpTransport) Send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	for i, p := range message.Personalizations {
+		if err := ctx.Err(); err != nil {
+			return nil, &partialSendError{delivered: i, err: err}
+		}
+		from := message.From
+		if p.From != nil {
+			from = p.From
+		}
+		var to []string
+		for _, address := range slices.Concat(p.To, p.CC, p.BCC) {
+			to = append(to, address.Address)
+		}
+		if err := t.sendMail(ctx, t.addr, t.auth, from.Address, to, t.buildMIME(message, p, from)); err != nil {
+			return nil, &partialSendError{delivered: i, err: fmt.Errorf("smtp %s: %w", t.addr, err)}
+		}
+	}
+	return &rest.Response{StatusCode: http.StatusAccepted}, nil
+}
+
+// sendMailContext is smtp.SendMail with a dial and session bound to ctx: the
+// connection is closed once ctx is done, so a server that stops answering fails the
+// send instead of blocking it
+func sendMailContext(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) (err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		if !stop() && err != nil {
+			err = fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+	}()
+
+	host, _, _ := net.SplitHostPort(addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := c.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMIME renders the message for personalization p: the text and HTML bodies as
+// multipart/alternative, wrapped in multipart/related with the inline images and in
+// multipart/mixed with any regular attachments. BCC addresses stay on the envelope only.
+func (t *smtpTransport) buildMIME(message *mail.SGMailV3, p *mail.Personalization, from *mail.Email) []byte {
+	var pairs []string
+	for tag, value := range p.Substitutions {
+		pairs = append(pairs, tag, value)
+	}
+	substitute := strings.NewReplacer(pairs...)
+
+	var buf bytes.Buffer
+	writeHeader := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	writeHeader("From", formatAddresses(from))
+	writeHeader("To", formatAddresses(p.To...))
+	if len(p.CC) > 0 {
+		writeHeader("Cc", formatAddresses(p.CC...))
+	}
+	if message.ReplyTo != nil {
+		writeHeader("Reply-To", formatAddresses(message.ReplyTo))
+	}
+	subject := message.Subject
+	if p.Subject != "" {
+		subject = p.Subject
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", substitute.Replace(subject)))
+	writeHeader("Date", t.now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+
+	headers := maps.Clone(message.Headers)
+	if headers == nil {
+		headers = make(map[string]string, len(p.Headers))
+	}
+	maps.Copy(headers, p.Headers)
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		writeHeader(name, mime.QEncoding.Encode("utf-8", substitute.Replace(headers[name])))
+	}
+
+	var alternatives, inline, attached []mimePart
+	for _, content := range message.Content {
+		alternatives = append(alternatives, textPart(content.Type, substitute.Replace(content.Value)))
+	}
+	for _, attachment := range message.Attachments {
+		if attachment.Disposition == "inline" && attachment.ContentID != "" {
+			inline = append(inline, attachmentPart(attachment))
+		} else {
+			attached = append(attached, attachmentPart(attachment))
+		}
+	}
+
+	body := multipartOf("alternative", alternatives)
+	if len(alternatives) == 1 {
+		body = alternatives[0]
+	}
+	if len(inline) > 0 {
+		body = multipartOf("related", append([]mimePart{body}, inline...))
+	}
+	if len(attached) > 0 {
+		body = multipartOf("mixed", append([]mimePart{body}, attached...))
+	}
+	for _, name := range slices.Sorted(maps.Keys(body.header)) {
+		writeHeader(name, body.header.Get(name))
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.body)
+	return buf.Bytes()
+}
+
+// mimePart is a MIME entity: its headers and encoded body
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// multipartOf combines parts into a multipart entity of subtype, e.g. "related"
+func multipartOf(subtype string, parts []mimePart) mimePart {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, part := range parts {
+		// Writes to a bytes.Buffer don't fail
+		pw, _ := w.CreatePart(part.header)
+		pw.Write(part.body)
+	}
+	w.Close()
+	return mimePart{
+		header: textproto.MIMEHeader{"Content-Type": {mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": w.Boundary()})}},
+		body:   body.Bytes(),
+	}
+}
+
+// textPart returns a quoted-printable UTF-8 body of contentType, e.g. "text/html"
+func textPart(contentType, value string) mimePart {
+	var body bytes.Buffer
+	qp := quotedprintable.NewWriter(&body)
+	qp.Write([]byte(value))
+	qp.Close()
+	return mimePart{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: body.Bytes(),
+	}
+}
+
+// attachmentPart returns an attachment's already base64-encoded content wrapped to the
+// 76-character lines MIME requires, referenced by its Content-ID when it has one
+func attachmentPart(attachment *mail.Attachment) mimePart {
+	disposition := attachment.Disposition
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	header := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(attachment.Type, map[string]string{"name": attachment.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename})},
+	}
+	if attachment.ContentID != "" {
+		header.Set("Content-ID", "<"+attachment.ContentID+">")
+	}
+
+	var body bytes.Buffer
+	for content := attachment.Content; content != ""; {
+		n := min(76, len(content))
+		body.WriteString(content[:n] + "\r\n")
+		content = content[n:]
+	}
+	return mimePart{header: header, body: body.Bytes()}
+}
+
+// formatAddresses formats addresses for an address list header, encoding non-ASCII names
+func formatAddresses(addresses ...*mail.Email) string {
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		formatted[i] = (&netmail.Address{Name: address.Name, Address: address.Address}).String()
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,225 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	netmail "net/mail"
+	"net/smtp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"email-service/config"
+)
+
+// smtpSend is one message handed to the SMTP fallback's sendMail
+type smtpSend struct {
+	addr, from string
+	to         []string
+	msg        []byte
+}
+
+// newFallbackSender returns a sender whose SendGrid sends go through transport and whose
+// SMTP fallback records its messages in sent instead of dialing a server
+func newFallbackSender(t *testing.T, transport Transport, sent *[]smtpSend) *EmailSender {
+	t.Helper()
+	e := NewEmailSenderWithTransport(&config.Config{
+		SendGridFromName:  "CleanApp",
+		SendGridFromEmail: "info@cleanapp.io",
+		SmtpHost:          "smtp.example.com",
+		SmtpPort:          587,
+	}, transport)
+	e.fallback.(*smtpTransport).sendMail = func(_ context.Context, addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		*sent = append(*sent, smtpSend{addr, from, to, msg})
+		return nil
+	}
+	return e
+}
+
+func TestSMTPFallbackOnSendGridError(t *testing.T) {
+	var sent []smtpSend
+	transport := &flakyTransport{failures: 1}
+	e := newFallbackSender(t, transport, &sent)
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+		encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), goldenAnalysis(), WithBCC("audit@cleanapp.io"))
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error despite the fallback: %v", err)
+	}
+	if transport.calls != 1 || len(sent) != 1 {
+		t.Fatalf("expected 1 failed SendGrid call and 1 SMTP send, got %d and %d", transport.calls, len(sent))
+	}
+	send := sent[0]
+	if send.addr != "smtp.example.com:587" || send.from != "info@cleanapp.io" ||
+		!slices.Equal(send.to, []string{"brand@example.com", "audit@cleanapp.io"}) {
+		t.Errorf("unexpected envelope %s from %s to %v", send.addr, send.from, send.to)
+	}
+
+	msg, err := netmail.ReadMessage(bytes.NewReader(send.msg))
+	if err != nil {
+		t.Fatalf("fallback message doesn't parse: %v", err)
+	}
+	if to, _ := msg.Header.AddressList("To"); len(to) != 1 || to[0].Address != "brand@example.com" || msg.Header.Get("Bcc") != "" {
+		t.Errorf("To = %q, Bcc = %q; want only the recipient in the headers", msg.Header.Get("To"), msg.Header.Get("Bcc"))
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != e.BuildSubject(goldenAnalysis()) {
+		t.Errorf("Subject = %q", subject)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/related" {
+		t.Fatalf("Content-Type = %s, want multipart/related", mediaType)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	alternative, err := parts.NextPart()
+	if err != nil || !strings.HasPrefix(alternative.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Fatalf("expected the bodies first, got %v (%v)", alternative.Header, err)
+	}
+	_, altParams, _ := mime.ParseMediaType(alternative.Header.Get("Content-Type"))
+	var html string
+	bodies := multipart.NewReader(alternative, altParams["boundary"])
+	for {
+		part, err := bodies.NextPart()
+		if err != nil {
+			break
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			data, _ := io.ReadAll(part)
+			html = string(data)
+		}
+	}
+
+	var images int
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		images++
+		cid := strings.Trim(part.Header.Get("Content-ID"), "<>")
+		if !strings.Contains(html, `src="cid:`+cid+`"`) {
+			t.Errorf("inline image %q isn't referenced by the HTML", cid)
+		}
+	}
+	if images != 2 {
+		t.Errorf("expected the report and map images, got %d parts", images)
+	}
+}
+
+func TestSMTPFallbackOn5xx(t *testing.T) {
+	var sent []smtpSend
+	e := newFallbackSender(t, &flakyTransport{failures: 1, status: http.StatusInternalServerError}, &sent)
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error despite the fallback: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("expected the 500 to fall back to SMTP, got %d sends", len(sent))
+	}
+}
+
+func TestNoSMTPFallbackOn4xx(t *testing.T) {
+	var sent []smtpSend
+	e := newFallbackSender(t, &flakyTransport{failures: 1, status: http.StatusBadRequest}, &sent)
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err == nil {
+		t.Fatal("expected the 400 to fail the send")
+	}
+	if len(sent) != 0 {
+		t.Errorf("expected no SMTP send for a rejected request, got %d", len(sent))
+	}
+}
+
+func TestNoSMTPFallbackWithoutHost(t *testing.T) {
+	if e := NewEmailSender(&config.Config{}); e.fallback != nil {
+		t.Errorf("expected no fallback without SmtpHost, got %+v", e.fallback)
+	}
+}
+
+func TestNoSMTPFallbackForScheduledSend(t *testing.T) {
+	var sent []smtpSend
+	e := newFallbackSender(t, &flakyTransport{failures: 1}, &sent)
+	e.config.QuietHoursStart, e.config.QuietHoursEnd = 22, 7
+	e.now = func() time.Time { return time.Date(2025, time.March, 5, 3, 0, 0, 0, time.UTC) }
+
+	var result SendResult
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis(), WithResult(&result)); err == nil {
+		t.Fatal("expected the scheduled send to fail rather than go out over SMTP")
+	}
+	if len(sent) != 0 {
+		t.Errorf("expected no SMTP send for a message held until the end of quiet hours, got %d", len(sent))
+	}
+	if len(result.Scheduled) != 0 || len(result.Succeeded) != 0 {
+		t.Errorf("expected nothing reported as scheduled or sent, got %v and %v", result.Scheduled, result.Succeeded)
+	}
+}
+
+func TestSMTPFallbackReportsPartlySentBatch(t *testing.T) {
+	var sent []smtpSend
+	e := newFallbackSender(t, &flakyTransport{failures: 1}, &sent)
+	e.fallback.(*smtpTransport).sendMail = func(_ context.Context, addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		if len(sent) == 1 {
+			return errors.New("421 service not available")
+		}
+		sent = append(sent, smtpSend{addr, from, to, msg})
+		return nil
+	}
+
+	var result SendResult
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+	if err := e.SendBatch(context.Background(), recipients, nil, nil, goldenAnalysis(), WithResult(&result)); err == nil {
+		t.Fatal("expected the recipients the fallback didn't reach to fail the batch")
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 SMTP send before the failure, got %d", len(sent))
+	}
+	if !slices.Equal(result.Succeeded, []string{"a@example.com"}) || result.Accounts["a@example.com"] != smtpFallbackAccount {
+		t.Errorf("Succeeded = %v, Accounts = %v; want a@example.com sent over SMTP", result.Succeeded, result.Accounts)
+	}
+	for _, recipient := range recipients[1:] {
+		if result.Failed[recipient] == nil {
+			t.Errorf("expected %s failed, got %v", recipient, result.Failed)
+		}
+	}
+	if result.Failed["a@example.com"] != nil {
+		t.Errorf("a@example.com was sent but is reported failed: %v", result.Failed["a@example.com"])
+	}
+}
+
+func TestSendMailContextAbandonsHungServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accept and never send the greeting
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- sendMailContext(ctx, listener.Addr().String(), nil, "info@cleanapp.io", []string{"brand@example.com"}, []byte("Subject: hi\r\n\r\nhi\r\n"))
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("sendMailContext() = %v, want the deadline exceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sendMailContext kept waiting on a server that never answers")
+	}
+}
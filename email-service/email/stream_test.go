@@ -0,0 +1,182 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestSendEmailsWithAnalysisStream(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	recipients := make(chan Recipient)
+	go func() {
+		defer close(recipients)
+		for _, email := range []string{"a@example.com", "not-an-address", "b@example.com"} {
+			recipients <- Recipient{Email: email}
+		}
+	}()
+
+	err := e.SendEmailsWithAnalysisStream(context.Background(), recipients, nil, nil, goldenAnalysis())
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError for the invalid address, got %v", err)
+	}
+	if batchErr.Total != 3 || batchErr.Sent != 2 || batchErr.Invalid != 1 {
+		t.Errorf("expected 2/3 sent with 1 invalid, got %+v", batchErr)
+	}
+	if len(sent) != 2 {
+		t.Errorf("expected 2 sends, got %d", len(sent))
+	}
+}
+
+func TestSendEmailsWithAnalysisStreamCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var sends int
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		sends++
+		cancel()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	// The producer never closes the channel, so only cancellation ends the stream
+	recipients := make(chan Recipient)
+	go func() {
+		for {
+			select {
+			case recipients <- Recipient{Email: "brand@example.com"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err := e.SendEmailsWithAnalysisStream(ctx, recipients, nil, nil, goldenAnalysis())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	if sends != 1 {
+		t.Errorf("expected the in-flight send to finish and no more, got %d sends", sends)
+	}
+}
+
+func TestStreamSkipsRepeatedRecipients(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	var records []AuditRecord
+	recipients := make(chan Recipient)
+	go func() {
+		defer close(recipients)
+		// The same contact arriving from two upstream sources
+		for _, email := range []string{"a@example.com", "b@example.com", "A@Example.com"} {
+			recipients <- Recipient{Email: email}
+		}
+	}()
+
+	err := e.SendEmailsWithAnalysisStream(context.Background(), recipients, nil, nil, goldenAnalysis(),
+		WithAudit(func(r AuditRecord) { records = append(records, r) }))
+	if err != nil {
+		t.Fatalf("expected duplicates alone not to fail the batch, got %v", err)
+	}
+	if len(sent) != 2 || len(records) != 2 {
+		t.Errorf("expected 2 sends, got %d (%d audited)", len(sent), len(records))
+	}
+}
+
+func TestBatchRecordsDuplicatesAsSkipped(t *testing.T) {
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	var sends int
+	err := e.runBatch(&batch{id: "batch-dup"}, "email", "emails", []string{"a@example.com", "a@example.com"}, func(recipient string) error {
+		sends++
+		return e.sendOneEmail(nil, Recipient{Email: recipient}, nil, nil)
+	})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if sends != 1 || batchErr.Total != 2 || batchErr.Failed != 1 || batchErr.Skipped != 1 {
+		t.Errorf("expected 1 failed send and 1 skipped duplicate, got %d sends and %+v", sends, batchErr)
+	}
+	if !errors.Is(errDuplicate, errSkipped) {
+		t.Error("expected duplicates to count as skipped")
+	}
+}
+
+func TestStreamStopsAtMaxBatchSize(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{MaxBatchSize: 2}, captureSends(t, &sent))
+
+	recipients := make(chan Recipient, 3)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		recipients <- Recipient{Email: email}
+	}
+	close(recipients)
+
+	err := e.SendEmailsWithAnalysisStream(context.Background(), recipients, nil, nil, goldenAnalysis())
+	if !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("expected ErrBatchTooLarge, got %v", err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("expected the first 2 recipients sent before the cap, got %d", len(sent))
+	}
+}
+
+func TestSendEmailsContextReportsSentOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var sends int
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		sends++
+		w.WriteHeader(http.StatusAccepted)
+		if sends == 2 {
+			cancel()
+		}
+	})
+
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	err := e.SendEmailsContext(ctx, recipients, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) {
+		t.Fatalf("expected a CancelledError, got %v", err)
+	}
+	if sends != 2 {
+		t.Errorf("expected no sends after cancellation, got %d", sends)
+	}
+	if cancelled.Sent > 2 || cancelled.Total > 2 {
+		t.Errorf("expected at most the 2 sends before cancellation counted, got %d/%d", cancelled.Sent, cancelled.Total)
+	}
+}
+
+func TestSendEmailsContextAlreadyDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var sends int
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		sends++
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	err := e.SendEmailsWithAnalysisContext(ctx, []string{"a@example.com"}, nil, nil, goldenAnalysis())
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) || cancelled.Sent != 0 {
+		t.Fatalf("expected a CancelledError with nothing sent, got %v", err)
+	}
+	if sends != 0 {
+		t.Errorf("expected no sends with a done context, got %d", sends)
+	}
+}
@@ -0,0 +1,102 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"email-service/models"
+)
+
+// subAnalyses returns the issues of a multi-issue physical report to render as cards,
+// at most SubAnalysisMaxCards of them, and how many more were left out. It returns no
+// cards for digital reports, reports without sub-analyses, or when cards are disabled,
+// which keeps the single-analysis layout.
+func (e *EmailSender) subAnalyses(analysis *models.ReportAnalysis) (cards []models.SubAnalysis, more int) {
+	if e.config.SubAnalysisMaxCards <= 0 || analysis.Classification == "digital" || len(analysis.SubAnalyses) == 0 {
+		return nil, 0
+	}
+	n := min(len(analysis.SubAnalyses), e.config.SubAnalysisMaxCards)
+	return analysis.SubAnalyses[:n], len(analysis.SubAnalyses) - n
+}
+
+// subAnalysisReport returns a copy of the report carrying the issue's own metrics, so
+// the single-analysis gauge and table renderers can draw the issue's card
+func subAnalysisReport(analysis *models.ReportAnalysis, sub models.SubAnalysis) *models.ReportAnalysis {
+	a := *analysis
+	a.Title = sub.Title
+	a.Description = sub.Description
+	a.LitterProbability = sub.LitterProbability
+	a.HazardProbability = sub.HazardProbability
+	a.SeverityLevel = sub.SeverityLevel
+	a.SubAnalyses = nil
+	return &a
+}
+
+// getSubAnalysisSubject returns the subject suffix counting a report's issues, e.g.
+// " (3 issues)", or "" unless the report is rendered with more than one card
+func (e *EmailSender) getSubAnalysisSubject(analysis *models.ReportAnalysis) string {
+	if cards, more := e.subAnalyses(analysis); len(cards)+more > 1 {
+		return fmt.Sprintf(" (%d issues)", len(cards)+more)
+	}
+	return ""
+}
+
+// getSubAnalysisCardsHtml renders a card with its own gauge per issue, or "" to use
+// the single-analysis gauge
+func (e *EmailSender) getSubAnalysisCardsHtml(analysis *models.ReportAnalysis, m messages) string {
+	cards, more := e.subAnalyses(analysis)
+	if len(cards) == 0 {
+		return ""
+	}
+
+	total := len(cards) + more
+	section := ""
+	for i, sub := range cards {
+		issue := subAnalysisReport(analysis, sub)
+		section += fmt.Sprintf(`
+    <div class="issue-card" style="margin: 20px 0; padding: 15px; border: 1px solid #e0e0e0; border-radius: 8px;">
+        <h3 style="margin: 0 0 5px 0;">Issue %d of %d: %s</h3>
+        %s%s
+    </div>`, i+1, total, html.EscapeString(sub.Title), e.getSubAnalysisDescriptionHtml(sub.Description),
+			e.getGaugeSection(issue, m, e.getGaugeColor(sub.HazardProbability)))
+	}
+	if more > 0 {
+		section += fmt.Sprintf(`
+    <p style="color: #666;">And %d more %s in this report.</p>`, more, pluralIssues(more))
+	}
+	return section
+}
+
+// getSubAnalysisText lists each issue with its metrics for the text body, or "" for
+// single-issue reports
+func (e *EmailSender) getSubAnalysisText(analysis *models.ReportAnalysis, m messages) string {
+	cards, more := e.subAnalyses(analysis)
+	if len(cards) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\nISSUES:\n")
+	for i, sub := range cards {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, sub.Title)
+		if sub.Description != "" {
+			fmt.Fprintf(&b, "   %s\n", sub.Description)
+		}
+		for _, line := range strings.Split(e.getMetricsText(subAnalysisReport(analysis, sub), m), "\n") {
+			fmt.Fprintf(&b, "   %s\n", line)
+		}
+	}
+	if more > 0 {
+		fmt.Fprintf(&b, "And %d more %s in this report.\n", more, pluralIssues(more))
+	}
+	return b.String()
+}
+
+// pluralIssues returns "issue" or "issues" for n
+func pluralIssues(n int) string {
+	if n == 1 {
+		return "issue"
+	}
+	return "issues"
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func multiIssueAnalysis() *models.ReportAnalysis {
+	analysis := goldenAnalysis()
+	analysis.SubAnalyses = []models.SubAnalysis{
+		{Title: "Overflowing bin", Description: "Bin lid open", LitterProbability: 0.9, HazardProbability: 0.21, SeverityLevel: 3},
+		{Title: "Broken glass", Description: "Glass on the walkway", LitterProbability: 0.4, HazardProbability: 0.87, SeverityLevel: 8},
+		{Title: "Graffiti", HazardProbability: 0.05, SeverityLevel: 1},
+	}
+	return analysis
+}
+
+func TestSubAnalysisCards(t *testing.T) {
+	e := &EmailSender{config: &config.Config{SubAnalysisMaxCards: 2}}
+	analysis := multiIssueAnalysis()
+
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if n := strings.Count(html, `class="issue-card"`); n != 2 {
+		t.Fatalf("expected 2 issue cards, got %d", n)
+	}
+	for _, want := range []string{"Issue 1 of 3: Overflowing bin", "Issue 2 of 3: Broken glass", "21.0%", "87.0%", "And 1 more issue in this report."} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+	if strings.Contains(html, "Graffiti") {
+		t.Error("expected issues past the card limit to be left out")
+	}
+
+	text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if !strings.Contains(text, "ISSUES:\n1. Overflowing bin\n   Bin lid open\n   - Litter probability: 90.0% (High)") {
+		t.Errorf("expected the text body to list each issue, got:\n%s", text)
+	}
+
+	if subject := e.BuildSubject(analysis); !strings.HasSuffix(subject, " (3 issues)") {
+		t.Errorf("BuildSubject() = %q, want the issue count", subject)
+	}
+}
+
+func TestSubAnalysisFallsBackToSingleLayout(t *testing.T) {
+	single := &EmailSender{config: &config.Config{}}
+	want := single.getEmailHtmlWithAnalysis("brand@example.com", goldenAnalysis(), false, false, analysisRender{})
+
+	// Disabled cards and digital reports both keep the single-analysis layout
+	disabled := &EmailSender{config: &config.Config{}}
+	if got := disabled.getEmailHtmlWithAnalysis("brand@example.com", multiIssueAnalysis(), false, false, analysisRender{}); got != want {
+		t.Error("expected the single-analysis layout with cards disabled")
+	}
+	digital := multiIssueAnalysis()
+	digital.Classification = "digital"
+	e := &EmailSender{config: &config.Config{SubAnalysisMaxCards: 5}}
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", digital, false, false, analysisRender{})
+	if strings.Contains(html, "issue-card") || strings.Contains(e.BuildSubject(digital), "issues)") {
+		t.Error("expected digital reports to keep the single-analysis layout")
+	}
+}
@@ -0,0 +1,115 @@
+package email
+
+import (
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestBuildSubjectEmptyTitle(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		EmptyTitleFallbackDigital:  "Digital experience issue",
+		EmptyTitleFallbackPhysical: "Reported issue",
+	}}
+
+	testCases := []struct {
+		description string
+		analysis    models.ReportAnalysis
+		expected    string
+	}{
+		{
+			"empty digital title",
+			models.ReportAnalysis{BrandDisplayName: "Acme", BrandReportCount: 3, Classification: "digital"},
+			"Acme issue #3: Digital experience issue",
+		},
+		{
+			"empty physical title",
+			models.ReportAnalysis{BrandName: "acme", BrandReportCount: 1, Classification: "physical", Title: "   "},
+			"acme issue #1: Reported issue",
+		},
+		{
+			"title present",
+			models.ReportAnalysis{BrandDisplayName: "Acme", BrandReportCount: 2, Classification: "digital", Title: "Checkout broken"},
+			"Acme issue #2: Checkout broken",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := e.BuildSubject(&tc.analysis); got != tc.expected {
+				t.Errorf("BuildSubject() = %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBuildSubjectEmoji(t *testing.T) {
+	cfg := &config.Config{
+		SubjectEmojiEnabled: true,
+		SubjectEmoji:        map[string]string{"hazard": "⚠️", "litter": "🗑️"},
+	}
+	e := &EmailSender{config: cfg}
+
+	hazard := &models.ReportAnalysis{BrandName: "acme", BrandReportCount: 1, Title: "Broken glass", HazardProbability: 0.8, LitterProbability: 0.2}
+	if got, want := e.BuildSubject(hazard), "⚠️ acme issue #1: Broken glass"; got != want {
+		t.Errorf("BuildSubject() = %q, want %q", got, want)
+	}
+
+	litter := &models.ReportAnalysis{BrandName: "acme", BrandReportCount: 1, Title: "Bottles", HazardProbability: 0.1, LitterProbability: 0.9}
+	if got, want := e.BuildSubject(litter), "🗑️ acme issue #1: Bottles"; got != want {
+		t.Errorf("BuildSubject() = %q, want %q", got, want)
+	}
+
+	cfg.SubjectEmojiEnabled = false
+	if got, want := e.BuildSubject(hazard), "acme issue #1: Broken glass"; got != want {
+		t.Errorf("BuildSubject() with emoji disabled = %q, want %q", got, want)
+	}
+}
+
+func TestSubjectVariantsTagCategory(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		SubjectVariants: []string{"{subject}", "Action needed: {title} at {brand}"},
+	}, captureSends(t, &sent))
+
+	analysis := &models.ReportAnalysis{BrandName: "acme", BrandReportCount: 2, Title: "Broken glass"}
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	if err := e.SendEmailsWithAnalysis(recipients, nil, nil, analysis); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+
+	subjects := map[string]string{
+		"subject-variant-a": "acme issue #2: Broken glass",
+		"subject-variant-b": "Action needed: Broken glass at acme",
+	}
+	seen := make(map[string]bool)
+	for i, m := range sent {
+		if len(m.Categories) != 1 {
+			t.Fatalf("expected one variant category, got %v", m.Categories)
+		}
+		category := m.Categories[0]
+		if want := subjects[category]; m.Subject != want {
+			t.Errorf("%s got subject %q, want %q", category, m.Subject, want)
+		}
+
+		// Hash assignment is stable per recipient
+		if _, again := e.subjectVariant(recipients[i], "", analysis); again != category {
+			t.Errorf("variant for %s changed from %s to %s", recipients[i], category, again)
+		}
+		seen[category] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected both variants across recipients, got %v", seen)
+	}
+}
+
+func TestSingleSubjectHasNoVariantCategory(t *testing.T) {
+	e := &EmailSender{config: &config.Config{SubjectVariants: []string{"Only {title}"}}}
+	analysis := &models.ReportAnalysis{BrandName: "acme", BrandReportCount: 1, Title: "Broken glass"}
+
+	subject, category := e.subjectVariant("a@example.com", "", analysis)
+	if subject != e.BuildSubject(analysis) || category != "" {
+		t.Errorf("subjectVariant() = %q, %q; want the standard subject and no category", subject, category)
+	}
+}
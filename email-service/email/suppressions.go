@@ -0,0 +1,80 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrSuppressed is the SendResult.Skipped reason of a recipient whose address is on the
+// suppression list
+var ErrSuppressed = fmt.Errorf("%w: address is suppressed", errSkipped)
+
+// Suppressions is the list of addresses never to email again, e.g. after an unsubscribe,
+// a permanent bounce or a spam report. Every batch checks its recipients against it
+// before sending. Implementations must be safe for concurrent use.
+type Suppressions interface {
+	IsSuppressed(email string) bool
+	Add(email, reason string)
+}
+
+// MemorySuppressions is the in-memory Suppressions an EmailSender uses by default. It is
+// empty at start and forgets its addresses on restart, so deployments that persist
+// suppressions inject their own store with WithSuppressions.
+type MemorySuppressions struct {
+	mu      sync.RWMutex
+	reasons map[string]string // Reason per normalized address
+}
+
+// NewMemorySuppressions returns an empty in-memory suppression list
+func NewMemorySuppressions() *MemorySuppressions {
+	return &MemorySuppressions{reasons: make(map[string]string)}
+}
+
+// IsSuppressed reports whether email was added, matched case insensitively
+func (s *MemorySuppressions) IsSuppressed(email string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.reasons[normalizeRecipient(email)]
+	return ok
+}
+
+// Add suppresses email, keeping the reason it was first added for
+func (s *MemorySuppressions) Add(email, reason string) {
+	email = normalizeRecipient(email)
+	if email == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.reasons[email]; !ok {
+		s.reasons[email] = strings.TrimSpace(reason)
+	}
+}
+
+// Reason returns why email was suppressed, and whether it is
+func (s *MemorySuppressions) Reason(email string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reason, ok := s.reasons[normalizeRecipient(email)]
+	return reason, ok
+}
+
+// WithSuppressions checks recipients against suppressions, e.g. a store backed by the
+// opted-out table, instead of the in-memory default
+func WithSuppressions(suppressions Suppressions) Option {
+	return func(e *EmailSender) {
+		e.suppressions = suppressions
+	}
+}
+
+// Suppressions returns the sender's suppression list, e.g. for adding the addresses
+// SendGrid's event webhook reports as bounced
+func (e *EmailSender) Suppressions() Suppressions {
+	return e.suppressions
+}
+
+// isSuppressed reports whether recipient is on the sender's suppression list
+func (e *EmailSender) isSuppressed(recipient string) bool {
+	return e.suppressions != nil && e.suppressions.IsSuppressed(recipient)
+}
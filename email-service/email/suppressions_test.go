@@ -0,0 +1,165 @@
+package email
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/config"
+)
+
+func TestSuppressedAddressNeverSent(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{}, transport)
+	e.Suppressions().Add("Bounced@Example.com", "bounce")
+
+	var result SendResult
+	err := e.SendEmailsWithAnalysis([]string{"ok@example.com", "bounced@example.com"}, nil, nil, goldenAnalysis(), WithResult(&result))
+	if err != nil {
+		t.Fatalf("expected a suppressed recipient not to fail the batch, got %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+	for _, p := range transport.messages[0].Personalizations {
+		for _, to := range p.To {
+			if to.Address == "bounced@example.com" {
+				t.Error("suppressed address was passed to the transport")
+			}
+		}
+	}
+	if !errors.Is(result.Skipped["bounced@example.com"], ErrSuppressed) {
+		t.Errorf("expected the suppressed address recorded as skipped, got %v", result.Skipped)
+	}
+	if len(result.Succeeded) != 1 || result.Succeeded[0] != "ok@example.com" {
+		t.Errorf("Succeeded = %v, want [ok@example.com]", result.Succeeded)
+	}
+}
+
+func TestSendBatchSkipsSuppressedAddress(t *testing.T) {
+	transport := &fakeTransport{}
+	suppressions := NewMemorySuppressions()
+	suppressions.Add("spam@example.com", "spamreport")
+	e := NewEmailSenderWithTransport(&config.Config{}, transport, WithSuppressions(suppressions))
+
+	if err := e.SendBatch(t.Context(), []string{"a@example.com", "spam@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendBatch returned error: %v", err)
+	}
+	if len(transport.messages) != 1 || len(transport.messages[0].Personalizations) != 1 {
+		t.Fatalf("expected one message to the unsuppressed recipient, got %+v", transport.messages)
+	}
+}
+
+func TestSingleSendSkipsSuppressedAddress(t *testing.T) {
+	singleSendPollInterval = time.Millisecond
+
+	var mu sync.Mutex
+	var imported string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v3/marketing/lists":
+			w.Write([]byte(`{"result":[]}`))
+		case "POST /v3/marketing/lists":
+			w.Write([]byte(`{"id":"list-1"}`))
+		case "PUT /v3/marketing/contacts":
+			imported = string(body)
+			w.Write([]byte(`{"job_id":"job-1"}`))
+		case "GET /v3/marketing/contacts/imports/job-1":
+			w.Write([]byte(`{"status":"completed"}`))
+		case "POST /v3/marketing/singlesends":
+			w.Write([]byte(`{"id":"ss-1"}`))
+		case "PUT /v3/marketing/singlesends/ss-1/schedule":
+			w.Write([]byte(`{"status":"scheduled"}`))
+		}
+	}))
+	defer srv.Close()
+
+	e := NewEmailSender(&config.Config{SingleSendEnabled: true, SingleSendMinBatch: 2, SingleSendImportTimeout: time.Second})
+	e.marketingHost = srv.URL
+	e.Suppressions().Add("unsubscribed@example.com", "unsubscribe")
+
+	var result SendResult
+	recipients := []string{"a@example.com", "b@example.com", "unsubscribed@example.com"}
+	if err := e.SendEmailsWithAnalysis(recipients, nil, nil, goldenAnalysis(), WithResult(&result)); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if strings.Contains(imported, "unsubscribed@example.com") {
+		t.Errorf("suppressed address was imported into the Single Send list: %s", imported)
+	}
+	if !errors.Is(result.Skipped["unsubscribed@example.com"], ErrSuppressed) {
+		t.Errorf("expected the suppressed address recorded as skipped, got %v", result.Skipped)
+	}
+}
+
+func TestOpsSummaryAndConfirmationSkipSuppressedAddress(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		OpsSummaryTo:                "ops@example.com",
+		OpsSummaryMinBatch:          1,
+		ReporterConfirmationEnabled: true,
+	}, transport)
+	e.Suppressions().Add("ops@example.com", "bounce")
+	e.Suppressions().Add("reporter@example.com", "unsubscribe")
+
+	if err := e.SendEmails([]string{"a@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Errorf("expected only the batch email, without an ops summary, got %d messages", len(transport.messages))
+	}
+	if err := e.SendReporterConfirmation("reporter@example.com", true, nil, nil, goldenAnalysis()); !errors.Is(err, ErrSuppressed) {
+		t.Errorf("SendReporterConfirmation() = %v, want ErrSuppressed", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Errorf("expected no confirmation to the suppressed reporter, got %d messages", len(transport.messages))
+	}
+}
+
+// recordingSuppressions is an injected Suppressions that records its lookups
+type recordingSuppressions struct {
+	mu     sync.Mutex
+	lookup []string
+}
+
+func (s *recordingSuppressions) IsSuppressed(email string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookup = append(s.lookup, email)
+	return email == "gone@example.com"
+}
+
+func (s *recordingSuppressions) Add(email, reason string) {}
+
+func TestInjectedSuppressionsConsulted(t *testing.T) {
+	transport := &fakeTransport{}
+	suppressions := &recordingSuppressions{}
+	e := NewEmailSenderWithTransport(&config.Config{}, transport, WithSuppressions(suppressions))
+
+	if err := e.SendEmails([]string{"a@example.com", "Gone@Example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if len(transport.messages) != 1 || len(suppressions.lookup) != 2 {
+		t.Errorf("expected 1 send after 2 lookups, got %d sends and lookups %v", len(transport.messages), suppressions.lookup)
+	}
+}
+
+func TestMemorySuppressionsKeepsFirstReason(t *testing.T) {
+	s := NewMemorySuppressions()
+	s.Add(" User@Example.com ", "unsubscribe")
+	s.Add("user@example.com", "bounce")
+
+	if reason, ok := s.Reason("USER@example.com"); !ok || reason != "unsubscribe" {
+		t.Errorf("Reason() = %q, %v, want unsubscribe, true", reason, ok)
+	}
+	if s.IsSuppressed("other@example.com") {
+		t.Error("expected an address never added not to be suppressed")
+	}
+}
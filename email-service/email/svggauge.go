@@ -0,0 +1,43 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"math"
+)
+
+// svgGaugeWidth and svgGaugeHeight are the drawn size of an SVG gauge; it scales down
+// to narrow screens through the viewBox
+const (
+	svgGaugeWidth  = 300
+	svgGaugeHeight = 40
+)
+
+// getGaugeBarHtml renders the bar of a gauge for value in [0, 1] in the low, medium or
+// high band color: CSS-sized divs by default, or an inline SVG with UseSvgGauges since
+// Outlook and parts of Gmail drop the div widths and show an empty bar
+func (e *EmailSender) getGaugeBarHtml(metric string, value float64, band string) string {
+	if e.config.UseSvgGauges {
+		return "\n            " + svgGauge(metric, value, band)
+	}
+	return fmt.Sprintf(`
+            <div style="position: relative; width: 100%%; height: 40px; background: #f0f0f0; border-radius: 20px; overflow: hidden; margin: 10px 0;">
+                <div class="%s" style="height: 100%%; width: %.1f%%; border-radius: 20px;"></div>
+            </div>`, band, value*100)
+}
+
+// svgGauge draws a self-contained SVG bar filled in proportion to value in [0, 1], with
+// the percentage centered on a white halo so it stays readable over any fill
+func svgGauge(metric string, value float64, band string) string {
+	value = math.Max(0, math.Min(1, value))
+	label := fmt.Sprintf("%.1f%%", value*100)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s %s" style="display: block; width: 100%%; max-width: %dpx; height: auto; margin: 10px 0;">`+
+		`<rect width="%d" height="%d" rx="%d" fill="#f0f0f0"/>`+
+		`<rect width="%.1f" height="%d" rx="%d" fill="%s"/>`+
+		`<text x="%d" y="%d" text-anchor="middle" font-family="Arial, sans-serif" font-size="16" font-weight="bold" fill="#333" stroke="#fff" stroke-width="3" paint-order="stroke">%s</text>`+
+		`</svg>`,
+		svgGaugeWidth, svgGaugeHeight, svgGaugeWidth, svgGaugeHeight, html.EscapeString(metric), label, svgGaugeWidth,
+		svgGaugeWidth, svgGaugeHeight, svgGaugeHeight/2,
+		value*svgGaugeWidth, svgGaugeHeight, svgGaugeHeight/2, severityBandColors[band],
+		svgGaugeWidth/2, svgGaugeHeight/2+6, label)
+}
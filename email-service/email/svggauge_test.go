@@ -0,0 +1,47 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestSvgGaugeWidthProportion(t *testing.T) {
+	e := &EmailSender{config: &config.Config{UseSvgGauges: true}}
+	litter := 0.42
+	gauge := e.getGaugeBarHtml("Litter probability", litter, e.getGaugeColor(litter))
+
+	// 42% of the 300-wide track
+	if !strings.Contains(gauge, `<rect width="126.0" height="40" rx="20" fill="#fd7e14"/>`) {
+		t.Errorf("expected a medium bar 126 of 300 wide, got %s", gauge)
+	}
+	if !strings.Contains(gauge, `>42.0%</text>`) || !strings.Contains(gauge, `aria-label="Litter probability 42.0%"`) {
+		t.Errorf("expected the percentage label, got %s", gauge)
+	}
+	if strings.Contains(gauge, "<div") {
+		t.Error("expected no div bar in SVG mode")
+	}
+}
+
+func TestSvgGaugeClampsValue(t *testing.T) {
+	if gauge := svgGauge("Hazard", 1.7, "high"); !strings.Contains(gauge, `<rect width="300.0"`) {
+		t.Errorf("expected a value over 1 drawn full width, got %s", gauge)
+	}
+	if gauge := svgGauge("Hazard", -0.2, "low"); !strings.Contains(gauge, `<rect width="0.0"`) {
+		t.Errorf("expected a negative value drawn empty, got %s", gauge)
+	}
+}
+
+func TestGaugeSectionUsesSvgWhenEnabled(t *testing.T) {
+	analysis := goldenAnalysis()
+	divs := (&EmailSender{config: &config.Config{}}).getGaugeSection(analysis, catalogs["en"], "medium")
+	svg := (&EmailSender{config: &config.Config{UseSvgGauges: true}}).getGaugeSection(analysis, catalogs["en"], "medium")
+
+	if strings.Contains(divs, "<svg") || !strings.Contains(divs, `class="medium"`) {
+		t.Error("expected CSS div bars by default")
+	}
+	if !strings.Contains(svg, "<svg") || strings.Contains(svg, `class="medium"`) {
+		t.Error("expected an SVG gauge with UseSvgGauges")
+	}
+}
@@ -0,0 +1,68 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/apex/log"
+)
+
+// imageInfo is the decoded format and size of an image, for diagnostics
+type imageInfo struct {
+	format        string
+	width, height int
+}
+
+func (i imageInfo) String() string {
+	return fmt.Sprintf("%s %dx%d", i.format, i.width, i.height)
+}
+
+// describeImage decodes the format and dimensions of data; ok is false when data is
+// empty or not a decodable image
+func describeImage(data []byte) (info imageInfo, ok bool) {
+	if len(data) == 0 {
+		return imageInfo{}, false
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return imageInfo{}, false
+	}
+	return imageInfo{format, cfg.Width, cfg.Height}, true
+}
+
+// mapLike reports whether the image looks like a rendered map: a PNG stitched from
+// whole OSM tiles, as GeneratePolygonImg produces
+func mapLike(info imageInfo) bool {
+	return info.format == "png" && info.width%tileSize == 0 && info.height%tileSize == 0
+}
+
+// checkImageSwap logs the detected format and dimensions of the report and map images
+// and warns when they look swapped: the "report" looks like a rendered map while the
+// "map" doesn't, or the report is a PNG while the map is a JPEG photo. It returns
+// whether a swap is suspected.
+func checkImageSwap(reportImage, mapImage []byte) bool {
+	report, hasReport := describeImage(reportImage)
+	mapImg, hasMap := describeImage(mapImage)
+	if !hasReport && !hasMap {
+		return false
+	}
+
+	describe := func(info imageInfo, ok bool, data []byte) string {
+		if !ok {
+			if len(data) == 0 {
+				return "none"
+			}
+			return fmt.Sprintf("undecodable (%d bytes)", len(data))
+		}
+		return fmt.Sprintf("%s (%d bytes)", info, len(data))
+	}
+	log.Infof("Images: report %s, map %s", describe(report, hasReport, reportImage), describe(mapImg, hasMap, mapImage))
+
+	suspected := hasReport && mapLike(report) && (!hasMap || !mapLike(mapImg))
+	suspected = suspected || (hasReport && hasMap && report.format == "png" && mapImg.format == "jpeg")
+	if suspected {
+		log.Warnf("Report and map images look swapped (report %s, map %s); check the caller's argument order", describe(report, hasReport, reportImage), describe(mapImg, hasMap, mapImage))
+	}
+	return suspected
+}
@@ -0,0 +1,31 @@
+package email
+
+import "testing"
+
+func TestCheckImageSwap(t *testing.T) {
+	photo := encodeTestImage(t, 800, 600, "jpeg")
+	renderedMap := encodeTestImage(t, 3*tileSize, 2*tileSize, "png")
+	screenshot := encodeTestImage(t, 1170, 2532, "png")
+
+	testCases := []struct {
+		description    string
+		report, mapImg []byte
+		swapped        bool
+	}{
+		{"correct order", photo, renderedMap, false},
+		{"swapped", renderedMap, photo, true},
+		{"map passed as report alone", renderedMap, nil, true},
+		{"png screenshot report with map", screenshot, renderedMap, false},
+		{"png report with jpeg map", screenshot, photo, true},
+		{"no images", nil, nil, false},
+		{"undecodable report", []byte("not an image"), renderedMap, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := checkImageSwap(tc.report, tc.mapImg); got != tc.swapped {
+				t.Errorf("checkImageSwap() = %v, want %v", got, tc.swapped)
+			}
+		})
+	}
+}
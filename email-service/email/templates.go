@@ -0,0 +1,79 @@
+package email
+
+import (
+	"embed"
+	"html/template"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// emailTemplates are the HTML email bodies, parsed once at startup. Fields are escaped
+// by html/template; sections rendered elsewhere are passed in as template.HTML.
+var emailTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	// html/template drops comments, so the clip markers are emitted as trusted HTML
+	"clipStart": func() template.HTML { return clipOptionalStart },
+	"clipEnd":   func() template.HTML { return clipOptionalEnd },
+}).ParseFS(templateFS, "templates/*.tmpl"))
+
+// emailView is the data of the report email without analysis
+type emailView struct {
+	T      messages
+	Images template.HTML
+}
+
+// analysisEmailView is the data of the analysis email
+type analysisEmailView struct {
+	T              messages
+	DocTitle       string
+	Heading        string
+	Title          string
+	Classification string
+
+	// OptOutHref is the opt-out link's href attribute, built outside the template so
+	// its URL normalization doesn't percent-encode placeholders such as {{email}}
+	OptOutHref template.HTMLAttr
+
+	// Band backgrounds of the gauges and the signature color
+	GaugeLow, GaugeMedium, GaugeHigh template.CSS
+	SignatureColor                   template.CSS
+
+	// Sections rendered by their own helpers
+	Preheader, Greeting, UpdateBanner template.HTML
+	Intro                             template.HTML
+	SeveritySentence, GeofenceNote    template.HTML
+	ConfidenceBadge, Description      template.HTML
+	ReportMeta                        template.HTML
+	Metrics, Images, NextSteps, Logo  template.HTML
+}
+
+// digestEmailView is the data of the digest email
+type digestEmailView struct {
+	Count                int
+	Highest, HighestBand string
+	Cards                []digestCardView
+	OptOutHref           template.HTMLAttr
+}
+
+// digestCardView is the data of one report card in the digest email
+type digestCardView struct {
+	Brand, Title, Classification string
+	ReportCount                  int
+	Severity, SeverityBand       string
+	Link                         string
+	Description, Images          template.HTML
+}
+
+// renderTemplate executes the named email template with view. The templates are fixed
+// at build time, so a failure is a bug; it is logged and leaves the body empty.
+func renderTemplate(name string, view any) string {
+	var b strings.Builder
+	if err := emailTemplates.ExecuteTemplate(&b, name, view); err != nil {
+		log.Errorf("Failed to render email template %s: %v", name, err)
+		return ""
+	}
+	return b.String()
+}
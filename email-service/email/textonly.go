@@ -0,0 +1,48 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// textOnly reports whether recipient is configured to receive text/plain mail only,
+// either by exact address or by an "@domain" entry in TextOnlyRecipients
+func (e *EmailSender) textOnly(recipient string) bool {
+	addr := strings.ToLower(strings.TrimSpace(recipient))
+	for _, entry := range e.config.TextOnlyRecipients {
+		if entry == addr || (strings.HasPrefix(entry, "@") && strings.HasSuffix(addr, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addBodies adds the text/plain part and, unless recipient is text-only, the
+// text/html part after the HTMLTransform hook. html is only rendered when it is
+// needed; a transform error aborts the send.
+func (e *EmailSender) addBodies(message *mail.SGMailV3, recipient, text string, html func() string) error {
+	message.AddContent(mail.NewContent("text/plain", text))
+	if e.textOnly(recipient) {
+		return nil
+	}
+
+	body, err := e.transformHTML(html())
+	if err != nil {
+		return fmt.Errorf("html transform for %s: %w", recipient, err)
+	}
+	message.AddContent(mail.NewContent("text/html", body))
+	return nil
+}
+
+// addImage attaches img inline under cid with its sniffed content type, or as a regular
+// attachment for text-only recipients since there is no HTML part to reference it
+func (e *EmailSender) addImage(message *mail.SGMailV3, recipient string, img *inlineImage, filename, cid string) {
+	attachment := newInlineAttachment(img, imageContentType(img.raw), filename, cid)
+	if e.textOnly(recipient) {
+		attachment.SetDisposition("attachment")
+		attachment.SetContentID("")
+	}
+	message.AddAttachment(attachment)
+}
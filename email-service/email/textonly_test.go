@@ -0,0 +1,57 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestTextOnlyRecipientGetsNoHTMLPart(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		TextOnlyRecipients: []string{"plain@example.com"},
+	}, captureSends(t, &sent))
+
+	if err := e.SendEmailsWithAnalysis([]string{"Plain@Example.com"}, []byte("report"), []byte("map"), goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(sent))
+	}
+
+	m := sent[0]
+	if len(m.Content) != 1 || m.Content[0].Type != "text/plain" {
+		t.Fatalf("expected a single text/plain part, got %+v", m.Content)
+	}
+	if !strings.Contains(m.Content[0].Value, "Severity: 6.5 / 10") {
+		t.Errorf("text-only body is missing the analysis metrics:\n%s", m.Content[0].Value)
+	}
+	for _, a := range m.Attachments {
+		if a.Disposition != "attachment" || a.ContentID != "" {
+			t.Errorf("expected a regular attachment without Content-ID, got %+v", a)
+		}
+	}
+}
+
+func TestTextOnlyDomainAndDefaultMultipart(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{
+		TextOnlyRecipients: []string{"@text.example.com"},
+	}, captureSends(t, &sent))
+
+	recipients := []string{"ops@text.example.com", "brand@example.com"}
+	if err := e.SendEmailsWithAnalysis(recipients, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(sent))
+	}
+
+	if len(sent[0].Content) != 1 {
+		t.Errorf("expected text/plain only for domain entry, got %d parts", len(sent[0].Content))
+	}
+	if len(sent[1].Content) != 2 || sent[1].Content[1].Type != "text/html" {
+		t.Errorf("expected multipart text and HTML by default, got %+v", sent[1].Content)
+	}
+}
@@ -0,0 +1,45 @@
+package email
+
+import (
+	"html"
+	"strings"
+)
+
+// The CleanApp palette, used for any theme setting left unset
+const (
+	defaultThemePrimary = "#28a745"
+	defaultThemeAccent  = "#20c997"
+	defaultThemeLogoURL = "https://cleanapp.io/cleanapp-logo.png"
+)
+
+// theme is the white-label palette and logo an email is rendered with
+type theme struct {
+	primary string // Buttons, gradient start and signature
+	accent  string // Gradient end
+	logoURL string // HTML-escaped logo URL
+}
+
+// getTheme returns the configured theme for brandName. A brand with its own color uses
+// it for both ends of the gradient so no CleanApp color is left in its header.
+func (e *EmailSender) getTheme(brandName string) theme {
+	t := theme{
+		primary: e.config.ThemePrimaryColor,
+		accent:  e.config.ThemeAccentColor,
+		logoURL: e.config.ThemeLogoURL,
+	}
+	if color, ok := e.config.ThemeBrandColors[strings.ToLower(brandName)]; ok {
+		t.primary, t.accent = color, color
+	}
+
+	if t.primary == "" {
+		t.primary = defaultThemePrimary
+	}
+	if t.accent == "" {
+		t.accent = defaultThemeAccent
+	}
+	if t.logoURL == "" {
+		t.logoURL = defaultThemeLogoURL
+	}
+	t.logoURL = html.EscapeString(t.logoURL)
+	return t
+}
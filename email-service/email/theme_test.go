@@ -0,0 +1,38 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestGetTheme(t *testing.T) {
+	e := &EmailSender{config: &config.Config{
+		ThemeAccentColor: "#123456",
+		ThemeLogoURL:     "https://example.com/logo.png?a=1&b=2",
+		ThemeBrandColors: map[string]string{"acme": "#ff6600"},
+	}}
+
+	if got, want := e.getTheme("globex"), (theme{defaultThemePrimary, "#123456", "https://example.com/logo.png?a=1&amp;b=2"}); got != want {
+		t.Errorf("getTheme(globex) = %+v, want %+v", got, want)
+	}
+	if got := e.getTheme("ACME"); got.primary != "#ff6600" || got.accent != "#ff6600" {
+		t.Errorf("getTheme(ACME) = %+v, want the brand color at both gradient ends", got)
+	}
+}
+
+func TestThemeAppliedToEmails(t *testing.T) {
+	e := &EmailSender{config: &config.Config{ThemeBrandColors: map[string]string{"acme": "#ff6600"}}}
+
+	aggregate := e.getAggregateEmailHTML("brand@acme.com", &models.BrandReportSummary{BrandName: "acme", NewReportCount: 2, TotalReportCount: 9}, "https://cleanapp.io/opt-out")
+	if !strings.Contains(aggregate, "linear-gradient(135deg, #ff6600 0%, #ff6600 100%)") || strings.Contains(aggregate, defaultThemePrimary) {
+		t.Error("expected the aggregate email to use only the brand color")
+	}
+
+	analysis := e.getEmailHtmlWithAnalysis("brand@acme.com", goldenAnalysis(), false, false, analysisRender{})
+	if !strings.Contains(analysis, "background-color: #ff6600;") || strings.Contains(analysis, "background-color: "+defaultThemePrimary) {
+		t.Error("expected the analysis CTA to use the brand color")
+	}
+}
@@ -0,0 +1,176 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Decode GIF thumbnails for re-encoding as PNG
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"email-service/models"
+
+	"github.com/apex/log"
+	_ "golang.org/x/image/webp" // Decode WebP thumbnails for re-encoding as PNG
+)
+
+// maxThumbnailBytes bounds how much of a provider response is read as a thumbnail
+const maxThumbnailBytes = 512 * 1024
+
+// defaultThumbnailZoom is the {zoom} of thumbnail requests when MapThumbnailZoom is unset
+const defaultThumbnailZoom = 15
+
+// locationThumbnail fetches a small static map for the analysis coordinates from the
+// configured MapThumbnailURL provider, or reuses one fetched for the same coordinates
+// within MapThumbnailTTL. The request is abandoned once the batch's ctx is done. It
+// returns nil whenever no thumbnail is available (digital report, missing coordinates,
+// unconfigured or failing provider) so the email is simply sent without a map.
+func (e *EmailSender) locationThumbnail(ctx context.Context, analysis *models.ReportAnalysis) []byte {
+	if e.config.MapThumbnailURL == "" || analysis.Classification == "digital" {
+		return nil
+	}
+	if analysis.Latitude == 0 && analysis.Longitude == 0 {
+		return nil
+	}
+
+	zoom := e.config.MapThumbnailZoom
+	if zoom <= 0 {
+		zoom = defaultThumbnailZoom
+	}
+	thumbnailURL := strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(analysis.Latitude, 'f', 6, 64),
+		"{lon}", strconv.FormatFloat(analysis.Longitude, 'f', 6, 64),
+		"{zoom}", strconv.Itoa(zoom),
+	).Replace(e.config.MapThumbnailURL)
+
+	now := e.now()
+	if thumbnail, ok := e.thumbnails.get(thumbnailURL, now); ok {
+		return thumbnail
+	}
+	thumbnail, err := e.fetchThumbnail(ctx, thumbnailURL)
+	if err != nil {
+		log.Warnf("Failed to fetch location thumbnail for report %d: %v, sending email without map", analysis.Seq, err)
+		return nil
+	}
+	e.thumbnails.put(thumbnailURL, thumbnail, now, e.config.MapThumbnailTTL)
+	return thumbnail
+}
+
+// fetchThumbnail requests the thumbnail at thumbnailURL through the sender's HTTP
+// client until ctx is done or within MapThumbnailTimeout and returns it as PNG, the
+// format of the map attachment, re-encoding JPEG, GIF and WebP images. Errors leave out
+// the URL's query, which usually carries the provider's API key.
+func (e *EmailSender) fetchThumbnail(ctx context.Context, thumbnailURL string) ([]byte, error) {
+	if timeout := e.config.MapThumbnailTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, thumbnailURL, nil)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	req.Header.Set("User-Agent", "CleanApp/2.0")
+
+	resp, err := e.httpClient.HTTPClient.Do(req)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("thumbnail provider returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxThumbnailBytes {
+		return nil, fmt.Errorf("thumbnail exceeds %d bytes", maxThumbnailBytes)
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("thumbnail provider returned %s instead of an image", contentType)
+	}
+	if contentType == "image/png" {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s thumbnail: %w", contentType, err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode thumbnail as PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// redactURLError removes the query from the URL of a *url.Error, keeping the provider's
+// API key out of logs
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := *urlErr
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		u.RawQuery, u.Fragment = "", ""
+		redacted.URL = u.Redacted()
+	} else {
+		redacted.URL = "(unparsable thumbnail URL)"
+	}
+	return &redacted
+}
+
+// thumbnailCache keeps fetched thumbnails by request URL for MapThumbnailTTL, so
+// reports from the same spot don't each hit the provider. Failed requests aren't cached.
+type thumbnailCache struct {
+	mu      sync.Mutex
+	entries map[string]thumbnailEntry
+}
+
+type thumbnailEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// get returns the thumbnail cached for thumbnailURL, unless it expired by now
+func (c *thumbnailCache) get(thumbnailURL string, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[thumbnailURL]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put caches data for thumbnailURL until ttl after now, dropping expired entries; a
+// ttl of 0 caches nothing
+func (c *thumbnailCache) put(thumbnailURL string, data []byte, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]thumbnailEntry)
+	}
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[thumbnailURL] = thumbnailEntry{data: data, expires: now.Add(ttl)}
+}
@@ -0,0 +1,215 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+// stubProvider is a static-map provider answering every request with the same status
+// and body, recording the request URLs
+type stubProvider struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newStubProvider(t *testing.T, status int, body []byte) *stubProvider {
+	t.Helper()
+	p := &stubProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests = append(p.requests, r.URL.String())
+		p.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// requested returns the URLs requested so far
+func (p *stubProvider) requested() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.requests)
+}
+
+// mapAttachment returns the message's map attachment, or nil
+func mapAttachment(t *testing.T, transport *fakeTransport, i int) []byte {
+	t.Helper()
+	for _, attachment := range transport.messages[i].Attachments {
+		if strings.HasPrefix(attachment.Filename, "cleanapp-map") {
+			if attachment.Type != "image/png" {
+				t.Errorf("map attachment type = %s, want image/png", attachment.Type)
+			}
+			return []byte(attachment.Content)
+		}
+	}
+	return nil
+}
+
+func locatedAnalysis() *models.ReportAnalysis {
+	analysis := goldenAnalysis()
+	analysis.Latitude, analysis.Longitude = 52.52, 13.405
+	return analysis
+}
+
+func TestLocationThumbnailFetchedAndCached(t *testing.T) {
+	provider := newStubProvider(t, http.StatusOK, encodeTestImage(t, 64, 48, "jpeg"))
+	now := time.Date(2025, time.March, 5, 9, 0, 0, 0, time.UTC)
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		MapThumbnailURL:     provider.URL + "/static?center={lat},{lon}&zoom={zoom}&key=test",
+		MapThumbnailTimeout: time.Second,
+		MapThumbnailZoom:    12,
+		MapThumbnailTTL:     time.Minute,
+	}, transport, WithClock(func() time.Time { return now }))
+
+	for range 2 {
+		if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, locatedAnalysis()); err != nil {
+			t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+		}
+	}
+	requests := provider.requested()
+	if len(requests) != 1 {
+		t.Fatalf("expected identical coordinates to be fetched once, got %d requests", len(requests))
+	}
+	if want := "/static?center=52.520000,13.405000&zoom=12&key=test"; requests[0] != want {
+		t.Errorf("provider request = %s, want %s", requests[0], want)
+	}
+	for i := range transport.messages {
+		if mapAttachment(t, transport, i) == nil {
+			t.Errorf("message %d has no map attachment", i+1)
+		}
+	}
+
+	// The JPEG from the provider is attached as PNG
+	thumbnail := e.locationThumbnail(context.Background(), locatedAnalysis())
+	if _, err := png.Decode(bytes.NewReader(thumbnail)); err != nil {
+		t.Errorf("expected a PNG thumbnail, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	e.locationThumbnail(context.Background(), locatedAnalysis())
+	if n := len(provider.requested()); n != 2 {
+		t.Errorf("expected an expired thumbnail to be fetched again, got %d requests", n)
+	}
+}
+
+func TestLocationThumbnailProviderFailure(t *testing.T) {
+	provider := newStubProvider(t, http.StatusInternalServerError, []byte("upstream error"))
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{
+		MapThumbnailURL:     provider.URL + "/static?center={lat},{lon}",
+		MapThumbnailTimeout: time.Second,
+		MapThumbnailTTL:     time.Minute,
+	}, transport)
+
+	for range 2 {
+		if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, locatedAnalysis()); err != nil {
+			t.Fatalf("expected the email sent without a map, got error: %v", err)
+		}
+	}
+	if len(transport.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(transport.messages))
+	}
+	if mapAttachment(t, transport, 0) != nil {
+		t.Error("expected no map attachment when the provider fails")
+	}
+	if n := len(provider.requested()); n != 2 {
+		t.Errorf("expected failures not to be cached, got %d requests", n)
+	}
+}
+
+func TestFetchThumbnailRedactsAPIKey(t *testing.T) {
+	provider := newStubProvider(t, http.StatusOK, nil)
+	provider.Close()
+	e := NewEmailSender(&config.Config{MapThumbnailTimeout: time.Second})
+
+	_, err := e.fetchThumbnail(context.Background(), provider.URL+"/static?center=52.52,13.405&key=secret-key")
+	if err == nil {
+		t.Fatal("expected an error from a closed provider")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error leaks the API key: %v", err)
+	}
+	if !strings.Contains(err.Error(), provider.URL+"/static") {
+		t.Errorf("expected the error to keep the provider URL without its query, got %v", err)
+	}
+}
+
+func TestFetchThumbnailConvertsGIFAndWebP(t *testing.T) {
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 4, 3), palette.Plan9), nil); err != nil {
+		t.Fatal(err)
+	}
+	// A 1x1 lossless WebP image
+	webpData := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+	for _, tt := range []struct {
+		name       string
+		data       []byte
+		wantBounds image.Rectangle
+	}{
+		{"gif", gifData.Bytes(), image.Rect(0, 0, 4, 3)},
+		{"webp", webpData, image.Rect(0, 0, 1, 1)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newStubProvider(t, http.StatusOK, tt.data)
+			e := NewEmailSender(&config.Config{MapThumbnailTimeout: time.Second})
+
+			thumbnail, err := e.fetchThumbnail(context.Background(), provider.URL+"/static")
+			if err != nil {
+				t.Fatalf("fetchThumbnail returned error: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(thumbnail))
+			if err != nil {
+				t.Fatalf("expected a PNG thumbnail: %v", err)
+			}
+			if img.Bounds() != tt.wantBounds {
+				t.Errorf("thumbnail bounds = %v, want %v", img.Bounds(), tt.wantBounds)
+			}
+		})
+	}
+}
+
+func TestFetchThumbnailStopsWhenContextDone(t *testing.T) {
+	release := make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang like an unresponsive provider until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer provider.Close()
+	defer close(release)
+	// No MapThumbnailTimeout, so only the batch's ctx bounds the request
+	e := NewEmailSender(&config.Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := e.fetchThumbnail(ctx, provider.URL+"/static")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("fetchThumbnail() = %v, want the batch deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetchThumbnail kept waiting %s after the batch's ctx was done", elapsed)
+	}
+}
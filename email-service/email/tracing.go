@@ -0,0 +1,85 @@
+package email
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes; recipients are identified by domain only to keep addresses out of traces
+const (
+	attrBatchID         = attribute.Key("email.batch_id")
+	attrKind            = attribute.Key("email.kind")
+	attrClassification  = attribute.Key("email.classification")
+	attrRecipientDomain = attribute.Key("email.recipient_domain")
+	attrStatus          = attribute.Key("email.status")
+	attrMessageID       = attribute.Key("email.message_id")
+	attrProviderID      = attribute.Key("sendgrid.message_id")
+	attrHTTPStatus      = attribute.Key("http.response.status_code")
+	attrAccount         = attribute.Key("sendgrid.account")
+)
+
+// startBatchSpan starts the span covering batch b as a child of ctx and makes it the
+// parent of the batch's send spans
+func (e *EmailSender) startBatchSpan(ctx context.Context, b *batch, kind string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attrBatchID.String(b.id), attrKind.String(kind)}
+	if b.classification != "" {
+		attrs = append(attrs, attrClassification.String(b.classification))
+	}
+	ctx, span := e.tracer.Start(ctx, "email.batch", trace.WithAttributes(attrs...))
+	b.ctx = ctx
+	return ctx, span
+}
+
+// endBatchSpan records the batch's outcome counts on its span and ends it
+func endBatchSpan(span trace.Span, report *batchReport, err error) {
+	status := "sent"
+	if err != nil {
+		status = "failed"
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(
+		attrStatus.String(status),
+		attribute.Int("email.total", report.total),
+		attribute.Int("email.sent", report.sent()),
+		attribute.Int("email.invalid", len(report.invalid)),
+		attribute.Int("email.skipped", len(report.skipped)),
+		attribute.Int("email.failed", len(report.failures)),
+	)
+	span.End()
+}
+
+// startSendSpan starts the span for one SendGrid send, under the batch span when the
+// send is part of a batch. The returned context also carries the batch's cancellation.
+func (e *EmailSender) startSendSpan(b *batch, recipient, kind string) (context.Context, trace.Span) {
+	ctx := context.Background()
+	attrs := []attribute.KeyValue{attrKind.String(kind)}
+	if b != nil {
+		if b.ctx != nil {
+			ctx = b.ctx
+		}
+		attrs = append(attrs, attrBatchID.String(b.id))
+		if b.classification != "" {
+			attrs = append(attrs, attrClassification.String(b.classification))
+		}
+	}
+	if _, domain, ok := strings.Cut(recipient, "@"); ok {
+		attrs = append(attrs, attrRecipientDomain.String(strings.ToLower(domain)))
+	}
+	return e.tracer.Start(ctx, "email.send", trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// endSendSpan records a send's outcome on its span and ends it
+func endSendSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attrStatus.String("failed"))
+	} else {
+		span.SetAttributes(attrStatus.String("sent"))
+	}
+	span.End()
+}
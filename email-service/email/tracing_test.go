@@ -0,0 +1,84 @@
+package email
+
+import (
+	"context"
+	"testing"
+
+	"email-service/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSendSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{CustomMessageIDs: true}, captureSends(t, &sent), WithTracer(tracer))
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	recipients := make(chan Recipient, 2)
+	recipients <- Recipient{Email: "a@example.com", MessageID: "<a@cleanapp.io>"}
+	recipients <- Recipient{Email: "b@Example.com"}
+	close(recipients)
+	if err := e.SendEmailsWithAnalysisStream(ctx, recipients, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysisStream returned error: %v", err)
+	}
+	parent.End()
+
+	var batchSpan sdktrace.ReadOnlySpan
+	var sendSpans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "email.batch":
+			batchSpan = span
+		case "email.send":
+			sendSpans = append(sendSpans, span)
+		}
+	}
+	if batchSpan == nil || len(sendSpans) != 2 {
+		t.Fatalf("expected a batch span and 2 send spans, got %d spans", len(recorder.Ended()))
+	}
+	if batchSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the batch span under the caller's span")
+	}
+	assertSpanAttr(t, batchSpan, "email.classification", "physical")
+	assertSpanAttr(t, batchSpan, "email.status", "sent")
+
+	for _, span := range sendSpans {
+		if span.Parent().SpanID() != batchSpan.SpanContext().SpanID() {
+			t.Errorf("expected send span under the batch span")
+		}
+		if span.Status().Code == codes.Error {
+			t.Errorf("expected send span without error, got %v", span.Status())
+		}
+		assertSpanAttr(t, span, "email.status", "sent")
+		assertSpanAttr(t, span, "email.recipient_domain", "example.com")
+	}
+	found := false
+	for _, span := range sendSpans {
+		for _, kv := range span.Attributes() {
+			found = found || (kv.Key == "email.message_id" && kv.Value.AsString() == "<a@cleanapp.io>")
+		}
+	}
+	if !found {
+		t.Error("expected the provided Message-ID on a send span")
+	}
+}
+
+// assertSpanAttr checks that span carries the string attribute key=want
+func assertSpanAttr(t *testing.T, span sdktrace.ReadOnlySpan, key attribute.Key, want string) {
+	t.Helper()
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			if got := kv.Value.AsString(); got != want {
+				t.Errorf("%s span %s = %q, want %q", span.Name(), key, got, want)
+			}
+			return
+		}
+	}
+	t.Errorf("%s span has no %s attribute", span.Name(), key)
+}
@@ -0,0 +1,24 @@
+package email
+
+import (
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// applyTracking sets the message's open and click tracking to EnableOpenTracking and
+// EnableClickTracking. Either left unset keeps the account's setting, and with both unset
+// the message carries no tracking settings at all.
+func (e *EmailSender) applyTracking(message *mail.SGMailV3) {
+	open, click := e.config.EnableOpenTracking, e.config.EnableClickTracking
+	if open == nil && click == nil {
+		return
+	}
+
+	settings := mail.NewTrackingSettings()
+	if open != nil {
+		settings.SetOpenTracking(mail.NewOpenTrackingSetting().SetEnable(*open))
+	}
+	if click != nil {
+		settings.SetClickTracking(mail.NewClickTrackingSetting().SetEnable(*click).SetEnableText(*click))
+	}
+	message.SetTrackingSettings(settings)
+}
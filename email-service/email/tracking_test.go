@@ -0,0 +1,70 @@
+package email
+
+import (
+	"encoding/json"
+	"testing"
+
+	"email-service/config"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+func TestTrackingDisabledWhenConfiguredOff(t *testing.T) {
+	off := false
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{EnableOpenTracking: &off, EnableClickTracking: &off}, transport)
+
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(transport.messages))
+	}
+
+	var body struct {
+		TrackingSettings *struct {
+			ClickTracking *struct {
+				Enable     *bool `json:"enable"`
+				EnableText *bool `json:"enable_text"`
+			} `json:"click_tracking"`
+			OpenTracking *struct {
+				Enable *bool `json:"enable"`
+			} `json:"open_tracking"`
+		} `json:"tracking_settings"`
+	}
+	if err := json.Unmarshal(mail.GetRequestBody(transport.messages[0]), &body); err != nil {
+		t.Fatalf("failed to decode request body: %v", err)
+	}
+	settings := body.TrackingSettings
+	if settings == nil || settings.OpenTracking == nil || settings.ClickTracking == nil {
+		t.Fatalf("expected open and click tracking settings, got %+v", settings)
+	}
+	if settings.OpenTracking.Enable == nil || *settings.OpenTracking.Enable {
+		t.Error("expected open tracking disabled")
+	}
+	if click := settings.ClickTracking; click.Enable == nil || *click.Enable || click.EnableText == nil || *click.EnableText {
+		t.Error("expected click tracking disabled in HTML and text")
+	}
+}
+
+func TestTrackingUnsetLeavesAccountSettings(t *testing.T) {
+	on := true
+	for _, cfg := range []*config.Config{{}, {EnableClickTracking: &on}} {
+		transport := &fakeTransport{}
+		e := NewEmailSenderWithTransport(cfg, transport)
+		if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+			t.Fatalf("SendEmails returned error: %v", err)
+		}
+
+		settings := transport.messages[0].TrackingSettings
+		if cfg.EnableClickTracking == nil {
+			if settings != nil {
+				t.Errorf("expected no tracking settings when unset, got %+v", settings)
+			}
+			continue
+		}
+		if settings == nil || settings.OpenTracking != nil || settings.ClickTracking == nil || !*settings.ClickTracking.Enable {
+			t.Errorf("expected only click tracking set, got %+v", settings)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// Transport tuning for the default SendGrid HTTP client. Every request goes to the same
+// API host, so the idle pool per host matters most: net/http keeps only 2 by default,
+// which forces concurrent senders to redial and redo the TLS handshake.
+const (
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 64
+	idleConnTimeout     = 90 * time.Second
+	sendRequestTimeout  = 30 * time.Second
+)
+
+// newHTTPClient returns the default client for SendGrid requests, tuned for connection
+// reuse under concurrency; WithHTTPClient replaces it
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: sendRequestTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          maxIdleConns,
+			MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+			IdleConnTimeout:       idleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+			TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+		},
+	}
+}
+
+// Transport delivers a fully built message and returns the provider's response, which
+// is interpreted like a SendGrid v3 mail/send response: 2xx accepted, 503 retried, any
+// other status a failure. Send must abandon the request when ctx is done. The default
+// posts to SendGrid through the account picked for the recipient; a custom one, given
+// to NewEmailSenderWithTransport, receives every account's mail.
+type Transport interface {
+	Send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error)
+}
+
+// sendGridTransport posts messages through an account's mail/send request using the
+// sender's HTTP client. The request is copied rather than filled in place, unlike
+// sendgrid.Client.Send, so concurrent sends can share an account.
+type sendGridTransport struct {
+	client *sendgrid.Client
+	http   *rest.Client
+}
+
+func (t *sendGridTransport) Send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	request := t.client.Request
+	request.Body = mail.GetRequestBody(message)
+	return t.http.SendWithContext(ctx, request)
+}
+
+// transportFor returns the transport delivering account's mail
+func (e *EmailSender) transportFor(account *sendAccount) Transport {
+	if e.transport != nil {
+		return e.transport
+	}
+	return &sendGridTransport{client: account.client, http: e.httpClient}
+}
@@ -0,0 +1,106 @@
+package email
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"email-service/config"
+
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// fakeTransport records the messages it is given and accepts them without a network call
+type fakeTransport struct {
+	mu       sync.Mutex
+	messages []*mail.SGMailV3
+}
+
+func (f *fakeTransport) Send(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, message)
+	return &rest.Response{StatusCode: http.StatusAccepted}, nil
+}
+
+func TestNewEmailSenderWithTransport(t *testing.T) {
+	transport := &fakeTransport{}
+	e := NewEmailSenderWithTransport(&config.Config{SendGridFromEmail: "info@cleanapp.io"}, transport)
+
+	err := e.SendEmailsWithAnalysis([]string{"brand@example.com"},
+		encodeTestImage(t, 40, 30, "jpeg"), encodeTestImage(t, 20, 20, "png"), goldenAnalysis())
+	if err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(transport.messages) != 1 {
+		t.Fatalf("expected 1 message handed to the transport, got %d", len(transport.messages))
+	}
+
+	message := transport.messages[0]
+	if message.Subject != e.BuildSubject(goldenAnalysis()) {
+		t.Errorf("unexpected subject %q", message.Subject)
+	}
+	if len(message.Personalizations) != 1 || len(message.Personalizations[0].To) != 1 || message.Personalizations[0].To[0].Address != "brand@example.com" {
+		t.Errorf("expected one personalization to brand@example.com, got %+v", message.Personalizations)
+	}
+	if len(message.Attachments) != 2 || !strings.HasPrefix(message.Attachments[0].ContentID, reportImgCid+"-") ||
+		!strings.HasPrefix(message.Attachments[1].ContentID, mapImgCid+"-") {
+		t.Errorf("expected the report and map attachments, got %d", len(message.Attachments))
+	}
+}
+
+// countingTransport counts round trips before handing them to the default transport
+type countingTransport struct {
+	trips int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.trips, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	transport := &countingTransport{}
+	e := newTestSender(t, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}, WithHTTPClient(&http.Client{Transport: transport}))
+
+	if err := e.SendEmails([]string{"a@example.com", "b@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if transport.trips != 2 {
+		t.Errorf("expected 2 requests through the injected client, got %d", transport.trips)
+	}
+}
+
+// BenchmarkSendConcurrent measures sends from many goroutines sharing one sender, where
+// the idle connection pool decides how often connections are redialed
+func BenchmarkSendConcurrent(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		client *http.Client
+	}{
+		{"default", newHTTPClient()},
+		{"library", &http.Client{}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			e := newTestSender(b, &config.Config{}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			}, WithHTTPClient(bm.client))
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package email
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// zeroWidthJoiner joins emoji into a single sequence, e.g. family or profession emoji
+const zeroWidthJoiner = '\u200d'
+
+// truncateRunes shortens s to at most max runes including the ellipsis, counting runes
+// rather than bytes. The cut is moved back to a grapheme boundary so it never splits a
+// multibyte rune, a base character from its combining marks, or an emoji sequence
+// (modifiers, ZWJ sequences, keycaps, flags). s is returned unchanged when it fits.
+func truncateRunes(s string, max int, ellipsis string) string {
+	if max <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	limit := max - utf8.RuneCountInString(ellipsis)
+	if limit <= 0 {
+		return truncateRunes(ellipsis, max, "")
+	}
+
+	runes := []rune(s)
+	end := 0 // Rune index of the last cluster boundary within limit
+	for i := 0; i < len(runes); {
+		next := i + clusterLen(runes[i:])
+		if next > limit {
+			break
+		}
+		end = next
+		i = next
+	}
+	return string(runes[:end]) + ellipsis
+}
+
+// truncateWords is truncateRunes cutting at the last space instead of mid-word. A single
+// word longer than the kept half is still cut at a grapheme boundary.
+func truncateWords(s string, max int, ellipsis string) string {
+	if utf8.RuneCountInString(s) <= max || max <= utf8.RuneCountInString(ellipsis) {
+		return truncateRunes(s, max, ellipsis)
+	}
+
+	kept := truncateRunes(s, max-utf8.RuneCountInString(ellipsis), "")
+	if next, _ := utf8.DecodeRuneInString(s[len(kept):]); !unicode.IsSpace(next) {
+		if i := strings.LastIndexFunc(kept, unicode.IsSpace); i > len(kept)/2 {
+			kept = kept[:i]
+		}
+	}
+	return strings.TrimRightFunc(kept, unicode.IsSpace) + ellipsis
+}
+
+// clusterLen returns the number of runes in the grapheme cluster starting at runes[0].
+// It covers the cases that matter for subjects and labels rather than the full
+// Unicode segmentation rules.
+func clusterLen(runes []rune) int {
+	if isRegionalIndicator(runes[0]) {
+		// Flags are pairs of regional indicators
+		if len(runes) > 1 && isRegionalIndicator(runes[1]) {
+			return 2
+		}
+		return 1
+	}
+
+	n := 1
+	for n < len(runes) {
+		r := runes[n]
+		switch {
+		case r == zeroWidthJoiner && n+1 < len(runes):
+			// Zero width joiner glues the following character into the cluster
+			n += 2
+		case isClusterExtender(r):
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// isClusterExtender reports whether r attaches to the preceding character: combining
+// marks, variation selectors, emoji skin tone modifiers and tag characters
+func isClusterExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zeroWidthJoiner ||
+		(r >= 0xfe00 && r <= 0xfe0f) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) ||
+		(r >= 0xe0020 && r <= 0xe007f)
+}
+
+// isRegionalIndicator reports whether r is a regional indicator symbol used in flags
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
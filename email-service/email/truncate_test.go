@@ -0,0 +1,133 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	testCases := []struct {
+		description string
+		s           string
+		max         int
+		expected    string
+	}{
+		{"fits", "Broken glass", 12, "Broken glass"},
+		{"ascii", "Broken glass on the sidewalk", 10, "Broken ..."},
+		{"multibyte counted as runes", "Ünïcödé fïné", 12, "Ünïcödé fïné"},
+		{"multibyte cut", "Ünïcödé tïtlé hérè", 10, "Ünïcödé..."},
+		{"emoji not split", "Trash 🗑️🗑️ everywhere", 11, "Trash 🗑️..."},
+		{"emoji dropped rather than split", "Trash 🗑️🗑️ everywhere", 10, "Trash ..."},
+		{"skin tone modifier kept", "Hi 👋🏽👋🏽👋🏽", 8, "Hi 👋🏽..."},
+		{"zwj sequence kept whole", "Family 👨‍👩‍👧 day", 12, "Family ..."},
+		{"flag pair kept whole", "Flags 🇺🇸🇫🇷🇩🇪", 11, "Flags 🇺🇸..."},
+		{"flag pair not split", "Flags 🇺🇸🇫🇷🇩🇪", 10, "Flags ..."},
+		{"combining accent kept with base", "café café café", 8, "café..."},
+		{"max smaller than ellipsis", "Broken glass", 2, ".."},
+		{"zero max", "Broken glass", 0, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got := truncateRunes(tc.s, tc.max, "...")
+			if got != tc.expected {
+				t.Errorf("truncateRunes(%q, %d) = %q, want %q", tc.s, tc.max, got, tc.expected)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateRunes(%q, %d) returned invalid UTF-8", tc.s, tc.max)
+			}
+			if n := utf8.RuneCountInString(got); n > tc.max {
+				t.Errorf("truncateRunes(%q, %d) returned %d runes", tc.s, tc.max, n)
+			}
+		})
+	}
+}
+
+func TestBuildSubjectTruncatesByRunes(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	analysis := &models.ReportAnalysis{
+		BrandName:        "acme",
+		BrandReportCount: 1,
+		Title:            strings.Repeat("é", 46) + "🗑️ overflowing",
+	}
+
+	got := e.BuildSubject(analysis)
+	if !utf8.ValidString(got) {
+		t.Fatalf("BuildSubject() returned invalid UTF-8: %q", got)
+	}
+	if want := "acme issue #1: " + strings.Repeat("é", 46) + "..."; got != want {
+		t.Errorf("BuildSubject() = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	testCases := []struct {
+		description string
+		s           string
+		max         int
+		expected    string
+	}{
+		{"fits", "Broken glass", 12, "Broken glass"},
+		{"cut at last space", "Broken glass on the sidewalk", 16, "Broken glass..."},
+		{"cut already at a space", "Broken glass on the sidewalk", 15, "Broken glass..."},
+		{"long word cut mid-word", "Overflowingtrashbins everywhere", 10, "Overflo..."},
+		{"max smaller than ellipsis", "Broken glass", 2, ".."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := truncateWords(tc.s, tc.max, "..."); got != tc.expected {
+				t.Errorf("truncateWords(%q, %d) = %q, want %q", tc.s, tc.max, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBodyDescriptionTruncated(t *testing.T) {
+	e := &EmailSender{config: &config.Config{MaxBodyDescriptionLen: 200}}
+	analysis := goldenAnalysis()
+	analysis.Description = strings.Repeat("Trash bags piled by the gate. ", 17)[:500]
+
+	got := e.bodyDescription(analysis.Description)
+	if n := utf8.RuneCountInString(got); n > 200 || !strings.HasSuffix(got, "...") {
+		t.Fatalf("bodyDescription() returned %d runes %q, want at most 200 ending in an ellipsis", n, got)
+	}
+	if !strings.HasPrefix(analysis.Description, strings.TrimSuffix(got, "...")+" ") {
+		t.Errorf("expected the description cut at a word boundary, got %q", got)
+	}
+
+	html := e.getEmailHtmlWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	text := e.getEmailTextWithAnalysis("brand@example.com", analysis, false, false, analysisRender{})
+	if strings.Contains(html, analysis.Description) || !strings.Contains(html, got) || !strings.Contains(text, got) {
+		t.Error("expected both bodies to carry the truncated description")
+	}
+}
+
+func TestBodyDescriptionShortUnchanged(t *testing.T) {
+	e := &EmailSender{config: &config.Config{MaxBodyDescriptionLen: 200}}
+	if got := e.bodyDescription("Overflowing bin by the bus stop."); got != "Overflowing bin by the bus stop." {
+		t.Errorf("bodyDescription() = %q, want it unchanged", got)
+	}
+}
+
+func TestBuildSubjectFitsRecommendedLength(t *testing.T) {
+	e := &EmailSender{config: &config.Config{MaxSubjectTitleLen: 70, SubjectEmojiEnabled: true, SubjectEmoji: map[string]string{"physical": "🗑️"}}}
+	analysis := &models.ReportAnalysis{
+		BrandDisplayName: "Acme Global Beverages",
+		BrandReportCount: 1234,
+		Classification:   "physical",
+		Title:            strings.Repeat("Overflowing trash bins ", 20),
+	}
+
+	got := e.BuildSubject(analysis)
+	if n := utf8.RuneCountInString(got); n > maxSubjectLen {
+		t.Errorf("BuildSubject() = %q is %d characters, want at most %d", got, n, maxSubjectLen)
+	}
+	if !strings.HasPrefix(got, "🗑️ Acme Global Beverages issue #1234: Overflowing") || !strings.HasSuffix(got, " Overflowing...") {
+		t.Errorf("BuildSubject() = %q, want the title cut at a word boundary", got)
+	}
+}
@@ -0,0 +1,138 @@
+package email
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"email-service/config"
+)
+
+func TestListUnsubscribeHeaders(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{OptOutURL: "https://cleanapp.io/opt-out"}, captureSends(t, &sent))
+
+	if err := e.SendEmailsWithAnalysis([]string{"ops+alerts@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sent))
+	}
+	headers := sent[0].Personalizations[0].Headers
+	if got, want := headers["List-Unsubscribe"], "<https://cleanapp.io/opt-out?email=ops%2Balerts%40example.com>"; got != want {
+		t.Errorf("List-Unsubscribe = %q, want %q", got, want)
+	}
+	if got := headers["List-Unsubscribe-Post"]; got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q, want List-Unsubscribe=One-Click", got)
+	}
+}
+
+func TestListUnsubscribeOmittedWithoutOptOutURL(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{}, captureSends(t, &sent))
+
+	if err := e.SendEmails([]string{"brand@example.com"}, nil, nil); err != nil {
+		t.Fatalf("SendEmails returned error: %v", err)
+	}
+	if _, ok := sent[0].Personalizations[0].Headers["List-Unsubscribe"]; ok {
+		t.Error("expected no List-Unsubscribe header without an opt-out URL")
+	}
+}
+
+func TestOptOutToken(t *testing.T) {
+	e := &EmailSender{config: &config.Config{OptOutSigningKey: "secret"}}
+	token := e.optOutToken("brand@example.com")
+
+	if token == "" || !e.VerifyOptOutToken("brand@example.com", token) {
+		t.Fatalf("expected a valid token for the signed address, got %q", token)
+	}
+	if !e.VerifyOptOutToken(" Brand@Example.com", token) {
+		t.Error("expected the token to hold for the same address in another case")
+	}
+	if e.VerifyOptOutToken("victim@example.com", token) {
+		t.Error("expected the token to be rejected for a tampered email")
+	}
+	if e.VerifyOptOutToken("brand@example.com", "") || e.VerifyOptOutToken("brand@example.com", token[1:]) {
+		t.Error("expected missing and truncated tokens to be rejected")
+	}
+	if other := (&EmailSender{config: &config.Config{OptOutSigningKey: "other"}}); other.VerifyOptOutToken("brand@example.com", token) {
+		t.Error("expected a token signed with another key to be rejected")
+	}
+}
+
+func TestOptOutTokenWithoutKey(t *testing.T) {
+	e := &EmailSender{config: &config.Config{}}
+	if token := e.optOutToken("brand@example.com"); token != "" {
+		t.Errorf("expected no token without a signing key, got %q", token)
+	}
+	if !e.VerifyOptOutToken("brand@example.com", "") {
+		t.Error("expected unsigned links to be accepted without a signing key")
+	}
+	if got := e.optOutLink("https://cleanapp.io/opt-out", "brand@example.com"); got != "https://cleanapp.io/opt-out?email=brand%40example.com" {
+		t.Errorf("expected an unsigned link, got %s", got)
+	}
+}
+
+func TestOptOutLinkRoundTripsPlusAddress(t *testing.T) {
+	e := &EmailSender{config: &config.Config{OptOutSigningKey: "secret"}}
+	link, err := url.Parse(e.optOutLink("https://cleanapp.io/opt-out", "ops+alerts@example.com"))
+	if err != nil {
+		t.Fatalf("failed to parse the opt-out link: %v", err)
+	}
+	query := link.Query()
+	if got := query.Get("email"); got != "ops+alerts@example.com" {
+		t.Errorf("email parameter = %q, want ops+alerts@example.com", got)
+	}
+	if !e.VerifyOptOutToken(query.Get("email"), query.Get("token")) {
+		t.Error("expected the token of a plus address to verify")
+	}
+}
+
+func TestSingleSendOptOutLinkIsUnsigned(t *testing.T) {
+	e := &EmailSender{config: &config.Config{OptOutSigningKey: "secret"}}
+	if got, want := e.optOutLink("https://cleanapp.io/opt-out", singleSendEmailTag), "https://cleanapp.io/opt-out?email={{email}}"; got != want {
+		t.Errorf("optOutLink() = %s, want %s", got, want)
+	}
+}
+
+func TestSignedOptOutLinks(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{OptOutURL: "https://cleanapp.io/opt-out", OptOutSigningKey: "secret"}, captureSends(t, &sent))
+
+	if err := e.SendEmailsWithAnalysis([]string{"brand@example.com"}, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendEmailsWithAnalysis returned error: %v", err)
+	}
+	token := e.optOutToken("brand@example.com")
+	link := "https://cleanapp.io/opt-out?email=brand%40example.com&token=" + token
+	for _, content := range sent[0].Content {
+		want := link
+		if content.Type == "text/html" {
+			want = strings.ReplaceAll(link, "&", "&amp;")
+		}
+		if !strings.Contains(content.Value, want) {
+			t.Errorf("expected the signed link in the %s body", content.Type)
+		}
+	}
+	if got := sent[0].Personalizations[0].Headers["List-Unsubscribe"]; !strings.HasSuffix(got, "&token="+token+">") {
+		t.Errorf("expected a signed List-Unsubscribe header, got %q", got)
+	}
+}
+
+func TestSendBatchSubstitutesOptOutTokens(t *testing.T) {
+	var sent []capturedMail
+	e := newTestSender(t, &config.Config{OptOutURL: "https://cleanapp.io/opt-out", OptOutSigningKey: "secret"}, captureSends(t, &sent))
+
+	recipients := []string{"a@example.com", "b@example.com"}
+	if err := e.SendBatch(context.Background(), recipients, nil, nil, goldenAnalysis()); err != nil {
+		t.Fatalf("SendBatch returned error: %v", err)
+	}
+	for i, p := range sent[0].Personalizations {
+		if p.Substitutions[optOutTokenTag] != e.optOutToken(recipients[i]) {
+			t.Errorf("personalization %d substitutes token %q, want the signature of %s", i, p.Substitutions[optOutTokenTag], recipients[i])
+		}
+	}
+	if !strings.Contains(sent[0].Content[0].Value, "?email="+recipientQueryTag+"&token="+optOutTokenTag) {
+		t.Error("expected the shared body to link with both substitution tags")
+	}
+}
@@ -0,0 +1,56 @@
+package email
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"email-service/config"
+	"email-service/models"
+)
+
+// subjectVariant picks the subject A/B variant for recipient and renders it in the
+// language of locale, returning
+// the subject and the variant's SendGrid category (e.g. "subject-variant-b"). With
+// fewer than two variants configured there is no experiment: the standard BuildSubject
+// subject is returned with an empty category.
+func (e *EmailSender) subjectVariant(recipient, locale string, analysis *models.ReportAnalysis) (subject, category string) {
+	variants := e.config.SubjectVariants
+	if len(variants) < 2 {
+		return e.buildSubject(analysis, locale), ""
+	}
+
+	var index int
+	if e.config.SubjectVariantStrategy == config.SubjectVariantRandom {
+		index = rand.IntN(len(variants))
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(strings.ToLower(recipient)))
+		index = int(h.Sum32() % uint32(len(variants)))
+	}
+
+	brandDisplay := analysis.BrandDisplayName
+	if brandDisplay == "" {
+		brandDisplay = analysis.BrandName
+	}
+	subject = strings.NewReplacer(
+		"{subject}", e.buildSubjectText(analysis, locale),
+		"{brand}", brandDisplay,
+		"{count}", strconv.Itoa(analysis.BrandReportCount),
+		"{title}", truncateWords(strings.TrimSpace(analysis.Title), e.subjectTitleLen(), "..."),
+	).Replace(variants[index])
+	if emoji := e.subjectEmoji(analysis); emoji != "" {
+		subject = emoji + " " + subject
+	}
+	return subject, variantCategory(index)
+}
+
+// variantCategory names variant index 0, 1, ... as "subject-variant-a", "-b", ...
+func variantCategory(index int) string {
+	if index < 26 {
+		return fmt.Sprintf("subject-variant-%c", 'a'+index)
+	}
+	return fmt.Sprintf("subject-variant-%d", index+1)
+}
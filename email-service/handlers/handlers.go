@@ -82,6 +82,14 @@ func (h *EmailServiceHandler) HandleOptOutLink(c *gin.Context) {
 		return
 	}
 
+	// Reject links whose email was changed from the one they were signed for
+	if !h.emailService.VerifyOptOutToken(email, c.Query("token")) {
+		c.HTML(http.StatusForbidden, "optout_error.html", gin.H{
+			"error": "This opt-out link is invalid",
+		})
+		return
+	}
+
 	// Add email to opted out table
 	err := h.emailService.AddOptedOutEmail(email)
 	if err != nil {
@@ -98,6 +106,40 @@ func (h *EmailServiceHandler) HandleOptOutLink(c *gin.Context) {
 	})
 }
 
+// HandleOneClickOptOut handles the RFC 8058 one-click POST to /opt-out with email
+// parameter, sent by mailbox providers from the List-Unsubscribe header
+func (h *EmailServiceHandler) HandleOneClickOptOut(c *gin.Context) {
+	email := c.Query("email")
+
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Email parameter is required",
+		})
+		return
+	}
+
+	if !h.emailService.VerifyOptOutToken(email, c.Query("token")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Invalid opt-out token",
+		})
+		return
+	}
+
+	// Add email to opted out table
+	err := h.emailService.AddOptedOutEmail(email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to opt out email: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, OptOutResponse{
+		Success: true,
+		Message: fmt.Sprintf("Email %s has been opted out successfully", email),
+	})
+}
+
 // HandleHealth handles GET requests to /health
 func (h *EmailServiceHandler) HandleHealth(c *gin.Context) {
 	response := gin.H{
@@ -12,8 +12,10 @@ import (
 	"email-service/config"
 	"email-service/handlers"
 	"email-service/service"
+	"email-service/webhook"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -42,12 +44,29 @@ func main() {
 		apiV3.POST("/optout", handler.HandleOptOut)
 	}
 
+	// SendGrid event webhook, suppressing addresses that bounce or report spam
+	if cfg.SendGridWebhookVerificationKey != "" {
+		events, err := webhook.NewHandler(cfg.SendGridWebhookVerificationKey, emailService.HandleDeliveryEvents)
+		if err != nil {
+			log.Fatal("Failed to create event webhook handler:", err)
+		}
+		apiV3.POST("/webhooks/sendgrid", gin.WrapH(events))
+	}
+
 	// Opt-out link route (for email links)
 	router.GET("/opt-out", handler.HandleOptOutLink)
 
+	// One-click opt-out (RFC 8058), posted by mailbox providers from the List-Unsubscribe header
+	router.POST("/opt-out", handler.HandleOneClickOptOut)
+
 	// Health check
 	router.GET("/health", handler.HandleHealth)
 
+	// Prometheus metrics
+	if cfg.MetricsEnabled {
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         ":" + cfg.HTTPPort,
@@ -16,30 +16,71 @@ type Report struct {
 
 // ReportAnalysis represents analysis data for a report
 type ReportAnalysis struct {
-	Seq                   int64   `json:"seq"`
-	Source                string  `json:"source"`
-	Title                 string  `json:"title"`
-	Description           string  `json:"description"`
-	BrandName             string  `json:"brand_name"`
-	BrandDisplayName      string  `json:"brand_display_name"`
-	LitterProbability     float64 `json:"litter_probability"`
-	HazardProbability     float64 `json:"hazard_probability"`
-	SeverityLevel         float64 `json:"severity_level"`
-	Summary               string  `json:"summary"`
-	InferredContactEmails string  `json:"inferred_contact_emails"`
-	Classification        string  `json:"classification"`
-	LegalRiskEstimate     string  `json:"legal_risk_estimate"`
-	BrandReportCount      int     `json:"brand_report_count"` // Total reports for this brand
+	Seq                   int64      `json:"seq"`
+	Source                string     `json:"source"`
+	Title                 string     `json:"title"`
+	Description           string     `json:"description"`
+	BrandName             string     `json:"brand_name"`
+	BrandDisplayName      string     `json:"brand_display_name"`
+	BrandID               string     `json:"brand_id,omitempty"` // Key into the configured brand registry, empty for the global sender
+	LitterProbability     float64    `json:"litter_probability"`
+	HazardProbability     float64    `json:"hazard_probability"`
+	SeverityLevel         float64    `json:"severity_level"`
+	Summary               string     `json:"summary"`
+	InferredContactEmails string     `json:"inferred_contact_emails"`
+	Classification        string     `json:"classification"`
+	LegalRiskEstimate     string     `json:"legal_risk_estimate"`
+	BrandReportCount      int        `json:"brand_report_count"`   // Total reports for this brand
+	Latitude              float64    `json:"latitude,omitempty"`   // Report location, zero when unknown
+	Longitude             float64    `json:"longitude,omitempty"`  // Report location, zero when unknown
+	Confidence            float64    `json:"confidence,omitempty"` // AI confidence 0-1, zero when not reported
+	RiskRange             *RiskRange `json:"risk_range,omitempty"` // Estimated exposure for digital reports, nil when not estimated
+	Critical              bool       `json:"critical,omitempty"`   // Safety alert eligible for the configured suppression bypass
+
+	// Reference and submission time of the report, shown in the email body when set
+	ReportID   string    `json:"report_id,omitempty"`
+	ReportedAt time.Time `json:"reported_at,omitzero"`
+
+	// Distinct issues found in one report, each rendered as its own card; empty for single-issue reports
+	SubAnalyses []SubAnalysis `json:"sub_analyses,omitempty"`
+
+	// Registered locations of location-based recipients, keyed by email address
+	RecipientLocations map[string]Location `json:"recipient_locations,omitempty"`
+
+	// IANA time zones of recipients, e.g. "Europe/Berlin", keyed by email address; UTC when absent
+	RecipientTimezones map[string]string `json:"recipient_timezones,omitempty"`
+}
+
+// SubAnalysis is the analysis of one of several distinct issues in a report
+type SubAnalysis struct {
+	Title             string  `json:"title"`
+	Description       string  `json:"description"`
+	LitterProbability float64 `json:"litter_probability"`
+	HazardProbability float64 `json:"hazard_probability"`
+	SeverityLevel     float64 `json:"severity_level"`
+}
+
+// Location is a point in WGS84 coordinates; the zero value means unknown
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// RiskRange is an estimated min-max monetary exposure for a report
+type RiskRange struct {
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Currency string  `json:"currency,omitempty"` // ISO 4217 code, USD when empty
 }
 
 // BrandReportSummary represents aggregated report data for a brand
 type BrandReportSummary struct {
 	BrandName             string  `json:"brand_name"`
 	BrandDisplayName      string  `json:"brand_display_name"`
-	NewReportCount        int     `json:"new_report_count"`         // New reports since last notification
-	TotalReportCount      int     `json:"total_report_count"`       // Total reports for this brand
-	Classification        string  `json:"classification"`           // digital or physical
-	InferredContactEmails string  `json:"inferred_contact_emails"`  // Comma-separated emails
-	ReportSeqs            []int64 `json:"report_seqs"`              // Seqs of reports to mark as processed
-	LatestReportSeq       int64   `json:"latest_report_seq"`        // Most recent report seq
+	NewReportCount        int     `json:"new_report_count"`        // New reports since last notification
+	TotalReportCount      int     `json:"total_report_count"`      // Total reports for this brand
+	Classification        string  `json:"classification"`          // digital or physical
+	InferredContactEmails string  `json:"inferred_contact_emails"` // Comma-separated emails
+	ReportSeqs            []int64 `json:"report_seqs"`             // Seqs of reports to mark as processed
+	LatestReportSeq       int64   `json:"latest_report_seq"`       // Most recent report seq
 }
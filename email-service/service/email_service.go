@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -13,6 +14,7 @@ import (
 	"email-service/config"
 	"email-service/email"
 	"email-service/models"
+	"email-service/webhook"
 
 	"github.com/apex/log"
 	_ "github.com/go-sql-driver/mysql"
@@ -172,9 +174,22 @@ func NewEmailService(cfg *config.Config) (*EmailService, error) {
 		return nil, fmt.Errorf("failed to verify/create tables: %w", err)
 	}
 
+	// Resolve the SendGrid API keys, the parent's and the subusers', from their configured sources
+	if err := cfg.ResolveSendGridAPIKey(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Create email sender
 	emailSender := email.NewEmailSender(cfg)
 
+	// Drop per-domain From identities SendGrid would reject; sending continues with the rest
+	if err := emailSender.VerifyDomainFroms(); err != nil {
+		log.Warnf("Domain From check: %v", err)
+	}
+
 	return &EmailService{
 		db:     db,
 		config: cfg,
@@ -182,8 +197,11 @@ func NewEmailService(cfg *config.Config) (*EmailService, error) {
 	}, nil
 }
 
-// Close closes the database connection
+// Close flushes any coalesced emails and closes the database connection
 func (s *EmailService) Close() error {
+	if err := s.email.Close(); err != nil {
+		log.Errorf("Failed to flush coalesced emails: %v", err)
+	}
 	return s.db.Close()
 }
 
@@ -721,28 +739,35 @@ func (s *EmailService) sendEmailsToInferredContacts(ctx context.Context, report
 		log.Infof("Report %d is digital, skipping map generation", report.Seq)
 	}
 
-	// Send emails with analysis data and map image
-	err := s.email.SendEmailsWithAnalysis(validEmails, report.Image, mapImg, analysis)
-	if err != nil {
-		return err
-	}
-
-	// Record that emails were sent to these recipients (for both general history and brand throttling)
-	for _, emailAddr := range validEmails {
-		// Record general email history
-		if recordErr := s.recordEmailSent(ctx, emailAddr); recordErr != nil {
-			log.Warnf("Failed to record email sent to %s: %v", emailAddr, recordErr)
-			// Continue - don't fail the whole operation for history tracking
-		}
+	// Send emails with analysis data and map image, coalesced per brand when
+	// EMAIL_COALESCE_ENABLED is set
+	analysis.Latitude, analysis.Longitude = report.Latitude, report.Longitude
+	analysis.ReportID, analysis.ReportedAt = report.ID, report.Timestamp
+	recordCtx := context.WithoutCancel(ctx)
+	err := s.email.QueueEmailWithAnalysisContext(ctx, validEmails, report.Image, mapImg, analysis, email.WithResultFunc(func(result *email.SendResult) {
+		// Record that emails were sent to the recipients that got one (for both general history
+		// and brand throttling) once they go out, which for a coalesced report is at its flush;
+		// the sender skips some, e.g. suppressed or frequency-capped ones
+		for _, emailAddr := range sentAddresses(validEmails, result) {
+			// Record general email history
+			if recordErr := s.recordEmailSent(recordCtx, emailAddr); recordErr != nil {
+				log.Warnf("Failed to record email sent to %s: %v", emailAddr, recordErr)
+				// Continue - don't fail the whole operation for history tracking
+			}
 
-		// Record brand-specific throttle (this is critical for preventing spam)
-		if recordErr := s.recordBrandEmailSent(ctx, brandName, emailAddr); recordErr != nil {
-			log.Warnf("Failed to record brand email sent for %s to %s: %v", brandName, emailAddr, recordErr)
-			// Continue - don't fail the whole operation
+			// Record brand-specific throttle (this is critical for preventing spam)
+			if recordErr := s.recordBrandEmailSent(recordCtx, brandName, emailAddr); recordErr != nil {
+				log.Warnf("Failed to record brand email sent for %s to %s: %v", brandName, emailAddr, recordErr)
+				// Continue - don't fail the whole operation
+			}
 		}
+	}))
+	if errors.Is(err, email.ErrBelowSeverityThreshold) {
+		// Nothing was sent, so there's no history to record or brand to throttle
+		log.Infof("Not emailing report %d: %v", report.Seq, err)
+		return nil
 	}
-
-	return nil
+	return err
 }
 
 // sendEmailsForArea sends emails for a specific area
@@ -788,21 +813,40 @@ func (s *EmailService) sendEmailsForArea(ctx context.Context, report models.Repo
 		log.Infof("Report %d is digital, skipping polygon image generation", report.Seq)
 	}
 
-	// Send emails with analysis data
-	err := s.email.SendEmailsWithAnalysis(validEmails, report.Image, polyImg, analysis)
-	if err != nil {
-		return err
+	// Send emails with analysis data, coalesced per brand when EMAIL_COALESCE_ENABLED is set
+	analysis.Latitude, analysis.Longitude = report.Latitude, report.Longitude
+	analysis.ReportID, analysis.ReportedAt = report.ID, report.Timestamp
+	recordCtx := context.WithoutCancel(ctx)
+	err := s.email.QueueEmailWithAnalysisContext(ctx, validEmails, report.Image, polyImg, analysis, email.WithResultFunc(func(result *email.SendResult) {
+		// Record that emails were sent to the recipients that got one, once they go out
+		for _, emailAddr := range sentAddresses(validEmails, result) {
+			if recordErr := s.recordEmailSent(recordCtx, emailAddr); recordErr != nil {
+				log.Warnf("Failed to record email sent to %s: %v", emailAddr, recordErr)
+				// Continue - don't fail the whole operation for history tracking
+			}
+		}
+	}))
+	if errors.Is(err, email.ErrBelowSeverityThreshold) {
+		log.Infof("Not emailing report %d: %v", report.Seq, err)
+		return nil
 	}
+	return err
+}
 
-	// Record that emails were sent to these recipients
-	for _, emailAddr := range validEmails {
-		if recordErr := s.recordEmailSent(ctx, emailAddr); recordErr != nil {
-			log.Warnf("Failed to record email sent to %s: %v", emailAddr, recordErr)
-			// Continue - don't fail the whole operation for history tracking
+// sentAddresses returns the addresses in emails, as given, that result reports as sent.
+// The sender reports normalized addresses, so they are matched case-insensitively.
+func sentAddresses(emails []string, result *email.SendResult) []string {
+	sent := make(map[string]bool, len(result.Succeeded))
+	for _, addr := range result.Succeeded {
+		sent[addr] = true
+	}
+	var addresses []string
+	for _, addr := range emails {
+		if sent[strings.ToLower(strings.TrimSpace(addr))] {
+			addresses = append(addresses, addr)
 		}
 	}
-
-	return nil
+	return addresses
 }
 
 // getReportAnalysis gets the analysis data for a specific report
@@ -1108,6 +1152,34 @@ func (s *EmailService) AddOptedOutEmail(email string) error {
 		return fmt.Errorf("failed to add email %s to opted out list: %w", email, err)
 	}
 
+	s.email.Suppressions().Add(email, "unsubscribe")
 	log.Infof("Email %s has been opted out successfully", email)
 	return nil
 }
+
+// HandleDeliveryEvents adds the addresses of permanent bounces, spam reports and
+// unsubscribes from SendGrid's event webhook to the opted-out list and the sender's
+// suppressions, so they aren't emailed again
+func (s *EmailService) HandleDeliveryEvents(ctx context.Context, events []webhook.DeliveryEvent) error {
+	for _, event := range events {
+		if !event.Suppresses() || event.Email == "" {
+			continue
+		}
+		result, err := s.db.ExecContext(ctx, `
+			INSERT IGNORE INTO opted_out_emails (email) VALUES (?)
+		`, event.Email)
+		if err != nil {
+			return fmt.Errorf("failed to suppress email %s after %s: %w", event.Email, event.Event, err)
+		}
+		if added, _ := result.RowsAffected(); added > 0 {
+			log.Infof("Email %s suppressed after a %s event (reason: %s)", event.Email, event.Event, event.Reason)
+		}
+		s.email.Suppressions().Add(event.Email, event.Event)
+	}
+	return nil
+}
+
+// VerifyOptOutToken reports whether token is the signature of email from an opt-out link
+func (s *EmailService) VerifyOptOutToken(email, token string) bool {
+	return s.email.VerifyOptOutToken(email, token)
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
+
+	"email-service/email"
 )
 
 func TestIsValidEmail(t *testing.T) {
@@ -97,6 +100,19 @@ func TestProcessInferredEmails(t *testing.T) {
 	}
 }
 
+func TestSentAddresses(t *testing.T) {
+	emails := []string{"Brand@Example.com", "capped@example.com", "ops@example.com"}
+	result := &email.SendResult{Succeeded: []string{"ops@example.com", "brand@example.com"}}
+
+	got := sentAddresses(emails, result)
+	if want := []string{"Brand@Example.com", "ops@example.com"}; !slices.Equal(got, want) {
+		t.Errorf("sentAddresses() = %v, want %v", got, want)
+	}
+	if got := sentAddresses(emails, &email.SendResult{}); len(got) != 0 {
+		t.Errorf("sentAddresses() = %v, want none without a sent recipient", got)
+	}
+}
+
 // Helper function for testing (copy of the service method)
 func isValidEmail(email string) bool {
 	// Updated regex to prevent consecutive dots and ensure proper email format
@@ -0,0 +1,193 @@
+// Package webhook receives SendGrid's signed event webhook, which reports what happened
+// to sent messages, e.g. bounces and spam reports, so dead or complaining addresses can
+// be suppressed.
+package webhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+)
+
+// Event types SendGrid posts; see its event webhook reference for the full list
+const (
+	EventProcessed        = "processed"
+	EventDelivered        = "delivered"
+	EventDeferred         = "deferred"
+	EventDropped          = "dropped"
+	EventBounce           = "bounce"
+	EventSpamReport       = "spamreport"
+	EventUnsubscribe      = "unsubscribe"
+	EventGroupUnsubscribe = "group_unsubscribe"
+	EventOpen             = "open"
+	EventClick            = "click"
+)
+
+// maxTimestampSkew is how far a signed timestamp may be from now before the payload is
+// refused as stale, so a captured post can't be replayed later
+const maxTimestampSkew = 5 * time.Minute
+
+// maxPayloadBytes bounds a webhook request body; SendGrid batches events into posts well
+// below this
+const maxPayloadBytes = 5 << 20
+
+// ErrInvalidSignature is returned for a payload whose signature doesn't verify against
+// the configured verification key
+var ErrInvalidSignature = errors.New("invalid event webhook signature")
+
+// DeliveryEvent is one SendGrid event about a sent message
+type DeliveryEvent struct {
+	Email       string
+	Event       string    // e.g. EventBounce
+	SGMessageID string    // SendGrid message ID, the X-Message-Id of the send followed by a filter suffix
+	Timestamp   time.Time // When the event happened
+
+	Reason     string // Bounce or drop reason
+	BounceType string // "bounce" for a permanent failure or "blocked" for a rejection by the receiving server
+
+	// Custom args the analysis email carried, for correlating the event with its report
+	ReportID       string
+	Classification string
+}
+
+// Suppresses reports whether the event means the address shouldn't be emailed again: a
+// permanent bounce, a spam report, or an unsubscribe through SendGrid's own link or
+// suppression group
+func (e DeliveryEvent) Suppresses() bool {
+	switch e.Event {
+	case EventBounce:
+		return e.BounceType != "blocked"
+	case EventSpamReport, EventUnsubscribe, EventGroupUnsubscribe:
+		return true
+	}
+	return false
+}
+
+// rawEvent is an event as SendGrid posts it
+type rawEvent struct {
+	Email          string `json:"email"`
+	Event          string `json:"event"`
+	SGMessageID    string `json:"sg_message_id"`
+	Timestamp      int64  `json:"timestamp"`
+	Reason         string `json:"reason"`
+	Type           string `json:"type"`
+	ReportID       string `json:"report_id"`
+	Classification string `json:"classification"`
+}
+
+// Verifier checks and parses event webhook payloads signed with one verification key
+type Verifier struct {
+	key *ecdsa.PublicKey
+	now func() time.Time // Clock the signed timestamp is checked against
+}
+
+// NewVerifier returns a Verifier for the base64 verification key shown in SendGrid's
+// signed event webhook settings
+func NewVerifier(verificationKey string) (*Verifier, error) {
+	if verificationKey == "" {
+		return nil, errors.New("event webhook verification key is not set")
+	}
+	key, err := eventwebhook.ConvertPublicKeyBase64ToECDSA(verificationKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event webhook verification key: %w", err)
+	}
+	return &Verifier{key: key, now: time.Now}, nil
+}
+
+// Parse verifies payload against its signature and timestamp headers and returns its
+// events in order. A timestamp more than a few minutes from now is refused like a bad
+// signature, as a replay of an old post.
+func (v *Verifier) Parse(payload []byte, signature, timestamp string) ([]DeliveryEvent, error) {
+	if signature == "" || timestamp == "" {
+		return nil, ErrInvalidSignature
+	}
+	if ok, err := eventwebhook.VerifySignature(v.key, payload, signature, timestamp); err != nil || !ok {
+		return nil, ErrInvalidSignature
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if skew := v.now().Sub(time.Unix(signedAt, 0)).Abs(); skew > maxTimestampSkew {
+		return nil, fmt.Errorf("%w: timestamp is %s from now", ErrInvalidSignature, skew.Round(time.Second))
+	}
+
+	var raw []rawEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("invalid event webhook payload: %w", err)
+	}
+	events := make([]DeliveryEvent, len(raw))
+	for i, r := range raw {
+		events[i] = DeliveryEvent{
+			Email:          strings.ToLower(strings.TrimSpace(r.Email)),
+			Event:          r.Event,
+			SGMessageID:    r.SGMessageID,
+			Timestamp:      time.Unix(r.Timestamp, 0).UTC(),
+			Reason:         r.Reason,
+			BounceType:     r.Type,
+			ReportID:       r.ReportID,
+			Classification: r.Classification,
+		}
+	}
+	return events, nil
+}
+
+// Handler serves SendGrid's event webhook, passing each verified batch of events to a
+// callback, e.g. one that adds bounced and spam-reporting addresses to a suppression store
+type Handler struct {
+	verifier *Verifier
+	onEvents func(ctx context.Context, events []DeliveryEvent) error
+}
+
+// NewHandler returns a Handler verifying payloads with verificationKey and passing their
+// events to onEvents
+func NewHandler(verificationKey string, onEvents func(ctx context.Context, events []DeliveryEvent) error) (*Handler, error) {
+	verifier, err := NewVerifier(verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{verifier: verifier, onEvents: onEvents}, nil
+}
+
+// ServeHTTP answers 403 to a payload that fails verification, 400 to one that isn't an
+// event array, and 500 when the callback fails so SendGrid posts the batch again
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadBytes))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.verifier.Parse(payload, r.Header.Get(eventwebhook.VerificationHTTPHeader), r.Header.Get(eventwebhook.TimestampHTTPHeader))
+	switch {
+	case errors.Is(err, ErrInvalidSignature):
+		log.Warnf("Rejected SendGrid event webhook from %s: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.onEvents(r.Context(), events); err != nil {
+		log.Errorf("Failed to handle %d SendGrid events: %v", len(events), err)
+		http.Error(w, "failed to handle events", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+)
+
+const samplePayload = `[
+  {"email":"Dead@Example.com","timestamp":1741181400,"event":"bounce","sg_message_id":"14c5d75ce93.dfd.64b469.filter0001.16648.5515E0B88.0","reason":"550 5.1.1 The email account that you tried to reach does not exist","type":"bounce","report_id":"rpt-42","classification":"physical"},
+  {"email":"angry@example.com","timestamp":1741181460,"event":"spamreport","sg_message_id":"14c5d75ce93.dfd.64b469.filter0001.16648.5515E0B88.1"},
+  {"email":"busy@example.com","timestamp":1741181520,"event":"bounce","type":"blocked","reason":"421 try again later"},
+  {"email":"ok@example.com","timestamp":1741181580,"event":"delivered"},
+  {"email":"leaving@example.com","timestamp":1741181590,"event":"unsubscribe"},
+  {"email":"quiet@example.com","timestamp":1741181595,"event":"group_unsubscribe","asm_group_id":1}
+]`
+
+// sampleNow is when the sample payload is posted, the timestamp the tests sign with
+var sampleNow = time.Unix(1741181600, 0)
+
+// fixedClock returns a clock stopped at t
+func fixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// newSigningKey returns a private key and the base64 verification key SendGrid shows for it
+func newSigningKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+// sign returns the signature header SendGrid sends for payload posted at timestamp
+func sign(t *testing.T, key *ecdsa.PrivateKey, payload, timestamp string) string {
+	t.Helper()
+	digest := sha256.Sum256([]byte(timestamp + payload))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign payload: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestParseBounceAndSpamReport(t *testing.T) {
+	key, verificationKey := newSigningKey(t)
+	v, err := NewVerifier(verificationKey)
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+	v.now = fixedClock(sampleNow)
+
+	const timestamp = "1741181600"
+	events, err := v.Parse([]byte(samplePayload), sign(t, key, samplePayload, timestamp), timestamp)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(events) != 6 {
+		t.Fatalf("expected 6 events, got %d", len(events))
+	}
+
+	bounce := events[0]
+	if bounce.Email != "dead@example.com" || bounce.Event != EventBounce || bounce.BounceType != "bounce" {
+		t.Errorf("unexpected bounce event %+v", bounce)
+	}
+	if bounce.SGMessageID != "14c5d75ce93.dfd.64b469.filter0001.16648.5515E0B88.0" || !bounce.Timestamp.Equal(time.Date(2025, time.March, 5, 13, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected bounce message ID or time %+v", bounce)
+	}
+	if bounce.ReportID != "rpt-42" || bounce.Classification != "physical" {
+		t.Errorf("expected the report custom args on the bounce, got %+v", bounce)
+	}
+	if events[1].Event != EventSpamReport || events[1].Email != "angry@example.com" {
+		t.Errorf("unexpected spam report event %+v", events[1])
+	}
+
+	for i, want := range []bool{true, true, false, false, true, true} {
+		if got := events[i].Suppresses(); got != want {
+			t.Errorf("event %d (%s %s): Suppresses() = %v, want %v", i, events[i].Event, events[i].BounceType, got, want)
+		}
+	}
+}
+
+func TestParseRejectsBadSignature(t *testing.T) {
+	key, verificationKey := newSigningKey(t)
+	other, _ := newSigningKey(t)
+	v, err := NewVerifier(verificationKey)
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+	v.now = fixedClock(sampleNow)
+
+	const timestamp = "1741181600"
+	tampered := strings.Replace(samplePayload, "Dead@Example.com", "ceo@example.com", 1)
+	tests := map[string]struct {
+		payload, signature, timestamp string
+	}{
+		"other key":         {samplePayload, sign(t, other, samplePayload, timestamp), timestamp},
+		"tampered payload":  {tampered, sign(t, key, samplePayload, timestamp), timestamp},
+		"changed timestamp": {samplePayload, sign(t, key, samplePayload, timestamp), "1741181601"},
+		"malformed":         {samplePayload, "not base64!", timestamp},
+		"missing":           {samplePayload, "", ""},
+		"stale":             {samplePayload, sign(t, key, samplePayload, "1741181200"), "1741181200"},
+		"future":            {samplePayload, sign(t, key, samplePayload, "1741182000"), "1741182000"},
+		"not a number":      {samplePayload, sign(t, key, samplePayload, "soon"), "soon"},
+	}
+	for name, tt := range tests {
+		if _, err := v.Parse([]byte(tt.payload), tt.signature, tt.timestamp); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestParseAcceptsTimestampWithinSkew(t *testing.T) {
+	key, verificationKey := newSigningKey(t)
+	v, err := NewVerifier(verificationKey)
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+	v.now = fixedClock(sampleNow.Add(4 * time.Minute))
+
+	if _, err := v.Parse([]byte(samplePayload), sign(t, key, samplePayload, "1741181600"), "1741181600"); err != nil {
+		t.Errorf("expected a post signed 4 minutes ago to verify, got %v", err)
+	}
+}
+
+func TestNewVerifierRejectsInvalidKey(t *testing.T) {
+	for _, key := range []string{"", "bm90IGEga2V5"} {
+		if _, err := NewVerifier(key); err == nil {
+			t.Errorf("NewVerifier(%q): expected an error", key)
+		}
+	}
+}
+
+func TestHandlerPassesVerifiedEventsToCallback(t *testing.T) {
+	key, verificationKey := newSigningKey(t)
+	var suppressed []string
+	h, err := NewHandler(verificationKey, func(ctx context.Context, events []DeliveryEvent) error {
+		for _, event := range events {
+			if event.Suppresses() {
+				suppressed = append(suppressed, event.Email)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewHandler returned error: %v", err)
+	}
+	h.verifier.now = fixedClock(sampleNow)
+
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v3/webhooks/sendgrid", strings.NewReader(samplePayload))
+		req.Header.Set(eventwebhook.VerificationHTTPHeader, signature)
+		req.Header.Set(eventwebhook.TimestampHTTPHeader, "1741181600")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(sign(t, key, samplePayload, "1741181599")); code != http.StatusForbidden {
+		t.Errorf("bad signature: status %d, want 403", code)
+	}
+	if len(suppressed) != 0 {
+		t.Fatalf("expected no events from an unverified payload, got %v", suppressed)
+	}
+	if code := post(sign(t, key, samplePayload, "1741181600")); code != http.StatusNoContent {
+		t.Errorf("valid signature: status %d, want 204", code)
+	}
+	if want := []string{"dead@example.com", "angry@example.com", "leaving@example.com", "quiet@example.com"}; strings.Join(suppressed, ",") != strings.Join(want, ",") {
+		t.Errorf("suppressed %v, want %v", suppressed, want)
+	}
+}
+
+func TestHandlerReportsCallbackFailure(t *testing.T) {
+	key, verificationKey := newSigningKey(t)
+	h, err := NewHandler(verificationKey, func(ctx context.Context, events []DeliveryEvent) error {
+		return errors.New("database unavailable")
+	})
+	if err != nil {
+		t.Fatalf("NewHandler returned error: %v", err)
+	}
+	h.verifier.now = fixedClock(sampleNow)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v3/webhooks/sendgrid", strings.NewReader(samplePayload))
+	req.Header.Set(eventwebhook.VerificationHTTPHeader, sign(t, key, samplePayload, "1741181600"))
+	req.Header.Set(eventwebhook.TimestampHTTPHeader, "1741181600")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500 so SendGrid retries", rec.Code)
+	}
+}